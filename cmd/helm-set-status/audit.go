@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// auditEntry is one line of the --audit-log file, recording a single change.
+type auditEntry struct {
+	Time           string `json:"time"`
+	Actor          string `json:"actor,omitempty"`
+	Release        string `json:"release"`
+	Namespace      string `json:"namespace"`
+	Revision       int    `json:"revision,omitempty"`
+	PreviousStatus string `json:"previous_status,omitempty"`
+	NewStatus      string `json:"new_status"`
+	Result         string `json:"result"`
+	Reason         string `json:"reason,omitempty"`
+}
+
+// auditMu serializes appends to --audit-log within the process. Each append
+// is also a single write to a file opened for appending, so that entries
+// from concurrent processes are not interleaved.
+var auditMu sync.Mutex
+
+// auditActor returns who is making the changes: the --actor flag, or else
+// $HELM_SET_STATUS_ACTOR, or else $USER.
+func auditActor(actor string) string {
+	if actor != "" {
+		return actor
+	}
+	if actor := os.Getenv("HELM_SET_STATUS_ACTOR"); actor != "" {
+		return actor
+	}
+	return os.Getenv("USER")
+}
+
+// appendAuditLog appends a JSON line to path for each of results, all
+// stamped with the current time.
+func appendAuditLog(path, actor string, results []changeResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+
+	stamp := time.Now().UTC().Format(time.RFC3339Nano)
+	actor = auditActor(actor)
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, res := range results {
+		revision := res.storedRevision
+		if revision == 0 {
+			revision = res.Revision
+		}
+		if err := enc.Encode(auditEntry{
+			Time:           stamp,
+			Actor:          actor,
+			Release:        res.Release,
+			Namespace:      res.Namespace,
+			Revision:       revision,
+			PreviousStatus: res.PreviousStatus,
+			NewStatus:      res.NewStatus,
+			Result:         res.Result,
+			Reason:         res.Reason,
+		}); err != nil {
+			return err
+		}
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to write --audit-log: %w", err)
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write --audit-log: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write --audit-log: %w", err)
+	}
+	return nil
+}
+
+// recordFailure records the failure of the only change of a run in
+// --audit-log and as an --emit-event line on w, if they are set, and returns
+// err along with any failure to record it.
+func recordFailure(w io.Writer, opts runOptions, res changeResult, err error) error {
+	if opts.auditLog == "" && !opts.emitEvent {
+		return err
+	}
+	res.Result = resultError
+	res.Reason = err.Error()
+	errs := []error{err}
+	if opts.auditLog != "" {
+		errs = append(errs, appendAuditLog(opts.auditLog, opts.actor, []changeResult{res}))
+	}
+	if opts.emitEvent {
+		errs = append(errs, writeEvents(w, []changeResult{res}, opts.dryRun))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/josegonzalez/helm-set-status/pkg/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+// readAuditLog returns the entries in an --audit-log file.
+func readAuditLog(t *testing.T, path string) []auditEntry {
+	t.Helper()
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var entries []auditEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry auditEntry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry), "line %q", scanner.Text())
+		entries = append(entries, entry)
+	}
+	require.NoError(t, scanner.Err())
+	return entries
+}
+
+func TestRunWithConfigFactory_AuditLog(t *testing.T) {
+	newStore := func(t *testing.T) *storage.Storage {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		for name, st := range map[string]release.Status{"app-a": release.StatusPendingUpgrade, "app-b": release.StatusDeployed} {
+			require.NoError(t, store.Create(&release.Release{
+				Name:      name,
+				Namespace: "default",
+				Version:   3,
+				Info:      &release.Info{Status: st},
+				Chart: &chart.Chart{
+					Metadata: &chart.Metadata{
+						Name:    "test-chart",
+						Version: "1.0.0",
+					},
+				},
+			}))
+		}
+		return store
+	}
+	factoryFor := func(store *storage.Storage) configurationFactory {
+		return func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+	}
+
+	t.Run("appends an entry per change", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "audit.jsonl")
+		require.NoError(t, os.WriteFile(path, []byte(`{"time":"2024-01-01T00:00:00Z","release":"earlier","namespace":"default","new_status":"failed","result":"changed"}`+"\n"), 0o600))
+		store := newStore(t)
+
+		cmd := newRootCmd()
+		cmd.SetOut(&bytes.Buffer{})
+		opts := runOptions{auditLog: path, actor: "oncall", pendingOnly: true}
+		require.NoError(t, runWithConfigFactory(cmd, []string{"app-a", "app-b", "missing", "deployed"}, opts, factoryFor(store)))
+
+		entries := readAuditLog(t, path)
+		require.Len(t, entries, 4)
+		assert.Equal(t, "earlier", entries[0].Release)
+		for _, entry := range entries[1:] {
+			_, err := time.Parse(time.RFC3339Nano, entry.Time)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, auditEntry{Actor: "oncall", Release: "app-a", Namespace: "default", Revision: 3, PreviousStatus: "pending-upgrade", NewStatus: "deployed", Result: resultChanged}, withoutTime(entries[1]))
+		assert.Equal(t, resultSkipped, entries[2].Result)
+		assert.Equal(t, "app-b", entries[2].Release)
+		assert.Equal(t, auditEntry{Actor: "oncall", Release: "missing", Namespace: "default", NewStatus: "deployed", Result: resultNotFound, Reason: `release "missing" not found`}, withoutTime(entries[3]))
+	})
+
+	t.Run("records the failure of a single release", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "audit.jsonl")
+		store := newStore(t)
+
+		opts := runOptions{auditLog: path, actor: "oncall", fromStatuses: []string{"failed"}}
+		err := runWithConfigFactory(newRootCmd(), []string{"app-b", "superseded"}, opts, factoryFor(store))
+		require.Error(t, err)
+
+		entries := readAuditLog(t, path)
+		require.Len(t, entries, 1)
+		assert.Equal(t, resultError, entries[0].Result)
+		assert.Equal(t, err.Error(), entries[0].Reason)
+	})
+
+	t.Run("records an --atomic change that is rolled back", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "audit.jsonl")
+		store := newStore(t)
+
+		opts := runOptions{auditLog: path, actor: "oncall", atomic: true}
+		err := runWithConfigFactory(newRootCmd(), []string{"app-a", "missing", "app-b", "deployed"}, opts, factoryFor(store))
+		var atomicErr *status.AtomicError
+		require.ErrorAs(t, err, &atomicErr)
+
+		entries := readAuditLog(t, path)
+		require.Len(t, entries, 3)
+		assert.Equal(t, auditEntry{Actor: "oncall", Release: "app-a", Namespace: "default", Revision: 3, PreviousStatus: "pending-upgrade", NewStatus: "deployed",
+			Result: resultRolledBack, Reason: `rolled back after release "missing" failed (--atomic)`}, withoutTime(entries[0]))
+		assert.Equal(t, auditEntry{Actor: "oncall", Release: "missing", Namespace: "default", NewStatus: "deployed",
+			Result: resultError, Reason: `release "missing" not found`}, withoutTime(entries[1]))
+		assert.Equal(t, auditEntry{Actor: "oncall", Release: "app-b", Namespace: "default", NewStatus: "deployed",
+			Result: resultNotAttempted, Reason: `not attempted after release "missing" failed (--atomic)`}, withoutTime(entries[2]))
+	})
+
+	t.Run("takes the actor from the environment", func(t *testing.T) {
+		t.Setenv("HELM_SET_STATUS_ACTOR", "ci-bot")
+		t.Setenv("USER", "alice")
+		assert.Equal(t, "ci-bot", auditActor(""))
+		assert.Equal(t, "oncall", auditActor("oncall"))
+
+		t.Setenv("HELM_SET_STATUS_ACTOR", "")
+		assert.Equal(t, "alice", auditActor(""))
+	})
+}
+
+// withoutTime returns entry with its time cleared, for comparison.
+func withoutTime(entry auditEntry) auditEntry {
+	entry.Time = ""
+	return entry
+}
+
+func TestAppendAuditLog_Concurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results := []changeResult{
+				{Release: fmt.Sprintf("app-%d", i), Namespace: "default", NewStatus: "failed", Result: resultChanged},
+				{Release: fmt.Sprintf("app-%d", i), Namespace: "staging", NewStatus: "failed", Result: resultChanged},
+			}
+			assert.NoError(t, appendAuditLog(path, "oncall", results))
+		}()
+	}
+	wg.Wait()
+
+	entries := readAuditLog(t, path)
+	require.Len(t, entries, 40)
+	// Each append is written as a whole, so a run's entries stay together
+	for i := 0; i < len(entries); i += 2 {
+		assert.Equal(t, entries[i].Release, entries[i+1].Release)
+	}
+}
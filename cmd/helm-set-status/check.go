@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/josegonzalez/helm-set-status/pkg/status"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/discovery"
+)
+
+// DiscoveryFactory creates the discovery client the check command uses to
+// reach the cluster. This can be overridden for testing.
+var DiscoveryFactory discoveryFactory = func(namespace string, opts status.ClientOptions) (discovery.ServerVersionInterface, error) {
+	return status.NewRESTClientGetter(namespace, opts).ToDiscoveryClient()
+}
+
+// discoveryFactory creates a discovery client for the given namespace and
+// cluster connection flags.
+type discoveryFactory func(namespace string, opts status.ClientOptions) (discovery.ServerVersionInterface, error)
+
+func newCheckCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Check that the cluster can be reached",
+		Long: `Check that the cluster can be reached with the current kubeconfig and context
+by reading the Kubernetes server version.
+
+Use it as a preflight step before changing release statuses, for example in CI.
+It honors --kube-context, --kubeconfig, and --namespace like the other commands,
+and exits non-zero if the server cannot be reached.`,
+		Args:              cobra.NoArgs,
+		ValidArgsFunction: cobra.NoFileCompletions,
+		RunE:              runCheck,
+	}
+
+	return cmd
+}
+
+func runCheck(cmd *cobra.Command, _ []string) error {
+	var opts runOptions
+	readGlobalFlags(cmd, &opts)
+	return runCheckWithDiscoveryFactory(cmd, opts, DiscoveryFactory)
+}
+
+func runCheckWithDiscoveryFactory(cmd *cobra.Command, opts runOptions, newDiscovery discoveryFactory) error {
+	namespace := status.ResolveNamespace(opts.namespace)
+
+	client, err := newDiscovery(namespace, opts.clientOptions())
+	if err != nil {
+		return fmt.Errorf("failed to create cluster client: %w", err)
+	}
+
+	info, err := client.ServerVersion()
+	if err != nil {
+		return fmt.Errorf("failed to reach the cluster: %w", err)
+	}
+
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Connected to Kubernetes %s (namespace %q)\n", info.GitVersion, namespace)
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/josegonzalez/helm-set-status/pkg/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kubeversion "k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/discovery"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	kubetesting "k8s.io/client-go/testing"
+)
+
+// failingServerVersion fails every ServerVersion call as an unreachable
+// server does.
+type failingServerVersion struct{}
+
+func (failingServerVersion) ServerVersion() (*kubeversion.Info, error) {
+	return nil, errors.New("dial tcp 127.0.0.1:6443: connect: connection refused")
+}
+
+func TestRunCheckWithDiscoveryFactory(t *testing.T) {
+	t.Run("reports the server version", func(t *testing.T) {
+		var gotNamespace string
+		var gotOpts status.ClientOptions
+		factory := func(namespace string, opts status.ClientOptions) (discovery.ServerVersionInterface, error) {
+			gotNamespace, gotOpts = namespace, opts
+			return &fakediscovery.FakeDiscovery{
+				Fake:               &kubetesting.Fake{},
+				FakedServerVersion: &kubeversion.Info{GitVersion: "v1.30.2"},
+			}, nil
+		}
+
+		cmd := newCheckCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		opts := runOptions{namespace: "production", kubeContext: "staging-cluster", kubeConfig: "/tmp/staging.yaml"}
+		require.NoError(t, runCheckWithDiscoveryFactory(cmd, opts, factory))
+		assert.Equal(t, "Connected to Kubernetes v1.30.2 (namespace \"production\")\n", buf.String())
+		assert.Equal(t, "production", gotNamespace)
+		assert.Equal(t, "staging-cluster", gotOpts.KubeContext)
+		assert.Equal(t, "/tmp/staging.yaml", gotOpts.KubeConfig)
+	})
+
+	t.Run("fails when the server cannot be reached", func(t *testing.T) {
+		factory := func(string, status.ClientOptions) (discovery.ServerVersionInterface, error) {
+			return failingServerVersion{}, nil
+		}
+
+		err := runCheckWithDiscoveryFactory(newCheckCmd(), runOptions{}, factory)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to reach the cluster: dial tcp")
+	})
+
+	t.Run("fails when the client cannot be created", func(t *testing.T) {
+		factory := func(string, status.ClientOptions) (discovery.ServerVersionInterface, error) {
+			return nil, errors.New("invalid configuration: no configuration has been provided")
+		}
+
+		err := runCheckWithDiscoveryFactory(newCheckCmd(), runOptions{}, factory)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to create cluster client")
+	})
+}
+
+func TestRun_Check(t *testing.T) {
+	originalFactory := DiscoveryFactory
+	defer func() { DiscoveryFactory = originalFactory }()
+	DiscoveryFactory = func(string, status.ClientOptions) (discovery.ServerVersionInterface, error) {
+		return &fakediscovery.FakeDiscovery{Fake: &kubetesting.Fake{}}, nil
+	}
+	t.Setenv("HELM_NAMESPACE", "")
+
+	cmd := newRootCmd()
+	var buf bytes.Buffer
+	cmd.SetOut(&buf)
+	cmd.SetArgs([]string{"check"})
+
+	require.NoError(t, cmd.Execute())
+	assert.Contains(t, buf.String(), "(namespace \"default\")")
+}
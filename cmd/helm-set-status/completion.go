@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/josegonzalez/helm-set-status/pkg/status"
+	"github.com/spf13/cobra"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// completeStatuses returns the valid statuses that start with toComplete.
+func completeStatuses(toComplete string) []string {
+	var matches []string
+	for _, s := range status.ValidStatuses {
+		if strings.HasPrefix(s, toComplete) {
+			matches = append(matches, s)
+		}
+	}
+	return matches
+}
+
+// completeReleaseNames returns the names of stored releases that start with
+// toComplete, each described by its current status. Completion is best
+// effort, so any failure to build the configuration or list releases yields
+// no names.
+func completeReleaseNames(cmd *cobra.Command, toComplete string) []string {
+	return describeReleases(listCompletionReleases(cmd, toComplete))
+}
+
+// completePendingReleaseNames is like completeReleaseNames, but suggests only
+// the releases in a pending status, falling back to every release when none
+// are pending.
+func completePendingReleaseNames(cmd *cobra.Command, toComplete string) []string {
+	rels := listCompletionReleases(cmd, toComplete)
+	var pending []*release.Release
+	for _, rel := range rels {
+		if slices.Contains(status.PendingStatuses, rel.Info.Status) {
+			pending = append(pending, rel)
+		}
+	}
+	if len(pending) == 0 {
+		return describeReleases(rels)
+	}
+	return describeReleases(pending)
+}
+
+// listCompletionReleases returns the stored releases whose names start with
+// toComplete, or nil if they cannot be listed.
+func listCompletionReleases(cmd *cobra.Command, toComplete string) []*release.Release {
+	var opts runOptions
+	readGlobalFlags(cmd, &opts)
+
+	cfg, err := ConfigurationFactory(opts.namespace, opts.clientOptions())
+	if err != nil {
+		return nil
+	}
+	rels, err := status.ListReleases(cfg, "")
+	if err != nil {
+		return nil
+	}
+
+	var matches []*release.Release
+	for _, rel := range rels {
+		if strings.HasPrefix(rel.Name, toComplete) {
+			matches = append(matches, rel)
+		}
+	}
+	return matches
+}
+
+// describeReleases returns a completion for each release, with its current
+// status as the description shells show beside the name.
+func describeReleases(rels []*release.Release) []string {
+	var completions []string
+	for _, rel := range rels {
+		completions = append(completions, cobra.CompletionWithDesc(rel.Name, rel.Info.Status.String()))
+	}
+	return completions
+}
+
+// completeRootArgs completes the RELEASE [RELEASE...] STATUS arguments of the
+// root command. The first argument is always a release unless --selector is
+// set; after that either another release or the status may follow.
+func completeRootArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if sel, _ := cmd.Flags().GetString("selector"); sel != "" {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return completeStatuses(toComplete), cobra.ShellCompDirectiveNoFileComp
+	}
+	if len(args) == 0 {
+		return completeReleaseNames(cmd, toComplete), cobra.ShellCompDirectiveNoFileComp
+	}
+	return append(completeStatuses(toComplete), completeReleaseNames(cmd, toComplete)...), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeReleaseArg completes a single RELEASE argument.
+func completeReleaseArg(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return completeReleaseNames(cmd, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completePendingReleaseArg completes the RELEASE argument of a command that
+// remediates a pending release, such as unstick.
+func completePendingReleaseArg(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return completePendingReleaseNames(cmd, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeReleaseStatusArgs completes RELEASE STATUS arguments.
+func completeReleaseStatusArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	switch len(args) {
+	case 0:
+		return completeReleaseNames(cmd, toComplete), cobra.ShellCompDirectiveNoFileComp
+	case 1:
+		return completeStatuses(toComplete), cobra.ShellCompDirectiveNoFileComp
+	default:
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completeStatusFlag completes the values of a status flag such as --from.
+func completeStatusFlag(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return completeStatuses(toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+func newCompletionCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "completion SHELL",
+		Short: "Print a shell completion script",
+		Long: `Print a script that completes release names, statuses, and flags in
+bash, zsh, fish, or powershell.
+
+Run as helm-set-status, the script completes the helm-set-status binary, or
+whatever name it was invoked by. Run as helm set-status, it prints Helm's own
+completion script instead: Helm completes the plugin's arguments through the
+plugin.complete script, so completing helm is what completes helm set-status.
+
+Source the output in the shell, for example:
+
+  source <(helm-set-status completion bash)
+  helm-set-status completion zsh > "${fpath[1]}/_helm-set-status"
+  helm-set-status completion fish | source
+  helm-set-status completion powershell | Out-String | Invoke-Expression`,
+		ValidArgs: completionShells,
+		Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		RunE:      runCompletion,
+	}
+
+	cmd.Flags().Bool("no-descriptions", false, "leave out completion descriptions, such as the current status of each release")
+
+	return cmd
+}
+
+// completionShells are the shells the completion command prints scripts for.
+var completionShells = []string{"bash", "zsh", "fish", "powershell"}
+
+// helmCompletion writes the completion script of the Helm binary helmBin for
+// shell to w. It is a variable so tests can avoid running Helm.
+var helmCompletion = func(ctx context.Context, helmBin, shell string, noDescriptions bool, w io.Writer) error {
+	args := []string{"completion", shell}
+	if noDescriptions {
+		args = append(args, "--no-descriptions")
+	}
+	helm := exec.CommandContext(ctx, helmBin, args...)
+	helm.Stdout = w
+	helm.Stderr = os.Stderr
+	if err := helm.Run(); err != nil {
+		return fmt.Errorf("failed to run %s completion: %w", helmBin, err)
+	}
+	return nil
+}
+
+func runCompletion(cmd *cobra.Command, args []string) error {
+	shell := args[0]
+	noDescriptions, _ := cmd.Flags().GetBool("no-descriptions")
+
+	// Helm sets these for the plugins it runs. The shell then completes helm,
+	// not this binary, so only Helm's script completes helm set-status.
+	if helmBin := os.Getenv("HELM_BIN"); helmBin != "" && os.Getenv("HELM_PLUGIN_NAME") != "" {
+		return helmCompletion(commandContext(cmd), helmBin, shell, noDescriptions, cmd.OutOrStdout())
+	}
+
+	// The generated script registers completions for the root command's name,
+	// so use the name the binary was run by, in case it was renamed.
+	root := cmd.Root()
+	defer func(use string) { root.Use = use }(root.Use)
+	root.Use = strings.Replace(root.Use, root.Name(), completionCommandName(root), 1)
+
+	w := cmd.OutOrStdout()
+	switch shell {
+	case "bash":
+		return root.GenBashCompletionV2(w, !noDescriptions)
+	case "zsh":
+		if noDescriptions {
+			return root.GenZshCompletionNoDesc(w)
+		}
+		return root.GenZshCompletion(w)
+	case "fish":
+		return root.GenFishCompletion(w, !noDescriptions)
+	default:
+		if noDescriptions {
+			return root.GenPowerShellCompletion(w)
+		}
+		return root.GenPowerShellCompletionWithDesc(w)
+	}
+}
+
+// completionCommandName returns the name the binary was run by, without a
+// .exe suffix, or the name of root if it cannot be told.
+func completionCommandName(root *cobra.Command) string {
+	if len(os.Args) == 0 {
+		return root.Name()
+	}
+	name := strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+	if name == "" || name == "." || name == string(filepath.Separator) {
+		return root.Name()
+	}
+	return name
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/josegonzalez/helm-set-status/pkg/status"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+func TestCompleteStatuses(t *testing.T) {
+	assert.Equal(t, status.ValidStatuses, completeStatuses(""))
+	assert.Equal(t, []string{"pending-install", "pending-upgrade", "pending-rollback"}, completeStatuses("pending-"))
+	assert.Empty(t, completeStatuses("bogus"))
+}
+
+func TestCompletion(t *testing.T) {
+	store := storage.Init(driver.NewMemory())
+	for _, name := range []string{"frontend", "backend"} {
+		rel := &release.Release{
+			Name:      name,
+			Namespace: "default",
+			Version:   1,
+			Info: &release.Info{
+				Status: release.StatusDeployed,
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}
+		require.NoError(t, store.Create(rel))
+	}
+
+	originalFactory := ConfigurationFactory
+	defer func() { ConfigurationFactory = originalFactory }()
+	ConfigurationFactory = func(string, status.ClientOptions) (*action.Configuration, error) {
+		return &action.Configuration{Releases: store}, nil
+	}
+
+	t.Run("first argument completes release names", func(t *testing.T) {
+		names, directive := completeRootArgs(newRootCmd(), nil, "")
+		assert.Equal(t, []string{"backend\tdeployed", "frontend\tdeployed"}, names)
+		assert.Equal(t, cobra.ShellCompDirectiveNoFileComp, directive)
+	})
+
+	t.Run("later arguments complete statuses and release names", func(t *testing.T) {
+		names, _ := completeRootArgs(newRootCmd(), []string{"frontend"}, "")
+		assert.Equal(t, append(append([]string{}, status.ValidStatuses...), "backend\tdeployed", "frontend\tdeployed"), names)
+
+		names, _ = completeRootArgs(newRootCmd(), []string{"frontend"}, "f")
+		assert.Equal(t, []string{"failed", "frontend\tdeployed"}, names)
+	})
+
+	t.Run("selector completes only the status", func(t *testing.T) {
+		cmd := newRootCmd()
+		require.NoError(t, cmd.Flags().Set("selector", "app=web"))
+
+		names, _ := completeRootArgs(cmd, nil, "")
+		assert.Equal(t, status.ValidStatuses, names)
+
+		names, _ = completeRootArgs(cmd, []string{"deployed"}, "")
+		assert.Empty(t, names)
+	})
+
+	t.Run("watch completes release then status", func(t *testing.T) {
+		names, _ := completeReleaseStatusArgs(newWatchCmd(), nil, "b")
+		assert.Equal(t, []string{"backend\tdeployed"}, names)
+
+		names, _ = completeReleaseStatusArgs(newWatchCmd(), []string{"backend"}, "")
+		assert.Equal(t, status.ValidStatuses, names)
+	})
+
+	t.Run("from flag completes statuses", func(t *testing.T) {
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetArgs([]string{cobra.ShellCompRequestCmd, "frontend", "deployed", "--from", "pending-"})
+
+		require.NoError(t, cmd.Execute())
+		assert.Equal(t, "pending-install\npending-upgrade\npending-rollback\n:4\n", buf.String())
+	})
+
+	t.Run("completion request shows the status as a description", func(t *testing.T) {
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetArgs([]string{cobra.ShellCompRequestCmd, "get", "f"})
+
+		require.NoError(t, cmd.Execute())
+		assert.Equal(t, "frontend\tdeployed\n:4\n", buf.String())
+	})
+
+	t.Run("unstick prefers pending releases", func(t *testing.T) {
+		names, _ := completePendingReleaseArg(newUnstickCmd(), nil, "")
+		assert.Equal(t, []string{"backend\tdeployed", "frontend\tdeployed"}, names)
+
+		rel, err := store.Last("backend")
+		require.NoError(t, err)
+		rel.Info.Status = release.StatusPendingUpgrade
+		defer func() { rel.Info.Status = release.StatusDeployed }()
+
+		names, _ = completePendingReleaseArg(newUnstickCmd(), nil, "")
+		assert.Equal(t, []string{"backend\tpending-upgrade"}, names)
+
+		names, _ = completePendingReleaseArg(newUnstickCmd(), []string{"backend"}, "")
+		assert.Empty(t, names)
+	})
+
+	t.Run("configuration errors yield no release names", func(t *testing.T) {
+		ConfigurationFactory = func(string, status.ClientOptions) (*action.Configuration, error) {
+			return nil, errors.New("no cluster")
+		}
+
+		names, _ := completeReleaseArg(newGetCmd(), nil, "")
+		assert.Empty(t, names)
+	})
+}
+
+func TestCompletionCmd(t *testing.T) {
+	t.Setenv("HELM_PLUGIN_NAME", "")
+	run := func(t *testing.T, args ...string) (string, error) {
+		t.Helper()
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetErr(io.Discard)
+		cmd.SetArgs(append([]string{"completion"}, args...))
+		err := cmd.Execute()
+		return buf.String(), err
+	}
+
+	for _, shell := range completionShells {
+		t.Run("prints a script for "+shell, func(t *testing.T) {
+			out, err := run(t, shell)
+			require.NoError(t, err)
+			assert.NotEmpty(t, out)
+			assert.Contains(t, out, "helm-set-status")
+
+			out, err = run(t, shell, "--no-descriptions")
+			require.NoError(t, err)
+			assert.NotEmpty(t, out)
+		})
+	}
+
+	t.Run("names the script after the binary", func(t *testing.T) {
+		args := os.Args
+		t.Cleanup(func() { os.Args = args })
+		os.Args = []string{"/usr/local/bin/set-status.exe"}
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetArgs([]string{"completion", "bash"})
+		require.NoError(t, cmd.Execute())
+		assert.Contains(t, buf.String(), "complete -o default -F __start_set-status set-status")
+		assert.True(t, strings.HasPrefix(cmd.Use, "helm-set-status "), "root Use is restored")
+	})
+
+	t.Run("prints Helm's script when run as a plugin", func(t *testing.T) {
+		t.Setenv("HELM_PLUGIN_NAME", "set-status")
+		t.Setenv("HELM_BIN", "/usr/bin/helm")
+		orig := helmCompletion
+		t.Cleanup(func() { helmCompletion = orig })
+		var gotBin, gotShell string
+		var gotNoDesc bool
+		helmCompletion = func(_ context.Context, helmBin, shell string, noDescriptions bool, w io.Writer) error {
+			gotBin, gotShell, gotNoDesc = helmBin, shell, noDescriptions
+			_, err := io.WriteString(w, "# helm completion\n")
+			return err
+		}
+
+		out, err := run(t, "zsh", "--no-descriptions")
+		require.NoError(t, err)
+		assert.Equal(t, "# helm completion\n", out)
+		assert.Equal(t, "/usr/bin/helm", gotBin)
+		assert.Equal(t, "zsh", gotShell)
+		assert.True(t, gotNoDesc)
+	})
+
+	t.Run("rejects other shells", func(t *testing.T) {
+		_, err := run(t, "tcsh")
+		assert.ErrorContains(t, err, `invalid argument "tcsh"`)
+
+		_, err = run(t)
+		assert.ErrorContains(t, err, "accepts 1 arg(s)")
+	})
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/josegonzalez/helm-set-status/pkg/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ClientsetFactory creates the Kubernetes clientset --from-configmap reads
+// the ConfigMap with. This can be overridden for testing.
+var ClientsetFactory clientsetFactory = func(namespace string, opts status.ClientOptions) (kubernetes.Interface, error) {
+	config, err := status.NewRESTClientGetter(namespace, opts).ToRESTConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(config)
+}
+
+// clientsetFactory creates a Kubernetes clientset for the given namespace and
+// cluster connection flags.
+type clientsetFactory func(namespace string, opts status.ClientOptions) (kubernetes.Interface, error)
+
+// readReleaseStatusConfigMap reads the release and status pairs stored in the
+// ConfigMap named by ref, given as NAMESPACE/NAME or as NAME in --namespace.
+// Each data key is a release name and its value the status to set on it.
+// The pairs are returned in key order.
+func readReleaseStatusConfigMap(ctx context.Context, ref string, opts runOptions, newClientset clientsetFactory) ([]releaseStatus, error) {
+	namespace, name, ok := strings.Cut(ref, "/")
+	if !ok {
+		namespace, name = status.ResolveNamespace(opts.namespace), ref
+	}
+	if namespace == "" || name == "" || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("--from-configmap: expected NAME or NAMESPACE/NAME, got %q", ref)
+	}
+
+	client, err := newClientset(namespace, opts.clientOptions())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cluster client: %w", err)
+	}
+	cm, err := client.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read --from-configmap %s/%s: %w", namespace, name, err)
+	}
+
+	keys := make([]string, 0, len(cm.Data))
+	for key := range cm.Data {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	entries := make([]releaseStatus, 0, len(keys))
+	for _, key := range keys {
+		parsed, err := status.ParseStatus(cm.Data[key])
+		if err != nil {
+			return nil, fmt.Errorf("invalid --from-configmap %s/%s: key %q: %w", namespace, name, key, err)
+		}
+		entries = append(entries, releaseStatus{name: key, status: parsed})
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("--from-configmap %s/%s lists no releases", namespace, name)
+	}
+	return entries, nil
+}
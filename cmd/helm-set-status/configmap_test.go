@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/josegonzalez/helm-set-status/pkg/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestReadReleaseStatusConfigMap(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "desired", Namespace: "ops"},
+			Data:       map[string]string{"app-b": "deployed", "app-a": "Failed"},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "invalid", Namespace: "ops"},
+			Data:       map[string]string{"app-a": "bogus"},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "empty", Namespace: "ops"},
+		},
+	)
+	var gotNamespace string
+	factory := func(namespace string, _ status.ClientOptions) (kubernetes.Interface, error) {
+		gotNamespace = namespace
+		return clientset, nil
+	}
+
+	t.Run("reads the pairs in key order", func(t *testing.T) {
+		entries, err := readReleaseStatusConfigMap(t.Context(), "ops/desired", runOptions{}, factory)
+		require.NoError(t, err)
+		assert.Equal(t, []releaseStatus{
+			{name: "app-a", status: release.StatusFailed},
+			{name: "app-b", status: release.StatusDeployed},
+		}, entries)
+		assert.Equal(t, "ops", gotNamespace)
+	})
+
+	t.Run("reads a bare name from --namespace", func(t *testing.T) {
+		entries, err := readReleaseStatusConfigMap(t.Context(), "desired", runOptions{namespace: "ops"}, factory)
+		require.NoError(t, err)
+		assert.Len(t, entries, 2)
+	})
+
+	tests := []struct {
+		name    string
+		ref     string
+		wantErr string
+	}{
+		{name: "invalid reference", ref: "ops/desired/extra", wantErr: `--from-configmap: expected NAME or NAMESPACE/NAME, got "ops/desired/extra"`},
+		{name: "missing configmap", ref: "ops/missing", wantErr: `failed to read --from-configmap ops/missing: configmaps "missing" not found`},
+		{name: "invalid status", ref: "ops/invalid", wantErr: `invalid --from-configmap ops/invalid: key "app-a": invalid status: bogus`},
+		{name: "no releases", ref: "ops/empty", wantErr: "--from-configmap ops/empty lists no releases"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readReleaseStatusConfigMap(t.Context(), tt.ref, runOptions{}, factory)
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestRunWithConfigFactory_FromConfigMap(t *testing.T) {
+	originalFactory := ClientsetFactory
+	defer func() { ClientsetFactory = originalFactory }()
+	ClientsetFactory = func(string, status.ClientOptions) (kubernetes.Interface, error) {
+		return fake.NewSimpleClientset(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "desired", Namespace: "ops"},
+			Data:       map[string]string{"app-a": "failed", "app-b": "superseded"},
+		}), nil
+	}
+
+	store := storage.Init(driver.NewMemory())
+	for _, name := range []string{"app-a", "app-b"} {
+		require.NoError(t, store.Create(&release.Release{
+			Name:      name,
+			Namespace: "default",
+			Version:   1,
+			Info:      &release.Info{Status: release.StatusDeployed},
+			Chart:     &chart.Chart{Metadata: &chart.Metadata{Name: "test-chart", Version: "1.0.0"}},
+		}))
+	}
+	newConfig := func(string, status.ClientOptions) (*action.Configuration, error) {
+		return &action.Configuration{Releases: store}, nil
+	}
+
+	t.Run("applies the statuses", func(t *testing.T) {
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		require.NoError(t, runWithConfigFactory(cmd, nil, runOptions{fromConfigMap: "ops/desired"}, newConfig))
+		assert.Contains(t, buf.String(), "Summary: 2 changed, 0 unchanged, 0 skipped, 0 not found, 0 errored (2 total)\n")
+		for name, want := range map[string]release.Status{"app-a": release.StatusFailed, "app-b": release.StatusSuperseded} {
+			rel, err := store.Get(name, 1)
+			require.NoError(t, err)
+			assert.Equal(t, want, rel.Info.Status)
+		}
+	})
+
+	t.Run("rejects --atomic", func(t *testing.T) {
+		err := runWithConfigFactory(newRootCmd(), nil, runOptions{fromConfigMap: "ops/desired", atomic: true}, newConfig)
+		assert.EqualError(t, err, "--from-configmap cannot be combined with --atomic")
+	})
+
+	t.Run("rejects --from-file", func(t *testing.T) {
+		err := runWithConfigFactory(newRootCmd(), nil, runOptions{fromConfigMap: "ops/desired", fromFile: "statuses.txt"}, newConfig)
+		assert.EqualError(t, err, "--from-file cannot be combined with --from-configmap")
+	})
+
+	t.Run("rejects arguments", func(t *testing.T) {
+		cmd := newRootCmd()
+		require.NoError(t, cmd.Flags().Set("from-configmap", "ops/desired"))
+		assert.EqualError(t, validateRootArgs(cmd, []string{"app-a"}), "--from-configmap cannot be combined with release names or STATUS; got 1 args")
+	})
+}
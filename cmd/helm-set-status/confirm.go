@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/josegonzalez/helm-set-status/pkg/status"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// errNotConfirmed is returned when the user declines the --interactive prompt.
+var errNotConfirmed = errors.New("status change not confirmed, nothing was changed")
+
+// stdinIsTerminal reports whether r is an interactive terminal.
+// This can be overridden for testing.
+var stdinIsTerminal = func(r io.Reader) bool {
+	f, ok := r.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// isDestructive reports whether moving a release from one status to another
+// takes it out of service: a deployed release set to anything but deployed
+// no longer counts as the live revision for Helm upgrades and rollbacks.
+func isDestructive(from, to release.Status) bool {
+	return from == release.StatusDeployed && to != release.StatusDeployed
+}
+
+// confirmChanges asks the user on out to confirm any destructive change,
+// reading the answer from in. Changes to releases that cannot be read are
+// left for SetStatus to report.
+func confirmChanges(changes []change, in io.Reader, out io.Writer) error {
+	var destructive []string
+	for _, c := range changes {
+		current, err := status.GetStatus(c.cfg, c.target.ReleaseName, c.target.Revision)
+		if err != nil || !isDestructive(current, c.status) {
+			continue
+		}
+		destructive = append(destructive, fmt.Sprintf("%s from %q to %q", c.target, current, c.status))
+	}
+	if len(destructive) == 0 {
+		return nil
+	}
+
+	_, _ = fmt.Fprintln(out, "The following changes take a deployed release out of service:")
+	for _, d := range destructive {
+		_, _ = fmt.Fprintf(out, "  %s\n", d)
+	}
+	_, _ = fmt.Fprint(out, "Are you sure? [y/N] ")
+
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to read confirmation: %w", err)
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	default:
+		return errNotConfirmed
+	}
+}
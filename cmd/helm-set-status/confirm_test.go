@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/josegonzalez/helm-set-status/pkg/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+func TestIsDestructive(t *testing.T) {
+	assert.True(t, isDestructive(release.StatusDeployed, release.StatusFailed))
+	assert.True(t, isDestructive(release.StatusDeployed, release.StatusSuperseded))
+	assert.False(t, isDestructive(release.StatusDeployed, release.StatusDeployed))
+	assert.False(t, isDestructive(release.StatusPendingUpgrade, release.StatusFailed))
+	assert.False(t, isDestructive(release.StatusFailed, release.StatusDeployed))
+}
+
+func TestRunWithConfigFactory_Interactive(t *testing.T) {
+	newStore := func(t *testing.T) *storage.Storage {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		require.NoError(t, store.Create(&release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   1,
+			Info: &release.Info{
+				Status: release.StatusDeployed,
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}))
+		return store
+	}
+	run := func(t *testing.T, store *storage.Storage, input string, terminal bool, opts runOptions) (string, error) {
+		t.Helper()
+		orig := stdinIsTerminal
+		stdinIsTerminal = func(io.Reader) bool { return terminal }
+		defer func() { stdinIsTerminal = orig }()
+
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+		cmd := newRootCmd()
+		var stdout, stderr bytes.Buffer
+		cmd.SetIn(strings.NewReader(input))
+		cmd.SetOut(&stdout)
+		cmd.SetErr(&stderr)
+		err := runWithConfigFactory(cmd, []string{"test-release", "failed"}, opts, configFactory)
+		return stderr.String(), err
+	}
+	current := func(t *testing.T, store *storage.Storage) release.Status {
+		t.Helper()
+		rel, err := store.Last("test-release")
+		require.NoError(t, err)
+		return rel.Info.Status
+	}
+
+	t.Run("applies the change when confirmed", func(t *testing.T) {
+		store := newStore(t)
+		prompt, err := run(t, store, "y\n", true, runOptions{interactive: true})
+		require.NoError(t, err)
+		assert.Contains(t, prompt, `test-release from "deployed" to "failed"`)
+		assert.Contains(t, prompt, "Are you sure? [y/N]")
+		assert.Equal(t, release.StatusFailed, current(t, store))
+	})
+
+	t.Run("leaves the release alone when rejected", func(t *testing.T) {
+		for _, input := range []string{"n\n", "\n", ""} {
+			store := newStore(t)
+			_, err := run(t, store, input, true, runOptions{interactive: true})
+			require.ErrorIs(t, err, errNotConfirmed, "input %q", input)
+			assert.Equal(t, release.StatusDeployed, current(t, store))
+		}
+	})
+
+	t.Run("does not prompt without a terminal", func(t *testing.T) {
+		store := newStore(t)
+		prompt, err := run(t, store, "", false, runOptions{interactive: true})
+		require.NoError(t, err)
+		assert.Empty(t, prompt)
+		assert.Equal(t, release.StatusFailed, current(t, store))
+	})
+
+	t.Run("does not prompt with --yes", func(t *testing.T) {
+		store := newStore(t)
+		prompt, err := run(t, store, "", true, runOptions{interactive: true, yes: true})
+		require.NoError(t, err)
+		assert.Empty(t, prompt)
+		assert.Equal(t, release.StatusFailed, current(t, store))
+	})
+}
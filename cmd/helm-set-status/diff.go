@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/josegonzalez/helm-set-status/pkg/status"
+	"github.com/spf13/cobra"
+)
+
+func newDiffCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff RELEASE STATUS",
+		Short: "Show what setting a Helm release's status would change",
+		Long: `Show the fields that setting a Helm release to STATUS would change, without
+writing anything.
+
+The status, description, and last-deployed time are printed before (-) and
+after (+) the change; fields that would not change are prefixed with a space.
+Use --revision, --description, --append-description, and --keep-last-deployed
+as you would when setting the status. Preconditions such as --from are not
+checked.`,
+		Args:              cobra.ExactArgs(2),
+		ValidArgsFunction: completeReleaseStatusArgs,
+		RunE:              runDiff,
+	}
+
+	cmd.Flags().String("revision", "latest", "revision to compare: a number, \"latest\", or an offset back from the latest such as -1")
+	cmd.Flags().String("description", "", "description that would be recorded (default: \"status set to <STATUS>\")")
+	cmd.Flags().Bool("append-description", false, "show the new description added below the existing one")
+	cmd.Flags().Bool("keep-last-deployed", false, "show the last-deployed timestamp left unchanged")
+
+	return cmd
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	var opts runOptions
+	var err error
+	revisionFlag, _ := cmd.Flags().GetString("revision")
+	if opts.revision, err = parseRevision(revisionFlag); err != nil {
+		return err
+	}
+	opts.description, _ = cmd.Flags().GetString("description")
+	opts.appendDesc, _ = cmd.Flags().GetBool("append-description")
+	opts.keepLastDeployed, _ = cmd.Flags().GetBool("keep-last-deployed")
+	readGlobalFlags(cmd, &opts)
+	return runDiffWithConfigFactory(cmd, args, opts, ConfigurationFactory)
+}
+
+func runDiffWithConfigFactory(cmd *cobra.Command, args []string, opts runOptions, newConfig configurationFactory) error {
+	releaseName := args[0]
+
+	targetStatus, err := status.ParseStatus(args[1])
+	if err != nil {
+		return fmt.Errorf("%w\nValid statuses: %s", err, status.ValidStatusesString())
+	}
+
+	cfg, err := newConfig(opts.namespace, opts.clientOptions())
+	if err != nil {
+		return fmt.Errorf("failed to create configuration: %w", err)
+	}
+
+	diff, err := status.DiffStatus(cfg, releaseName, targetStatus, status.SetStatusOptions{
+		Revision:          opts.revision,
+		Description:       opts.description,
+		AppendDescription: opts.appendDesc,
+		KeepLastDeployed:  opts.keepLastDeployed,
+	})
+	if err != nil {
+		return err
+	}
+
+	return writeDiff(cmd.OutOrStdout(), diff)
+}
+
+// writeDiff renders diff to w with one line per field, marking removed
+// values with - and added values with +.
+func writeDiff(w io.Writer, diff status.Diff) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s revision %d\n", diff.ReleaseName, diff.Revision)
+	fmt.Fprintf(&b, "+++ %s revision %d\n", diff.ReleaseName, diff.Revision)
+	writeDiffField(&b, "status", diff.Before.Status.String(), diff.After.Status.String())
+	writeDiffField(&b, "description", diff.Before.Description, diff.After.Description)
+	writeDiffField(&b, "last-deployed", formatDiffTime(diff.Before.LastDeployed), formatDiffTime(diff.After.LastDeployed))
+	if !diff.Changed() {
+		b.WriteString("No changes\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeDiffField writes a field that is unchanged once, or as a - line and a
+// + line if it changes. Multi-line values are continued on indented lines.
+func writeDiffField(b *strings.Builder, name, before, after string) {
+	write := func(sign, value string) {
+		lines := strings.Split(value, "\n")
+		fmt.Fprintf(b, "%s%s: %s\n", sign, name, lines[0])
+		for _, line := range lines[1:] {
+			fmt.Fprintf(b, "%s  %s\n", sign, line)
+		}
+	}
+	if before == after {
+		write(" ", before)
+		return
+	}
+	write("-", before)
+	write("+", after)
+}
+
+// formatDiffTime formats t for a diff, or returns "" for the zero time.
+func formatDiffTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
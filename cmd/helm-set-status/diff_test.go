@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/josegonzalez/helm-set-status/pkg/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	helmtime "helm.sh/helm/v3/pkg/time"
+)
+
+func TestNewDiffCmd(t *testing.T) {
+	cmd := newDiffCmd()
+
+	assert.Equal(t, "diff RELEASE STATUS", cmd.Use)
+
+	revFlag := cmd.Flags().Lookup("revision")
+	assert.NotNil(t, revFlag)
+	assert.Equal(t, "latest", revFlag.DefValue)
+}
+
+func TestRunDiffWithConfigFactory(t *testing.T) {
+	deployed := helmtime.Time{Time: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+	store := storage.Init(driver.NewMemory())
+	for _, r := range []struct {
+		version int
+		status  release.Status
+	}{
+		{1, release.StatusSuperseded},
+		{2, release.StatusDeployed},
+	} {
+		require.NoError(t, store.Create(&release.Release{
+			Name:      "my-release",
+			Namespace: "default",
+			Version:   r.version,
+			Info: &release.Info{
+				Status:       r.status,
+				Description:  "Upgrade complete",
+				LastDeployed: deployed,
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}))
+	}
+	configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+		return &action.Configuration{Releases: store}, nil
+	}
+
+	t.Run("prints before and after without writing", func(t *testing.T) {
+		cmd := newDiffCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		err := runDiffWithConfigFactory(cmd, []string{"my-release", "failed"}, runOptions{}, configFactory)
+		require.NoError(t, err)
+
+		lines := strings.Split(buf.String(), "\n")
+		assert.Equal(t, "--- my-release revision 2", lines[0])
+		assert.Equal(t, "+++ my-release revision 2", lines[1])
+		assert.Equal(t, "-status: deployed", lines[2])
+		assert.Equal(t, "+status: failed", lines[3])
+		assert.Equal(t, "-description: Upgrade complete", lines[4])
+		assert.Equal(t, "+description: status set to failed", lines[5])
+		assert.Equal(t, "-last-deployed: 2024-01-02T03:04:05Z", lines[6])
+		assert.True(t, strings.HasPrefix(lines[7], "+last-deployed: "))
+
+		rel, err := store.Last("my-release")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusDeployed, rel.Info.Status)
+		assert.Equal(t, "Upgrade complete", rel.Info.Description)
+	})
+
+	t.Run("compares a specific revision", func(t *testing.T) {
+		cmd := newDiffCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		err := runDiffWithConfigFactory(cmd, []string{"my-release", "failed"}, runOptions{revision: 1, description: "cleanup", keepLastDeployed: true}, configFactory)
+		require.NoError(t, err)
+		assert.Equal(t, `--- my-release revision 1
++++ my-release revision 1
+-status: superseded
++status: failed
+-description: Upgrade complete
++description: cleanup
+ last-deployed: 2024-01-02T03:04:05Z
+`, buf.String())
+	})
+
+	t.Run("reports no changes at the target status", func(t *testing.T) {
+		cmd := newDiffCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		err := runDiffWithConfigFactory(cmd, []string{"my-release", "deployed"}, runOptions{}, configFactory)
+		require.NoError(t, err)
+		assert.Contains(t, buf.String(), " status: deployed\n")
+		assert.Contains(t, buf.String(), "No changes\n")
+	})
+
+	t.Run("indents multi-line descriptions", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, writeDiff(&buf, status.Diff{
+			ReleaseName: "my-release",
+			Revision:    1,
+			Before:      status.ReleaseState{Status: release.StatusDeployed, Description: "one"},
+			After:       status.ReleaseState{Status: release.StatusFailed, Description: "one\ntwo"},
+		}))
+		assert.Contains(t, buf.String(), "+description: one\n+  two\n")
+	})
+
+	t.Run("invalid status", func(t *testing.T) {
+		err := runDiffWithConfigFactory(newDiffCmd(), []string{"my-release", "bogus"}, runOptions{}, configFactory)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid status: bogus")
+	})
+
+	t.Run("release not found", func(t *testing.T) {
+		err := runDiffWithConfigFactory(newDiffCmd(), []string{"missing", "failed"}, runOptions{}, configFactory)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `release "missing" not found`)
+	})
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/josegonzalez/helm-set-status/pkg/status"
+	"github.com/spf13/cobra"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// Values of errorOutput.Type.
+const (
+	errorTypeReleaseNotFound      = "release_not_found"
+	errorTypeRevisionNotFound     = "revision_not_found"
+	errorTypeInvalidReleaseName   = "invalid_release_name"
+	errorTypeMissingInfo          = "missing_info"
+	errorTypePrecondition         = "precondition"
+	errorTypeInvalidTransition    = "invalid_transition"
+	errorTypeMaskedFailure        = "masked_failure"
+	errorTypeChartMismatch        = "chart_mismatch"
+	errorTypeConflict             = "conflict"
+	errorTypeBeforeUpdate         = "before_update"
+	errorTypeAtomic               = "atomic"
+	errorTypeOutcomeUnknown       = "outcome_unknown"
+	errorTypeStorageNotConfigured = "storage_not_configured"
+	errorTypeTimeout              = "timeout"
+	errorTypeCanceled             = "canceled"
+	errorTypeError                = "error"
+)
+
+// errorOutput describes a failed command in JSON and YAML output. Type
+// identifies the kind of error; the other fields are set when they apply to
+// it.
+type errorOutput struct {
+	Error              string       `json:"error"`
+	Type               string       `json:"type"`
+	Release            string       `json:"release,omitempty"`
+	Revision           int          `json:"revision,omitempty"`
+	CurrentStatus      string       `json:"current_status,omitempty"`
+	Description        string       `json:"description,omitempty"`
+	TargetStatus       string       `json:"target_status,omitempty"`
+	AllowedStatuses    []string     `json:"allowed_statuses,omitempty"`
+	DisallowedStatuses []string     `json:"disallowed_statuses,omitempty"`
+	MinAge             string       `json:"min_age,omitempty"`
+	LastDeployed       string       `json:"last_deployed,omitempty"`
+	Attempts           int          `json:"attempts,omitempty"`
+	RolledBack         []string     `json:"rolled_back,omitempty"`
+	Chart              string       `json:"chart,omitempty"`
+	ExpectedChart      string       `json:"expected_chart,omitempty"`
+	ChartVersion       string       `json:"chart_version,omitempty"`
+	ExpectedVersion    string       `json:"expected_chart_version,omitempty"`
+	Cause              *errorOutput `json:"cause,omitempty"`
+}
+
+// newErrorOutput describes err, filling in the fields of the error types
+// returned by the status package.
+func newErrorOutput(err error) errorOutput {
+	out := errorOutput{Error: err.Error(), Type: errorTypeError}
+
+	var atomicErr *status.AtomicError
+	var conflictErr *status.ConflictError
+	var nameErr *status.InvalidReleaseNameError
+	var revisionErr *status.RevisionNotFoundError
+	var notFoundErr *status.ReleaseNotFoundError
+	var infoErr *status.MissingInfoError
+	var precondErr *status.PreconditionError
+	var transitionErr *status.InvalidTransitionError
+	var beforeUpdateErr *status.BeforeUpdateError
+	var maskedErr *status.MaskedFailureError
+	var chartErr *status.ChartMismatchError
+	var unknownErr *status.OutcomeUnknownError
+	switch {
+	case errors.As(err, &atomicErr):
+		out.Type = errorTypeAtomic
+		out.Release = atomicErr.Failed.ReleaseName
+		out.Revision = atomicErr.Failed.Revision
+		for _, t := range atomicErr.RolledBack {
+			out.RolledBack = append(out.RolledBack, t.String())
+		}
+		cause := newErrorOutput(atomicErr.Err)
+		out.Cause = &cause
+	case errors.As(err, &conflictErr):
+		out.Type = errorTypeConflict
+		out.Release = conflictErr.ReleaseName
+		out.Revision = conflictErr.Revision
+		out.Attempts = conflictErr.Attempts
+	case errors.As(err, &nameErr):
+		out.Type = errorTypeInvalidReleaseName
+		out.Release = nameErr.ReleaseName
+	case errors.As(err, &revisionErr):
+		out.Type = errorTypeRevisionNotFound
+		out.Release = revisionErr.ReleaseName
+		out.Revision = revisionErr.Revision
+	case errors.As(err, &notFoundErr):
+		out.Type = errorTypeReleaseNotFound
+		out.Release = notFoundErr.ReleaseName
+	case errors.As(err, &infoErr):
+		out.Type = errorTypeMissingInfo
+		out.Release = infoErr.ReleaseName
+		out.Revision = infoErr.Revision
+	case errors.As(err, &precondErr):
+		out.Type = errorTypePrecondition
+		out.Release = precondErr.ReleaseName
+		out.Revision = precondErr.Revision
+		out.CurrentStatus = precondErr.CurrentStatus.String()
+		out.Description = precondErr.CurrentDescription
+		out.TargetStatus = precondErr.TargetStatus.String()
+		for _, s := range precondErr.AllowedStatuses {
+			out.AllowedStatuses = append(out.AllowedStatuses, s.String())
+		}
+		for _, s := range precondErr.DisallowedStatuses {
+			out.DisallowedStatuses = append(out.DisallowedStatuses, s.String())
+		}
+		if precondErr.MinAge > 0 {
+			out.MinAge = precondErr.MinAge.String()
+			out.LastDeployed = precondErr.LastDeployed.UTC().Format(time.RFC3339)
+		}
+	case errors.As(err, &transitionErr):
+		out.Type = errorTypeInvalidTransition
+		out.Release = transitionErr.ReleaseName
+		out.CurrentStatus = transitionErr.From.String()
+		out.TargetStatus = transitionErr.To.String()
+	case errors.As(err, &maskedErr):
+		out.Type = errorTypeMaskedFailure
+		out.Release = maskedErr.ReleaseName
+		out.Revision = maskedErr.Revision
+		out.CurrentStatus = release.StatusFailed.String()
+		out.Description = maskedErr.CurrentDescription
+		out.TargetStatus = release.StatusDeployed.String()
+	case errors.As(err, &chartErr):
+		out.Type = errorTypeChartMismatch
+		out.Release = chartErr.ReleaseName
+		out.Revision = chartErr.Revision
+		out.Chart = chartErr.Chart
+		out.ExpectedChart = chartErr.ExpectedChart
+		out.ChartVersion = chartErr.Version
+		out.ExpectedVersion = chartErr.ExpectedVersion
+	case errors.As(err, &beforeUpdateErr):
+		out.Type = errorTypeBeforeUpdate
+		out.Release = beforeUpdateErr.ReleaseName
+		out.Revision = beforeUpdateErr.Revision
+	case errors.As(err, &unknownErr):
+		out.Type = errorTypeOutcomeUnknown
+		out.Release = unknownErr.ReleaseName
+	case errors.Is(err, status.ErrStorageNotConfigured):
+		out.Type = errorTypeStorageNotConfigured
+	case errors.Is(err, context.DeadlineExceeded):
+		out.Type = errorTypeTimeout
+	case errors.Is(err, context.Canceled):
+		out.Type = errorTypeCanceled
+	}
+	return out
+}
+
+// reportError writes err to w in format, as an errorOutput for JSON or YAML
+// or as a workflow command for github, and silences cobra's plain-text copy.
+// err is returned so that the command exits non-zero, and returned as is if it
+// only carries an exit code, having been reported already.
+func reportError(cmd *cobra.Command, w io.Writer, format string, err error) error {
+	var exitErr *exitCodeError
+	if err == nil || errors.As(err, &exitErr) {
+		return err
+	}
+	if format == outputGitHub {
+		if _, writeErr := fmt.Fprintf(w, "::error::%s\n", escapeWorkflowData(err.Error())); writeErr != nil {
+			return err
+		}
+		cmd.SilenceErrors = true
+		cmd.SilenceUsage = true
+		return err
+	}
+	if !isStructured(format) {
+		return err
+	}
+
+	if writeErr := writeStructured(w, format, newErrorOutput(err)); writeErr != nil {
+		return err
+	}
+	cmd.SilenceErrors = true
+	cmd.SilenceUsage = true
+	return err
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/josegonzalez/helm-set-status/pkg/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	"sigs.k8s.io/yaml"
+)
+
+func TestNewErrorOutput(t *testing.T) {
+	lastDeployed := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			name: "release not found",
+			err:  &status.ReleaseNotFoundError{ReleaseName: "my-release"},
+			want: `{"error": "release \"my-release\" not found", "type": "release_not_found", "release": "my-release"}`,
+		},
+		{
+			name: "revision not found",
+			err:  &status.RevisionNotFoundError{ReleaseName: "my-release", Revision: 3},
+			want: `{"error": "release \"my-release\" revision 3 not found", "type": "revision_not_found", "release": "my-release", "revision": 3}`,
+		},
+		{
+			name: "invalid release name",
+			err:  &status.InvalidReleaseNameError{ReleaseName: "Bad_Name", Err: errors.New("invalid release name")},
+			want: `{"error": "invalid release name \"Bad_Name\": invalid release name", "type": "invalid_release_name", "release": "Bad_Name"}`,
+		},
+		{
+			name: "missing info",
+			err:  &status.MissingInfoError{ReleaseName: "my-release", Revision: 4},
+			want: `{"error": "release \"my-release\" revision 4 has no info metadata", "type": "missing_info", "release": "my-release", "revision": 4}`,
+		},
+		{
+			name: "masked failure",
+			err:  &status.MaskedFailureError{ReleaseName: "my-release", Revision: 3},
+			want: `{"error": "release \"my-release\" revision 3 is \"failed\"; setting it to \"deployed\" would hide the failure", "type": "masked_failure", "release": "my-release", "revision": 3, "current_status": "failed", "target_status": "deployed"}`,
+		},
+		{
+			name: "chart mismatch",
+			err:  &status.ChartMismatchError{ReleaseName: "my-release", Revision: 3, Chart: "nginx", ExpectedChart: "redis"},
+			want: `{"error": "release \"my-release\" revision 3 has chart \"nginx\", expected \"redis\"", "type": "chart_mismatch", "release": "my-release", "revision": 3, "chart": "nginx", "expected_chart": "redis"}`,
+		},
+		{
+			name: "chart version mismatch",
+			err:  &status.ChartMismatchError{ReleaseName: "my-release", Revision: 3, Chart: "nginx", Version: "1.2.3", ExpectedVersion: ">=2.0.0"},
+			want: `{"error": "release \"my-release\" revision 3 has chart \"nginx\" version \"1.2.3\", which does not match \">=2.0.0\"", "type": "chart_mismatch", "release": "my-release", "revision": 3, "chart": "nginx", "chart_version": "1.2.3", "expected_chart_version": ">=2.0.0"}`,
+		},
+		{
+			name: "before update",
+			err:  &status.BeforeUpdateError{ReleaseName: "my-release", Revision: 4, Err: errors.New("pre-hook failed: exit status 1")},
+			want: `{"error": "update of release \"my-release\" revision 4 aborted: pre-hook failed: exit status 1", "type": "before_update", "release": "my-release", "revision": 4}`,
+		},
+		{
+			name: "precondition with allowed statuses",
+			err: &status.PreconditionError{
+				ReleaseName:     "my-release",
+				Revision:        2,
+				CurrentStatus:   release.StatusDeployed,
+				TargetStatus:    release.StatusFailed,
+				AllowedStatuses: []release.Status{release.StatusPendingInstall, release.StatusPendingUpgrade},
+			},
+			want: `{"error": "current status \"deployed\" not in allowed list [pending-install pending-upgrade]; cannot set to \"failed\"",
+				"type": "precondition", "release": "my-release", "revision": 2, "current_status": "deployed", "target_status": "failed",
+				"allowed_statuses": ["pending-install", "pending-upgrade"]}`,
+		},
+		{
+			name: "precondition with current description",
+			err: &status.PreconditionError{
+				ReleaseName:        "my-release",
+				Revision:           2,
+				CurrentStatus:      release.StatusFailed,
+				CurrentDescription: "Upgrade failed: timed out",
+				TargetStatus:       release.StatusDeployed,
+				AllowedStatuses:    []release.Status{release.StatusPendingUpgrade},
+			},
+			want: `{"error": "current status \"failed\" not in allowed list [pending-upgrade]; cannot set to \"deployed\" (release description: \"Upgrade failed: timed out\")",
+				"type": "precondition", "release": "my-release", "revision": 2, "current_status": "failed", "description": "Upgrade failed: timed out",
+				"target_status": "deployed", "allowed_statuses": ["pending-upgrade"]}`,
+		},
+		{
+			name: "precondition with disallowed statuses",
+			err: &status.PreconditionError{
+				ReleaseName:        "my-release",
+				Revision:           2,
+				CurrentStatus:      release.StatusDeployed,
+				TargetStatus:       release.StatusFailed,
+				DisallowedStatuses: []release.Status{release.StatusDeployed},
+			},
+			want: `{"error": "current status \"deployed\" in disallowed list [deployed]; cannot set to \"failed\"",
+				"type": "precondition", "release": "my-release", "revision": 2, "current_status": "deployed", "target_status": "failed",
+				"disallowed_statuses": ["deployed"]}`,
+		},
+		{
+			name: "precondition with minimum age",
+			err: &status.PreconditionError{
+				ReleaseName:   "my-release",
+				Revision:      2,
+				CurrentStatus: release.StatusDeployed,
+				TargetStatus:  release.StatusFailed,
+				MinAge:        30 * time.Minute,
+				LastDeployed:  lastDeployed,
+			},
+			want: `{"error": "release was last deployed at 2024-01-02T03:04:05Z, less than 30m0s ago; cannot set to \"failed\"",
+				"type": "precondition", "release": "my-release", "revision": 2, "current_status": "deployed", "target_status": "failed",
+				"min_age": "30m0s", "last_deployed": "2024-01-02T03:04:05Z"}`,
+		},
+		{
+			name: "invalid transition",
+			err:  &status.InvalidTransitionError{ReleaseName: "my-release", From: release.StatusSuperseded, To: release.StatusFailed},
+			want: `{"error": "invalid transition from \"superseded\" to \"failed\"", "type": "invalid_transition", "release": "my-release",
+				"current_status": "superseded", "target_status": "failed"}`,
+		},
+		{
+			name: "conflict",
+			err:  &status.ConflictError{ReleaseName: "my-release", Revision: 2, Attempts: 4},
+			want: `{"error": "release \"my-release\" revision 2 was modified by another process while its status was being set (4 attempts)",
+				"type": "conflict", "release": "my-release", "revision": 2, "attempts": 4}`,
+		},
+		{
+			name: "atomic",
+			err: &status.AtomicError{
+				Failed:     status.Target{ReleaseName: "second"},
+				Err:        &status.ReleaseNotFoundError{ReleaseName: "second"},
+				RolledBack: []status.Target{{ReleaseName: "first"}},
+			},
+			want: `{"error": "failed to set status on second: release \"second\" not found; rolled back first", "type": "atomic",
+				"release": "second", "rolled_back": ["first"],
+				"cause": {"error": "release \"second\" not found", "type": "release_not_found", "release": "second"}}`,
+		},
+		{
+			name: "wrapped error",
+			err:  fmt.Errorf("refusing to unstick release %q: %w", "my-release", &status.ReleaseNotFoundError{ReleaseName: "my-release"}),
+			want: `{"error": "refusing to unstick release \"my-release\": release \"my-release\" not found", "type": "release_not_found", "release": "my-release"}`,
+		},
+		{
+			name: "outcome unknown",
+			err:  &status.OutcomeUnknownError{ReleaseName: "my-release", Err: context.DeadlineExceeded},
+			want: `{"error": "stopped setting status of release my-release while it was being written, so it may or may not have changed: context deadline exceeded", "type": "outcome_unknown", "release": "my-release"}`,
+		},
+		{
+			name: "storage not configured",
+			err:  fmt.Errorf("failed to list releases: %w", status.ErrStorageNotConfigured),
+			want: `{"error": "failed to list releases: storage not configured", "type": "storage_not_configured"}`,
+		},
+		{
+			name: "timeout",
+			err:  fmt.Errorf("stopped setting status of release my-release: %w", context.DeadlineExceeded),
+			want: `{"error": "stopped setting status of release my-release: context deadline exceeded", "type": "timeout"}`,
+		},
+		{
+			name: "canceled",
+			err:  fmt.Errorf("stopped setting status of release my-release: %w", context.Canceled),
+			want: `{"error": "stopped setting status of release my-release: context canceled", "type": "canceled"}`,
+		},
+		{
+			name: "other error",
+			err:  errors.New("failed to create configuration: boom"),
+			want: `{"error": "failed to create configuration: boom", "type": "error"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(newErrorOutput(tt.err))
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(data))
+		})
+	}
+}
+
+func TestReportError(t *testing.T) {
+	err := &status.ReleaseNotFoundError{ReleaseName: "my-release"}
+
+	t.Run("writes JSON and silences the text error", func(t *testing.T) {
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+
+		assert.Same(t, err, reportError(cmd, &buf, outputJSON, err))
+		assert.JSONEq(t, `{"error": "release \"my-release\" not found", "type": "release_not_found", "release": "my-release"}`, buf.String())
+		assert.True(t, cmd.SilenceErrors)
+	})
+
+	t.Run("writes YAML", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.Error(t, reportError(newRootCmd(), &buf, outputYAML, err))
+
+		var decoded errorOutput
+		require.NoError(t, yaml.Unmarshal(buf.Bytes(), &decoded))
+		assert.Equal(t, errorTypeReleaseNotFound, decoded.Type)
+	})
+
+	t.Run("writes a GitHub Actions error command", func(t *testing.T) {
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+
+		assert.Same(t, err, reportError(cmd, &buf, outputGitHub, err))
+		assert.Equal(t, "::error::release \"my-release\" not found\n", buf.String())
+		assert.True(t, cmd.SilenceErrors)
+	})
+
+	t.Run("leaves text output to cobra", func(t *testing.T) {
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+
+		assert.Same(t, err, reportError(cmd, &buf, outputText, err))
+		assert.Empty(t, buf.String())
+		assert.False(t, cmd.SilenceErrors)
+	})
+
+	t.Run("leaves exit codes alone", func(t *testing.T) {
+		var buf bytes.Buffer
+		exitErr := &exitCodeError{code: 2}
+
+		assert.Same(t, exitErr, reportError(newRootCmd(), &buf, outputJSON, exitErr))
+		assert.Empty(t, buf.String())
+	})
+}
+
+func TestRun_StructuredError(t *testing.T) {
+	store := storage.Init(driver.NewMemory())
+	require.NoError(t, store.Create(&release.Release{
+		Name:      "my-release",
+		Namespace: "default",
+		Version:   1,
+		Info:      &release.Info{Status: release.StatusDeployed},
+		Chart: &chart.Chart{
+			Metadata: &chart.Metadata{
+				Name:    "test-chart",
+				Version: "1.0.0",
+			},
+		},
+	}))
+
+	originalFactory := ConfigurationFactory
+	defer func() { ConfigurationFactory = originalFactory }()
+	ConfigurationFactory = func(string, status.ClientOptions) (*action.Configuration, error) {
+		return &action.Configuration{Releases: store}, nil
+	}
+
+	cmd := newRootCmd()
+	var stdout, stderr bytes.Buffer
+	cmd.SetOut(&stdout)
+	cmd.SetErr(&stderr)
+	cmd.SetArgs([]string{"my-release", "failed", "--from", "pending-upgrade", "--output", "json"})
+
+	err := cmd.Execute()
+	require.Error(t, err)
+	assert.Equal(t, 1, exitCode(err))
+	assert.Empty(t, stdout.String())
+
+	var out errorOutput
+	require.NoError(t, json.Unmarshal(stderr.Bytes(), &out))
+	assert.Equal(t, errorOutput{
+		Error:           `current status "deployed" not in allowed list [pending-upgrade]; cannot set to "failed"`,
+		Type:            errorTypePrecondition,
+		Release:         "my-release",
+		Revision:        1,
+		CurrentStatus:   "deployed",
+		TargetStatus:    "failed",
+		AllowedStatuses: []string{"pending-upgrade"},
+	}, out)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// eventVersion is the version of the changeEvent schema written by
+// --emit-event. It is increased if a field is removed or changes meaning;
+// new fields may be added without a new version, so consumers should ignore
+// fields they do not know.
+const eventVersion = 1
+
+// changeEvent is the line written to stdout by --emit-event for each change,
+// one JSON object per line (NDJSON). Its fields are:
+//
+//   - version: eventVersion, the schema version
+//   - time: when the run finished, in RFC 3339 format with nanoseconds
+//   - release, namespace: the release that was changed
+//   - revision: the revision changed, or 0 if it was never resolved, such as
+//     for a release that was not found
+//   - previous_status: the status the revision had, if it was read
+//   - status: the status the revision was to be given
+//   - result: changed, would-change, unchanged, skipped, not-found, error, or
+//     not-attempted
+//   - reason: why the change was skipped, not found, failed, or not attempted
+//   - dry_run: true if the change was only reported, not written
+type changeEvent struct {
+	Version        int    `json:"version"`
+	Time           string `json:"time"`
+	Release        string `json:"release"`
+	Namespace      string `json:"namespace"`
+	Revision       int    `json:"revision"`
+	PreviousStatus string `json:"previous_status,omitempty"`
+	Status         string `json:"status"`
+	Result         string `json:"result"`
+	Reason         string `json:"reason,omitempty"`
+	DryRun         bool   `json:"dry_run"`
+}
+
+// writeEvents writes a changeEvent line to w for each of results, all
+// stamped with the current time. The lines are written at once, so that a
+// reader never sees part of a batch.
+func writeEvents(w io.Writer, results []changeResult, dryRun bool) error {
+	stamp := time.Now().UTC().Format(time.RFC3339Nano)
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, res := range results {
+		revision := res.storedRevision
+		if revision == 0 {
+			revision = res.Revision
+		}
+		if err := enc.Encode(changeEvent{
+			Version:        eventVersion,
+			Time:           stamp,
+			Release:        res.Release,
+			Namespace:      res.Namespace,
+			Revision:       revision,
+			PreviousStatus: res.PreviousStatus,
+			Status:         res.NewStatus,
+			Result:         res.Result,
+			Reason:         res.Reason,
+			DryRun:         dryRun,
+		}); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/josegonzalez/helm-set-status/pkg/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+// readEvents returns the events in --emit-event output, requiring each line
+// to be a single JSON object.
+func readEvents(t *testing.T, output string) []changeEvent {
+	t.Helper()
+	var events []changeEvent
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		var event changeEvent
+		dec := json.NewDecoder(bytes.NewReader(scanner.Bytes()))
+		dec.DisallowUnknownFields()
+		require.NoError(t, dec.Decode(&event), "line %q", scanner.Text())
+		assert.False(t, dec.More(), "line %q", scanner.Text())
+		events = append(events, event)
+	}
+	require.NoError(t, scanner.Err())
+	return events
+}
+
+func TestRunWithConfigFactory_EmitEvent(t *testing.T) {
+	newStore := func(t *testing.T) *storage.Storage {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		for name, st := range map[string]release.Status{"app-a": release.StatusPendingUpgrade, "app-b": release.StatusDeployed} {
+			require.NoError(t, store.Create(&release.Release{
+				Name:      name,
+				Namespace: "default",
+				Version:   2,
+				Info:      &release.Info{Status: st},
+				Chart: &chart.Chart{
+					Metadata: &chart.Metadata{
+						Name:    "test-chart",
+						Version: "1.0.0",
+					},
+				},
+			}))
+		}
+		return store
+	}
+	factoryFor := func(store *storage.Storage) configurationFactory {
+		return func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+	}
+
+	t.Run("writes one line per change in a batch", func(t *testing.T) {
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		opts := runOptions{emitEvent: true, pendingOnly: true}
+		require.NoError(t, runWithConfigFactory(cmd, []string{"app-a", "app-b", "missing", "deployed"}, opts, factoryFor(newStore(t))))
+
+		events := readEvents(t, buf.String())
+		require.Len(t, events, 3)
+		for _, event := range events {
+			assert.Equal(t, eventVersion, event.Version)
+			_, err := time.Parse(time.RFC3339Nano, event.Time)
+			assert.NoError(t, err)
+		}
+		events[0].Time, events[1].Time, events[2].Time = "", "", ""
+		assert.Equal(t, changeEvent{Version: 1, Release: "app-a", Namespace: "default", Revision: 2, PreviousStatus: "pending-upgrade", Status: "deployed", Result: resultChanged}, events[0])
+		assert.Equal(t, "app-b", events[1].Release)
+		assert.Equal(t, resultSkipped, events[1].Result)
+		assert.NotEmpty(t, events[1].Reason)
+		assert.Equal(t, changeEvent{Version: 1, Release: "missing", Namespace: "default", Status: "deployed", Result: resultNotFound, Reason: events[2].Reason}, events[2])
+	})
+
+	t.Run("writes one line for a single change", func(t *testing.T) {
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		require.NoError(t, runWithConfigFactory(cmd, []string{"app-b", "failed"}, runOptions{emitEvent: true, dryRun: true}, factoryFor(newStore(t))))
+
+		events := readEvents(t, buf.String())
+		require.Len(t, events, 1)
+		assert.Equal(t, "app-b", events[0].Release)
+		assert.Equal(t, resultWouldChange, events[0].Result)
+		assert.True(t, events[0].DryRun)
+	})
+
+	t.Run("writes a line for a failed single change", func(t *testing.T) {
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		err := runWithConfigFactory(cmd, []string{"app-b", "failed"}, runOptions{emitEvent: true, fromStatuses: []string{"failed"}}, factoryFor(newStore(t)))
+		require.Error(t, err)
+
+		events := readEvents(t, buf.String())
+		require.Len(t, events, 1)
+		assert.Equal(t, resultError, events[0].Result)
+		assert.Equal(t, err.Error(), events[0].Reason)
+	})
+
+	t.Run("rejects other output formats", func(t *testing.T) {
+		err := runWithConfigFactory(newRootCmd(), []string{"app-a", "deployed"}, runOptions{emitEvent: true, output: outputJSON}, factoryFor(newStore(t)))
+		assert.EqualError(t, err, "--emit-event cannot be combined with --output json")
+
+		err = runWithConfigFactory(newRootCmd(), []string{"app-a", "deployed"}, runOptions{emitEvent: true, printRelease: true}, factoryFor(newStore(t)))
+		assert.EqualError(t, err, "--emit-event cannot be combined with --print-release")
+	})
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// defaultSkipExitCode is the exit code --exit-nonzero-on-skip uses for a
+// skipped release when --skip-exit-code is not set. It differs from the 1 of
+// a failure, so that scripts can tell the two apart.
+const defaultSkipExitCode = 2
+
+// exitCodeError asks main to exit with a specific code. It is returned once
+// the command's output has been written, so it is not printed as an error.
+type exitCodeError struct {
+	code int
+}
+
+func (e *exitCodeError) Error() string {
+	return fmt.Sprintf("exit code %d", e.code)
+}
+
+// exitCode returns the process exit code for an error returned by the root
+// command: the requested code for an exitCodeError, and 1 for anything else.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exitCodeError
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
+	return 1
+}
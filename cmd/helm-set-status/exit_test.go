@@ -0,0 +1,16 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExitCode(t *testing.T) {
+	assert.Equal(t, 0, exitCode(nil))
+	assert.Equal(t, 1, exitCode(errors.New("boom")))
+	assert.Equal(t, 2, exitCode(&exitCodeError{code: 2}))
+	assert.Equal(t, 3, exitCode(fmt.Errorf("wrapped: %w", &exitCodeError{code: 3})))
+}
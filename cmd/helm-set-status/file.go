@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/josegonzalez/helm-set-status/pkg/status"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// releaseStatus is a release and the status to set on it. An empty
+// namespace leaves the release in the namespace the command runs in.
+type releaseStatus struct {
+	namespace string
+	name      string
+	status    release.Status
+}
+
+// splitNamespacedRelease splits a NAMESPACE/RELEASE reference into its
+// namespace and release name. Release names cannot contain a slash, so a
+// reference without one is a release name with no namespace of its own.
+func splitNamespacedRelease(ref string) (namespace, name string, err error) {
+	namespace, name, ok := strings.Cut(ref, "/")
+	if !ok {
+		return "", ref, nil
+	}
+	if namespace == "" || name == "" || strings.Contains(name, "/") {
+		return "", "", fmt.Errorf("expected RELEASE or NAMESPACE/RELEASE, got %q", ref)
+	}
+	return namespace, name, nil
+}
+
+// readReleaseStatusFile reads the release and status pairs listed in the
+// file at path, given by the flag named flag.
+func readReleaseStatusFile(flag, path string) ([]releaseStatus, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", flag, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	entries, err := parseReleaseStatusFile(f)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %s: %w", flag, path, err)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%s %s lists no releases", flag, path)
+	}
+	return entries, nil
+}
+
+// parseReleaseStatusFile parses one "RELEASE STATUS" or "RELEASE,STATUS"
+// pair per line, where RELEASE may be given as NAMESPACE/RELEASE. Blank lines
+// and lines starting with # are ignored.
+func parseReleaseStatusFile(r io.Reader) ([]releaseStatus, error) {
+	var entries []releaseStatus
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var fields []string
+		if strings.Contains(line, ",") {
+			fields = strings.Split(line, ",")
+			for i := range fields {
+				fields[i] = strings.TrimSpace(fields[i])
+			}
+		} else {
+			fields = strings.Fields(line)
+		}
+		if len(fields) != 2 || fields[0] == "" || fields[1] == "" {
+			return nil, fmt.Errorf("line %d: expected \"RELEASE STATUS\" or \"RELEASE,STATUS\", got %q", n, line)
+		}
+
+		namespace, name, err := splitNamespacedRelease(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		parsed, err := status.ParseStatus(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		entries = append(entries, releaseStatus{namespace: namespace, name: name, status: parsed})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// hasReleaseStatusPairs reports whether args are given as RELEASE=STATUS
+// pairs rather than release names followed by a STATUS.
+func hasReleaseStatusPairs(args []string) bool {
+	for _, arg := range args {
+		if strings.Contains(arg, "=") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseReleaseStatusPairs parses RELEASE=STATUS arguments, where RELEASE may
+// be given as NAMESPACE/RELEASE. Every argument must be a pair, and every
+// status must be valid.
+func parseReleaseStatusPairs(args []string) ([]releaseStatus, error) {
+	entries := make([]releaseStatus, 0, len(args))
+	for _, arg := range args {
+		name, st, ok := strings.Cut(arg, "=")
+		if !ok {
+			return nil, fmt.Errorf("cannot mix RELEASE=STATUS pairs with release names or STATUS; got %q", arg)
+		}
+		if name == "" || st == "" {
+			return nil, fmt.Errorf("expected RELEASE=STATUS, got %q", arg)
+		}
+
+		namespace, name, err := splitNamespacedRelease(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid release in %q: %w", arg, err)
+		}
+		parsed, err := status.ParseStatus(st)
+		if err != nil {
+			return nil, fmt.Errorf("invalid status in %q: %w\nValid statuses: %s", arg, err, status.ValidStatusesString())
+		}
+		entries = append(entries, releaseStatus{namespace: namespace, name: name, status: parsed})
+	}
+	return entries, nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func TestParseReleaseStatusFile(t *testing.T) {
+	t.Run("parses space and comma separated pairs", func(t *testing.T) {
+		input := `# releases stuck after the outage
+frontend   deployed
+
+backend,failed
+  worker , Pending_Upgrade
+	# indented comment
+`
+		entries, err := parseReleaseStatusFile(strings.NewReader(input))
+		require.NoError(t, err)
+		assert.Equal(t, []releaseStatus{
+			{name: "frontend", status: release.StatusDeployed},
+			{name: "backend", status: release.StatusFailed},
+			{name: "worker", status: release.StatusPendingUpgrade},
+		}, entries)
+	})
+
+	t.Run("rejects malformed lines", func(t *testing.T) {
+		tests := []struct {
+			input    string
+			expected string
+		}{
+			{"frontend\n", `line 1: expected "RELEASE STATUS" or "RELEASE,STATUS", got "frontend"`},
+			{"# comment\nfrontend deployed now\n", `line 2: expected "RELEASE STATUS" or "RELEASE,STATUS", got "frontend deployed now"`},
+			{"frontend,\n", `line 1: expected "RELEASE STATUS" or "RELEASE,STATUS", got "frontend,"`},
+			{"a,b,c\n", `line 1: expected "RELEASE STATUS" or "RELEASE,STATUS", got "a,b,c"`},
+			{"frontend bogus\n", "line 1: invalid status: bogus"},
+		}
+		for _, tt := range tests {
+			_, err := parseReleaseStatusFile(strings.NewReader(tt.input))
+			require.Error(t, err, tt.input)
+			assert.Equal(t, tt.expected, err.Error())
+		}
+	})
+
+	t.Run("parses namespaced releases", func(t *testing.T) {
+		entries, err := parseReleaseStatusFile(strings.NewReader("team-a/frontend deployed\nbackend,failed\nteam-b/worker,failed\n"))
+		require.NoError(t, err)
+		assert.Equal(t, []releaseStatus{
+			{namespace: "team-a", name: "frontend", status: release.StatusDeployed},
+			{name: "backend", status: release.StatusFailed},
+			{namespace: "team-b", name: "worker", status: release.StatusFailed},
+		}, entries)
+
+		_, err = parseReleaseStatusFile(strings.NewReader("team-a/ deployed\n"))
+		assert.EqualError(t, err, `line 1: expected RELEASE or NAMESPACE/RELEASE, got "team-a/"`)
+	})
+
+	t.Run("returns nothing for a file of comments", func(t *testing.T) {
+		entries, err := parseReleaseStatusFile(strings.NewReader("# nothing to do\n\n"))
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+}
+
+func TestParseReleaseStatusPairs(t *testing.T) {
+	assert.False(t, hasReleaseStatusPairs([]string{"rel1", "failed"}))
+	assert.True(t, hasReleaseStatusPairs([]string{"rel1=failed"}))
+
+	entries, err := parseReleaseStatusPairs([]string{"rel1=failed", "rel2=PENDING_UPGRADE"})
+	require.NoError(t, err)
+	assert.Equal(t, []releaseStatus{
+		{name: "rel1", status: release.StatusFailed},
+		{name: "rel2", status: release.StatusPendingUpgrade},
+	}, entries)
+
+	entries, err = parseReleaseStatusPairs([]string{"team-a/rel1=failed", "rel2=deployed"})
+	require.NoError(t, err)
+	assert.Equal(t, []releaseStatus{
+		{namespace: "team-a", name: "rel1", status: release.StatusFailed},
+		{name: "rel2", status: release.StatusDeployed},
+	}, entries)
+
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"rel1=failed", "rel2=bogus"}, `invalid status in "rel2=bogus": invalid status: bogus`},
+		{[]string{"=failed"}, `expected RELEASE=STATUS, got "=failed"`},
+		{[]string{"rel1="}, `expected RELEASE=STATUS, got "rel1="`},
+		{[]string{"rel1=failed", "deployed"}, `cannot mix RELEASE=STATUS pairs with release names or STATUS; got "deployed"`},
+		{[]string{"/rel1=failed"}, `invalid release in "/rel1=failed": expected RELEASE or NAMESPACE/RELEASE, got "/rel1"`},
+		{[]string{"a/b/rel1=failed"}, `invalid release in "a/b/rel1=failed": expected RELEASE or NAMESPACE/RELEASE, got "a/b/rel1"`},
+	}
+	for _, tt := range tests {
+		_, err := parseReleaseStatusPairs(tt.args)
+		require.Error(t, err, tt.args)
+		assert.Contains(t, err.Error(), tt.expected)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/josegonzalez/helm-set-status/pkg/status"
+	"github.com/spf13/cobra"
+)
+
+func newGetCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "get RELEASE",
+		Short: "Print the current status of a Helm release",
+		Long: `Print the current status of a Helm release.
+
+By default, the status of the latest revision is printed. Use --revision to read a specific
+revision, or a negative offset such as --revision=-1 for the revision before the latest.`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeReleaseArg,
+		RunE:              runGet,
+	}
+
+	cmd.Flags().String("revision", "latest", "revision to read: a number, \"latest\", or an offset back from the latest such as -1")
+
+	return cmd
+}
+
+func runGet(cmd *cobra.Command, args []string) error {
+	var opts runOptions
+	var err error
+	revisionFlag, _ := cmd.Flags().GetString("revision")
+	if opts.revision, err = parseRevision(revisionFlag); err != nil {
+		return err
+	}
+	readGlobalFlags(cmd, &opts)
+	return runGetWithConfigFactory(cmd, args, opts, ConfigurationFactory)
+}
+
+func runGetWithConfigFactory(cmd *cobra.Command, args []string, opts runOptions, newConfig configurationFactory) error {
+	releaseName := args[0]
+
+	cfg, err := newConfig(opts.namespace, opts.clientOptions())
+	if err != nil {
+		return fmt.Errorf("failed to create configuration: %w", err)
+	}
+
+	current, err := status.GetStatus(cfg, releaseName, opts.revision)
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintln(cmd.OutOrStdout(), current)
+	return nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/josegonzalez/helm-set-status/pkg/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+func TestNewGetCmd(t *testing.T) {
+	cmd := newGetCmd()
+
+	assert.Equal(t, "get RELEASE", cmd.Use)
+	assert.Contains(t, cmd.Long, "--revision")
+
+	revFlag := cmd.Flags().Lookup("revision")
+	assert.NotNil(t, revFlag)
+	assert.Equal(t, "latest", revFlag.DefValue)
+}
+
+func TestRunGetWithConfigFactory(t *testing.T) {
+	mem := driver.NewMemory()
+	store := storage.Init(mem)
+
+	rel1 := &release.Release{
+		Name:      "my-release",
+		Namespace: "default",
+		Version:   1,
+		Info: &release.Info{
+			Status: release.StatusSuperseded,
+		},
+		Chart: &chart.Chart{
+			Metadata: &chart.Metadata{
+				Name:    "test-chart",
+				Version: "1.0.0",
+			},
+		},
+	}
+	rel2 := &release.Release{
+		Name:      "my-release",
+		Namespace: "default",
+		Version:   2,
+		Info: &release.Info{
+			Status: release.StatusPendingUpgrade,
+		},
+		Chart: &chart.Chart{
+			Metadata: &chart.Metadata{
+				Name:    "test-chart",
+				Version: "1.0.0",
+			},
+		},
+	}
+	require.NoError(t, store.Create(rel1))
+	require.NoError(t, store.Create(rel2))
+
+	configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+		return &action.Configuration{Releases: store}, nil
+	}
+
+	t.Run("prints latest status", func(t *testing.T) {
+		cmd := newGetCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		err := runGetWithConfigFactory(cmd, []string{"my-release"}, runOptions{}, configFactory)
+		require.NoError(t, err)
+		assert.Equal(t, "pending-upgrade\n", buf.String())
+	})
+
+	t.Run("prints status of specific revision", func(t *testing.T) {
+		cmd := newGetCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		err := runGetWithConfigFactory(cmd, []string{"my-release"}, runOptions{revision: 1}, configFactory)
+		require.NoError(t, err)
+		assert.Equal(t, "superseded\n", buf.String())
+	})
+
+	t.Run("returns ReleaseNotFoundError for missing release", func(t *testing.T) {
+		cmd := newGetCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		err := runGetWithConfigFactory(cmd, []string{"non-existent"}, runOptions{}, configFactory)
+		var notFoundErr *status.ReleaseNotFoundError
+		assert.True(t, errors.As(err, &notFoundErr), "error should be *ReleaseNotFoundError")
+	})
+
+	t.Run("returns config error", func(t *testing.T) {
+		cmd := newGetCmd()
+		failingFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return nil, errors.New("config creation failed")
+		}
+
+		err := runGetWithConfigFactory(cmd, []string{"my-release"}, runOptions{}, failingFactory)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to create configuration")
+	})
+}
+
+func TestRunGet_ViaRootCommand(t *testing.T) {
+	originalFactory := ConfigurationFactory
+	defer func() { ConfigurationFactory = originalFactory }()
+
+	mem := driver.NewMemory()
+	store := storage.Init(mem)
+
+	rel := &release.Release{
+		Name:      "my-release",
+		Namespace: "staging",
+		Version:   1,
+		Info: &release.Info{
+			Status: release.StatusFailed,
+		},
+		Chart: &chart.Chart{
+			Metadata: &chart.Metadata{
+				Name:    "test-chart",
+				Version: "1.0.0",
+			},
+		},
+	}
+	require.NoError(t, store.Create(rel))
+
+	var gotNamespace string
+	ConfigurationFactory = func(namespace string, _ status.ClientOptions) (*action.Configuration, error) {
+		gotNamespace = namespace
+		return &action.Configuration{Releases: store}, nil
+	}
+
+	cmd := newRootCmd()
+	var buf bytes.Buffer
+	cmd.SetOut(&buf)
+	cmd.SetErr(&buf)
+	cmd.SetArgs([]string{"get", "my-release", "-n", "staging"})
+
+	require.NoError(t, cmd.Execute())
+	assert.Equal(t, "failed\n", buf.String())
+	assert.Equal(t, "staging", gotNamespace)
+}
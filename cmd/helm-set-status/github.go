@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// workflowCommand returns the GitHub Actions workflow command that reports a
+// result: an error for a failed release, a warning for one that was skipped,
+// not found, or not attempted, and a notice otherwise.
+func workflowCommand(result string) string {
+	switch result {
+	case resultError:
+		return "error"
+	case resultSkipped, resultNotFound, resultNotAttempted:
+		return "warning"
+	default:
+		return "notice"
+	}
+}
+
+// escapeWorkflowData escapes s for use as the message of a workflow command,
+// which ends at the first line break.
+func escapeWorkflowData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// writeWorkflowResult renders res to w as a GitHub Actions workflow command,
+// so that it is shown as an annotation. The message is the text output of
+// res, with any further lines, such as the revisions pruned, escaped into the
+// same annotation.
+func writeWorkflowResult(w io.Writer, res changeResult) error {
+	var buf bytes.Buffer
+	if err := writeResult(&buf, outputText, res); err != nil {
+		return err
+	}
+
+	message := strings.TrimSuffix(buf.String(), "\n")
+	message = strings.TrimPrefix(message, "Error: ")
+	message = strings.TrimPrefix(message, "Warning: ")
+	_, err := fmt.Fprintf(w, "::%s::%s\n", workflowCommand(res.Result), escapeWorkflowData(message))
+	return err
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteResult_GitHub(t *testing.T) {
+	tests := []struct {
+		name string
+		res  changeResult
+		want string
+	}{
+		{
+			name: "changed",
+			res:  changeResult{Release: "my-release", PreviousStatus: "deployed", NewStatus: "failed", Result: resultChanged},
+			want: "::notice::Release \"my-release\" status changed from \"deployed\" to \"failed\"\n",
+		},
+		{
+			name: "unchanged",
+			res:  changeResult{Release: "my-release", NewStatus: "failed", Result: resultUnchanged},
+			want: "::notice::Release \"my-release\" already \"failed\", no change\n",
+		},
+		{
+			name: "skipped",
+			res:  changeResult{Release: "my-release", NewStatus: "failed", Result: resultSkipped, Reason: `current status "deployed" not in allowed list [pending-upgrade]`},
+			want: "::warning::Skipped: current status \"deployed\" not in allowed list [pending-upgrade]\n",
+		},
+		{
+			name: "not found",
+			res:  changeResult{Release: "my-release", NewStatus: "failed", Result: resultNotFound},
+			want: "::warning::release \"my-release\" not found, skipping\n",
+		},
+		{
+			name: "error with a multi-line reason",
+			res:  changeResult{Release: "my-release", NewStatus: "failed", Result: resultError, Reason: "pre-hook failed: exit status 1: 100% broken\nsee logs"},
+			want: "::error::release \"my-release\": pre-hook failed: exit status 1: 100%25 broken%0Asee logs\n",
+		},
+		{
+			name: "pruned revisions",
+			res:  changeResult{Release: "my-release", PreviousStatus: "deployed", NewStatus: "failed", Result: resultChanged, Pruned: []int{1, 2}},
+			want: "::notice::Release \"my-release\" status changed from \"deployed\" to \"failed\"%0APruned release \"my-release\" revisions 1, 2\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, writeResult(&buf, outputGitHub, tt.res))
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+func TestWriteResults_GitHub(t *testing.T) {
+	results := []changeResult{
+		{Release: "app-a", Namespace: "staging", PreviousStatus: "deployed", NewStatus: "failed", Result: resultChanged},
+		{Release: "app-b", Namespace: "production", NewStatus: "failed", Result: resultSkipped, Reason: "not pending"},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, writeResultsByNamespace(&buf, outputGitHub, results))
+	assert.Equal(t, "::notice::Release \"app-a\" status changed from \"deployed\" to \"failed\"\n"+
+		"::warning::Skipped: not pending\n"+
+		"Summary: 1 changed, 0 unchanged, 1 skipped, 0 not found, 0 errored (2 total)\n", buf.String())
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/josegonzalez/helm-set-status/pkg/status"
+	"github.com/spf13/cobra"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// historyEntry describes a single revision in the output of the history command.
+type historyEntry struct {
+	Revision    int    `json:"revision"`
+	Status      string `json:"status"`
+	Updated     string `json:"updated,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+func newHistoryCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "history RELEASE",
+		Short: "List every revision of a Helm release and its status",
+		Long: `List every revision of a Helm release with its status, last-deployed time, and description.
+
+Use this to choose a revision to pass to --revision.`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeReleaseArg,
+		RunE:              runHistory,
+	}
+
+	cmd.Flags().StringP("output", "o", outputText, "output format: text, table, json, or yaml")
+
+	return cmd
+}
+
+func runHistory(cmd *cobra.Command, args []string) error {
+	var opts runOptions
+	opts.output, _ = cmd.Flags().GetString("output")
+	readGlobalFlags(cmd, &opts)
+	return reportError(cmd, cmd.ErrOrStderr(), opts.output, runHistoryWithConfigFactory(cmd, args, opts, ConfigurationFactory))
+}
+
+func runHistoryWithConfigFactory(cmd *cobra.Command, args []string, opts runOptions, newConfig configurationFactory) error {
+	if err := validateReportFormat(opts.output); err != nil {
+		return err
+	}
+
+	releaseName := args[0]
+
+	cfg, err := newConfig(opts.namespace, opts.clientOptions())
+	if err != nil {
+		return fmt.Errorf("failed to create configuration: %w", err)
+	}
+
+	revisions, err := status.History(cfg, releaseName)
+	if err != nil {
+		return err
+	}
+
+	entries := make([]historyEntry, 0, len(revisions))
+	for _, rel := range revisions {
+		entries = append(entries, newHistoryEntry(rel))
+	}
+
+	return writeHistory(cmd.OutOrStdout(), opts.output, entries)
+}
+
+// newHistoryEntry summarises a stored revision for display.
+func newHistoryEntry(rel *release.Release) historyEntry {
+	entry := historyEntry{Revision: rel.Version, Status: release.StatusUnknown.String()}
+	if rel.Info != nil {
+		entry.Status = rel.Info.Status.String()
+		entry.Description = rel.Info.Description
+		if !rel.Info.LastDeployed.IsZero() {
+			entry.Updated = rel.Info.LastDeployed.Format(time.RFC3339)
+		}
+	}
+	return entry
+}
+
+// writeHistory renders entries to w, as a list in JSON or YAML mode, or as a
+// table in text and table mode, with each description on one line.
+func writeHistory(w io.Writer, format string, entries []historyEntry) error {
+	if isStructured(format) {
+		return writeStructured(w, format, entries)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "REVISION\tSTATUS\tUPDATED\tDESCRIPTION")
+	for _, e := range entries {
+		_, _ = fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", e.Revision, e.Status, e.Updated, oneLine(e.Description))
+	}
+	return tw.Flush()
+}
+
+// oneLine joins the lines of s with "; ", so that a description built up with
+// --append-description stays in its table row.
+func oneLine(s string) string {
+	lines := strings.FieldsFunc(s, func(r rune) bool { return r == '\n' || r == '\r' })
+	return strings.Join(lines, "; ")
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/josegonzalez/helm-set-status/pkg/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	helmtime "helm.sh/helm/v3/pkg/time"
+)
+
+func TestNewHistoryCmd(t *testing.T) {
+	cmd := newHistoryCmd()
+
+	assert.Equal(t, "history RELEASE", cmd.Use)
+
+	outputFlag := cmd.Flags().Lookup("output")
+	assert.NotNil(t, outputFlag)
+	assert.Equal(t, "o", outputFlag.Shorthand)
+	assert.Equal(t, "text", outputFlag.DefValue)
+}
+
+func TestRunHistoryWithConfigFactory(t *testing.T) {
+	mem := driver.NewMemory()
+	store := storage.Init(mem)
+
+	deployed := helmtime.Time{Time: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+	rel1 := &release.Release{
+		Name:      "my-release",
+		Namespace: "default",
+		Version:   1,
+		Info: &release.Info{
+			Status:       release.StatusSuperseded,
+			LastDeployed: deployed,
+			Description:  "Install complete",
+		},
+		Chart: &chart.Chart{
+			Metadata: &chart.Metadata{
+				Name:    "test-chart",
+				Version: "1.0.0",
+			},
+		},
+	}
+	rel2 := &release.Release{
+		Name:      "my-release",
+		Namespace: "default",
+		Version:   2,
+		Info: &release.Info{
+			Status:      release.StatusPendingUpgrade,
+			Description: "Preparing upgrade",
+		},
+		Chart: &chart.Chart{
+			Metadata: &chart.Metadata{
+				Name:    "test-chart",
+				Version: "1.0.0",
+			},
+		},
+	}
+	require.NoError(t, store.Create(rel1))
+	require.NoError(t, store.Create(rel2))
+
+	configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+		return &action.Configuration{Releases: store}, nil
+	}
+
+	t.Run("prints a table", func(t *testing.T) {
+		cmd := newHistoryCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		err := runHistoryWithConfigFactory(cmd, []string{"my-release"}, runOptions{output: outputText}, configFactory)
+		require.NoError(t, err)
+
+		lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+		require.Len(t, lines, 3)
+		assert.Contains(t, string(lines[0]), "REVISION")
+		assert.Contains(t, string(lines[1]), "superseded")
+		assert.Contains(t, string(lines[1]), "2024-01-02T03:04:05Z")
+		assert.Contains(t, string(lines[1]), "Install complete")
+		assert.Contains(t, string(lines[2]), "pending-upgrade")
+	})
+
+	t.Run("prints a multi-line description on one line", func(t *testing.T) {
+		store := storage.Init(driver.NewMemory())
+		require.NoError(t, store.Create(&release.Release{
+			Name:      "my-release",
+			Namespace: "default",
+			Version:   1,
+			Info: &release.Info{
+				Status:      release.StatusFailed,
+				Description: "Upgrade failed\r\n2024-01-02T03:04:05Z: status set to failed\n",
+			},
+			Chart: rel1.Chart,
+		}))
+		factory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		for _, format := range []string{outputText, outputTable} {
+			cmd := newHistoryCmd()
+			var buf bytes.Buffer
+			cmd.SetOut(&buf)
+
+			err := runHistoryWithConfigFactory(cmd, []string{"my-release"}, runOptions{output: format}, factory)
+			require.NoError(t, err)
+
+			lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+			require.Len(t, lines, 2, format)
+			assert.True(t, strings.HasSuffix(string(lines[1]), "Upgrade failed; 2024-01-02T03:04:05Z: status set to failed"), string(lines[1]))
+		}
+	})
+
+	t.Run("prints json", func(t *testing.T) {
+		cmd := newHistoryCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		err := runHistoryWithConfigFactory(cmd, []string{"my-release"}, runOptions{output: outputJSON}, configFactory)
+		require.NoError(t, err)
+
+		var entries []historyEntry
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &entries))
+		assert.Equal(t, []historyEntry{
+			{Revision: 1, Status: "superseded", Updated: "2024-01-02T03:04:05Z", Description: "Install complete"},
+			{Revision: 2, Status: "pending-upgrade", Description: "Preparing upgrade"},
+		}, entries)
+	})
+
+	t.Run("prints yaml", func(t *testing.T) {
+		cmd := newHistoryCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		err := runHistoryWithConfigFactory(cmd, []string{"my-release"}, runOptions{output: outputYAML}, configFactory)
+		require.NoError(t, err)
+		assert.Contains(t, buf.String(), "- description: Install complete\n")
+	})
+
+	t.Run("release not found", func(t *testing.T) {
+		cmd := newHistoryCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		err := runHistoryWithConfigFactory(cmd, []string{"nonexistent"}, runOptions{}, configFactory)
+		var notFoundErr *status.ReleaseNotFoundError
+		assert.True(t, errors.As(err, &notFoundErr), "error should be *ReleaseNotFoundError")
+	})
+
+	t.Run("invalid output format", func(t *testing.T) {
+		cmd := newHistoryCmd()
+		err := runHistoryWithConfigFactory(cmd, []string{"my-release"}, runOptions{output: "xml"}, configFactory)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid --output format")
+
+		err = runHistoryWithConfigFactory(cmd, []string{"my-release"}, runOptions{output: outputGitHub}, configFactory)
+		assert.ErrorContains(t, err, `invalid --output format "github"`)
+	})
+}
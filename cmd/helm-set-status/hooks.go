@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/josegonzalez/helm-set-status/pkg/status"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// hookEnv describes the change a hook runs for. Each field is passed to the
+// hook as a HELM_SET_STATUS_* environment variable.
+type hookEnv struct {
+	stage          string
+	release        string
+	namespace      string
+	revision       int
+	previousStatus string
+	status         string
+}
+
+// environ returns the process environment with the hook's variables added.
+func (e hookEnv) environ() []string {
+	return append(os.Environ(),
+		"HELM_SET_STATUS_HOOK="+e.stage,
+		"HELM_SET_STATUS_RELEASE="+e.release,
+		"HELM_SET_STATUS_NAMESPACE="+e.namespace,
+		"HELM_SET_STATUS_REVISION="+strconv.Itoa(e.revision),
+		"HELM_SET_STATUS_PREVIOUS_STATUS="+e.previousStatus,
+		"HELM_SET_STATUS_STATUS="+e.status,
+	)
+}
+
+// runHook runs command with sh -c and env. A command that exits non-zero
+// fails with its combined output, so that the failure can be reported
+// without interleaving the output of hooks run in parallel.
+func runHook(ctx context.Context, command string, env hookEnv) error {
+	hook := exec.CommandContext(ctx, "sh", "-c", command)
+	hook.Env = env.environ()
+	out, err := hook.CombinedOutput()
+	if err != nil {
+		if output := strings.TrimSpace(string(out)); output != "" {
+			return fmt.Errorf("%s-hook failed: %w: %s", env.stage, err, output)
+		}
+		return fmt.Errorf("%s-hook failed: %w", env.stage, err)
+	}
+	return nil
+}
+
+// preHook returns the SetStatusOptions.BeforeUpdate callback that runs
+// --pre-hook before each release is written.
+func preHook(command string) func(context.Context, *release.Release, release.Status) error {
+	return func(ctx context.Context, rel *release.Release, target release.Status) error {
+		return runHook(ctx, command, hookEnv{
+			stage:          "pre",
+			release:        rel.Name,
+			namespace:      rel.Namespace,
+			revision:       rel.Version,
+			previousStatus: rel.Info.Status.String(),
+			status:         target.String(),
+		})
+	}
+}
+
+// runPostHooks runs --post-hook for every changed release in results, which
+// are in the same order as changes. A failing hook is warned about on w and
+// recorded in the result. With --strict-hooks, the release is restored to its
+// previous status instead and the failure is returned in errs.
+func runPostHooks(ctx context.Context, w io.Writer, changes []change, results []changeResult, opts runOptions) []error {
+	errs := make([]error, len(results))
+	for i := range results {
+		res := &results[i]
+		if res.Result != resultChanged {
+			continue
+		}
+		err := runHook(ctx, opts.postHook, hookEnv{
+			stage:          "post",
+			release:        res.Release,
+			namespace:      res.Namespace,
+			revision:       res.storedRevision,
+			previousStatus: res.PreviousStatus,
+			status:         res.NewStatus,
+		})
+		if err == nil {
+			continue
+		}
+		if !opts.strictHooks {
+			res.PostHookError = err.Error()
+			_, _ = fmt.Fprintf(w, "Warning: release %q was changed, but its %v\n", res.Release, err)
+			continue
+		}
+		if restoreErr := restoreChange(changes[i], *res); restoreErr != nil {
+			errs[i] = fmt.Errorf("%w; failed to restore status %q: %w", err, res.PreviousStatus, restoreErr)
+		} else {
+			errs[i] = fmt.Errorf("%w; status restored to %q", err, res.PreviousStatus)
+		}
+	}
+	return errs
+}
+
+// restoreChange restores the revision res changed to the status, description,
+// and last-deployed time it had before, as long as it still has the status it
+// was given.
+func restoreChange(c change, res changeResult) error {
+	return status.RestoreStatus(c.cfg, res.previous, c.status)
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/josegonzalez/helm-set-status/pkg/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+// writeHookScript writes a hook script to a temporary directory that appends
+// the HELM_SET_STATUS_* variables it is run with to a log file, then exits
+// with exitCode. It returns the script and log file paths.
+func writeHookScript(t *testing.T, exitCode int) (script, log string) {
+	t.Helper()
+	dir := t.TempDir()
+	script = filepath.Join(dir, "hook.sh")
+	log = filepath.Join(dir, "hook.log")
+	content := "#!/bin/sh\n" +
+		"env | grep '^HELM_SET_STATUS_' | sort >> '" + log + "'\n" +
+		"echo '---' >> '" + log + "'\n" +
+		"echo 'hook output'\n" +
+		"exit " + strconv.Itoa(exitCode) + "\n"
+	require.NoError(t, os.WriteFile(script, []byte(content), 0o755))
+	return script, log
+}
+
+// readHookLog returns the variables of each hook run recorded by a script
+// from writeHookScript.
+func readHookLog(t *testing.T, log string) []string {
+	t.Helper()
+	data, err := os.ReadFile(log)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	require.NoError(t, err)
+	runs := strings.Split(strings.TrimSuffix(string(data), "---\n"), "---\n")
+	for i := range runs {
+		runs[i] = strings.TrimSpace(runs[i])
+	}
+	return runs
+}
+
+func TestRunWithConfigFactory_Hooks(t *testing.T) {
+	newStore := func(t *testing.T) *storage.Storage {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		for _, name := range []string{"app-a", "app-b"} {
+			require.NoError(t, store.Create(&release.Release{
+				Name:      name,
+				Namespace: "default",
+				Version:   1,
+				Info:      &release.Info{Status: release.StatusPendingUpgrade, Description: "Upgrade started"},
+				Chart: &chart.Chart{
+					Metadata: &chart.Metadata{
+						Name:    "test-chart",
+						Version: "1.0.0",
+					},
+				},
+			}))
+		}
+		return store
+	}
+	factoryFor := func(store *storage.Storage) configurationFactory {
+		return func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+	}
+	statusOf := func(t *testing.T, store *storage.Storage, name string) release.Status {
+		t.Helper()
+		rel, err := store.Get(name, 1)
+		require.NoError(t, err)
+		return rel.Info.Status
+	}
+
+	t.Run("passes the change to the hooks", func(t *testing.T) {
+		store := newStore(t)
+		pre, preLog := writeHookScript(t, 0)
+		post, postLog := writeHookScript(t, 0)
+
+		cmd := newRootCmd()
+		cmd.SetOut(&bytes.Buffer{})
+		opts := runOptions{preHook: pre, postHook: post}
+		require.NoError(t, runWithConfigFactory(cmd, []string{"app-a", "deployed"}, opts, factoryFor(store)))
+
+		assert.Equal(t, []string{"HELM_SET_STATUS_HOOK=pre\n" +
+			"HELM_SET_STATUS_NAMESPACE=default\n" +
+			"HELM_SET_STATUS_PREVIOUS_STATUS=pending-upgrade\n" +
+			"HELM_SET_STATUS_RELEASE=app-a\n" +
+			"HELM_SET_STATUS_REVISION=1\n" +
+			"HELM_SET_STATUS_STATUS=deployed"}, readHookLog(t, preLog))
+		assert.Equal(t, []string{"HELM_SET_STATUS_HOOK=post\n" +
+			"HELM_SET_STATUS_NAMESPACE=default\n" +
+			"HELM_SET_STATUS_PREVIOUS_STATUS=pending-upgrade\n" +
+			"HELM_SET_STATUS_RELEASE=app-a\n" +
+			"HELM_SET_STATUS_REVISION=1\n" +
+			"HELM_SET_STATUS_STATUS=deployed"}, readHookLog(t, postLog))
+		assert.Equal(t, release.StatusDeployed, statusOf(t, store, "app-a"))
+	})
+
+	t.Run("does not run the hooks for an unchanged release", func(t *testing.T) {
+		store := newStore(t)
+		pre, preLog := writeHookScript(t, 0)
+		post, postLog := writeHookScript(t, 0)
+
+		cmd := newRootCmd()
+		cmd.SetOut(&bytes.Buffer{})
+		opts := runOptions{preHook: pre, postHook: post}
+		require.NoError(t, runWithConfigFactory(cmd, []string{"app-a", "pending-upgrade"}, opts, factoryFor(store)))
+		assert.Empty(t, readHookLog(t, preLog))
+		assert.Empty(t, readHookLog(t, postLog))
+	})
+
+	t.Run("a failing pre-hook aborts the change", func(t *testing.T) {
+		store := newStore(t)
+		pre, _ := writeHookScript(t, 1)
+		post, postLog := writeHookScript(t, 0)
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		opts := runOptions{preHook: pre, postHook: post}
+		err := runWithConfigFactory(cmd, []string{"app-a", "app-b", "deployed"}, opts, factoryFor(store))
+		assert.EqualError(t, err, "failed to set status on 2 of 2 releases")
+		assert.Contains(t, buf.String(), `update of release "app-a" revision 1 aborted: pre-hook failed: exit status 1: hook output`)
+		assert.Equal(t, release.StatusPendingUpgrade, statusOf(t, store, "app-a"))
+		assert.Equal(t, release.StatusPendingUpgrade, statusOf(t, store, "app-b"))
+		assert.Empty(t, readHookLog(t, postLog))
+	})
+
+	t.Run("a failing post-hook is reported", func(t *testing.T) {
+		store := newStore(t)
+		post, _ := writeHookScript(t, 1)
+
+		cmd := newRootCmd()
+		var out, errOut bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetErr(&errOut)
+		opts := runOptions{postHook: post, output: outputJSON}
+		require.NoError(t, runWithConfigFactory(cmd, []string{"app-a", "deployed"}, opts, factoryFor(store)))
+		assert.Equal(t, "Warning: release \"app-a\" was changed, but its post-hook failed: exit status 1: hook output\n", errOut.String())
+		assert.Contains(t, out.String(), `"post_hook_error": "post-hook failed: exit status 1: hook output"`)
+		assert.Equal(t, release.StatusDeployed, statusOf(t, store, "app-a"))
+	})
+
+	t.Run("a failing post-hook restores the release with --strict-hooks", func(t *testing.T) {
+		store := newStore(t)
+		post, postLog := writeHookScript(t, 1)
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		opts := runOptions{postHook: post, strictHooks: true}
+		err := runWithConfigFactory(cmd, []string{"app-a", "app-b", "deployed"}, opts, factoryFor(store))
+		assert.EqualError(t, err, "failed to set status on 2 of 2 releases")
+		assert.Contains(t, buf.String(), `post-hook failed: exit status 1: hook output; status restored to "pending-upgrade"`)
+		assert.Len(t, readHookLog(t, postLog), 2)
+		assert.Equal(t, release.StatusPendingUpgrade, statusOf(t, store, "app-a"))
+		assert.Equal(t, release.StatusPendingUpgrade, statusOf(t, store, "app-b"))
+
+		rel, err := store.Get("app-a", 1)
+		require.NoError(t, err)
+		assert.Equal(t, "Upgrade started", rel.Info.Description)
+	})
+
+	t.Run("--strict-hooks requires --post-hook", func(t *testing.T) {
+		err := runWithConfigFactory(newRootCmd(), []string{"app-a", "deployed"}, runOptions{strictHooks: true}, factoryFor(newStore(t)))
+		assert.EqualError(t, err, "--strict-hooks requires --post-hook")
+	})
+}
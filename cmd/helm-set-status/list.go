@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/josegonzalez/helm-set-status/pkg/status"
+	"github.com/spf13/cobra"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// listEntry describes a single release in the output of the list command.
+type listEntry struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Revision  int    `json:"revision"`
+	Status    string `json:"status"`
+	Updated   string `json:"updated,omitempty"`
+}
+
+func newListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List Helm releases and their current status",
+		Long: `List the releases in the namespace with the status, revision, and
+last-deployed time of their latest revision, in any status.
+
+Use --all-namespaces to list the releases in every namespace, --selector to
+only list releases whose labels match, and --status to only list releases in
+one of the given statuses. Use this for an overview before deciding which
+releases to change.`,
+		Args:              cobra.NoArgs,
+		ValidArgsFunction: cobra.NoFileCompletions,
+		RunE:              runList,
+	}
+
+	cmd.Flags().StringP("selector", "l", "", "only list releases matching this label selector (e.g. app=frontend)")
+	cmd.Flags().BoolP("all-namespaces", "A", false, "list releases in every namespace instead of one")
+	cmd.Flags().StringSlice("status", nil, "only list releases in one of these statuses (can specify multiple)")
+	cmd.Flags().StringP("output", "o", outputText, "output format: text, table, json, or yaml")
+	_ = cmd.RegisterFlagCompletionFunc("status", completeStatusFlag)
+
+	return cmd
+}
+
+func runList(cmd *cobra.Command, args []string) error {
+	var opts runOptions
+	opts.selector, _ = cmd.Flags().GetString("selector")
+	opts.allNamespaces, _ = cmd.Flags().GetBool("all-namespaces")
+	opts.statuses, _ = cmd.Flags().GetStringSlice("status")
+	opts.output, _ = cmd.Flags().GetString("output")
+	readGlobalFlags(cmd, &opts)
+	return reportError(cmd, cmd.ErrOrStderr(), opts.output, runListWithConfigFactory(cmd, args, opts, ConfigurationFactory))
+}
+
+func runListWithConfigFactory(cmd *cobra.Command, _ []string, opts runOptions, newConfig configurationFactory) error {
+	if err := validateReportFormat(opts.output); err != nil {
+		return err
+	}
+	if opts.allNamespaces && opts.namespace != "" {
+		return errors.New("--all-namespaces cannot be combined with --namespace")
+	}
+	statuses, err := parseStatusFlag("--status", opts.statuses)
+	if err != nil {
+		return err
+	}
+
+	namespace := opts.namespace
+	clientOpts := opts.clientOptions()
+	if opts.allNamespaces {
+		namespace = ""
+		clientOpts.AllNamespaces = true
+	}
+	cfg, err := newConfig(namespace, clientOpts)
+	if err != nil {
+		return fmt.Errorf("failed to create configuration: %w", err)
+	}
+
+	rels, err := status.List(cfg, status.ListOptions{Selector: opts.selector})
+	if err != nil {
+		return err
+	}
+
+	rels = status.InStatus(rels, statuses)
+	entries := make([]listEntry, 0, len(rels))
+	for _, rel := range rels {
+		entries = append(entries, newListEntry(rel))
+	}
+
+	return writeList(cmd.OutOrStdout(), opts.output, entries)
+}
+
+// newListEntry summarises the latest revision of a release for display.
+func newListEntry(rel *release.Release) listEntry {
+	entry := listEntry{
+		Name:      rel.Name,
+		Namespace: rel.Namespace,
+		Revision:  rel.Version,
+		Status:    rel.Info.Status.String(),
+	}
+	if !rel.Info.LastDeployed.IsZero() {
+		entry.Updated = rel.Info.LastDeployed.Format(time.RFC3339)
+	}
+	return entry
+}
+
+// writeList renders entries to w, as a list in JSON or YAML mode, or as a
+// table in text and table mode.
+func writeList(w io.Writer, format string, entries []listEntry) error {
+	if isStructured(format) {
+		return writeStructured(w, format, entries)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "NAME\tNAMESPACE\tREVISION\tSTATUS\tUPDATED")
+	for _, e := range entries {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", e.Name, e.Namespace, e.Revision, e.Status, e.Updated)
+	}
+	return tw.Flush()
+}
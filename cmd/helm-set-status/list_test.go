@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/josegonzalez/helm-set-status/pkg/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	helmtime "helm.sh/helm/v3/pkg/time"
+)
+
+func TestNewListCmd(t *testing.T) {
+	cmd := newListCmd()
+
+	assert.Equal(t, "list", cmd.Use)
+	assert.Equal(t, "l", cmd.Flags().Lookup("selector").Shorthand)
+	assert.Equal(t, "A", cmd.Flags().Lookup("all-namespaces").Shorthand)
+	assert.NotNil(t, cmd.Flags().Lookup("status"))
+	assert.Equal(t, "text", cmd.Flags().Lookup("output").DefValue)
+}
+
+func TestRunListWithConfigFactory(t *testing.T) {
+	mem := driver.NewMemory()
+	store := storage.Init(mem)
+	deployed := helmtime.Time{Time: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+	for _, r := range []struct {
+		name      string
+		namespace string
+		version   int
+		app       string
+		status    release.Status
+	}{
+		{"web", "default", 1, "frontend", release.StatusSuperseded},
+		{"web", "default", 2, "frontend", release.StatusDeployed},
+		{"api", "default", 1, "backend", release.StatusPendingUpgrade},
+		{"worker", "default", 1, "backend", release.StatusFailed},
+		{"web", "production", 1, "frontend", release.StatusFailed},
+	} {
+		require.NoError(t, store.Create(&release.Release{
+			Name:      r.name,
+			Namespace: r.namespace,
+			Version:   r.version,
+			Labels:    map[string]string{"app": r.app},
+			Info:      &release.Info{Status: r.status, LastDeployed: deployed},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}))
+	}
+	configFactory := func(namespace string, opts status.ClientOptions) (*action.Configuration, error) {
+		if namespace == "" && !opts.AllNamespaces {
+			namespace = "default"
+		}
+		return &action.Configuration{Releases: storage.Init(&namespacedDriver{Memory: mem, namespace: namespace})}, nil
+	}
+	list := func(t *testing.T, opts runOptions) []listEntry {
+		t.Helper()
+		opts.output = outputJSON
+		cmd := newListCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		require.NoError(t, runListWithConfigFactory(cmd, nil, opts, configFactory))
+
+		var entries []listEntry
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &entries))
+		return entries
+	}
+	names := func(entries []listEntry) []string {
+		var result []string
+		for _, e := range entries {
+			result = append(result, e.Namespace+"/"+e.Name)
+		}
+		return result
+	}
+
+	t.Run("lists the latest revision of each release in the namespace", func(t *testing.T) {
+		assert.Equal(t, []listEntry{
+			{Name: "api", Namespace: "default", Revision: 1, Status: "pending-upgrade", Updated: "2024-01-02T03:04:05Z"},
+			{Name: "web", Namespace: "default", Revision: 2, Status: "deployed", Updated: "2024-01-02T03:04:05Z"},
+			{Name: "worker", Namespace: "default", Revision: 1, Status: "failed", Updated: "2024-01-02T03:04:05Z"},
+		}, list(t, runOptions{}))
+	})
+
+	t.Run("lists every namespace with --all-namespaces", func(t *testing.T) {
+		assert.Equal(t, []string{"default/api", "default/web", "default/worker", "production/web"}, names(list(t, runOptions{allNamespaces: true})))
+	})
+
+	t.Run("filters by selector and status", func(t *testing.T) {
+		assert.Equal(t, []string{"default/api", "default/worker"}, names(list(t, runOptions{selector: "app=backend"})))
+		assert.Equal(t, []string{"default/worker", "production/web"}, names(list(t, runOptions{allNamespaces: true, statuses: []string{"failed"}})))
+		assert.Equal(t, []string{"default/api", "default/web"}, names(list(t, runOptions{statuses: []string{"deployed", "pending_upgrade"}})))
+		assert.Empty(t, list(t, runOptions{selector: "app=frontend", statuses: []string{"failed"}}))
+	})
+
+	t.Run("prints a table", func(t *testing.T) {
+		cmd := newListCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		require.NoError(t, runListWithConfigFactory(cmd, nil, runOptions{output: outputTable, selector: "app=frontend"}, configFactory))
+		assert.Equal(t, "NAME  NAMESPACE  REVISION  STATUS    UPDATED\n"+
+			"web   default    2         deployed  2024-01-02T03:04:05Z\n", buf.String())
+	})
+
+	t.Run("rejects invalid flags", func(t *testing.T) {
+		err := runListWithConfigFactory(newListCmd(), nil, runOptions{statuses: []string{"bogus"}}, configFactory)
+		assert.ErrorContains(t, err, `invalid --status status "bogus"`)
+
+		err = runListWithConfigFactory(newListCmd(), nil, runOptions{allNamespaces: true, namespace: "default"}, configFactory)
+		assert.EqualError(t, err, "--all-namespaces cannot be combined with --namespace")
+
+		err = runListWithConfigFactory(newListCmd(), nil, runOptions{output: "xml"}, configFactory)
+		assert.ErrorContains(t, err, "invalid --output format")
+
+		err = runListWithConfigFactory(newListCmd(), nil, runOptions{output: outputGitHub}, configFactory)
+		assert.ErrorContains(t, err, `invalid --output format "github"`)
+	})
+}
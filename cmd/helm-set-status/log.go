@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// newLogger returns a debug-level logger writing to w in the given format,
+// or a logger that discards everything when verbose is false.
+func newLogger(w io.Writer, verbose bool, format string) (*slog.Logger, error) {
+	if !verbose {
+		return slog.New(slog.DiscardHandler), nil
+	}
+
+	handlerOpts := &slog.HandlerOptions{Level: slog.LevelDebug}
+	switch format {
+	case "", logFormatText:
+		return slog.New(slog.NewTextHandler(w, handlerOpts)), nil
+	case logFormatJSON:
+		return slog.New(slog.NewJSONHandler(w, handlerOpts)), nil
+	default:
+		return nil, fmt.Errorf("invalid --log-format %q (valid: %s, %s)", format, logFormatText, logFormatJSON)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLogger(t *testing.T) {
+	t.Run("discards when not verbose", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger, err := newLogger(&buf, false, logFormatText)
+		require.NoError(t, err)
+
+		logger.Debug("hello")
+		assert.Empty(t, buf.String())
+	})
+
+	t.Run("writes debug text when verbose", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger, err := newLogger(&buf, true, logFormatText)
+		require.NoError(t, err)
+
+		logger.Debug("hello", "key", "value")
+		assert.Contains(t, buf.String(), "level=DEBUG msg=hello key=value")
+	})
+
+	t.Run("writes debug JSON when verbose", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger, err := newLogger(&buf, true, logFormatJSON)
+		require.NoError(t, err)
+
+		logger.Debug("hello", "key", "value")
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+		assert.Equal(t, "hello", entry["msg"])
+		assert.Equal(t, "value", entry["key"])
+	})
+
+	t.Run("rejects invalid format", func(t *testing.T) {
+		_, err := newLogger(&bytes.Buffer{}, true, "xml")
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid --log-format")
+	})
+}
@@ -1,35 +1,225 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/josegonzalez/helm-set-status/pkg/status"
 	"github.com/spf13/cobra"
 	"helm.sh/helm/v3/pkg/action"
 	"helm.sh/helm/v3/pkg/release"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 )
 
 var version = "dev"
 
-// ConfigurationFactory creates Helm action configurations.
+// ConfigurationFactory creates Helm action configurations for a namespace.
 // This can be overridden for testing.
 var ConfigurationFactory = status.NewConfiguration
 
+// configurationFactory creates a Helm action configuration for the given namespace.
+// An empty namespace selects the default resolution (HELM_NAMESPACE, then "default").
+// opts carries the cluster connection flags.
+type configurationFactory func(namespace string, opts status.ClientOptions) (*action.Configuration, error)
+
+// runOptions holds the flag values for a single invocation.
+type runOptions struct {
+	revision         int
+	fromStatuses     []string
+	notFromStatuses  []string
+	noFail           bool
+	namespace        string
+	dryRun           bool
+	clientDryRun     bool
+	printRelease     bool
+	unsafe           bool
+	output           string
+	description      string
+	keepLastDeployed bool
+	selector         string
+	wait             bool
+	timeout          time.Duration
+	interval         time.Duration
+	forceWrite       bool
+	verbose          bool
+	strict           bool
+	skipExitCode     int
+	ifOlderThan      time.Duration
+	parallelism      int
+	force            bool
+	atomic           bool
+	annotations      []string
+	appendDesc       bool
+	logFormat        string
+	kubeContext      string
+	kubeConfig       string
+	storageDriver    string
+	token            string
+	asUser           string
+	asGroups         []string
+	insecure         bool
+	allRevisions     bool
+	excludeLatest    bool
+	fromFile         string
+	fromConfigMap    string
+	interactive      bool
+	yes              bool
+	maxRetries       int
+	retryOnConflict  bool
+	retryDelay       time.Duration
+	allNamespaces    bool
+	waitMaxInterval  time.Duration
+	pendingTimeout   time.Duration
+	assumeDeployed   bool
+	glob             bool
+	record           string
+	pruneHistory     int
+	desiredFile      string
+	apply            bool
+	deployedAfter    time.Time
+	deployedBefore   time.Time
+	pendingOnly      bool
+	failOnSkip       bool
+	preHook          string
+	postHook         string
+	strictHooks      bool
+	iUnderstand      bool
+	auditLog         string
+	actor            string
+	disallowUnknown  bool
+	revisionRange    revisionRange
+	emitEvent        bool
+	statuses         []string
+	expectChart      string
+	expectVersion    string
+	supersedeOthers  bool
+	failFast         bool
+}
+
+// readGlobalFlags reads the persistent flags shared by every command into opts.
+func readGlobalFlags(cmd *cobra.Command, opts *runOptions) {
+	opts.namespace, _ = cmd.Flags().GetString("namespace")
+	opts.kubeContext, _ = cmd.Flags().GetString("kube-context")
+	opts.kubeConfig, _ = cmd.Flags().GetString("kubeconfig")
+	opts.storageDriver, _ = cmd.Flags().GetString("storage-driver")
+	opts.token, _ = cmd.Flags().GetString("token")
+	opts.asUser, _ = cmd.Flags().GetString("as")
+	opts.asGroups, _ = cmd.Flags().GetStringArray("as-group")
+	opts.insecure, _ = cmd.Flags().GetBool("insecure-skip-tls-verify")
+	opts.failOnSkip, _ = cmd.Flags().GetBool("exit-nonzero-on-skip")
+}
+
+// clientOptions returns the cluster connection settings given on the command line.
+func (o runOptions) clientOptions() status.ClientOptions {
+	return status.ClientOptions{
+		KubeContext:           o.kubeContext,
+		KubeConfig:            o.kubeConfig,
+		StorageDriver:         o.storageDriver,
+		Token:                 o.token,
+		AsUser:                o.asUser,
+		AsGroups:              o.asGroups,
+		InsecureSkipTLSVerify: o.insecure,
+	}
+}
+
 func main() {
-	if err := newRootCmd().Execute(); err != nil {
-		os.Exit(1)
+	// Cancel in-flight work, such as --wait polling, when the process is
+	// interrupted or a CI runner stops the step.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := newRootCmd().ExecuteContext(ctx)
+	stop()
+	if err != nil {
+		os.Exit(exitCode(err))
+	}
+}
+
+// commandContext returns the context the command was executed with, or
+// context.Background if it was run without one.
+func commandContext(cmd *cobra.Command) context.Context {
+	if ctx := cmd.Context(); ctx != nil {
+		return ctx
 	}
+	return context.Background()
 }
 
-var revision int
+var revision string
 var fromStatuses []string
+var notFromStatuses []string
 var noFail bool
+var pendingOnly bool
+var namespace string
+var dryRun string
+var printRelease bool
+var unsafe bool
+var output string
+var description string
+var keepLastDeployed bool
+var selector string
+var wait bool
+var timeout time.Duration
+var forceWrite bool
+var verbose bool
+var strictTransitions bool
+var skipExitCode int
+var exitNonzeroOnSkip bool
+var preHookCommand string
+var postHookCommand string
+var strictHooks bool
+var iUnderstand bool
+var auditLog string
+var actor string
+var emitEvent bool
+var disallowUnknown bool
+var expectChart string
+var expectChartVersion string
+var ifOlderThan time.Duration
+var parallelism int
+var failFast bool
+var force bool
+var atomic bool
+var annotations []string
+var appendDescription bool
+var logFormat string
+var kubeContext string
+var kubeConfig string
+var storageDriver string
+var token string
+var asUser string
+var asGroups []string
+var insecureSkipTLSVerify bool
+var allRevisions bool
+var revisionRangeFlag string
+var excludeLatest bool
+var fromFile string
+var fromConfigMap string
+var interactive bool
+var yes bool
+var maxRetries int
+var retryOnConflict bool
+var retryDelay time.Duration
+var allNamespaces bool
+var waitMaxInterval time.Duration
+var glob bool
+var record string
+var pruneHistory int
+var supersedeOthers bool
+var deployedAfter string
+var currentStatuses []string
+var deployedBefore string
 
 func newRootCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "helm-set-status RELEASE STATUS",
+		Use:   "helm-set-status (RELEASE [RELEASE...] | --selector SELECTOR) STATUS | RELEASE=STATUS [RELEASE=STATUS...] | --from-file PATH | --from-configmap [NAMESPACE/]NAME",
 		Short: "Set the status of a Helm release",
 		Long: `Set the status of a Helm release to any valid Helm status value.
 
@@ -37,72 +227,1470 @@ Valid status values:
   unknown, deployed, superseded, failed,
   uninstalling, pending-install, pending-upgrade, pending-rollback
 
-By default, the latest revision is updated. Use --revision to update a specific revision.
-Use --from to only change status if the current status matches one of the specified values.`,
-		Args:    cobra.ExactArgs(2),
-		Version: version,
-		RunE:    run,
+Pass - as STATUS to read the status from stdin.
+
+Multiple releases may be given; each is processed independently and the
+command exits non-zero if any of them fails. Use --selector instead of release
+names to apply the status to every release whose labels match, and add
+--all-namespaces to match releases in every namespace; this needs permission to
+list Helm releases cluster-wide. Add --deployed-after or --deployed-before, each an
+RFC3339 time or a duration ago such as 720h, to only match releases last
+deployed in that window, or --status to only match releases currently in one
+of the given statuses. Use --glob to treat release names as shell
+patterns such as 'frontend-*', matched against the releases in the namespace;
+without it, names are always taken literally. Use --parallelism
+to update several releases at once; results are still reported in order. A
+release that fails does not stop the others; use --fail-fast to stop at the
+first failure, reporting the remaining releases as not attempted, or
+--atomic to make the change all or nothing: if any release fails, including a
+missing release or an unmet precondition, the releases already changed are
+restored and nothing is reported as changed. When several releases are
+processed, a summary of how many were changed, unchanged, skipped, not found,
+errored, and not attempted is printed last; with --output json or yaml, the results and the
+summary are written as one object.
+
+To set a different status on each release, pass RELEASE=STATUS pairs instead,
+such as "rel1=failed rel2=deployed"; they are processed like several releases.
+
+Use --from-file to read the releases to change from a file instead, one
+"RELEASE STATUS" or "RELEASE,STATUS" pair per line. Blank lines and lines
+starting with # are ignored. Use --from-configmap to read them from the data
+of a ConfigMap instead, each key a release name and its value the status; the
+releases are changed in --namespace.
+
+In RELEASE=STATUS pairs and --from-file, a release may be given as
+NAMESPACE/RELEASE to change it in that namespace instead of --namespace.
+
+By default, the latest revision is updated. Use --revision to update a specific revision,
+or a negative offset such as --revision=-1 for the revision before the latest.
+Use --all-revisions to update every stored revision of each release instead, and
+--exclude-latest to leave the latest revision alone, or --revision-range FROM-TO
+(e.g. 2-5) to update the revisions from FROM through TO. With --all-revisions or
+--revision-range, a revision that does not meet --from or --not-from is skipped.
+Use --from to only change status if the current status matches one of the specified values.
+$HELM_SET_STATUS_FROM, a comma-separated list of statuses, sets the default for
+--from; an explicit --from or --force overrides it.
+Use --not-from to only change status if the current status matches none of the specified values.
+Use --pending-only to only change releases in a pending-* status and skip the
+rest, a guardrail for broad selectors.
+Use --if-older-than to leave releases alone that were deployed more recently than a duration.
+Use --skip-exit-code with --no-fail to exit with a distinct code (e.g. 2) when a
+release is skipped because its precondition is not met.
+Use --exit-nonzero-on-skip to exit non-zero whenever a release is skipped, by
+--no-fail, --pending-only, or --all-revisions; it takes precedence over
+--no-fail and exits with --skip-exit-code, or 2 if that is not set. A release
+that fails still exits 1.
+Use --dry-run to report the change that would be made without writing it. The
+release is still read, so a missing release or an unmet --from, --not-from, or
+transition check is reported as it would be; this is --dry-run=server. Use
+--dry-run=client to only validate the arguments without contacting the cluster.
+Use --prune-history N to delete all but the N most recent revisions of each
+release after its status is set. The revision whose status was set is never
+deleted.
+Use --print-release to print each release as stored after the change as JSON,
+saving a "helm get" call. Its values, manifest, hooks, and notes, which may
+contain secrets, are left out unless --unsafe is also set.
+Use --record FILE to save the previous status of every release changed, so that
+"helm-set-status undo FILE" can restore it.
+Use --audit-log FILE to append a JSON line for every change, with the time, the
+--actor making it, the release, and the result, as a durable record.
+Use --emit-event to write one versioned JSON line per change to stdout instead
+of the usual output, for piping into a log shipper.
+Use --pre-hook and --post-hook to run a shell command before and after each
+release's status is changed, with the change in HELM_SET_STATUS_* environment
+variables. A failing pre-hook leaves the release unchanged; a failing post-hook
+is reported as a warning, or with --strict-hooks restores the previous status.
+Use --output json or --output yaml to print a machine-readable result.
+Use --output table to print the results as a table with aligned columns.
+Use --description to record why the status was changed, and --append-description
+to add it below the existing description instead of replacing it. The description
+is a Go template that may use {{.Status}}, {{.Previous}}, {{.Revision}}, and
+{{.Time}}.
+Use --set-annotation key=value to record who changed the status, or any other
+note, as a release label that Helm stores with the release.
+Use --force to skip every precondition and transition check (--not-from,
+--if-older-than, --strict-transitions). This is a foot-gun: only use it when you
+are sure the stored release state is wrong.
+Setting the latest revision to a pending status or uninstalling prints a warning
+on stderr, since Helm then treats an operation as in progress; --force silences it.
+Use --interactive to be asked for confirmation before a deployed release is set
+to another status, when stdin is a terminal; --yes skips the question.
+Use --strict-transitions to reject changes that do not follow Helm's release lifecycle.
+Setting a failed release to deployed is refused, since it hides a real failure;
+pass --i-understand to allow it, or --force, which also skips the other checks.
+Use --disallow-unknown to refuse to set any release to unknown, a status Helm
+cannot act on.
+Releases that already have the target status are left untouched unless --force-write is set.
+A release modified by another process while its status is being set is read
+again and the change retried, up to --max-retries times. Use --retry-on-conflict
+to also retry an update the cluster rejects as a conflict, which happens on busy
+clusters, and --retry-delay to wait between retries. Other errors, such as an
+unmet precondition or a missing release, are never retried.
+Use --wait to read the release back until the new status is visible. The delay
+between reads doubles each time, with some jitter, up to --wait-max-interval.
+Use --timeout to bound how long the run, from reading the releases through
+--wait, may take, not counting an --interactive confirmation; a read or update
+blocked on a slow storage backend fails once it elapses.
+Use --verbose to log each step to stderr.`,
+		Args:              validateRootArgs,
+		ValidArgsFunction: completeRootArgs,
+		Version:           version,
+		RunE:              run,
 	}
 
-	cmd.Flags().IntVar(&revision, "revision", 0, "update a specific revision (default: latest)")
+	cmd.Flags().StringVar(&revision, "revision", "latest", "revision to update: a number, \"latest\", or an offset back from the latest such as -1")
+	cmd.Flags().BoolVar(&allRevisions, "all-revisions", false, "update every stored revision of the release instead of a single one")
+	cmd.Flags().BoolVar(&excludeLatest, "exclude-latest", false, "with --all-revisions, leave the latest revision unchanged")
+	cmd.Flags().StringVar(&revisionRangeFlag, "revision-range", "", "update the revisions from FROM through TO inclusive, given as FROM-TO (e.g. 2-5)")
 	cmd.Flags().StringSliceVar(&fromStatuses, "from", nil, "only change status if current status is one of these values (can specify multiple)")
-	cmd.Flags().BoolVar(&noFail, "no-fail", false, "exit 0 instead of 1 when --from precondition is not met")
+	cmd.Flags().StringSliceVar(&notFromStatuses, "not-from", nil, "only change status if current status is none of these values (can specify multiple)")
+	cmd.Flags().BoolVar(&pendingOnly, "pending-only", false, "only change releases in a pending-* status; skip every other release")
+	cmd.Flags().BoolVar(&noFail, "no-fail", false, "exit 0 instead of 1 when a --from or --not-from precondition is not met")
+	cmd.Flags().DurationVar(&ifOlderThan, "if-older-than", 0, "only change status if the release was last deployed at least this long ago (e.g. 30m)")
+	cmd.Flags().IntVar(&skipExitCode, "skip-exit-code", 0, "exit code to use when --no-fail skips a release")
+	cmd.Flags().StringVar(&dryRun, "dry-run", dryRunNone, "report the intended change without writing it: \"server\" reads the release and checks preconditions, \"client\" only validates the arguments")
+	cmd.Flags().Lookup("dry-run").NoOptDefVal = dryRunServer
+	cmd.Flags().BoolVar(&printRelease, "print-release", false, "after the change, print the release as stored as JSON, without its values, manifest, and notes")
+	cmd.Flags().BoolVar(&unsafe, "unsafe", false, "with --print-release, include the release's values, manifest, hooks, notes, and full chart, which may contain secrets")
+	cmd.Flags().StringVar(&record, "record", "", "write the previous status of every changed release to this file, for the undo command")
+	cmd.Flags().StringVar(&auditLog, "audit-log", "", "append a JSON line recording each change, including skipped and failed ones, to this file")
+	cmd.Flags().BoolVar(&emitEvent, "emit-event", false, "write one JSON line per change to stdout instead of the usual output, for piping into a log shipper")
+	cmd.Flags().StringVar(&actor, "actor", "", "who is making the changes, for --audit-log (default: $HELM_SET_STATUS_ACTOR or $USER)")
+	cmd.Flags().StringVar(&preHookCommand, "pre-hook", "", "shell command to run before each release's status is changed; if it fails, the release is not changed")
+	cmd.Flags().StringVar(&postHookCommand, "post-hook", "", "shell command to run after each release's status is changed; a failure is reported as a warning")
+	cmd.Flags().BoolVar(&strictHooks, "strict-hooks", false, "when --post-hook fails, restore the release's previous status and report it as failed")
+	cmd.Flags().StringVar(&description, "description", "", "description to record on the release (default: \"status set to <STATUS>\")")
+	cmd.Flags().BoolVar(&appendDescription, "append-description", false, "keep the existing description and add the new one on a timestamped line")
+	cmd.Flags().StringArrayVar(&annotations, "set-annotation", nil, "record key=value as a release label when the status is written (can specify multiple)")
+	cmd.Flags().BoolVar(&keepLastDeployed, "keep-last-deployed", false, "leave the release's last-deployed timestamp unchanged")
+	cmd.Flags().IntVar(&pruneHistory, "prune-history", 0, "after setting the status, delete all but this many of the most recent revisions (0 keeps every revision)")
+	cmd.Flags().BoolVar(&supersedeOthers, "supersede-others", false, "after setting a revision to deployed, set every other deployed revision of the release to superseded")
+	cmd.Flags().BoolVar(&atomic, "atomic", false, "when several releases are given, restore every release already changed if any change fails")
+	cmd.Flags().IntVar(&parallelism, "parallelism", 1, "number of releases to update at once when several are given")
+	cmd.Flags().BoolVar(&failFast, "fail-fast", false, "when several releases are given, stop at the first release that fails instead of attempting the rest")
+	cmd.Flags().StringVar(&fromFile, "from-file", "", "read RELEASE STATUS pairs, one per line, from this file instead of the arguments")
+	cmd.Flags().StringVar(&fromConfigMap, "from-configmap", "", "read release names and statuses from the data of this ConfigMap, given as NAMESPACE/NAME or NAME, instead of the arguments")
+	cmd.Flags().StringVarP(&selector, "selector", "l", "", "apply to all releases matching this label selector (e.g. app=frontend)")
+	cmd.Flags().StringVar(&deployedAfter, "deployed-after", "", "with --selector, only match releases last deployed after this time (RFC3339, or a duration such as 24h meaning that long ago)")
+	cmd.Flags().StringVar(&deployedBefore, "deployed-before", "", "with --selector, only match releases last deployed before this time (RFC3339, or a duration such as 720h meaning that long ago)")
+	cmd.Flags().StringSliceVar(&currentStatuses, "status", nil, "with --selector, only match releases currently in one of these statuses (can specify multiple)")
+	cmd.Flags().BoolVar(&glob, "glob", false, "treat release names as shell patterns (e.g. 'frontend-*') matched against the releases in the namespace")
+	cmd.Flags().BoolVarP(&allNamespaces, "all-namespaces", "A", false, "with --selector, match releases in every namespace instead of one")
+	cmd.Flags().BoolVar(&force, "force", false, "skip all precondition and transition checks (cannot be combined with --from)")
+	cmd.Flags().BoolVar(&interactive, "interactive", false, "ask for confirmation before taking a deployed release out of service")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "answer yes to the --interactive confirmation")
+	cmd.Flags().BoolVar(&strictTransitions, "strict-transitions", false, "reject status changes that do not follow Helm's release lifecycle")
+	cmd.Flags().BoolVar(&iUnderstand, "i-understand", false, "allow setting a failed release to deployed, which is refused otherwise, without skipping the other checks like --force")
+	cmd.Flags().BoolVar(&disallowUnknown, "disallow-unknown", false, "refuse to set a release to unknown, which Helm cannot act on")
+	cmd.Flags().StringVar(&expectChart, "expect-chart", "", "refuse to change a release unless it was installed from the chart with this name, even with --force")
+	cmd.Flags().StringVar(&expectChartVersion, "expect-chart-version", "", "refuse to change a release unless its chart version matches this version or semver constraint (e.g. 1.2.0 or '>=1.2.0, <2.0.0'), even with --force")
+	cmd.Flags().BoolVar(&forceWrite, "force-write", false, "write the release even if it already has the target status")
+	cmd.Flags().IntVar(&maxRetries, "max-retries", 3, "times to retry when the release is modified by another process during the update")
+	cmd.Flags().BoolVar(&retryOnConflict, "retry-on-conflict", false, "also retry, up to --max-retries times, an update the cluster rejects as a conflict")
+	cmd.Flags().DurationVar(&retryDelay, "retry-delay", 0, "how long to wait before each retry (e.g. 500ms)")
+	cmd.Flags().BoolVar(&wait, "wait", false, "after updating, wait until the new status can be read back")
+	cmd.Flags().DurationVar(&waitMaxInterval, "wait-max-interval", status.DefaultWaitMaxInterval, "longest delay between --wait read-backs, which back off exponentially")
+	cmd.Flags().DurationVar(&timeout, "timeout", 5*time.Minute, "how long reading and changing the releases may take, including --wait, before failing")
+	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "log each step to stderr")
+	cmd.Flags().StringVar(&logFormat, "log-format", logFormatText, "format of --verbose logs: text or json")
+	cmd.Flags().StringVarP(&output, "output", "o", outputText, "output format: text, table, json, yaml, or github")
+	cmd.PersistentFlags().StringVarP(&namespace, "namespace", "n", "", "namespace of the release (default: $HELM_NAMESPACE or \"default\")")
+	cmd.PersistentFlags().StringVar(&kubeConfig, "kubeconfig", "", "path to the kubeconfig file (default: $KUBECONFIG or ~/.kube/config)")
+	cmd.PersistentFlags().StringVar(&storageDriver, "storage-driver", "", "release storage backend: secret, configmap, sql, or memory (default: $HELM_DRIVER or \"secret\")")
+	cmd.PersistentFlags().StringVar(&token, "token", "", "bearer token to authenticate with (default: $HELM_KUBETOKEN or the kubeconfig)")
+	cmd.PersistentFlags().StringVar(&asUser, "as", "", "user to impersonate (default: $HELM_KUBEASUSER)")
+	cmd.PersistentFlags().StringArrayVar(&asGroups, "as-group", nil, "group to impersonate, can be repeated (default: $HELM_KUBEASGROUPS)")
+	cmd.PersistentFlags().BoolVar(&insecureSkipTLSVerify, "insecure-skip-tls-verify", false, "do not verify the API server's certificate; insecure, for throwaway clusters only")
+	cmd.PersistentFlags().StringVar(&kubeContext, "kube-context", "", "kubeconfig context to use (default: $HELM_KUBECONTEXT or the current context)")
+	cmd.PersistentFlags().BoolVar(&exitNonzeroOnSkip, "exit-nonzero-on-skip", false, "exit with --skip-exit-code, or 2 if it is not set, when a release is skipped, even with --no-fail")
+
+	_ = cmd.RegisterFlagCompletionFunc("from", completeStatusFlag)
+	_ = cmd.RegisterFlagCompletionFunc("not-from", completeStatusFlag)
+	_ = cmd.RegisterFlagCompletionFunc("status", completeStatusFlag)
+
+	cmd.CompletionOptions.DisableDefaultCmd = true
+	cmd.AddCommand(newCheckCmd())
+	cmd.AddCommand(newCompletionCmd())
+	cmd.AddCommand(newDiffCmd())
+	cmd.AddCommand(newGetCmd())
+	cmd.AddCommand(newHistoryCmd())
+	cmd.AddCommand(newListCmd())
+	cmd.AddCommand(newReconcileCmd())
+	cmd.AddCommand(newUndoCmd())
+	cmd.AddCommand(newUnstickCmd())
+	cmd.AddCommand(newVersionCmd())
+	cmd.AddCommand(newWatchCmd())
 
 	return cmd
 }
 
+// validateRootArgs requires a STATUS argument, preceded by at least one
+// release name unless --selector is set, or one or more RELEASE=STATUS pairs.
+// With --from-file or --from-configmap, no arguments are accepted.
+func validateRootArgs(cmd *cobra.Command, args []string) error {
+	sel, _ := cmd.Flags().GetString("selector")
+	for _, flag := range []string{"from-file", "from-configmap"} {
+		if value, _ := cmd.Flags().GetString(flag); value == "" {
+			continue
+		}
+		if sel != "" {
+			return fmt.Errorf("--%s cannot be combined with --selector", flag)
+		}
+		if len(args) != 0 {
+			return fmt.Errorf("--%s cannot be combined with release names or STATUS; got %d args", flag, len(args))
+		}
+		return nil
+	}
+	if hasReleaseStatusPairs(args) {
+		if sel != "" {
+			return errors.New("RELEASE=STATUS pairs cannot be combined with --selector")
+		}
+		return nil
+	}
+	if sel != "" {
+		if len(args) != 1 {
+			return fmt.Errorf("--selector cannot be combined with release names; expected only STATUS, got %d args", len(args))
+		}
+		return nil
+	}
+	return cobra.MinimumNArgs(2)(cmd, args)
+}
+
 func run(cmd *cobra.Command, args []string) error {
-	rev, _ := cmd.Flags().GetInt("revision")
-	from, _ := cmd.Flags().GetStringSlice("from")
-	nf, _ := cmd.Flags().GetBool("no-fail")
-	return runWithConfigFactory(cmd, args, rev, from, nf, ConfigurationFactory)
+	output, _ := cmd.Flags().GetString("output")
+	return reportError(cmd, cmd.ErrOrStderr(), output, runFromFlags(cmd, args))
+}
+
+// runFromFlags reads the command-line flags and sets the status.
+func runFromFlags(cmd *cobra.Command, args []string) error {
+	var opts runOptions
+	var err error
+	revisionFlag, _ := cmd.Flags().GetString("revision")
+	if opts.revision, err = parseRevision(revisionFlag); err != nil {
+		return err
+	}
+	rangeFlag, _ := cmd.Flags().GetString("revision-range")
+	if opts.revisionRange, err = parseRevisionRange(rangeFlag); err != nil {
+		return err
+	}
+	opts.allRevisions, _ = cmd.Flags().GetBool("all-revisions")
+	opts.excludeLatest, _ = cmd.Flags().GetBool("exclude-latest")
+	opts.fromStatuses, _ = cmd.Flags().GetStringSlice("from")
+	opts.notFromStatuses, _ = cmd.Flags().GetStringSlice("not-from")
+	opts.noFail, _ = cmd.Flags().GetBool("no-fail")
+	opts.pendingOnly, _ = cmd.Flags().GetBool("pending-only")
+	opts.skipExitCode, _ = cmd.Flags().GetInt("skip-exit-code")
+	opts.ifOlderThan, _ = cmd.Flags().GetDuration("if-older-than")
+	readGlobalFlags(cmd, &opts)
+	dryRunFlag, _ := cmd.Flags().GetString("dry-run")
+	if opts.dryRun, opts.clientDryRun, err = parseDryRun(dryRunFlag); err != nil {
+		return err
+	}
+	opts.record, _ = cmd.Flags().GetString("record")
+	opts.auditLog, _ = cmd.Flags().GetString("audit-log")
+	opts.actor, _ = cmd.Flags().GetString("actor")
+	opts.emitEvent, _ = cmd.Flags().GetBool("emit-event")
+	opts.preHook, _ = cmd.Flags().GetString("pre-hook")
+	opts.postHook, _ = cmd.Flags().GetString("post-hook")
+	opts.strictHooks, _ = cmd.Flags().GetBool("strict-hooks")
+	opts.printRelease, _ = cmd.Flags().GetBool("print-release")
+	opts.unsafe, _ = cmd.Flags().GetBool("unsafe")
+	opts.output, _ = cmd.Flags().GetString("output")
+	opts.description, _ = cmd.Flags().GetString("description")
+	opts.keepLastDeployed, _ = cmd.Flags().GetBool("keep-last-deployed")
+	opts.pruneHistory, _ = cmd.Flags().GetInt("prune-history")
+	opts.supersedeOthers, _ = cmd.Flags().GetBool("supersede-others")
+	if opts.pruneHistory < 0 {
+		return fmt.Errorf("--prune-history must not be negative, got %d", opts.pruneHistory)
+	}
+	opts.annotations, _ = cmd.Flags().GetStringArray("set-annotation")
+	opts.appendDesc, _ = cmd.Flags().GetBool("append-description")
+	opts.selector, _ = cmd.Flags().GetString("selector")
+	opts.allNamespaces, _ = cmd.Flags().GetBool("all-namespaces")
+	opts.statuses, _ = cmd.Flags().GetStringSlice("status")
+	now := time.Now()
+	for _, f := range []struct {
+		name string
+		dest *time.Time
+	}{
+		{"deployed-after", &opts.deployedAfter},
+		{"deployed-before", &opts.deployedBefore},
+	} {
+		value, _ := cmd.Flags().GetString(f.name)
+		if *f.dest, err = parseDeployedTime(f.name, value, now); err != nil {
+			return err
+		}
+	}
+	opts.glob, _ = cmd.Flags().GetBool("glob")
+	opts.fromFile, _ = cmd.Flags().GetString("from-file")
+	opts.fromConfigMap, _ = cmd.Flags().GetString("from-configmap")
+	opts.parallelism, _ = cmd.Flags().GetInt("parallelism")
+	opts.atomic, _ = cmd.Flags().GetBool("atomic")
+	opts.failFast, _ = cmd.Flags().GetBool("fail-fast")
+	if opts.parallelism < 1 {
+		return fmt.Errorf("--parallelism must be at least 1, got %d", opts.parallelism)
+	}
+	opts.maxRetries, _ = cmd.Flags().GetInt("max-retries")
+	if opts.maxRetries < 0 {
+		return fmt.Errorf("--max-retries must not be negative, got %d", opts.maxRetries)
+	}
+	opts.retryOnConflict, _ = cmd.Flags().GetBool("retry-on-conflict")
+	if opts.retryOnConflict && opts.maxRetries == 0 {
+		return errors.New("--retry-on-conflict requires --max-retries of at least 1")
+	}
+	opts.retryDelay, _ = cmd.Flags().GetDuration("retry-delay")
+	if opts.retryDelay < 0 {
+		return fmt.Errorf("--retry-delay must not be negative, got %s", opts.retryDelay)
+	}
+	opts.wait, _ = cmd.Flags().GetBool("wait")
+	opts.waitMaxInterval, _ = cmd.Flags().GetDuration("wait-max-interval")
+	if opts.waitMaxInterval < 0 {
+		return fmt.Errorf("--wait-max-interval must not be negative, got %s", opts.waitMaxInterval)
+	}
+	opts.forceWrite, _ = cmd.Flags().GetBool("force-write")
+	opts.verbose, _ = cmd.Flags().GetBool("verbose")
+	opts.strict, _ = cmd.Flags().GetBool("strict-transitions")
+	opts.iUnderstand, _ = cmd.Flags().GetBool("i-understand")
+	opts.disallowUnknown, _ = cmd.Flags().GetBool("disallow-unknown")
+	opts.expectChart, _ = cmd.Flags().GetString("expect-chart")
+	opts.expectVersion, _ = cmd.Flags().GetString("expect-chart-version")
+	opts.interactive, _ = cmd.Flags().GetBool("interactive")
+	opts.yes, _ = cmd.Flags().GetBool("yes")
+	opts.force, _ = cmd.Flags().GetBool("force")
+	if !cmd.Flags().Changed("from") && !opts.force {
+		if opts.fromStatuses, err = envFromStatuses(); err != nil {
+			return err
+		}
+	}
+	opts.logFormat, _ = cmd.Flags().GetString("log-format")
+	opts.timeout, _ = cmd.Flags().GetDuration("timeout")
+	// --timeout also bounds --wait, which would otherwise not read back at all
+	if opts.wait && opts.timeout <= 0 {
+		return fmt.Errorf("--wait requires a positive --timeout, got %s", opts.timeout)
+	}
+	return runWithConfigFactory(cmd, args, opts, ConfigurationFactory)
+}
+
+func runWithConfigFactory(cmd *cobra.Command, args []string, opts runOptions, newConfig configurationFactory) error {
+	run, err := runChanges(cmd, args, opts, newConfig)
+	if err != nil {
+		return err
+	}
+	if opts.emitEvent {
+		if err := writeEvents(cmd.OutOrStdout(), run.results, opts.dryRun); err != nil {
+			return err
+		}
+		return runExit(cmd, opts, run)
+	}
+	if opts.printRelease {
+		attachStoredReleases(run.results, opts.unsafe)
+	}
+
+	write := writeResults
+	if !run.batch {
+		write = func(w io.Writer, format string, results []changeResult) error {
+			return writeResult(w, format, results[0])
+		}
+	} else if opts.allNamespaces || spansNamespaces(run.results) {
+		write = writeResultsByNamespace
+	}
+	if err := write(cmd.OutOrStdout(), opts.output, run.results); err != nil {
+		return err
+	}
+	if opts.printRelease && !isStructured(opts.output) {
+		if err := writeStoredReleases(cmd.OutOrStdout(), run.results); err != nil {
+			return err
+		}
+	}
+	return runExit(cmd, opts, run)
+}
+
+// runExit returns the error the run should exit with once its results have
+// been written: one counting the failed changes of a batch, or else any
+// --skip-exit-code.
+func runExit(cmd *cobra.Command, opts runOptions, run runResult) error {
+	if run.failed > 0 {
+		if opts.allRevisions || opts.revisionRange.isSet() {
+			return fmt.Errorf("failed to set status on %d of %d revisions", run.failed, len(run.results))
+		}
+		return fmt.Errorf("failed to set status on %d of %d releases", run.failed, len(run.results))
+	}
+	return skipExit(cmd, opts, run.results)
+}
+
+// runResult is the outcome of runChanges, with one result per change.
+type runResult struct {
+	results []changeResult
+	// batch is true when the arguments named more than one change, so that
+	// failures are recorded in results rather than returned.
+	batch bool
+	// failed counts the results of a batch that errored.
+	failed int
+}
+
+// runChanges sets the statuses named by args and opts and returns the outcome
+// of each change, leaving the caller to report them. A skipped change, such as
+// one whose preconditions are not met under --no-fail, is returned as a
+// result rather than an error.
+func runChanges(cmd *cobra.Command, args []string, opts runOptions, newConfig configurationFactory) (runResult, error) {
+	if err := validateOutputFormat(opts.output); err != nil {
+		return runResult{}, err
+	}
+
+	logger, err := newLogger(cmd.ErrOrStderr(), opts.verbose, opts.logFormat)
+	if err != nil {
+		return runResult{}, err
+	}
+
+	// --timeout covers reading the releases as well as changing them
+	var deadline time.Time
+	if opts.timeout > 0 {
+		deadline = time.Now().Add(opts.timeout)
+	}
+	ctx, cancel := contextWithDeadline(commandContext(cmd), deadline)
+	defer func() { cancel() }()
+
+	var releaseNames []string
+	var targetStatus release.Status
+	var entries []releaseStatus
+	pairs := opts.fromFile == "" && opts.fromConfigMap == "" && hasReleaseStatusPairs(args)
+	if opts.fromFile != "" && opts.fromConfigMap != "" {
+		return runResult{}, errors.New("--from-file cannot be combined with --from-configmap")
+	}
+	if opts.fromFile != "" {
+		if opts.atomic {
+			return runResult{}, errors.New("--from-file cannot be combined with --atomic")
+		}
+		if entries, err = readReleaseStatusFile("--from-file", opts.fromFile); err != nil {
+			return runResult{}, err
+		}
+	} else if opts.fromConfigMap != "" {
+		if opts.atomic {
+			return runResult{}, errors.New("--from-configmap cannot be combined with --atomic")
+		}
+		if opts.glob {
+			return runResult{}, errors.New("--from-configmap cannot be combined with --glob")
+		}
+		if entries, err = readReleaseStatusConfigMap(ctx, opts.fromConfigMap, opts, ClientsetFactory); err != nil {
+			return runResult{}, err
+		}
+	} else if pairs {
+		if opts.atomic {
+			return runResult{}, errors.New("RELEASE=STATUS pairs cannot be combined with --atomic")
+		}
+		if opts.glob {
+			return runResult{}, errors.New("RELEASE=STATUS pairs cannot be combined with --glob")
+		}
+		if entries, err = parseReleaseStatusPairs(args); err != nil {
+			return runResult{}, err
+		}
+	} else {
+		releaseNames = args[:len(args)-1]
+		statusStr := args[len(args)-1]
+
+		// A status of "-" is read from stdin
+		if statusStr == "-" {
+			statusStr, err = readStatus(cmd.InOrStdin())
+			if err != nil {
+				return runResult{}, err
+			}
+		}
+
+		// Parse and validate status
+		targetStatus, err = status.ParseStatus(statusStr)
+		if err != nil {
+			return runResult{}, fmt.Errorf("%w\nValid statuses: %s", err, status.ValidStatusesString())
+		}
+	}
+	if opts.disallowUnknown {
+		if err := disallowUnknownStatus(targetStatus, entries); err != nil {
+			return runResult{}, err
+		}
+	}
+
+	// Parse and validate --from and --not-from statuses
+	allowedFromStatuses, err := parseStatusFlag("--from", opts.fromStatuses)
+	if err != nil {
+		return runResult{}, err
+	}
+	disallowedFromStatuses, err := parseStatusFlag("--not-from", opts.notFromStatuses)
+	if err != nil {
+		return runResult{}, err
+	}
+	if opts.pendingOnly {
+		if allowedFromStatuses, err = pendingOnlyStatuses(allowedFromStatuses); err != nil {
+			return runResult{}, err
+		}
+	}
+	pre := preconditions{allowed: allowedFromStatuses, disallowed: disallowedFromStatuses}
+	matchStatuses, err := parseStatusFlag("--status", opts.statuses)
+	if err != nil {
+		return runResult{}, err
+	}
+	if len(matchStatuses) > 0 && opts.selector == "" {
+		return runResult{}, errors.New("--status requires --selector; use --from to set a named release only if it is in a status")
+	}
+
+	labels, err := parseAnnotations(opts.annotations)
+	if err != nil {
+		return runResult{}, err
+	}
+	if err := status.ValidateDescription(opts.description); err != nil {
+		return runResult{}, fmt.Errorf("--description: %w", err)
+	}
+	if opts.expectVersion != "" {
+		if _, err := status.ParseChartVersionConstraint(opts.expectVersion); err != nil {
+			return runResult{}, fmt.Errorf("--expect-chart-version: %w", err)
+		}
+	}
+	if opts.force && opts.pendingOnly {
+		return runResult{}, errors.New("--force cannot be combined with --pending-only")
+	}
+	if opts.force && len(pre.allowed) > 0 {
+		return runResult{}, errors.New("--force cannot be combined with --from")
+	}
+	if opts.atomic && opts.noFail {
+		return runResult{}, errors.New("--atomic cannot be combined with --no-fail")
+	}
+	if opts.atomic && opts.pendingOnly {
+		return runResult{}, errors.New("--atomic cannot be combined with --pending-only")
+	}
+	if opts.atomic && opts.parallelism > 1 {
+		return runResult{}, errors.New("--atomic cannot be combined with --parallelism")
+	}
+	if opts.failFast && opts.parallelism > 1 {
+		return runResult{}, errors.New("--fail-fast cannot be combined with --parallelism")
+	}
+	if opts.excludeLatest && !opts.allRevisions {
+		return runResult{}, errors.New("--exclude-latest requires --all-revisions")
+	}
+	if opts.strictHooks && opts.postHook == "" {
+		return runResult{}, errors.New("--strict-hooks requires --post-hook")
+	}
+	if opts.strictHooks && opts.atomic {
+		return runResult{}, errors.New("--strict-hooks cannot be combined with --atomic")
+	}
+	if opts.allRevisions && opts.revision != 0 {
+		return runResult{}, errors.New("--all-revisions cannot be combined with --revision")
+	}
+	if opts.revisionRange.isSet() && (opts.allRevisions || opts.revision != 0) {
+		return runResult{}, errors.New("--revision-range cannot be combined with --revision or --all-revisions")
+	}
+	if opts.revisionRange.isSet() && opts.pruneHistory > 0 {
+		return runResult{}, errors.New("--prune-history cannot be combined with --revision-range")
+	}
+	if opts.pruneHistory > 0 && (opts.allRevisions || opts.atomic) {
+		return runResult{}, errors.New("--prune-history cannot be combined with --all-revisions or --atomic")
+	}
+	if opts.supersedeOthers && (opts.allRevisions || opts.revisionRange.isSet() || opts.atomic) {
+		return runResult{}, errors.New("--supersede-others cannot be combined with --all-revisions, --revision-range, or --atomic")
+	}
+	if opts.record != "" && opts.dryRun {
+		return runResult{}, errors.New("--record cannot be combined with --dry-run")
+	}
+	if opts.emitEvent && opts.output != "" && opts.output != outputText {
+		return runResult{}, fmt.Errorf("--emit-event cannot be combined with --output %s", opts.output)
+	}
+	if opts.emitEvent && opts.printRelease {
+		return runResult{}, errors.New("--emit-event cannot be combined with --print-release")
+	}
+	if opts.unsafe && !opts.printRelease {
+		return runResult{}, errors.New("--unsafe requires --print-release")
+	}
+	if opts.glob && (opts.selector != "" || opts.fromFile != "") {
+		return runResult{}, errors.New("--glob cannot be combined with --selector or --from-file")
+	}
+	if !opts.deployedAfter.IsZero() || !opts.deployedBefore.IsZero() {
+		if opts.selector == "" {
+			return runResult{}, errors.New("--deployed-after and --deployed-before require --selector")
+		}
+		if !opts.deployedAfter.IsZero() && !opts.deployedBefore.IsZero() && !opts.deployedAfter.Before(opts.deployedBefore) {
+			return runResult{}, errors.New("--deployed-after must be earlier than --deployed-before")
+		}
+	}
+	if opts.allNamespaces {
+		if opts.selector == "" {
+			return runResult{}, errors.New("--all-namespaces requires --selector")
+		}
+		if opts.namespace != "" {
+			return runResult{}, errors.New("--all-namespaces cannot be combined with --namespace")
+		}
+		if opts.atomic {
+			return runResult{}, errors.New("--all-namespaces cannot be combined with --atomic")
+		}
+	}
+
+	if opts.clientDryRun {
+		switch {
+		case opts.selector != "":
+			return runResult{}, errors.New("--dry-run=client cannot be combined with --selector, which reads releases from the cluster")
+		case opts.glob:
+			return runResult{}, errors.New("--dry-run=client cannot be combined with --glob, which reads releases from the cluster")
+		case opts.allRevisions:
+			return runResult{}, errors.New("--dry-run=client cannot be combined with --all-revisions, which reads releases from the cluster")
+		case opts.revisionRange.isSet():
+			return runResult{}, errors.New("--dry-run=client cannot be combined with --revision-range, which reads releases from the cluster")
+		case opts.pruneHistory > 0:
+			return runResult{}, errors.New("--dry-run=client cannot be combined with --prune-history, which reads releases from the cluster")
+		case opts.supersedeOthers:
+			return runResult{}, errors.New("--dry-run=client cannot be combined with --supersede-others, which reads releases from the cluster")
+		}
+	}
+
+	// Entries come from a list, rather than from release names and a STATUS
+	listed := opts.fromFile != "" || opts.fromConfigMap != "" || pairs
+	batch := len(releaseNames) > 1 || opts.allRevisions || opts.revisionRange.isSet() || listed || opts.selector != "" || opts.glob
+	if opts.clientDryRun {
+		if !listed {
+			entries = releaseEntries(releaseNames, targetStatus)
+		}
+		return runResult{results: clientDryRunResults(entries, opts), batch: batch}, nil
+	}
+
+	var cfg *action.Configuration
+	var changes []change
+	err = runUntilDone(ctx, "reading releases", func() error {
+		var err error
+		cfg, changes, err = listChanges(cmd.OutOrStdout(), releaseNames, entries, listed, targetStatus, matchStatuses, opts, newConfig)
+		return err
+	})
+	if err != nil {
+		return runResult{}, err
+	}
+
+	if opts.interactive && !opts.yes && !opts.dryRun && stdinIsTerminal(cmd.InOrStdin()) {
+		// The time spent waiting for confirmation does not count toward
+		// --timeout
+		asked := time.Now()
+		if err := confirmChanges(changes, cmd.InOrStdin(), cmd.ErrOrStderr()); err != nil {
+			return runResult{}, err
+		}
+		if !deadline.IsZero() {
+			deadline = deadline.Add(time.Since(asked))
+		}
+		cancel()
+		ctx, cancel = contextWithDeadline(commandContext(cmd), deadline)
+	}
+
+	// Set the status of each target independently, or all or nothing
+	setOpts := setStatusOptions(pre, labels, opts, logger)
+	var results []changeResult
+	var errs []error
+	if opts.atomic {
+		targets := make([]status.Target, len(changes))
+		for i, c := range changes {
+			targets[i] = c.target
+		}
+		results, err = setReleaseStatusesAtomic(ctx, cfg, targets, targetStatus, setOpts, status.ResolveNamespace(opts.namespace))
+		if err != nil {
+			if recordErr := recordResults(opts, results); recordErr != nil {
+				return runResult{}, errors.Join(err, recordErr)
+			}
+			return runResult{}, err
+		}
+		errs = make([]error, len(results))
+	} else {
+		results, errs = setReleaseStatuses(ctx, changes, setOpts, opts)
+	}
+	warnUnrecognizedStatuses(cmd.ErrOrStderr(), results)
+	failed := 0
+	for i, err := range errs {
+		if err != nil {
+			if !batch {
+				return runResult{}, recordFailure(cmd.OutOrStdout(), opts, results[i], err)
+			}
+			failed++
+			results[i].Result = resultError
+			results[i].Reason = err.Error()
+		}
+	}
+	if opts.postHook != "" {
+		for i, err := range runPostHooks(ctx, cmd.ErrOrStderr(), changes, results, opts) {
+			if err == nil {
+				continue
+			}
+			if !batch {
+				return runResult{}, recordFailure(cmd.OutOrStdout(), opts, results[i], err)
+			}
+			failed++
+			results[i].Changed = false
+			results[i].Result = resultError
+			results[i].Reason = err.Error()
+		}
+	}
+
+	if !opts.force {
+		warnInProgressStatuses(cmd.ErrOrStderr(), changes, results)
+	}
+	if err := recordResults(opts, results); err != nil {
+		return runResult{}, err
+	}
+	return runResult{results: results, batch: batch, failed: failed}, nil
+}
+
+// recordResults writes results to the --record file and appends them to the
+// --audit-log, when those are set.
+func recordResults(opts runOptions, results []changeResult) error {
+	if opts.record != "" {
+		// runChanges has validated --set-annotation
+		labels, _ := parseAnnotations(opts.annotations)
+		if err := writeChangeRecord(opts.record, results, labels); err != nil {
+			return err
+		}
+	}
+	if opts.auditLog != "" {
+		if err := appendAuditLog(opts.auditLog, opts.actor, results); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skipExit returns an exitCodeError carrying --skip-exit-code if it is set
+// and any release was skipped. With --exit-nonzero-on-skip, a skip exits with
+// defaultSkipExitCode when --skip-exit-code is not set. The error is not
+// printed, since the skip has already been reported.
+func skipExit(cmd *cobra.Command, opts runOptions, results []changeResult) error {
+	code := opts.skipExitCode
+	if code == 0 && opts.failOnSkip {
+		code = defaultSkipExitCode
+	}
+	if code == 0 {
+		return nil
+	}
+	for _, res := range results {
+		if res.Result == resultSkipped {
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+			return &exitCodeError{code: code}
+		}
+	}
+	return nil
+}
+
+// warnUnrecognizedStatuses warns on w about each release whose stored status
+// is not one Helm defines, which is treated as unknown.
+func warnUnrecognizedStatuses(w io.Writer, results []changeResult) {
+	for _, res := range results {
+		if res.StoredStatus == "" {
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "Warning: release %q has unrecognized stored status %q, treating it as %q\n",
+			res.Release, res.StoredStatus, release.StatusUnknown)
+	}
+}
+
+// warnInProgressStatuses warns on w about each change that gives the latest
+// revision of a release a status Helm uses while an operation is in progress.
+// changes and results must be in the same order.
+func warnInProgressStatuses(w io.Writer, changes []change, results []changeResult) {
+	for i, res := range results {
+		if res.Result != resultChanged && res.Result != resultWouldChange {
+			continue
+		}
+		st := changes[i].status
+		if !slices.Contains(status.PendingStatuses, st) && st != release.StatusUninstalling {
+			continue
+		}
+		latest, err := changes[i].cfg.Releases.Last(res.Release)
+		if err != nil || latest.Version != res.storedRevision {
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "Warning: release %q: %q on the latest revision tells Helm an operation is in progress, "+
+			"so later helm upgrade, rollback, and uninstall commands may fail until it is set to deployed or failed. "+
+			"Use --force to silence this warning.\n", res.Release, st)
+	}
+}
+
+// readStatus reads a status value from r, ignoring surrounding whitespace.
+func readStatus(r io.Reader) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to read status from stdin: %w", err)
+	}
+	s := strings.TrimSpace(string(data))
+	if s == "" {
+		return "", errors.New("no status provided on stdin")
+	}
+	return s, nil
+}
+
+// revisionRange is a span of revisions given to --revision-range. The zero
+// value means the flag was not set.
+type revisionRange struct {
+	from int
+	to   int
+}
+
+func (r revisionRange) isSet() bool {
+	return r.from != 0
 }
 
-func runWithConfigFactory(cmd *cobra.Command, args []string, rev int, fromStatuses []string, noFail bool, configFactory func() (*action.Configuration, error)) error {
-	releaseName := args[0]
-	statusStr := args[1]
+// parseRevisionRange parses a --revision-range value of the form FROM-TO,
+// where FROM and TO are revision numbers and FROM is not after TO.
+func parseRevisionRange(s string) (revisionRange, error) {
+	if s == "" {
+		return revisionRange{}, nil
+	}
+	fromStr, toStr, ok := strings.Cut(s, "-")
+	from, fromErr := strconv.Atoi(fromStr)
+	to, toErr := strconv.Atoi(toStr)
+	if !ok || fromErr != nil || toErr != nil || from < 1 || to < 1 {
+		return revisionRange{}, fmt.Errorf("invalid --revision-range %q: expected FROM-TO, two revision numbers such as 2-5", s)
+	}
+	if from > to {
+		return revisionRange{}, fmt.Errorf("invalid --revision-range %q: revision %d is after revision %d", s, from, to)
+	}
+	return revisionRange{from: from, to: to}, nil
+}
 
-	// Parse and validate status
-	targetStatus, err := status.ParseStatus(statusStr)
+// parseRevision parses a --revision value. "latest" selects the latest
+// revision, a positive number selects that revision, and a negative number
+// counts back from the latest.
+func parseRevision(s string) (int, error) {
+	if s == "" || strings.EqualFold(s, "latest") {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
 	if err != nil {
-		return fmt.Errorf("%w\nValid statuses: %s", err, status.ValidStatusesString())
+		return 0, fmt.Errorf("invalid --revision %q: expected a revision number, \"latest\", or a negative offset such as -1", s)
+	}
+	return n, nil
+}
+
+// Values of --dry-run.
+const (
+	dryRunNone   = "none"
+	dryRunClient = "client"
+	dryRunServer = "server"
+)
+
+// parseDryRun parses a --dry-run value. A server dry run reads the release
+// and checks preconditions without writing; a client dry run is also a dry
+// run, but does not contact the cluster at all. "true" and "false" are
+// accepted for compatibility with the flag's boolean form.
+func parseDryRun(value string) (dryRun, client bool, err error) {
+	switch strings.ToLower(value) {
+	case dryRunNone, "false", "":
+		return false, false, nil
+	case dryRunServer, "true":
+		return true, false, nil
+	case dryRunClient:
+		return true, true, nil
+	}
+	return false, false, fmt.Errorf("invalid --dry-run value %q (valid: %s, %s, %s)", value, dryRunNone, dryRunServer, dryRunClient)
+}
+
+// parseDeployedTime parses a --deployed-after or --deployed-before value: an
+// RFC3339 time, or a duration counted back from now. An empty value returns
+// the zero time.
+func parseDeployedTime(flag, value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return time.Time{}, fmt.Errorf("invalid --%s %q: expected an RFC3339 time such as 2024-01-02T15:04:05Z or a duration such as 24h", flag, value)
+	}
+	return now.Add(-d), nil
+}
+
+// parseAnnotations parses the key=value pairs given with --set-annotation.
+func parseAnnotations(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	labels := make(map[string]string, len(values))
+	for _, v := range values {
+		key, value, ok := strings.Cut(v, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid --set-annotation %q: expected key=value", v)
+		}
+		labels[key] = value
+	}
+	return labels, nil
+}
+
+// expandReleasePatterns returns the names of the releases matching each of
+// the --glob patterns, in the order the patterns are given and without
+// duplicates. If report is set, the releases each pattern matched are
+// written to w.
+func expandReleasePatterns(w io.Writer, cfg *action.Configuration, patterns []string, report bool) ([]string, error) {
+	var names []string
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		matched, err := status.MatchReleaseNames(cfg, pattern)
+		if err != nil {
+			return nil, err
+		}
+		if report {
+			if len(matched) == 0 {
+				_, _ = fmt.Fprintf(w, "Pattern %q matched no releases\n", pattern)
+			} else {
+				_, _ = fmt.Fprintf(w, "Pattern %q matched %s\n", pattern, strings.Join(matched, ", "))
+			}
+		}
+		for _, name := range matched {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names, nil
+}
+
+// disallowUnknownStatus returns an error, for --disallow-unknown, if target or
+// the status of any entry is unknown.
+func disallowUnknownStatus(target release.Status, entries []releaseStatus) error {
+	for _, e := range entries {
+		if _, err := status.ParseStatusStrict(e.status.String()); err != nil {
+			return fmt.Errorf("release %q: %w (--disallow-unknown)", e.name, err)
+		}
+	}
+	if len(entries) == 0 {
+		if _, err := status.ParseStatusStrict(target.String()); err != nil {
+			return fmt.Errorf("%w (--disallow-unknown)", err)
+		}
+	}
+	return nil
+}
+
+// preconditions holds the parsed --from and --not-from statuses.
+type preconditions struct {
+	allowed    []release.Status
+	disallowed []release.Status
+}
+
+// fromEnv names the environment variable holding the default --from statuses.
+const fromEnv = "HELM_SET_STATUS_FROM"
+
+// envFromStatuses returns the comma-separated statuses in $HELM_SET_STATUS_FROM,
+// validated as --from values are. Empty entries are ignored.
+func envFromStatuses() ([]string, error) {
+	var values []string
+	for _, s := range strings.Split(os.Getenv(fromEnv), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			values = append(values, s)
+		}
 	}
+	if _, err := parseStatusFlag("$"+fromEnv, values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
 
-	// Parse and validate --from statuses
-	var allowedFromStatuses []release.Status
-	for _, s := range fromStatuses {
+// parseStatusFlag parses the values of a repeatable status flag.
+func parseStatusFlag(flag string, values []string) ([]release.Status, error) {
+	var statuses []release.Status
+	for _, s := range values {
 		parsed, err := status.ParseStatus(s)
 		if err != nil {
-			return fmt.Errorf("invalid --from status %q: %w\nValid statuses: %s", s, err, status.ValidStatusesString())
+			return nil, fmt.Errorf("invalid %s status %q: %w\nValid statuses: %s", flag, s, err, status.ValidStatusesString())
+		}
+		statuses = append(statuses, parsed)
+	}
+	return statuses, nil
+}
+
+// pendingOnlyStatuses returns the statuses --pending-only allows a release to
+// be changed from: the pending statuses, narrowed to those also given to
+// --from.
+func pendingOnlyStatuses(from []release.Status) ([]release.Status, error) {
+	if len(from) == 0 {
+		return status.PendingStatuses, nil
+	}
+
+	var allowed []release.Status
+	for _, s := range from {
+		if slices.Contains(status.PendingStatuses, s) {
+			allowed = append(allowed, s)
 		}
-		allowedFromStatuses = append(allowedFromStatuses, parsed)
+	}
+	if len(allowed) == 0 {
+		return nil, errors.New("--pending-only cannot be combined with --from statuses that are not pending")
+	}
+	return allowed, nil
+}
+
+// change is a status to set on a single release revision, and the
+// configuration of the namespace the release is stored in.
+// contextWithDeadline returns a copy of parent that is done at deadline, or
+// only when cancelled if deadline is zero.
+func contextWithDeadline(parent context.Context, deadline time.Time) (context.Context, context.CancelFunc) {
+	if deadline.IsZero() {
+		return context.WithCancel(parent)
+	}
+	return context.WithDeadline(parent, deadline)
+}
+
+// runUntilDone runs fn and returns its error, or an error wrapping ctx's if
+// ctx is done first. Helm's storage drivers take no context, so a read
+// blocked on a slow backend is left to finish in the background.
+func runUntilDone(ctx context.Context, what string, fn func() error) error {
+	done := make(chan error, 1)
+	go func() { done <- fn() }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("stopped %s: %w", what, ctx.Err())
+	}
+}
+
+// listChanges returns the changes a run makes, reading the releases to
+// change with --selector or --glob, and their revisions with --all-revisions
+// or --revision-range. Unless --all-namespaces is set, it also returns the
+// configuration of the namespace. Unless listed is set, each of
+// releaseNames is set to targetStatus.
+func listChanges(w io.Writer, releaseNames []string, entries []releaseStatus, listed bool, targetStatus release.Status, matchStatuses []release.Status, opts runOptions, newConfig configurationFactory) (*action.Configuration, []change, error) {
+	if opts.allNamespaces {
+		changes, err := allNamespacesChanges(targetStatus, matchStatuses, opts, newConfig)
+		return nil, changes, err
 	}
 
 	// Create Helm configuration
-	cfg, err := configFactory()
+	cfg, err := newConfig(opts.namespace, opts.clientOptions())
 	if err != nil {
-		return fmt.Errorf("failed to create configuration: %w", err)
+		return nil, nil, fmt.Errorf("failed to create configuration: %w", err)
+	}
+
+	if opts.selector != "" {
+		rels, err := status.ListReleases(cfg, opts.selector)
+		if err != nil {
+			return nil, nil, err
+		}
+		rels = status.DeployedBetween(rels, opts.deployedAfter, opts.deployedBefore)
+		rels = status.InStatus(rels, matchStatuses)
+		releaseNames = make([]string, 0, len(rels))
+		for _, rel := range rels {
+			releaseNames = append(releaseNames, rel.Name)
+		}
+	}
+	if opts.glob {
+		releaseNames, err = expandReleasePatterns(w, cfg, releaseNames, !isStructured(opts.output) && !opts.emitEvent)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if !listed {
+		entries = releaseEntries(releaseNames, targetStatus)
 	}
 
-	// Set the status
-	if err := status.SetStatus(cfg, releaseName, targetStatus, rev, allowedFromStatuses); err != nil {
+	changes, err := namespacedChanges(cfg, entries, opts, newConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfg, changes, nil
+}
+
+type change struct {
+	cfg       *action.Configuration
+	namespace string
+	target    status.Target
+	status    release.Status
+}
+
+// releaseChanges returns the revisions to update in namespace: each release
+// at --revision, with --all-revisions every stored revision of each release,
+// or with --revision-range the stored revisions in the range. A release with
+// no history is kept as a single target so that it is reported as not found;
+// any other error reading the history, such as a range reaching past the
+// stored revisions of a release, is an error naming the flag.
+func releaseChanges(cfg *action.Configuration, namespace string, entries []releaseStatus, opts runOptions) ([]change, error) {
+	changes := make([]change, 0, len(entries))
+	add := func(target status.Target, st release.Status) {
+		changes = append(changes, change{cfg: cfg, namespace: namespace, target: target, status: st})
+	}
+	for _, entry := range entries {
+		var revisions []status.Target
+		var flag string
+		var err error
+		switch {
+		case opts.allRevisions:
+			flag = "--all-revisions"
+			revisions, err = status.RevisionTargets(cfg, entry.name, opts.excludeLatest)
+		case opts.revisionRange.isSet():
+			flag = fmt.Sprintf("--revision-range %d-%d", opts.revisionRange.from, opts.revisionRange.to)
+			revisions, err = status.RevisionRangeTargets(cfg, entry.name, opts.revisionRange.from, opts.revisionRange.to)
+		default:
+			add(status.Target{ReleaseName: entry.name, Revision: opts.revision}, entry.status)
+			continue
+		}
 		var notFoundErr *status.ReleaseNotFoundError
 		if errors.As(err, &notFoundErr) {
-			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Warning: release %q not found, skipping\n", releaseName)
-			return nil
+			add(status.Target{ReleaseName: entry.name}, entry.status)
+			continue
 		}
-		var precondErr *status.PreconditionError
-		if errors.As(err, &precondErr) && noFail {
-			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Skipped: %s\n", err)
-			return nil
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", flag, err)
+		}
+		for _, target := range revisions {
+			add(target, entry.status)
 		}
-		return err
 	}
+	return changes, nil
+}
 
-	if rev > 0 {
-		_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Release %q revision %d status set to %q\n", releaseName, rev, statusStr)
+// releaseEntries returns an entry setting targetStatus on each of names.
+func releaseEntries(names []string, targetStatus release.Status) []releaseStatus {
+	entries := make([]releaseStatus, len(names))
+	for i, name := range names {
+		entries[i] = releaseStatus{name: name, status: targetStatus}
+	}
+	return entries
+}
+
+// clientDryRunResults reports each entry as a change that would be made,
+// without reading the release, for --dry-run=client.
+func clientDryRunResults(entries []releaseStatus, opts runOptions) []changeResult {
+	results := make([]changeResult, len(entries))
+	for i, entry := range entries {
+		ns := entry.namespace
+		if ns == "" {
+			ns = status.ResolveNamespace(opts.namespace)
+		}
+		results[i] = newChangeResult(status.Target{ReleaseName: entry.name, Revision: opts.revision}, ns, entry.status)
+		results[i].Result = resultWouldChange
+		results[i].Reason = "release not read with --dry-run=client"
+	}
+	return results
+}
+
+// namespacedChanges returns the changes for entries. Entries without a
+// namespace of their own use cfg, the configuration for --namespace; the
+// others use a configuration for their namespace, created once per namespace.
+func namespacedChanges(cfg *action.Configuration, entries []releaseStatus, opts runOptions, newConfig configurationFactory) ([]change, error) {
+	defaultNamespace := status.ResolveNamespace(opts.namespace)
+	configs := map[string]*action.Configuration{defaultNamespace: cfg}
+	changes := make([]change, 0, len(entries))
+	for _, entry := range entries {
+		ns := entry.namespace
+		if ns == "" {
+			ns = defaultNamespace
+		}
+		nsCfg, ok := configs[ns]
+		if !ok {
+			var err error
+			if nsCfg, err = newConfig(ns, opts.clientOptions()); err != nil {
+				return nil, fmt.Errorf("failed to create configuration for namespace %q: %w", ns, err)
+			}
+			configs[ns] = nsCfg
+		}
+		nsChanges, err := releaseChanges(nsCfg, ns, []releaseStatus{entry}, opts)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, nsChanges...)
+	}
+	return changes, nil
+}
+
+// allNamespacesChanges lists the releases matching --selector and any
+// --status in every namespace and returns the changes to make to them,
+// grouped by namespace. Each namespace gets its own configuration, since Helm
+// reads and writes releases one namespace at a time.
+func allNamespacesChanges(targetStatus release.Status, matchStatuses []release.Status, opts runOptions, newConfig configurationFactory) ([]change, error) {
+	clientOpts := opts.clientOptions()
+	clientOpts.AllNamespaces = true
+	listCfg, err := newConfig("", clientOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create configuration: %w", err)
+	}
+
+	rels, err := status.ListReleases(listCfg, opts.selector)
+	if err != nil {
+		if apierrors.IsForbidden(err) {
+			return nil, fmt.Errorf("--all-namespaces: not allowed to list releases in every namespace; "+
+				"this needs permission to list the release storage (such as secrets) cluster-wide: %w", err)
+		}
+		return nil, err
+	}
+	rels = status.DeployedBetween(rels, opts.deployedAfter, opts.deployedBefore)
+	rels = status.InStatus(rels, matchStatuses)
+
+	// ListReleases sorts by namespace, so each namespace's releases are adjacent
+	var changes []change
+	for i := 0; i < len(rels); {
+		ns := rels[i].Namespace
+		var entries []releaseStatus
+		for ; i < len(rels) && rels[i].Namespace == ns; i++ {
+			entries = append(entries, releaseStatus{name: rels[i].Name, status: targetStatus})
+		}
+
+		cfg, err := newConfig(ns, opts.clientOptions())
+		if err != nil {
+			return nil, fmt.Errorf("failed to create configuration for namespace %q: %w", ns, err)
+		}
+		nsChanges, err := releaseChanges(cfg, ns, entries, opts)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, nsChanges...)
+	}
+	return changes, nil
+}
+
+// setReleaseStatuses sets the status of each change with
+// status.BatchSetStatusParallel, running up to opts.parallelism changes to the
+// same configuration at once, and describes the outcomes with changeOutcome.
+// Results and errors are returned in the same order as changes regardless of
+// the order in which the changes finish. With --fail-fast, the changes are
+// made one at a time instead, and those after the first that fails are not
+// attempted.
+func setReleaseStatuses(ctx context.Context, changes []change, setOpts status.SetStatusOptions, opts runOptions) ([]changeResult, []error) {
+	results := make([]changeResult, len(changes))
+	errs := make([]error, len(changes))
+
+	if opts.failFast {
+		for i, c := range changes {
+			results[i], errs[i] = setReleaseStatus(ctx, c, setOpts, opts)
+			if errs[i] == nil {
+				continue
+			}
+			for j, rest := range changes[i+1:] {
+				res := newChangeResult(rest.target, rest.namespace, rest.status)
+				res.Result = resultNotAttempted
+				res.Reason = fmt.Sprintf("not attempted after release %q failed (--fail-fast)", c.target.ReleaseName)
+				results[i+1+j] = res
+			}
+			break
+		}
+		return results, errs
+	}
+
+	// Adjacent changes to the same configuration, such as those to one
+	// namespace with --all-namespaces, form one batch
+	for start := 0; start < len(changes); {
+		end := start + 1
+		for end < len(changes) && changes[end].cfg == changes[start].cfg {
+			end++
+		}
+
+		requests := make([]status.SetStatusRequest, end-start)
+		for i, c := range changes[start:end] {
+			requestOpts := setOpts
+			requestOpts.Revision = c.target.Revision
+			requests[i] = status.SetStatusRequest{ReleaseName: c.target.ReleaseName, Status: c.status, Options: requestOpts}
+		}
+		for i, out := range status.BatchSetStatusParallel(ctx, changes[start].cfg, requests, opts.parallelism) {
+			results[start+i], errs[start+i] = changeOutcome(changes[start+i], out.Result, out.Err, opts)
+		}
+		start = end
+	}
+
+	return results, errs
+}
+
+// setReleaseStatus sets the status of a single release revision and describes
+// the outcome with changeOutcome.
+func setReleaseStatus(ctx context.Context, c change, setOpts status.SetStatusOptions, opts runOptions) (changeResult, error) {
+	setOpts.Revision = c.target.Revision
+	setResult, err := status.SetStatus(ctx, c.cfg, c.target.ReleaseName, c.status, setOpts)
+	return changeOutcome(c, setResult, err, opts)
+}
+
+// changeOutcome describes the outcome of setting the status of c. A missing
+// release is reported as not found rather than as an error. With
+// --supersede-others, the other deployed revisions are superseded once a
+// revision is set to deployed, and with --prune-history, older revisions are
+// pruned once the status is set.
+func changeOutcome(c change, setResult status.Result, err error, opts runOptions) (changeResult, error) {
+	res := newChangeResult(c.target, c.namespace, c.status)
+	if err != nil {
+		res.StoredStatus = setResult.UnrecognizedStatus
+		var notFoundErr *status.ReleaseNotFoundError
+		if errors.As(err, &notFoundErr) {
+			res.Result = resultNotFound
+			res.Reason = err.Error()
+			return res, nil
+		}
+		var maskedErr *status.MaskedFailureError
+		if errors.As(err, &maskedErr) {
+			return res, fmt.Errorf("%w; use --i-understand to set it anyway", err)
+		}
+		return res, err
+	}
+
+	describeResult(&res, setResult, opts.dryRun)
+	if opts.supersedeOthers && c.status == release.StatusDeployed && !setResult.Skipped {
+		if err := supersedeOtherRevisions(&res, c.cfg, opts); err != nil {
+			return res, err
+		}
+	}
+	if opts.pruneHistory > 0 && !setResult.Skipped {
+		if err := pruneReleaseHistory(&res, c.cfg, opts); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// supersedeOtherRevisions sets every deployed revision of the release in res
+// other than the one just set to superseded, or under --dry-run lists them.
+func supersedeOtherRevisions(res *changeResult, cfg *action.Configuration, opts runOptions) error {
+	var err error
+	if opts.dryRun {
+		res.WouldSupersede, err = status.SupersedableRevisions(cfg, res.Release, res.storedRevision)
 	} else {
-		_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Release %q status set to %q\n", releaseName, statusStr)
+		res.Superseded, err = status.SupersedeRevisions(cfg, res.Release, res.storedRevision)
+	}
+	if err != nil {
+		return fmt.Errorf("--supersede-others: %w", err)
 	}
 	return nil
 }
+
+// pruneReleaseHistory deletes all but the --prune-history most recent
+// revisions of the release res describes, keeping the revision whose status
+// was set, and records the deleted revisions in res. With --dry-run, nothing
+// is deleted and the revisions that would be are recorded instead.
+func pruneReleaseHistory(res *changeResult, cfg *action.Configuration, opts runOptions) error {
+	var err error
+	if opts.dryRun {
+		res.WouldPrune, err = status.PrunableRevisions(cfg, res.Release, opts.pruneHistory, res.storedRevision)
+	} else {
+		res.Pruned, err = status.PruneHistory(cfg, res.Release, opts.pruneHistory, res.storedRevision)
+	}
+	if err != nil {
+		return fmt.Errorf("--prune-history: %w", err)
+	}
+	return nil
+}
+
+// setReleaseStatusesAtomic sets the status of every target with
+// status.SetStatusAtomic, so that a failure restores the targets already
+// updated. Any failure, including a missing release or an unmet precondition,
+// is returned as an error, along with a result for every target describing
+// what became of it.
+func setReleaseStatusesAtomic(ctx context.Context, cfg *action.Configuration, targets []status.Target, targetStatus release.Status, setOpts status.SetStatusOptions, namespace string) ([]changeResult, error) {
+	setResults, err := status.SetStatusAtomic(ctx, cfg, targets, targetStatus, setOpts)
+
+	results := make([]changeResult, len(targets))
+	for i, target := range targets {
+		results[i] = newChangeResult(target, namespace, targetStatus)
+		if i < len(setResults) {
+			describeResult(&results[i], setResults[i], setOpts.DryRun)
+		}
+	}
+	if err != nil {
+		describeAtomicFailure(results, targets, len(setResults), err)
+	}
+	return results, err
+}
+
+// describeAtomicFailure updates the results of an --atomic change to targets
+// that failed at targets[failed]: the changes before it are reported as rolled
+// back, or as still changed if they could not be, and those after it as not
+// attempted.
+func describeAtomicFailure(results []changeResult, targets []status.Target, failed int, err error) {
+	var atomicErr *status.AtomicError
+	if !errors.As(err, &atomicErr) || failed >= len(results) {
+		return
+	}
+
+	for i, target := range targets {
+		res := &results[i]
+		switch {
+		case i == failed:
+			res.Result = resultError
+			res.Reason = atomicErr.Err.Error()
+			if rollbackErr := atomicErr.RollbackErrors[target]; rollbackErr != nil {
+				res.Reason += fmt.Sprintf("; failed to roll back: %v", rollbackErr)
+			}
+		case i > failed:
+			res.Result = resultNotAttempted
+			res.Reason = fmt.Sprintf("not attempted after release %q failed (--atomic)", atomicErr.Failed.ReleaseName)
+		case atomicErr.RollbackErrors[target] != nil:
+			res.Reason = fmt.Sprintf("failed to roll back after release %q failed (--atomic): %v", atomicErr.Failed.ReleaseName, atomicErr.RollbackErrors[target])
+		case slices.Contains(atomicErr.RolledBack, target):
+			res.Changed = false
+			res.Result = resultRolledBack
+			res.Reason = fmt.Sprintf("rolled back after release %q failed (--atomic)", atomicErr.Failed.ReleaseName)
+		}
+	}
+}
+
+// setStatusOptions builds the library options for a status change from the
+// command-line flags.
+func setStatusOptions(pre preconditions, labels map[string]string, opts runOptions, logger *slog.Logger) status.SetStatusOptions {
+	setOpts := status.SetStatusOptions{
+		Revision:               opts.revision,
+		AllowedFromStatuses:    pre.allowed,
+		DisallowedFromStatuses: pre.disallowed,
+		IfOlderThan:            opts.ifOlderThan,
+		DryRun:                 opts.dryRun,
+		Description:            opts.description,
+		AppendDescription:      opts.appendDesc,
+		KeepLastDeployed:       opts.keepLastDeployed,
+		ForceWrite:             opts.forceWrite,
+		StrictTransitions:      opts.strict,
+		SafeMode:               !opts.iUnderstand,
+		ExpectChart:            opts.expectChart,
+		ExpectChartVersion:     opts.expectVersion,
+		Force:                  opts.force,
+		MaxRetries:             opts.maxRetries,
+		RetryOnConflict:        opts.retryOnConflict,
+		RetryDelay:             opts.retryDelay,
+		// With --all-revisions, --revision-range, or --pending-only, a
+		// revision that does not meet its preconditions is skipped as if
+		// --no-fail were set. With --atomic it fails the whole change instead.
+		SkipUnmetPreconditions: !opts.atomic && (opts.noFail || opts.allRevisions || opts.revisionRange.isSet() || opts.pendingOnly),
+		Labels:                 labels,
+		Logger:                 logger,
+	}
+	if opts.preHook != "" {
+		setOpts.BeforeUpdate = preHook(opts.preHook)
+	}
+	if opts.wait {
+		setOpts.WaitTimeout = opts.timeout
+		setOpts.WaitMaxInterval = opts.waitMaxInterval
+	}
+	return setOpts
+}
+
+// newChangeResult returns the result skeleton for a change to target in namespace.
+func newChangeResult(target status.Target, namespace string, targetStatus release.Status) changeResult {
+	return changeResult{
+		Release:   target.ReleaseName,
+		Namespace: namespace,
+		Revision:  target.Revision,
+		NewStatus: targetStatus.String(),
+	}
+}
+
+// describeResult fills in res from the outcome of a successful SetStatus call.
+// Revision is set to the revision SetStatus resolved, so that the latest or a
+// relative revision is reported by number. The description is taken from the
+// release SetStatus returns, unless dryRun is set and the release was left as
+// it was.
+func describeResult(res *changeResult, setResult status.Result, dryRun bool) {
+	res.PreviousStatus = setResult.PreviousStatus.String()
+	res.StoredStatus = setResult.UnrecognizedStatus
+	res.stored = setResult.Release
+	res.previous = setResult.PreviousRelease
+	if setResult.Release != nil {
+		res.storedRevision = setResult.Release.Version
+		res.Revision = setResult.Release.Version
+		if setResult.Release.Info != nil && !(dryRun && setResult.Changed) {
+			res.Description = setResult.Release.Info.Description
+		}
+	}
+	if setResult.Skipped {
+		res.Skipped = true
+		res.Result = resultSkipped
+		res.Reason = setResult.SkipReason
+	} else if !setResult.Changed {
+		res.Result = resultUnchanged
+		res.Reason = fmt.Sprintf("already %s, no change", setResult.Status)
+	} else if dryRun {
+		res.Result = resultWouldChange
+	} else {
+		res.Changed = true
+		res.Result = resultChanged
+	}
+}
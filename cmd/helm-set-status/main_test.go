@@ -2,9 +2,18 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/josegonzalez/helm-set-status/pkg/status"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"helm.sh/helm/v3/pkg/action"
@@ -12,12 +21,15 @@ import (
 	"helm.sh/helm/v3/pkg/release"
 	"helm.sh/helm/v3/pkg/storage"
 	"helm.sh/helm/v3/pkg/storage/driver"
+	helmtime "helm.sh/helm/v3/pkg/time"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 func TestNewRootCmd(t *testing.T) {
 	cmd := newRootCmd()
 
-	assert.Equal(t, "helm-set-status RELEASE STATUS", cmd.Use)
+	assert.Equal(t, "helm-set-status (RELEASE [RELEASE...] | --selector SELECTOR) STATUS | RELEASE=STATUS [RELEASE=STATUS...] | --from-file PATH | --from-configmap [NAMESPACE/]NAME", cmd.Use)
 	assert.Equal(t, "Set the status of a Helm release", cmd.Short)
 	assert.Contains(t, cmd.Long, "Valid status values")
 	assert.Contains(t, cmd.Long, "--revision")
@@ -27,17 +39,141 @@ func TestNewRootCmd(t *testing.T) {
 	// Verify --revision flag exists
 	revFlag := cmd.Flags().Lookup("revision")
 	assert.NotNil(t, revFlag)
-	assert.Equal(t, "0", revFlag.DefValue)
+	assert.Equal(t, "latest", revFlag.DefValue)
+
+	// Verify --all-revisions and --exclude-latest flags exist
+	allRevisionsFlag := cmd.Flags().Lookup("all-revisions")
+	assert.NotNil(t, allRevisionsFlag)
+	assert.Equal(t, "false", allRevisionsFlag.DefValue)
+	excludeLatestFlag := cmd.Flags().Lookup("exclude-latest")
+	assert.NotNil(t, excludeLatestFlag)
+	assert.Equal(t, "false", excludeLatestFlag.DefValue)
+
+	// Verify --from-file flag exists
+	fromFileFlag := cmd.Flags().Lookup("from-file")
+	assert.NotNil(t, fromFileFlag)
+	assert.Equal(t, "", fromFileFlag.DefValue)
 
 	// Verify --from flag exists
 	fromFlag := cmd.Flags().Lookup("from")
 	assert.NotNil(t, fromFlag)
 	assert.Equal(t, "[]", fromFlag.DefValue)
 
+	// Verify --not-from flag exists
+	notFromFlag := cmd.Flags().Lookup("not-from")
+	assert.NotNil(t, notFromFlag)
+	assert.Equal(t, "[]", notFromFlag.DefValue)
+
 	// Verify --no-fail flag exists
 	noFailFlag := cmd.Flags().Lookup("no-fail")
 	assert.NotNil(t, noFailFlag)
 	assert.Equal(t, "false", noFailFlag.DefValue)
+
+	// Verify --append-description flag exists
+	appendDescFlag := cmd.Flags().Lookup("append-description")
+	assert.NotNil(t, appendDescFlag)
+	assert.Equal(t, "false", appendDescFlag.DefValue)
+
+	// Verify --set-annotation flag exists
+	annotationFlag := cmd.Flags().Lookup("set-annotation")
+	assert.NotNil(t, annotationFlag)
+	assert.Equal(t, "[]", annotationFlag.DefValue)
+
+	// Verify --atomic flag exists
+	atomicFlag := cmd.Flags().Lookup("atomic")
+	assert.NotNil(t, atomicFlag)
+	assert.Equal(t, "false", atomicFlag.DefValue)
+
+	// Verify --parallelism flag exists
+	parallelismFlag := cmd.Flags().Lookup("parallelism")
+	assert.NotNil(t, parallelismFlag)
+	assert.Equal(t, "1", parallelismFlag.DefValue)
+
+	// Verify --if-older-than flag exists
+	ifOlderThanFlag := cmd.Flags().Lookup("if-older-than")
+	assert.NotNil(t, ifOlderThanFlag)
+	assert.Equal(t, "0s", ifOlderThanFlag.DefValue)
+
+	// Verify --skip-exit-code flag exists
+	skipExitCodeFlag := cmd.Flags().Lookup("skip-exit-code")
+	assert.NotNil(t, skipExitCodeFlag)
+	assert.Equal(t, "0", skipExitCodeFlag.DefValue)
+
+	// Verify --dry-run flag exists
+	dryRunFlag := cmd.Flags().Lookup("dry-run")
+	assert.NotNil(t, dryRunFlag)
+	assert.Equal(t, "none", dryRunFlag.DefValue)
+	assert.Equal(t, "server", dryRunFlag.NoOptDefVal)
+
+	// Verify --description flag exists
+	descFlag := cmd.Flags().Lookup("description")
+	assert.NotNil(t, descFlag)
+	assert.Equal(t, "", descFlag.DefValue)
+
+	// Verify --keep-last-deployed flag exists
+	keepFlag := cmd.Flags().Lookup("keep-last-deployed")
+	assert.NotNil(t, keepFlag)
+	assert.Equal(t, "false", keepFlag.DefValue)
+
+	// Verify --force flag exists
+	forceFlag := cmd.Flags().Lookup("force")
+	assert.NotNil(t, forceFlag)
+	assert.Equal(t, "false", forceFlag.DefValue)
+
+	// Verify --strict-transitions flag exists
+	strictFlag := cmd.Flags().Lookup("strict-transitions")
+	assert.NotNil(t, strictFlag)
+	assert.Equal(t, "false", strictFlag.DefValue)
+
+	// Verify --interactive and --yes flags exist
+	interactiveFlag := cmd.Flags().Lookup("interactive")
+	assert.NotNil(t, interactiveFlag)
+	assert.Equal(t, "false", interactiveFlag.DefValue)
+	yesFlag := cmd.Flags().Lookup("yes")
+	assert.NotNil(t, yesFlag)
+	assert.Equal(t, "y", yesFlag.Shorthand)
+
+	// Verify --force-write flag exists
+	forceWriteFlag := cmd.Flags().Lookup("force-write")
+	assert.NotNil(t, forceWriteFlag)
+	assert.Equal(t, "false", forceWriteFlag.DefValue)
+
+	// Verify --max-retries flag exists
+	maxRetriesFlag := cmd.Flags().Lookup("max-retries")
+	assert.NotNil(t, maxRetriesFlag)
+	assert.Equal(t, "3", maxRetriesFlag.DefValue)
+
+	// Verify --wait and --timeout flags exist
+	waitFlag := cmd.Flags().Lookup("wait")
+	assert.NotNil(t, waitFlag)
+	assert.Equal(t, "false", waitFlag.DefValue)
+	timeoutFlag := cmd.Flags().Lookup("timeout")
+	assert.NotNil(t, timeoutFlag)
+	assert.Equal(t, "5m0s", timeoutFlag.DefValue)
+	waitMaxIntervalFlag := cmd.Flags().Lookup("wait-max-interval")
+	assert.NotNil(t, waitMaxIntervalFlag)
+	assert.Equal(t, "5s", waitMaxIntervalFlag.DefValue)
+
+	// Verify --verbose and --log-format flags exist
+	verboseFlag := cmd.Flags().Lookup("verbose")
+	assert.NotNil(t, verboseFlag)
+	assert.Equal(t, "v", verboseFlag.Shorthand)
+	assert.Equal(t, "false", verboseFlag.DefValue)
+	logFormatFlag := cmd.Flags().Lookup("log-format")
+	assert.NotNil(t, logFormatFlag)
+	assert.Equal(t, "text", logFormatFlag.DefValue)
+
+	// Verify --output flag exists
+	outputFlag := cmd.Flags().Lookup("output")
+	assert.NotNil(t, outputFlag)
+	assert.Equal(t, "o", outputFlag.Shorthand)
+	assert.Equal(t, "text", outputFlag.DefValue)
+
+	// Verify --namespace flag exists
+	nsFlag := cmd.PersistentFlags().Lookup("namespace")
+	assert.NotNil(t, nsFlag)
+	assert.Equal(t, "n", nsFlag.Shorthand)
+	assert.Equal(t, "", nsFlag.DefValue)
 }
 
 func TestRunWithConfigFactory_Success(t *testing.T) {
@@ -63,7 +199,7 @@ func TestRunWithConfigFactory_Success(t *testing.T) {
 	require.NoError(t, err)
 
 	// Create configuration factory
-	configFactory := func() (*action.Configuration, error) {
+	configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
 		return &action.Configuration{Releases: store}, nil
 	}
 
@@ -74,12 +210,11 @@ func TestRunWithConfigFactory_Success(t *testing.T) {
 	cmd.SetErr(&buf)
 
 	// Run with revision 0 (latest)
-	err = runWithConfigFactory(cmd, []string{"my-release", "failed"}, 0, nil, false, configFactory)
+	err = runWithConfigFactory(cmd, []string{"my-release", "failed"}, runOptions{}, configFactory)
 	require.NoError(t, err)
 
 	// Verify output
-	assert.Contains(t, buf.String(), "my-release")
-	assert.Contains(t, buf.String(), "failed")
+	assert.Equal(t, "Release \"my-release\" revision 1 status changed from \"deployed\" to \"failed\"\n", buf.String())
 
 	// Verify status was changed
 	updated, err := store.Last("my-release")
@@ -125,7 +260,7 @@ func TestRunWithConfigFactory_SpecificRevision(t *testing.T) {
 	err = store.Create(rel2)
 	require.NoError(t, err)
 
-	configFactory := func() (*action.Configuration, error) {
+	configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
 		return &action.Configuration{Releases: store}, nil
 	}
 
@@ -135,7 +270,7 @@ func TestRunWithConfigFactory_SpecificRevision(t *testing.T) {
 	cmd.SetErr(&buf)
 
 	// Update revision 1 specifically
-	err = runWithConfigFactory(cmd, []string{"my-release", "failed"}, 1, nil, false, configFactory)
+	err = runWithConfigFactory(cmd, []string{"my-release", "failed"}, runOptions{revision: 1}, configFactory)
 	require.NoError(t, err)
 
 	// Verify output mentions revision
@@ -155,7 +290,7 @@ func TestRunWithConfigFactory_SpecificRevision(t *testing.T) {
 
 func TestRunWithConfigFactory_InvalidStatus(t *testing.T) {
 	// Configuration factory won't be called for invalid status
-	configFactory := func() (*action.Configuration, error) {
+	configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
 		return nil, errors.New("should not be called")
 	}
 
@@ -164,14 +299,14 @@ func TestRunWithConfigFactory_InvalidStatus(t *testing.T) {
 	cmd.SetOut(&buf)
 	cmd.SetErr(&buf)
 
-	err := runWithConfigFactory(cmd, []string{"my-release", "invalid-status"}, 0, nil, false, configFactory)
+	err := runWithConfigFactory(cmd, []string{"my-release", "invalid-status"}, runOptions{}, configFactory)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "invalid status")
 	assert.Contains(t, err.Error(), "Valid statuses")
 }
 
 func TestRunWithConfigFactory_ConfigError(t *testing.T) {
-	configFactory := func() (*action.Configuration, error) {
+	configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
 		return nil, errors.New("config creation failed")
 	}
 
@@ -180,7 +315,7 @@ func TestRunWithConfigFactory_ConfigError(t *testing.T) {
 	cmd.SetOut(&buf)
 	cmd.SetErr(&buf)
 
-	err := runWithConfigFactory(cmd, []string{"my-release", "failed"}, 0, nil, false, configFactory)
+	err := runWithConfigFactory(cmd, []string{"my-release", "failed"}, runOptions{}, configFactory)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to create configuration")
 }
@@ -190,7 +325,7 @@ func TestRunWithConfigFactory_ReleaseNotFound(t *testing.T) {
 	mem := driver.NewMemory()
 	store := storage.Init(mem)
 
-	configFactory := func() (*action.Configuration, error) {
+	configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
 		return &action.Configuration{Releases: store}, nil
 	}
 
@@ -199,7 +334,7 @@ func TestRunWithConfigFactory_ReleaseNotFound(t *testing.T) {
 	cmd.SetOut(&buf)
 	cmd.SetErr(&buf)
 
-	err := runWithConfigFactory(cmd, []string{"non-existent", "failed"}, 0, nil, false, configFactory)
+	err := runWithConfigFactory(cmd, []string{"non-existent", "failed"}, runOptions{}, configFactory)
 	require.NoError(t, err)
 	assert.Contains(t, buf.String(), "Warning")
 	assert.Contains(t, buf.String(), "non-existent")
@@ -231,7 +366,7 @@ func TestRun_UsesConfigurationFactory(t *testing.T) {
 	err := store.Create(rel)
 	require.NoError(t, err)
 
-	ConfigurationFactory = func() (*action.Configuration, error) {
+	ConfigurationFactory = func(string, status.ClientOptions) (*action.Configuration, error) {
 		return &action.Configuration{Releases: store}, nil
 	}
 
@@ -280,7 +415,7 @@ func TestRunWithConfigFactory_AllStatuses(t *testing.T) {
 			err := store.Create(rel)
 			require.NoError(t, err)
 
-			configFactory := func() (*action.Configuration, error) {
+			configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
 				return &action.Configuration{Releases: store}, nil
 			}
 
@@ -289,7 +424,7 @@ func TestRunWithConfigFactory_AllStatuses(t *testing.T) {
 			cmd.SetOut(&buf)
 			cmd.SetErr(&buf)
 
-			err = runWithConfigFactory(cmd, []string{"test-release", statusStr}, 0, nil, false, configFactory)
+			err = runWithConfigFactory(cmd, []string{"test-release", statusStr}, runOptions{}, configFactory)
 			require.NoError(t, err)
 			assert.Contains(t, buf.String(), statusStr)
 		})
@@ -318,7 +453,7 @@ func TestRunWithConfigFactory_FromFlag(t *testing.T) {
 		err := store.Create(rel)
 		require.NoError(t, err)
 
-		configFactory := func() (*action.Configuration, error) {
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
 			return &action.Configuration{Releases: store}, nil
 		}
 
@@ -328,7 +463,7 @@ func TestRunWithConfigFactory_FromFlag(t *testing.T) {
 		cmd.SetErr(&buf)
 
 		// Should succeed because current status (pending-upgrade) is in allowed list
-		err = runWithConfigFactory(cmd, []string{"test-release", "deployed"}, 0, []string{"pending-upgrade", "pending-rollback"}, false, configFactory)
+		err = runWithConfigFactory(cmd, []string{"test-release", "deployed"}, runOptions{fromStatuses: []string{"pending-upgrade", "pending-rollback"}}, configFactory)
 		require.NoError(t, err)
 		assert.Contains(t, buf.String(), "test-release")
 		assert.Contains(t, buf.String(), "deployed")
@@ -355,7 +490,7 @@ func TestRunWithConfigFactory_FromFlag(t *testing.T) {
 		err := store.Create(rel)
 		require.NoError(t, err)
 
-		configFactory := func() (*action.Configuration, error) {
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
 			return &action.Configuration{Releases: store}, nil
 		}
 
@@ -365,14 +500,14 @@ func TestRunWithConfigFactory_FromFlag(t *testing.T) {
 		cmd.SetErr(&buf)
 
 		// Should fail because current status (deployed) is NOT in allowed list
-		err = runWithConfigFactory(cmd, []string{"test-release", "failed"}, 0, []string{"pending-upgrade", "pending-rollback"}, false, configFactory)
+		err = runWithConfigFactory(cmd, []string{"test-release", "failed"}, runOptions{fromStatuses: []string{"pending-upgrade", "pending-rollback"}}, configFactory)
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "current status")
 		assert.Contains(t, err.Error(), "not in allowed list")
 	})
 
 	t.Run("fails with invalid from status", func(t *testing.T) {
-		configFactory := func() (*action.Configuration, error) {
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
 			return nil, errors.New("should not be called")
 		}
 
@@ -381,7 +516,7 @@ func TestRunWithConfigFactory_FromFlag(t *testing.T) {
 		cmd.SetOut(&buf)
 		cmd.SetErr(&buf)
 
-		err := runWithConfigFactory(cmd, []string{"test-release", "deployed"}, 0, []string{"invalid-status"}, false, configFactory)
+		err := runWithConfigFactory(cmd, []string{"test-release", "deployed"}, runOptions{fromStatuses: []string{"invalid-status"}}, configFactory)
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "invalid --from status")
 		assert.Contains(t, err.Error(), "Valid statuses")
@@ -408,7 +543,7 @@ func TestRunWithConfigFactory_FromFlag(t *testing.T) {
 		err := store.Create(rel)
 		require.NoError(t, err)
 
-		configFactory := func() (*action.Configuration, error) {
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
 			return &action.Configuration{Releases: store}, nil
 		}
 
@@ -418,7 +553,7 @@ func TestRunWithConfigFactory_FromFlag(t *testing.T) {
 		cmd.SetErr(&buf)
 
 		// Should succeed because pending-rollback is in the list of allowed statuses
-		err = runWithConfigFactory(cmd, []string{"test-release", "deployed"}, 0, []string{"pending-install", "pending-upgrade", "pending-rollback"}, false, configFactory)
+		err = runWithConfigFactory(cmd, []string{"test-release", "deployed"}, runOptions{fromStatuses: []string{"pending-install", "pending-upgrade", "pending-rollback"}}, configFactory)
 		require.NoError(t, err)
 		assert.Contains(t, buf.String(), "test-release")
 	})
@@ -446,7 +581,7 @@ func TestRunWithConfigFactory_NoFailFlag(t *testing.T) {
 		err := store.Create(rel)
 		require.NoError(t, err)
 
-		configFactory := func() (*action.Configuration, error) {
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
 			return &action.Configuration{Releases: store}, nil
 		}
 
@@ -456,7 +591,7 @@ func TestRunWithConfigFactory_NoFailFlag(t *testing.T) {
 		cmd.SetErr(&buf)
 
 		// Should succeed because current status matches precondition
-		err = runWithConfigFactory(cmd, []string{"test-release", "deployed"}, 0, []string{"pending-upgrade"}, true, configFactory)
+		err = runWithConfigFactory(cmd, []string{"test-release", "deployed"}, runOptions{fromStatuses: []string{"pending-upgrade"}, noFail: true}, configFactory)
 		require.NoError(t, err)
 		assert.Contains(t, buf.String(), "test-release")
 		assert.Contains(t, buf.String(), "deployed")
@@ -488,7 +623,7 @@ func TestRunWithConfigFactory_NoFailFlag(t *testing.T) {
 		err := store.Create(rel)
 		require.NoError(t, err)
 
-		configFactory := func() (*action.Configuration, error) {
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
 			return &action.Configuration{Releases: store}, nil
 		}
 
@@ -498,7 +633,7 @@ func TestRunWithConfigFactory_NoFailFlag(t *testing.T) {
 		cmd.SetErr(&buf)
 
 		// Should not fail because --no-fail is set, even though precondition doesn't match
-		err = runWithConfigFactory(cmd, []string{"test-release", "failed"}, 0, []string{"pending-upgrade"}, true, configFactory)
+		err = runWithConfigFactory(cmd, []string{"test-release", "failed"}, runOptions{fromStatuses: []string{"pending-upgrade"}, noFail: true}, configFactory)
 		require.NoError(t, err)
 		assert.Contains(t, buf.String(), "Skipped")
 		assert.Contains(t, buf.String(), "current status")
@@ -531,7 +666,7 @@ func TestRunWithConfigFactory_NoFailFlag(t *testing.T) {
 		err := store.Create(rel)
 		require.NoError(t, err)
 
-		configFactory := func() (*action.Configuration, error) {
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
 			return &action.Configuration{Releases: store}, nil
 		}
 
@@ -541,7 +676,7 @@ func TestRunWithConfigFactory_NoFailFlag(t *testing.T) {
 		cmd.SetErr(&buf)
 
 		// Should fail because --no-fail is not set
-		err = runWithConfigFactory(cmd, []string{"test-release", "failed"}, 0, []string{"pending-upgrade"}, false, configFactory)
+		err = runWithConfigFactory(cmd, []string{"test-release", "failed"}, runOptions{fromStatuses: []string{"pending-upgrade"}}, configFactory)
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "current status")
 		assert.Contains(t, err.Error(), "not in allowed list")
@@ -552,3 +687,4059 @@ func TestRunWithConfigFactory_NoFailFlag(t *testing.T) {
 		assert.Equal(t, release.StatusDeployed, unchanged.Info.Status)
 	})
 }
+
+func TestRunWithConfigFactory_UnderscoreAliasPrintsCanonicalStatus(t *testing.T) {
+	mem := driver.NewMemory()
+	store := storage.Init(mem)
+
+	rel := &release.Release{
+		Name:      "test-release",
+		Namespace: "default",
+		Version:   1,
+		Info: &release.Info{
+			Status: release.StatusDeployed,
+		},
+		Chart: &chart.Chart{
+			Metadata: &chart.Metadata{
+				Name:    "test-chart",
+				Version: "1.0.0",
+			},
+		},
+	}
+	err := store.Create(rel)
+	require.NoError(t, err)
+
+	configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+		return &action.Configuration{Releases: store}, nil
+	}
+
+	cmd := newRootCmd()
+	var buf bytes.Buffer
+	cmd.SetOut(&buf)
+	cmd.SetErr(&buf)
+
+	err = runWithConfigFactory(cmd, []string{"test-release", "Pending_Upgrade"}, runOptions{}, configFactory)
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), `status changed from "deployed" to "pending-upgrade"`)
+
+	updated, err := store.Last("test-release")
+	require.NoError(t, err)
+	assert.Equal(t, release.StatusPendingUpgrade, updated.Info.Status)
+}
+
+func TestRunWithConfigFactory_Namespace(t *testing.T) {
+	mem := driver.NewMemory()
+	store := storage.Init(mem)
+
+	rel := &release.Release{
+		Name:      "test-release",
+		Namespace: "production",
+		Version:   1,
+		Info: &release.Info{
+			Status: release.StatusDeployed,
+		},
+		Chart: &chart.Chart{
+			Metadata: &chart.Metadata{
+				Name:    "test-chart",
+				Version: "1.0.0",
+			},
+		},
+	}
+	err := store.Create(rel)
+	require.NoError(t, err)
+
+	var gotNamespace string
+	configFactory := func(namespace string, _ status.ClientOptions) (*action.Configuration, error) {
+		gotNamespace = namespace
+		return &action.Configuration{Releases: store}, nil
+	}
+
+	cmd := newRootCmd()
+	var buf bytes.Buffer
+	cmd.SetOut(&buf)
+	cmd.SetErr(&buf)
+
+	err = runWithConfigFactory(cmd, []string{"test-release", "failed"}, runOptions{namespace: "production"}, configFactory)
+	require.NoError(t, err)
+	assert.Equal(t, "production", gotNamespace)
+}
+
+func TestRun_NamespaceFlag(t *testing.T) {
+	originalFactory := ConfigurationFactory
+	defer func() { ConfigurationFactory = originalFactory }()
+
+	var gotNamespace string
+	ConfigurationFactory = func(namespace string, _ status.ClientOptions) (*action.Configuration, error) {
+		gotNamespace = namespace
+		return &action.Configuration{Releases: storage.Init(driver.NewMemory())}, nil
+	}
+
+	cmd := newRootCmd()
+	var buf bytes.Buffer
+	cmd.SetOut(&buf)
+	cmd.SetErr(&buf)
+	cmd.SetArgs([]string{"-n", "staging", "test-release", "failed"})
+
+	require.NoError(t, cmd.Execute())
+	assert.Equal(t, "staging", gotNamespace)
+}
+
+func TestRun_NamespacePrecedence(t *testing.T) {
+	originalFactory := ConfigurationFactory
+	defer func() { ConfigurationFactory = originalFactory }()
+	ConfigurationFactory = func(string, status.ClientOptions) (*action.Configuration, error) {
+		store := storage.Init(driver.NewMemory())
+		require.NoError(t, store.Create(&release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   1,
+			Info: &release.Info{
+				Status: release.StatusDeployed,
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}))
+		return &action.Configuration{Releases: store}, nil
+	}
+	t.Setenv("HELM_NAMESPACE", "env-namespace")
+
+	for _, tt := range []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"test-release", "failed", "-o", "json"}, "env-namespace"},
+		{[]string{"test-release", "failed", "-o", "json", "-n", "flag-namespace"}, "flag-namespace"},
+	} {
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetArgs(tt.args)
+		require.NoError(t, cmd.Execute())
+
+		var res changeResult
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &res))
+		assert.Equal(t, tt.expected, res.Namespace)
+	}
+}
+
+func TestRun_KubeFlags(t *testing.T) {
+	originalFactory := ConfigurationFactory
+	defer func() { ConfigurationFactory = originalFactory }()
+
+	var gotOpts status.ClientOptions
+	ConfigurationFactory = func(_ string, opts status.ClientOptions) (*action.Configuration, error) {
+		gotOpts = opts
+		return &action.Configuration{Releases: storage.Init(driver.NewMemory())}, nil
+	}
+
+	t.Run("root command", func(t *testing.T) {
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetErr(&buf)
+		cmd.SetArgs([]string{"--kube-context", "staging-cluster", "--kubeconfig", "/tmp/staging.yaml", "--storage-driver", "sql", "test-release", "failed"})
+
+		require.NoError(t, cmd.Execute())
+		assert.Equal(t, "sql", gotOpts.StorageDriver)
+		assert.Equal(t, "staging-cluster", gotOpts.KubeContext)
+		assert.Equal(t, "/tmp/staging.yaml", gotOpts.KubeConfig)
+	})
+
+	t.Run("token, impersonation, and TLS", func(t *testing.T) {
+		gotOpts = status.ClientOptions{}
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetErr(&buf)
+		cmd.SetArgs([]string{"--token", "ci-token", "--as", "deployer", "--as-group", "ops", "--as-group", "release-managers", "--insecure-skip-tls-verify", "test-release", "failed"})
+
+		require.NoError(t, cmd.Execute())
+		assert.True(t, gotOpts.InsecureSkipTLSVerify)
+		assert.Equal(t, "ci-token", gotOpts.Token)
+		assert.Equal(t, "deployer", gotOpts.AsUser)
+		assert.Equal(t, []string{"ops", "release-managers"}, gotOpts.AsGroups)
+	})
+
+	t.Run("subcommand", func(t *testing.T) {
+		gotOpts = status.ClientOptions{}
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetErr(&buf)
+		cmd.SetArgs([]string{"get", "test-release", "--kube-context", "prod-cluster", "--kubeconfig", "/tmp/prod.yaml"})
+
+		// The release does not exist; only the propagated settings matter here
+		_ = cmd.Execute()
+		assert.Equal(t, "prod-cluster", gotOpts.KubeContext)
+		assert.Equal(t, "/tmp/prod.yaml", gotOpts.KubeConfig)
+	})
+}
+
+func TestRunWithConfigFactory_DryRun(t *testing.T) {
+	t.Run("reports intended change without writing", func(t *testing.T) {
+		mem := driver.NewMemory()
+		store := storage.Init(mem)
+
+		rel := &release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   1,
+			Info: &release.Info{
+				Status: release.StatusDeployed,
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}
+		err := store.Create(rel)
+		require.NoError(t, err)
+
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetErr(&buf)
+
+		err = runWithConfigFactory(cmd, []string{"test-release", "failed"}, runOptions{dryRun: true}, configFactory)
+		require.NoError(t, err)
+		assert.Equal(t, "Would set release \"test-release\" revision 1 status from \"deployed\" to \"failed\"\n", buf.String())
+
+		unchanged, err := store.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusDeployed, unchanged.Info.Status)
+	})
+
+	t.Run("reports specific revision", func(t *testing.T) {
+		mem := driver.NewMemory()
+		store := storage.Init(mem)
+
+		rel := &release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   1,
+			Info: &release.Info{
+				Status: release.StatusSuperseded,
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}
+		err := store.Create(rel)
+		require.NoError(t, err)
+
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetErr(&buf)
+
+		err = runWithConfigFactory(cmd, []string{"test-release", "failed"}, runOptions{revision: 1, dryRun: true}, configFactory)
+		require.NoError(t, err)
+		assert.Contains(t, buf.String(), "Would set release \"test-release\" revision 1")
+	})
+
+	t.Run("reports precondition failure", func(t *testing.T) {
+		mem := driver.NewMemory()
+		store := storage.Init(mem)
+
+		rel := &release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   1,
+			Info: &release.Info{
+				Status: release.StatusDeployed,
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}
+		err := store.Create(rel)
+		require.NoError(t, err)
+
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetErr(&buf)
+
+		err = runWithConfigFactory(cmd, []string{"test-release", "failed"}, runOptions{fromStatuses: []string{"pending-upgrade"}, dryRun: true}, configFactory)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "not in allowed list")
+	})
+}
+
+func TestRunWithConfigFactory_JSONOutput(t *testing.T) {
+	t.Run("emits changed result", func(t *testing.T) {
+		mem := driver.NewMemory()
+		store := storage.Init(mem)
+
+		rel := &release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   1,
+			Info: &release.Info{
+				Status: release.StatusDeployed,
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}
+		err := store.Create(rel)
+		require.NoError(t, err)
+
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetErr(&buf)
+
+		err = runWithConfigFactory(cmd, []string{"test-release", "failed"}, runOptions{namespace: "default", output: "json"}, configFactory)
+		require.NoError(t, err)
+
+		var res changeResult
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &res))
+		assert.Equal(t, changeResult{
+			Release:        "test-release",
+			Namespace:      "default",
+			Revision:       1,
+			PreviousStatus: "deployed",
+			NewStatus:      "failed",
+			Changed:        true,
+			Result:         resultChanged,
+			Description:    "status set to failed",
+		}, res)
+	})
+
+	t.Run("emits skipped result under --no-fail", func(t *testing.T) {
+		mem := driver.NewMemory()
+		store := storage.Init(mem)
+
+		rel := &release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   1,
+			Info: &release.Info{
+				Status: release.StatusDeployed,
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}
+		err := store.Create(rel)
+		require.NoError(t, err)
+
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetErr(&buf)
+
+		opts := runOptions{fromStatuses: []string{"pending-upgrade"}, noFail: true, output: "json"}
+		err = runWithConfigFactory(cmd, []string{"test-release", "failed"}, opts, configFactory)
+		require.NoError(t, err)
+
+		var res changeResult
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &res))
+		assert.False(t, res.Changed)
+		assert.True(t, res.Skipped)
+		assert.Equal(t, resultSkipped, res.Result)
+		assert.Equal(t, "deployed", res.PreviousStatus)
+		assert.Contains(t, res.Reason, "not in allowed list")
+	})
+
+	t.Run("emits not-found result", func(t *testing.T) {
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: storage.Init(driver.NewMemory())}, nil
+		}
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetErr(&buf)
+
+		err := runWithConfigFactory(cmd, []string{"missing", "failed"}, runOptions{output: "json"}, configFactory)
+		require.NoError(t, err)
+
+		var res changeResult
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &res))
+		assert.False(t, res.Changed)
+		assert.Equal(t, resultNotFound, res.Result)
+		assert.Contains(t, res.Reason, "not found")
+	})
+
+	t.Run("rejects invalid output format", func(t *testing.T) {
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return nil, errors.New("should not be called")
+		}
+
+		cmd := newRootCmd()
+		err := runWithConfigFactory(cmd, []string{"test-release", "failed"}, runOptions{output: "xml"}, configFactory)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid --output format")
+	})
+}
+
+func TestRunChanges_Skipped(t *testing.T) {
+	store := storage.Init(driver.NewMemory())
+	for i, st := range []release.Status{release.StatusDeployed, release.StatusPendingUpgrade} {
+		require.NoError(t, store.Create(&release.Release{
+			Name:      []string{"app-a", "app-b"}[i],
+			Namespace: "default",
+			Version:   1,
+			Info:      &release.Info{Status: st},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}))
+	}
+	configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+		return &action.Configuration{Releases: store}, nil
+	}
+
+	cmd := newRootCmd()
+	var buf bytes.Buffer
+	cmd.SetOut(&buf)
+	cmd.SetErr(&buf)
+
+	opts := runOptions{fromStatuses: []string{"pending-upgrade"}, noFail: true}
+	run, err := runChanges(cmd, []string{"app-a", "app-b", "failed"}, opts, configFactory)
+	require.NoError(t, err)
+	assert.Empty(t, buf.String(), "runChanges should leave reporting to the caller")
+	assert.Equal(t, 0, run.failed)
+	require.Len(t, run.results, 2)
+
+	skipped := run.results[0]
+	assert.True(t, skipped.Skipped)
+	assert.False(t, skipped.Changed)
+	assert.Equal(t, resultSkipped, skipped.Result)
+	assert.Equal(t, "deployed", skipped.PreviousStatus)
+	assert.Contains(t, skipped.Reason, `current status "deployed" not in allowed list [pending-upgrade]`)
+
+	changed := run.results[1]
+	assert.False(t, changed.Skipped)
+	assert.True(t, changed.Changed)
+	assert.Equal(t, resultChanged, changed.Result)
+
+	rel, err := store.Get("app-a", 1)
+	require.NoError(t, err)
+	assert.Equal(t, release.StatusDeployed, rel.Info.Status)
+}
+
+func TestRunWithConfigFactory_Description(t *testing.T) {
+	mem := driver.NewMemory()
+	store := storage.Init(mem)
+
+	rel := &release.Release{
+		Name:      "test-release",
+		Namespace: "default",
+		Version:   1,
+		Info: &release.Info{
+			Status: release.StatusDeployed,
+		},
+		Chart: &chart.Chart{
+			Metadata: &chart.Metadata{
+				Name:    "test-chart",
+				Version: "1.0.0",
+			},
+		},
+	}
+	err := store.Create(rel)
+	require.NoError(t, err)
+
+	configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+		return &action.Configuration{Releases: store}, nil
+	}
+
+	cmd := newRootCmd()
+	var buf bytes.Buffer
+	cmd.SetOut(&buf)
+	cmd.SetErr(&buf)
+
+	err = runWithConfigFactory(cmd, []string{"test-release", "failed"}, runOptions{description: "smoke tests failed"}, configFactory)
+	require.NoError(t, err)
+
+	updated, err := store.Last("test-release")
+	require.NoError(t, err)
+	assert.Equal(t, "smoke tests failed", updated.Info.Description)
+}
+
+func TestRunWithConfigFactory_InvalidDescriptionTemplate(t *testing.T) {
+	configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+		t.Fatal("configuration should not be created for an invalid template")
+		return nil, nil
+	}
+
+	err := runWithConfigFactory(newRootCmd(), []string{"test-release", "failed"}, runOptions{description: "{{.Status"}, configFactory)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--description: invalid description template")
+}
+
+func TestRunWithConfigFactory_MultipleReleases(t *testing.T) {
+	newStore := func(t *testing.T) *storage.Storage {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		for _, r := range []struct {
+			name    string
+			version int
+		}{
+			{"rel1", 1},
+			{"rel1", 2},
+			{"rel2", 1},
+			{"rel3", 1},
+			{"rel3", 2},
+		} {
+			rel := &release.Release{
+				Name:      r.name,
+				Namespace: "default",
+				Version:   r.version,
+				Info: &release.Info{
+					Status: release.StatusDeployed,
+				},
+				Chart: &chart.Chart{
+					Metadata: &chart.Metadata{
+						Name:    "test-chart",
+						Version: "1.0.0",
+					},
+				},
+			}
+			require.NoError(t, store.Create(rel))
+		}
+		return store
+	}
+
+	t.Run("sets status on every release", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetErr(&buf)
+
+		err := runWithConfigFactory(cmd, []string{"rel1", "rel2", "rel3", "failed"}, runOptions{}, configFactory)
+		require.NoError(t, err)
+		assert.Contains(t, buf.String(), `Release "rel1" revision 2 status changed`)
+		assert.Contains(t, buf.String(), `Release "rel2" revision 1 status changed`)
+		assert.Contains(t, buf.String(), `Release "rel3" revision 2 status changed`)
+
+		for _, name := range []string{"rel1", "rel2", "rel3"} {
+			updated, err := store.Last(name)
+			require.NoError(t, err)
+			assert.Equal(t, release.StatusFailed, updated.Info.Status)
+		}
+	})
+
+	t.Run("reports missing releases and continues", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetErr(&buf)
+
+		err := runWithConfigFactory(cmd, []string{"rel1", "missing", "rel2", "failed"}, runOptions{}, configFactory)
+		require.NoError(t, err)
+		assert.Contains(t, buf.String(), `Warning: release "missing" not found`)
+
+		updated, err := store.Last("rel2")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusFailed, updated.Info.Status)
+	})
+
+	t.Run("aggregates hard failures", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetErr(&buf)
+
+		// rel2 has no revision 2
+		err := runWithConfigFactory(cmd, []string{"rel1", "rel2", "rel3", "failed"}, runOptions{revision: 2}, configFactory)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to set status on 1 of 3 releases")
+		assert.Contains(t, buf.String(), `Error: release "rel2"`)
+
+		updated, err := store.Get("rel3", 2)
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusFailed, updated.Info.Status)
+	})
+
+	t.Run("respects --no-fail per release", func(t *testing.T) {
+		store := newStore(t)
+		_, err := status.SetStatus(t.Context(), &action.Configuration{Releases: store}, "rel2", release.StatusPendingUpgrade, status.SetStatusOptions{})
+		require.NoError(t, err)
+
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetErr(&buf)
+
+		opts := runOptions{fromStatuses: []string{"pending-upgrade"}, noFail: true}
+		err = runWithConfigFactory(cmd, []string{"rel1", "rel2", "deployed"}, opts, configFactory)
+		require.NoError(t, err)
+		assert.Contains(t, buf.String(), "Skipped:")
+		assert.Contains(t, buf.String(), `Release "rel2" revision 1 status changed from "pending-upgrade" to "deployed"`)
+	})
+
+	t.Run("emits JSON results with a summary", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetErr(&buf)
+
+		err := runWithConfigFactory(cmd, []string{"rel1", "missing", "failed"}, runOptions{output: "json"}, configFactory)
+		require.NoError(t, err)
+
+		var out batchOutput
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &out))
+		results := out.Results
+		require.Len(t, results, 2)
+		assert.Equal(t, resultChanged, results[0].Result)
+		assert.Equal(t, resultNotFound, results[1].Result)
+		assert.Equal(t, batchSummary{Total: 2, Changed: 1, NotFound: 1}, out.Summary)
+	})
+}
+
+func TestRunWithConfigFactory_BatchSummary(t *testing.T) {
+	newStore := func(t *testing.T) *storage.Storage {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		for name, st := range map[string]release.Status{
+			"changed":   release.StatusDeployed,
+			"unchanged": release.StatusFailed,
+			"skipped":   release.StatusPendingInstall,
+			"errored":   release.StatusSuperseded,
+		} {
+			require.NoError(t, store.Create(&release.Release{
+				Name:      name,
+				Namespace: "default",
+				Version:   1,
+				Info:      &release.Info{Status: st},
+				Chart: &chart.Chart{
+					Metadata: &chart.Metadata{
+						Name:    "test-chart",
+						Version: "1.0.0",
+					},
+				},
+			}))
+		}
+		return store
+	}
+	args := []string{"changed", "unchanged", "skipped", "errored", "missing", "failed"}
+	opts := runOptions{notFromStatuses: []string{"pending-install"}, noFail: true, strict: true}
+
+	t.Run("counts each outcome in text output", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		err := runWithConfigFactory(cmd, args, opts, configFactory)
+		assert.EqualError(t, err, "failed to set status on 1 of 5 releases")
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		assert.Equal(t, "Summary: 1 changed, 1 unchanged, 1 skipped, 1 not found, 1 errored (5 total)", lines[len(lines)-1])
+	})
+
+	t.Run("includes the summary in JSON output", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		jsonOpts := opts
+		jsonOpts.output = outputJSON
+		err := runWithConfigFactory(cmd, args, jsonOpts, configFactory)
+		assert.Error(t, err)
+
+		var out batchOutput
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &out))
+		assert.Len(t, out.Results, 5)
+		assert.Equal(t, batchSummary{Total: 5, Changed: 1, Unchanged: 1, Skipped: 1, NotFound: 1, Errored: 1}, out.Summary)
+	})
+
+	t.Run("succeeds without hard errors", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		err := runWithConfigFactory(cmd, []string{"changed", "unchanged", "skipped", "missing", "failed"}, opts, configFactory)
+		require.NoError(t, err)
+		assert.Contains(t, buf.String(), "Summary: 1 changed, 1 unchanged, 1 skipped, 1 not found, 0 errored (4 total)")
+	})
+}
+
+func TestValidateRootArgs(t *testing.T) {
+	t.Run("requires release and status", func(t *testing.T) {
+		cmd := newRootCmd()
+		assert.Error(t, validateRootArgs(cmd, []string{"failed"}))
+		assert.NoError(t, validateRootArgs(cmd, []string{"my-release", "failed"}))
+		assert.NoError(t, validateRootArgs(cmd, []string{"rel1", "rel2", "failed"}))
+	})
+
+	t.Run("requires only status with --selector", func(t *testing.T) {
+		cmd := newRootCmd()
+		require.NoError(t, cmd.Flags().Set("selector", "app=frontend"))
+		assert.NoError(t, validateRootArgs(cmd, []string{"failed"}))
+
+		err := validateRootArgs(cmd, []string{"my-release", "failed"})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "--selector cannot be combined with release names")
+	})
+
+	t.Run("accepts no arguments with --from-file", func(t *testing.T) {
+		cmd := newRootCmd()
+		require.NoError(t, cmd.Flags().Set("from-file", "changes.txt"))
+		assert.NoError(t, validateRootArgs(cmd, nil))
+
+		err := validateRootArgs(cmd, []string{"failed"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "--from-file cannot be combined with release names or STATUS")
+
+		require.NoError(t, cmd.Flags().Set("selector", "app=frontend"))
+		err = validateRootArgs(cmd, nil)
+		require.Error(t, err)
+		assert.Equal(t, "--from-file cannot be combined with --selector", err.Error())
+	})
+
+	t.Run("accepts RELEASE=STATUS pairs on their own", func(t *testing.T) {
+		cmd := newRootCmd()
+		assert.NoError(t, validateRootArgs(cmd, []string{"rel1=failed"}))
+		assert.NoError(t, validateRootArgs(cmd, []string{"rel1=failed", "rel2=deployed"}))
+
+		require.NoError(t, cmd.Flags().Set("selector", "app=frontend"))
+		err := validateRootArgs(cmd, []string{"rel1=failed"})
+		require.Error(t, err)
+		assert.Equal(t, "RELEASE=STATUS pairs cannot be combined with --selector", err.Error())
+	})
+}
+
+func TestRunWithConfigFactory_DeployedWindow(t *testing.T) {
+	now := time.Now()
+	newStore := func(t *testing.T) *storage.Storage {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		for _, r := range []struct {
+			name string
+			age  time.Duration
+		}{
+			{"stale", 90 * 24 * time.Hour},
+			{"recent", 10 * 24 * time.Hour},
+			{"fresh", time.Hour},
+		} {
+			require.NoError(t, store.Create(&release.Release{
+				Name:      r.name,
+				Namespace: "default",
+				Version:   1,
+				Labels:    map[string]string{"team": "web"},
+				Info: &release.Info{
+					Status:       release.StatusDeployed,
+					LastDeployed: helmtime.Time{Time: now.Add(-r.age)},
+				},
+				Chart: &chart.Chart{
+					Metadata: &chart.Metadata{
+						Name:    "test-chart",
+						Version: "1.0.0",
+					},
+				},
+			}))
+		}
+		return store
+	}
+	changed := func(t *testing.T, opts runOptions) []string {
+		t.Helper()
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		cmd.SetOut(&bytes.Buffer{})
+		opts.selector = "team=web"
+		require.NoError(t, runWithConfigFactory(cmd, []string{"failed"}, opts, configFactory))
+
+		var names []string
+		for _, name := range []string{"fresh", "recent", "stale"} {
+			rel, err := store.Last(name)
+			require.NoError(t, err)
+			if rel.Info.Status == release.StatusFailed {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+
+	t.Run("--deployed-before", func(t *testing.T) {
+		assert.Equal(t, []string{"stale"}, changed(t, runOptions{deployedBefore: now.Add(-30 * 24 * time.Hour)}))
+	})
+
+	t.Run("--deployed-after", func(t *testing.T) {
+		assert.Equal(t, []string{"fresh", "recent"}, changed(t, runOptions{deployedAfter: now.Add(-30 * 24 * time.Hour)}))
+	})
+
+	t.Run("both", func(t *testing.T) {
+		opts := runOptions{deployedAfter: now.Add(-30 * 24 * time.Hour), deployedBefore: now.Add(-24 * time.Hour)}
+		assert.Equal(t, []string{"recent"}, changed(t, opts))
+	})
+
+	t.Run("requires --selector", func(t *testing.T) {
+		err := runWithConfigFactory(newRootCmd(), []string{"my-release", "failed"}, runOptions{deployedBefore: now}, nil)
+		assert.EqualError(t, err, "--deployed-after and --deployed-before require --selector")
+	})
+
+	t.Run("rejects an empty window", func(t *testing.T) {
+		opts := runOptions{selector: "team=web", deployedAfter: now, deployedBefore: now.Add(-time.Hour)}
+		err := runWithConfigFactory(newRootCmd(), []string{"failed"}, opts, nil)
+		assert.EqualError(t, err, "--deployed-after must be earlier than --deployed-before")
+	})
+}
+
+func TestParseDeployedTime(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	parsed, err := parseDeployedTime("deployed-before", "", now)
+	require.NoError(t, err)
+	assert.True(t, parsed.IsZero())
+
+	parsed, err = parseDeployedTime("deployed-before", "2024-01-02T03:04:05Z", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), parsed)
+
+	parsed, err = parseDeployedTime("deployed-before", "36h", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 5, 31, 0, 0, 0, 0, time.UTC), parsed)
+
+	for _, value := range []string{"yesterday", "-1h", "2024-01-02"} {
+		_, err := parseDeployedTime("deployed-after", value, now)
+		assert.ErrorContains(t, err, fmt.Sprintf("invalid --deployed-after %q", value))
+	}
+}
+
+func TestRun_DeployedTimeFlags(t *testing.T) {
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"--selector", "team=web", "--deployed-before", "last week", "failed"})
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	err := cmd.Execute()
+	assert.ErrorContains(t, err, `invalid --deployed-before "last week"`)
+}
+
+func TestRunWithConfigFactory_PendingOnly(t *testing.T) {
+	newStore := func(t *testing.T) *storage.Storage {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		for _, r := range []struct {
+			name   string
+			status release.Status
+		}{
+			{"healthy", release.StatusDeployed},
+			{"installing", release.StatusPendingInstall},
+			{"upgrading", release.StatusPendingUpgrade},
+		} {
+			require.NoError(t, store.Create(&release.Release{
+				Name:      r.name,
+				Namespace: "default",
+				Version:   1,
+				Labels:    map[string]string{"team": "web"},
+				Info: &release.Info{
+					Status: r.status,
+				},
+				Chart: &chart.Chart{
+					Metadata: &chart.Metadata{
+						Name:    "test-chart",
+						Version: "1.0.0",
+					},
+				},
+			}))
+		}
+		return store
+	}
+	factoryFor := func(store *storage.Storage) configurationFactory {
+		return func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+	}
+	statusOf := func(t *testing.T, store *storage.Storage, name string) release.Status {
+		t.Helper()
+		rel, err := store.Last(name)
+		require.NoError(t, err)
+		return rel.Info.Status
+	}
+
+	t.Run("skips releases that are not pending", func(t *testing.T) {
+		store := newStore(t)
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		opts := runOptions{selector: "team=web", pendingOnly: true}
+		require.NoError(t, runWithConfigFactory(cmd, []string{"failed"}, opts, factoryFor(store)))
+		assert.Contains(t, buf.String(), `Skipped: current status "deployed" not in allowed list [pending-install pending-upgrade pending-rollback]; cannot set to "failed"`)
+		assert.Contains(t, buf.String(), "Summary: 2 changed, 0 unchanged, 1 skipped, 0 not found, 0 errored (3 total)")
+		assert.Equal(t, release.StatusDeployed, statusOf(t, store, "healthy"))
+		assert.Equal(t, release.StatusFailed, statusOf(t, store, "installing"))
+		assert.Equal(t, release.StatusFailed, statusOf(t, store, "upgrading"))
+	})
+
+	t.Run("skips a single healthy release without failing", func(t *testing.T) {
+		store := newStore(t)
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		opts := runOptions{pendingOnly: true, output: outputJSON}
+		require.NoError(t, runWithConfigFactory(cmd, []string{"healthy", "failed"}, opts, factoryFor(store)))
+		var res changeResult
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &res))
+		assert.Equal(t, resultSkipped, res.Result)
+		assert.Equal(t, "deployed", res.PreviousStatus)
+		assert.Equal(t, release.StatusDeployed, statusOf(t, store, "healthy"))
+	})
+
+	t.Run("narrows --from to the pending statuses", func(t *testing.T) {
+		store := newStore(t)
+
+		cmd := newRootCmd()
+		cmd.SetOut(&bytes.Buffer{})
+
+		opts := runOptions{selector: "team=web", pendingOnly: true, fromStatuses: []string{"deployed", "pending-upgrade"}}
+		require.NoError(t, runWithConfigFactory(cmd, []string{"failed"}, opts, factoryFor(store)))
+		assert.Equal(t, release.StatusDeployed, statusOf(t, store, "healthy"))
+		assert.Equal(t, release.StatusPendingInstall, statusOf(t, store, "installing"))
+		assert.Equal(t, release.StatusFailed, statusOf(t, store, "upgrading"))
+	})
+
+	t.Run("rejects --from with no pending status", func(t *testing.T) {
+		opts := runOptions{pendingOnly: true, fromStatuses: []string{"deployed"}}
+		err := runWithConfigFactory(newRootCmd(), []string{"healthy", "failed"}, opts, nil)
+		assert.EqualError(t, err, "--pending-only cannot be combined with --from statuses that are not pending")
+	})
+
+	t.Run("rejects --force and --atomic", func(t *testing.T) {
+		err := runWithConfigFactory(newRootCmd(), []string{"healthy", "failed"}, runOptions{pendingOnly: true, force: true}, nil)
+		assert.EqualError(t, err, "--force cannot be combined with --pending-only")
+
+		err = runWithConfigFactory(newRootCmd(), []string{"healthy", "failed"}, runOptions{pendingOnly: true, atomic: true}, nil)
+		assert.EqualError(t, err, "--atomic cannot be combined with --pending-only")
+	})
+}
+
+func TestRunWithConfigFactory_Selector(t *testing.T) {
+	newStore := func(t *testing.T) *storage.Storage {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		for _, r := range []struct {
+			name   string
+			status release.Status
+			app    string
+		}{
+			{"frontend-a", release.StatusDeployed, "frontend"},
+			{"frontend-b", release.StatusPendingUpgrade, "frontend"},
+			{"backend", release.StatusDeployed, "backend"},
+		} {
+			rel := &release.Release{
+				Name:      r.name,
+				Namespace: "default",
+				Version:   1,
+				Labels:    map[string]string{"app": r.app},
+				Info: &release.Info{
+					Status: r.status,
+				},
+				Chart: &chart.Chart{
+					Metadata: &chart.Metadata{
+						Name:    "test-chart",
+						Version: "1.0.0",
+					},
+				},
+			}
+			require.NoError(t, store.Create(rel))
+		}
+		return store
+	}
+
+	t.Run("sets status on matching releases", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetErr(&buf)
+
+		err := runWithConfigFactory(cmd, []string{"failed"}, runOptions{selector: "app=frontend"}, configFactory)
+		require.NoError(t, err)
+		assert.Contains(t, buf.String(), "Summary: 2 changed, 0 unchanged, 0 skipped, 0 not found, 0 errored (2 total)")
+
+		for name, expected := range map[string]release.Status{
+			"frontend-a": release.StatusFailed,
+			"frontend-b": release.StatusFailed,
+			"backend":    release.StatusDeployed,
+		} {
+			rel, err := store.Last(name)
+			require.NoError(t, err)
+			assert.Equal(t, expected, rel.Info.Status, name)
+		}
+	})
+
+	t.Run("applies --from per release", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetErr(&buf)
+
+		opts := runOptions{selector: "app=frontend", fromStatuses: []string{"pending-upgrade"}, noFail: true}
+		err := runWithConfigFactory(cmd, []string{"deployed"}, opts, configFactory)
+		require.NoError(t, err)
+		assert.Contains(t, buf.String(), "Skipped:")
+		assert.Contains(t, buf.String(), "Summary: 1 changed, 0 unchanged, 1 skipped, 0 not found, 0 errored (2 total)")
+	})
+
+	t.Run("only matches releases in a --status", func(t *testing.T) {
+		statusesAfter := func(t *testing.T, opts runOptions) map[string]release.Status {
+			t.Helper()
+			store := newStore(t)
+			configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+				return &action.Configuration{Releases: store}, nil
+			}
+
+			cmd := newRootCmd()
+			cmd.SetOut(&bytes.Buffer{})
+			require.NoError(t, runWithConfigFactory(cmd, []string{"failed"}, opts, configFactory))
+
+			result := make(map[string]release.Status)
+			for _, name := range []string{"frontend-a", "frontend-b", "backend"} {
+				rel, err := store.Last(name)
+				require.NoError(t, err)
+				result[name] = rel.Info.Status
+			}
+			return result
+		}
+
+		assert.Equal(t, map[string]release.Status{
+			"frontend-a": release.StatusDeployed,
+			"frontend-b": release.StatusFailed,
+			"backend":    release.StatusDeployed,
+		}, statusesAfter(t, runOptions{selector: "app=frontend", statuses: []string{"pending-upgrade"}}))
+
+		assert.Equal(t, map[string]release.Status{
+			"frontend-a": release.StatusFailed,
+			"frontend-b": release.StatusFailed,
+			"backend":    release.StatusFailed,
+		}, statusesAfter(t, runOptions{selector: "app in (frontend, backend)", statuses: []string{"deployed", "pending-upgrade"}}))
+	})
+
+	t.Run("rejects an invalid or misplaced --status", func(t *testing.T) {
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: newStore(t)}, nil
+		}
+
+		err := runWithConfigFactory(newRootCmd(), []string{"failed"}, runOptions{selector: "app=frontend", statuses: []string{"bogus"}}, configFactory)
+		assert.ErrorContains(t, err, `invalid --status status "bogus"`)
+
+		err = runWithConfigFactory(newRootCmd(), []string{"backend", "failed"}, runOptions{statuses: []string{"deployed"}}, configFactory)
+		assert.EqualError(t, err, "--status requires --selector; use --from to set a named release only if it is in a status")
+	})
+
+	t.Run("reports zero matches", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetErr(&buf)
+
+		err := runWithConfigFactory(cmd, []string{"failed"}, runOptions{selector: "app=missing"}, configFactory)
+		require.NoError(t, err)
+		assert.Equal(t, "Summary: 0 changed, 0 unchanged, 0 skipped, 0 not found, 0 errored (0 total)\n", buf.String())
+	})
+
+	t.Run("emits a JSON results list even for a single match", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetErr(&buf)
+
+		err := runWithConfigFactory(cmd, []string{"failed"}, runOptions{selector: "app=backend", output: "json"}, configFactory)
+		require.NoError(t, err)
+
+		var out batchOutput
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &out))
+		results := out.Results
+		require.Len(t, results, 1)
+		assert.Equal(t, "backend", results[0].Release)
+	})
+
+	t.Run("rejects invalid selector", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		err := runWithConfigFactory(cmd, []string{"failed"}, runOptions{selector: "app in ("}, configFactory)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid selector")
+	})
+}
+
+func TestRunWithConfigFactory_Wait(t *testing.T) {
+	mem := driver.NewMemory()
+	store := storage.Init(mem)
+
+	rel := &release.Release{
+		Name:      "test-release",
+		Namespace: "default",
+		Version:   1,
+		Info: &release.Info{
+			Status: release.StatusPendingUpgrade,
+		},
+		Chart: &chart.Chart{
+			Metadata: &chart.Metadata{
+				Name:    "test-chart",
+				Version: "1.0.0",
+			},
+		},
+	}
+	err := store.Create(rel)
+	require.NoError(t, err)
+
+	configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+		return &action.Configuration{Releases: store}, nil
+	}
+
+	cmd := newRootCmd()
+	var buf bytes.Buffer
+	cmd.SetOut(&buf)
+	cmd.SetErr(&buf)
+
+	err = runWithConfigFactory(cmd, []string{"test-release", "deployed"}, runOptions{wait: true, timeout: time.Second}, configFactory)
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), `status changed from "pending-upgrade" to "deployed"`)
+}
+
+func TestRunWithConfigFactory_RevisionNotFound(t *testing.T) {
+	mem := driver.NewMemory()
+	store := storage.Init(mem)
+
+	rel := &release.Release{
+		Name:      "test-release",
+		Namespace: "default",
+		Version:   1,
+		Info: &release.Info{
+			Status: release.StatusDeployed,
+		},
+		Chart: &chart.Chart{
+			Metadata: &chart.Metadata{
+				Name:    "test-chart",
+				Version: "1.0.0",
+			},
+		},
+	}
+	err := store.Create(rel)
+	require.NoError(t, err)
+
+	configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+		return &action.Configuration{Releases: store}, nil
+	}
+
+	cmd := newRootCmd()
+	var buf bytes.Buffer
+	cmd.SetOut(&buf)
+	cmd.SetErr(&buf)
+
+	err = runWithConfigFactory(cmd, []string{"test-release", "failed"}, runOptions{revision: 7}, configFactory)
+	var revErr *status.RevisionNotFoundError
+	require.True(t, errors.As(err, &revErr), "error should be *RevisionNotFoundError")
+	assert.Equal(t, `release "test-release" revision 7 not found`, err.Error())
+}
+
+func TestRunWithConfigFactory_NotFromFlag(t *testing.T) {
+	newStore := func(t *testing.T) *storage.Storage {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		rel := &release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   1,
+			Info: &release.Info{
+				Status: release.StatusDeployed,
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}
+		require.NoError(t, store.Create(rel))
+		return store
+	}
+
+	t.Run("fails when current status is excluded", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetErr(&buf)
+
+		err := runWithConfigFactory(cmd, []string{"test-release", "failed"}, runOptions{notFromStatuses: []string{"deployed", "superseded"}}, configFactory)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "in disallowed list")
+	})
+
+	t.Run("skips with --no-fail when current status is excluded", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetErr(&buf)
+
+		err := runWithConfigFactory(cmd, []string{"test-release", "failed"}, runOptions{notFromStatuses: []string{"deployed"}, noFail: true}, configFactory)
+		require.NoError(t, err)
+		assert.Contains(t, buf.String(), "Skipped:")
+		assert.Contains(t, buf.String(), "in disallowed list")
+
+		unchanged, err := store.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusDeployed, unchanged.Info.Status)
+	})
+
+	t.Run("succeeds when current status is not excluded", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetErr(&buf)
+
+		err := runWithConfigFactory(cmd, []string{"test-release", "failed"}, runOptions{notFromStatuses: []string{"pending-install"}}, configFactory)
+		require.NoError(t, err)
+		assert.Contains(t, buf.String(), "status changed")
+	})
+
+	t.Run("fails with invalid not-from status", func(t *testing.T) {
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return nil, errors.New("should not be called")
+		}
+
+		cmd := newRootCmd()
+		err := runWithConfigFactory(cmd, []string{"test-release", "failed"}, runOptions{notFromStatuses: []string{"bogus"}}, configFactory)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid --not-from status")
+	})
+}
+
+func TestRunWithConfigFactory_AlreadyAtTargetStatus(t *testing.T) {
+	newStore := func(t *testing.T) *storage.Storage {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		rel := &release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   1,
+			Info: &release.Info{
+				Status:      release.StatusDeployed,
+				Description: "Upgrade complete",
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}
+		require.NoError(t, store.Create(rel))
+		return store
+	}
+
+	t.Run("reports no change", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetErr(&buf)
+
+		err := runWithConfigFactory(cmd, []string{"test-release", "deployed"}, runOptions{output: "json"}, configFactory)
+		require.NoError(t, err)
+
+		var res changeResult
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &res))
+		assert.False(t, res.Changed)
+		assert.Equal(t, resultUnchanged, res.Result)
+		assert.Equal(t, "already deployed, no change", res.Reason)
+
+		unchanged, err := store.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, "Upgrade complete", unchanged.Info.Description)
+	})
+
+	t.Run("writes with --force-write", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetErr(&buf)
+
+		err := runWithConfigFactory(cmd, []string{"test-release", "deployed"}, runOptions{forceWrite: true}, configFactory)
+		require.NoError(t, err)
+		assert.Contains(t, buf.String(), `status changed from "deployed" to "deployed"`)
+
+		updated, err := store.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, "status set to deployed", updated.Info.Description)
+	})
+}
+
+func TestRunWithConfigFactory_Verbose(t *testing.T) {
+	newStore := func(t *testing.T) *storage.Storage {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		rel := &release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   1,
+			Info: &release.Info{
+				Status: release.StatusDeployed,
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}
+		require.NoError(t, store.Create(rel))
+		return store
+	}
+
+	t.Run("logs steps to stderr", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		var stdout, stderr bytes.Buffer
+		cmd.SetOut(&stdout)
+		cmd.SetErr(&stderr)
+
+		err := runWithConfigFactory(cmd, []string{"test-release", "failed"}, runOptions{verbose: true}, configFactory)
+		require.NoError(t, err)
+		assert.Equal(t, "Release \"test-release\" revision 1 status changed from \"deployed\" to \"failed\"\n", stdout.String())
+		assert.Contains(t, stderr.String(), "resolved release")
+		assert.Contains(t, stderr.String(), "release updated")
+	})
+
+	t.Run("is silent without --verbose", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		var stdout, stderr bytes.Buffer
+		cmd.SetOut(&stdout)
+		cmd.SetErr(&stderr)
+
+		err := runWithConfigFactory(cmd, []string{"test-release", "failed"}, runOptions{}, configFactory)
+		require.NoError(t, err)
+		assert.Empty(t, stderr.String())
+	})
+
+	t.Run("rejects invalid log format", func(t *testing.T) {
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return nil, errors.New("should not be called")
+		}
+
+		cmd := newRootCmd()
+		err := runWithConfigFactory(cmd, []string{"test-release", "failed"}, runOptions{verbose: true, logFormat: "xml"}, configFactory)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid --log-format")
+	})
+}
+
+func TestRunWithConfigFactory_StrictTransitions(t *testing.T) {
+	mem := driver.NewMemory()
+	store := storage.Init(mem)
+
+	rel := &release.Release{
+		Name:      "test-release",
+		Namespace: "default",
+		Version:   1,
+		Info: &release.Info{
+			Status: release.StatusUninstalling,
+		},
+		Chart: &chart.Chart{
+			Metadata: &chart.Metadata{
+				Name:    "test-chart",
+				Version: "1.0.0",
+			},
+		},
+	}
+	err := store.Create(rel)
+	require.NoError(t, err)
+
+	configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+		return &action.Configuration{Releases: store}, nil
+	}
+
+	cmd := newRootCmd()
+	var buf bytes.Buffer
+	cmd.SetOut(&buf)
+	cmd.SetErr(&buf)
+
+	err = runWithConfigFactory(cmd, []string{"test-release", "pending-install"}, runOptions{strict: true}, configFactory)
+	var transitionErr *status.InvalidTransitionError
+	assert.True(t, errors.As(err, &transitionErr), "error should be *InvalidTransitionError")
+}
+
+func TestRunWithConfigFactory_SafeMode(t *testing.T) {
+	newStore := func(t *testing.T) *storage.Storage {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		require.NoError(t, store.Create(&release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   1,
+			Info: &release.Info{
+				Status: release.StatusFailed,
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}))
+		return store
+	}
+	statusOf := func(t *testing.T, store *storage.Storage) release.Status {
+		t.Helper()
+		rel, err := store.Last("test-release")
+		require.NoError(t, err)
+		return rel.Info.Status
+	}
+
+	t.Run("refuses to set a failed release to deployed", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		err := runWithConfigFactory(newRootCmd(), []string{"test-release", "deployed"}, runOptions{}, configFactory)
+		var maskedErr *status.MaskedFailureError
+		require.ErrorAs(t, err, &maskedErr)
+		assert.EqualError(t, err, `release "test-release" revision 1 is "failed"; setting it to "deployed" would hide the failure; `+
+			`use --i-understand to set it anyway`)
+		assert.Equal(t, release.StatusFailed, statusOf(t, store))
+	})
+
+	for name, opts := range map[string]runOptions{
+		"--i-understand": {iUnderstand: true},
+		"--force":        {force: true},
+	} {
+		t.Run("allows it with "+name, func(t *testing.T) {
+			store := newStore(t)
+			configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+				return &action.Configuration{Releases: store}, nil
+			}
+
+			cmd := newRootCmd()
+			cmd.SetOut(&bytes.Buffer{})
+			require.NoError(t, runWithConfigFactory(cmd, []string{"test-release", "deployed"}, opts, configFactory))
+			assert.Equal(t, release.StatusDeployed, statusOf(t, store))
+		})
+	}
+
+	t.Run("--i-understand keeps the other checks", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		opts := runOptions{iUnderstand: true, fromStatuses: []string{"pending-upgrade"}}
+		err := runWithConfigFactory(newRootCmd(), []string{"test-release", "deployed"}, opts, configFactory)
+		var precondErr *status.PreconditionError
+		assert.ErrorAs(t, err, &precondErr)
+		assert.Equal(t, release.StatusFailed, statusOf(t, store))
+	})
+}
+
+func TestRunWithConfigFactory_DisallowUnknown(t *testing.T) {
+	store := storage.Init(driver.NewMemory())
+	for _, name := range []string{"app-a", "app-b"} {
+		require.NoError(t, store.Create(&release.Release{
+			Name:      name,
+			Namespace: "default",
+			Version:   1,
+			Info:      &release.Info{Status: release.StatusDeployed},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}))
+	}
+	configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+		return &action.Configuration{Releases: store}, nil
+	}
+	statusOf := func(t *testing.T, name string) release.Status {
+		t.Helper()
+		rel, err := store.Last(name)
+		require.NoError(t, err)
+		return rel.Info.Status
+	}
+
+	t.Run("refuses a status of unknown", func(t *testing.T) {
+		err := runWithConfigFactory(newRootCmd(), []string{"app-a", "unknown"}, runOptions{disallowUnknown: true}, configFactory)
+		assert.EqualError(t, err, "invalid status: unknown is not allowed (--disallow-unknown)")
+		assert.Equal(t, release.StatusDeployed, statusOf(t, "app-a"))
+	})
+
+	t.Run("refuses a RELEASE=STATUS pair of unknown", func(t *testing.T) {
+		err := runWithConfigFactory(newRootCmd(), []string{"app-a=failed", "app-b=UNKNOWN"}, runOptions{disallowUnknown: true}, configFactory)
+		assert.EqualError(t, err, `release "app-b": invalid status: unknown is not allowed (--disallow-unknown)`)
+		assert.Equal(t, release.StatusDeployed, statusOf(t, "app-a"))
+		assert.Equal(t, release.StatusDeployed, statusOf(t, "app-b"))
+	})
+
+	t.Run("allows other statuses", func(t *testing.T) {
+		cmd := newRootCmd()
+		cmd.SetOut(&bytes.Buffer{})
+		require.NoError(t, runWithConfigFactory(cmd, []string{"app-a", "failed"}, runOptions{disallowUnknown: true}, configFactory))
+		assert.Equal(t, release.StatusFailed, statusOf(t, "app-a"))
+	})
+
+	t.Run("allows unknown by default", func(t *testing.T) {
+		cmd := newRootCmd()
+		cmd.SetOut(&bytes.Buffer{})
+		require.NoError(t, runWithConfigFactory(cmd, []string{"app-b", "unknown"}, runOptions{}, configFactory))
+		assert.Equal(t, release.StatusUnknown, statusOf(t, "app-b"))
+	})
+}
+
+func TestRunWithConfigFactory_ExpectChart(t *testing.T) {
+	store := storage.Init(driver.NewMemory())
+	for name, chartName := range map[string]string{"app-a": "test-chart", "app-b": "other-chart"} {
+		require.NoError(t, store.Create(&release.Release{
+			Name:      name,
+			Namespace: "default",
+			Version:   1,
+			Info:      &release.Info{Status: release.StatusDeployed},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    chartName,
+					Version: "1.0.0",
+				},
+			},
+		}))
+	}
+	configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+		return &action.Configuration{Releases: store}, nil
+	}
+	statusOf := func(t *testing.T, name string) release.Status {
+		t.Helper()
+		rel, err := store.Last(name)
+		require.NoError(t, err)
+		return rel.Info.Status
+	}
+
+	t.Run("changes a release of the expected chart", func(t *testing.T) {
+		cmd := newRootCmd()
+		cmd.SetOut(&bytes.Buffer{})
+		require.NoError(t, runWithConfigFactory(cmd, []string{"app-a", "failed"}, runOptions{expectChart: "test-chart"}, configFactory))
+		assert.Equal(t, release.StatusFailed, statusOf(t, "app-a"))
+	})
+
+	t.Run("refuses a release of another chart", func(t *testing.T) {
+		for _, opts := range []runOptions{{expectChart: "test-chart"}, {expectChart: "test-chart", force: true}} {
+			cmd := newRootCmd()
+			cmd.SetOut(&bytes.Buffer{})
+			err := runWithConfigFactory(cmd, []string{"app-b", "failed"}, opts, configFactory)
+			var mismatchErr *status.ChartMismatchError
+			require.ErrorAs(t, err, &mismatchErr)
+			assert.EqualError(t, err, `release "app-b" revision 1 has chart "other-chart", expected "test-chart"`)
+			assert.Equal(t, release.StatusDeployed, statusOf(t, "app-b"))
+		}
+	})
+
+	t.Run("changes a release whose chart version matches", func(t *testing.T) {
+		for _, expected := range []string{"1.0.0", ">=1.0.0, <2.0.0"} {
+			cmd := newRootCmd()
+			cmd.SetOut(&bytes.Buffer{})
+			require.NoError(t, runWithConfigFactory(cmd, []string{"app-a", "pending-upgrade"}, runOptions{expectChart: "test-chart", expectVersion: expected}, configFactory), expected)
+			assert.Equal(t, release.StatusPendingUpgrade, statusOf(t, "app-a"))
+			require.NoError(t, runWithConfigFactory(cmd, []string{"app-a", "deployed"}, runOptions{force: true}, configFactory))
+		}
+	})
+
+	t.Run("refuses a release whose chart version does not match", func(t *testing.T) {
+		for _, expected := range []string{"1.0.1", ">=1.2.0"} {
+			cmd := newRootCmd()
+			cmd.SetOut(&bytes.Buffer{})
+			err := runWithConfigFactory(cmd, []string{"app-a", "failed"}, runOptions{expectVersion: expected, force: true}, configFactory)
+			assert.EqualError(t, err, `release "app-a" revision 1 has chart "test-chart" version "1.0.0", which does not match "`+expected+`"`)
+			assert.Equal(t, release.StatusDeployed, statusOf(t, "app-a"))
+		}
+	})
+
+	t.Run("rejects an invalid chart version constraint", func(t *testing.T) {
+		err := runWithConfigFactory(newRootCmd(), []string{"app-a", "failed"}, runOptions{expectVersion: ">= one"}, configFactory)
+		assert.ErrorContains(t, err, `--expect-chart-version: invalid chart version constraint ">= one"`)
+	})
+}
+
+func TestRunWithConfigFactory_StatusFromStdin(t *testing.T) {
+	newStore := func(t *testing.T) *storage.Storage {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		rel := &release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   1,
+			Info: &release.Info{
+				Status: release.StatusPendingUpgrade,
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}
+		require.NoError(t, store.Create(rel))
+		return store
+	}
+
+	t.Run("reads and trims status", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetIn(strings.NewReader("  Deployed\n"))
+
+		err := runWithConfigFactory(cmd, []string{"test-release", "-"}, runOptions{}, configFactory)
+		require.NoError(t, err)
+
+		updated, err := store.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusDeployed, updated.Info.Status)
+	})
+
+	t.Run("empty stdin", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		cmd.SetIn(strings.NewReader(" \n"))
+
+		err := runWithConfigFactory(cmd, []string{"test-release", "-"}, runOptions{}, configFactory)
+		require.Error(t, err)
+		assert.Equal(t, "no status provided on stdin", err.Error())
+	})
+
+	t.Run("invalid status on stdin", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		cmd.SetIn(strings.NewReader("bogus\n"))
+
+		err := runWithConfigFactory(cmd, []string{"test-release", "-"}, runOptions{}, configFactory)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid status: bogus")
+	})
+}
+
+func TestRunWithConfigFactory_SkipExitCode(t *testing.T) {
+	newStore := func(t *testing.T) *storage.Storage {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		rel := &release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   1,
+			Info: &release.Info{
+				Status: release.StatusDeployed,
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}
+		require.NoError(t, store.Create(rel))
+		return store
+	}
+
+	t.Run("skip returns the configured exit code", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetErr(&buf)
+
+		err := runWithConfigFactory(cmd, []string{"test-release", "failed"}, runOptions{fromStatuses: []string{"pending-upgrade"}, noFail: true, skipExitCode: 2}, configFactory)
+		require.Error(t, err)
+		assert.Equal(t, 2, exitCode(err))
+		assert.True(t, cmd.SilenceErrors)
+		assert.Contains(t, buf.String(), "Skipped")
+	})
+
+	t.Run("change exits 0", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		err := runWithConfigFactory(cmd, []string{"test-release", "failed"}, runOptions{fromStatuses: []string{"deployed"}, noFail: true, skipExitCode: 2}, configFactory)
+		require.NoError(t, err)
+	})
+
+	t.Run("skip through Execute is not printed as an error", func(t *testing.T) {
+		store := newStore(t)
+		originalFactory := ConfigurationFactory
+		ConfigurationFactory = func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+		defer func() { ConfigurationFactory = originalFactory }()
+
+		cmd := newRootCmd()
+		var out, errOut bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetErr(&errOut)
+		cmd.SetArgs([]string{"test-release", "failed", "--from", "pending-upgrade", "--no-fail", "--skip-exit-code", "3"})
+
+		err := cmd.Execute()
+		assert.Equal(t, 3, exitCode(err))
+		assert.Contains(t, out.String(), "Skipped")
+		assert.Empty(t, errOut.String())
+	})
+
+	t.Run("--exit-nonzero-on-skip", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			from     string
+			opts     runOptions
+			wantCode int
+		}{
+			{name: "overrides --no-fail", from: "pending-upgrade", opts: runOptions{noFail: true, failOnSkip: true}, wantCode: 2},
+			{name: "uses --skip-exit-code", from: "pending-upgrade", opts: runOptions{noFail: true, failOnSkip: true, skipExitCode: 4}, wantCode: 4},
+			{name: "applies to --pending-only", opts: runOptions{pendingOnly: true, failOnSkip: true}, wantCode: 2},
+			{name: "exits 1 when the precondition fails without --no-fail", from: "pending-upgrade", opts: runOptions{failOnSkip: true}, wantCode: 1},
+			{name: "exits 0 on a change", from: "deployed", opts: runOptions{noFail: true, failOnSkip: true}, wantCode: 0},
+			{name: "--no-fail alone exits 0", from: "pending-upgrade", opts: runOptions{noFail: true}, wantCode: 0},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				store := newStore(t)
+				configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+					return &action.Configuration{Releases: store}, nil
+				}
+				if tt.from != "" {
+					tt.opts.fromStatuses = []string{tt.from}
+				}
+
+				cmd := newRootCmd()
+				cmd.SetOut(&bytes.Buffer{})
+				cmd.SetErr(&bytes.Buffer{})
+
+				err := runWithConfigFactory(cmd, []string{"test-release", "failed"}, tt.opts, configFactory)
+				assert.Equal(t, tt.wantCode, exitCode(err))
+			})
+		}
+	})
+
+	t.Run("--exit-nonzero-on-skip is a global flag", func(t *testing.T) {
+		store := newStore(t)
+		originalFactory := ConfigurationFactory
+		ConfigurationFactory = func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+		defer func() { ConfigurationFactory = originalFactory }()
+
+		cmd := newRootCmd()
+		require.NotNil(t, cmd.PersistentFlags().Lookup("exit-nonzero-on-skip"))
+		var out, errOut bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetErr(&errOut)
+		cmd.SetArgs([]string{"--exit-nonzero-on-skip", "test-release", "failed", "--from", "pending-upgrade", "--no-fail"})
+
+		err := cmd.Execute()
+		assert.Equal(t, 2, exitCode(err))
+		assert.Empty(t, errOut.String())
+	})
+}
+
+func TestRunWithConfigFactory_IfOlderThan(t *testing.T) {
+	newStore := func(t *testing.T) *storage.Storage {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		rel := &release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   1,
+			Info: &release.Info{
+				Status:       release.StatusPendingUpgrade,
+				LastDeployed: helmtime.Now(),
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}
+		require.NoError(t, store.Create(rel))
+		return store
+	}
+
+	t.Run("recent release fails the precondition", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		err := runWithConfigFactory(cmd, []string{"test-release", "failed"}, runOptions{ifOlderThan: time.Hour}, configFactory)
+		var precondErr *status.PreconditionError
+		require.True(t, errors.As(err, &precondErr), "error should be *PreconditionError")
+		assert.Equal(t, time.Hour, precondErr.MinAge)
+		assert.Equal(t, release.StatusFailed, precondErr.TargetStatus)
+	})
+
+	t.Run("recent release is skipped with --no-fail", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		err := runWithConfigFactory(cmd, []string{"test-release", "failed"}, runOptions{ifOlderThan: time.Hour, noFail: true}, configFactory)
+		require.NoError(t, err)
+		assert.Contains(t, buf.String(), "Skipped: release was last deployed at")
+
+		unchanged, err := store.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusPendingUpgrade, unchanged.Info.Status)
+	})
+}
+
+func TestRunWithConfigFactory_Parallelism(t *testing.T) {
+	store := storage.Init(driver.NewMemory())
+
+	var names []string
+	for i := range 20 {
+		name := fmt.Sprintf("release-%02d", i)
+		names = append(names, name)
+		rel := &release.Release{
+			Name:      name,
+			Namespace: "default",
+			Version:   1,
+			Info: &release.Info{
+				Status: release.StatusPendingUpgrade,
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}
+		require.NoError(t, store.Create(rel))
+	}
+
+	configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+		return &action.Configuration{Releases: store}, nil
+	}
+
+	t.Run("updates every release and reports in order", func(t *testing.T) {
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		args := append(append([]string{}, names...), "missing-release", "failed")
+		err := runWithConfigFactory(cmd, args, runOptions{output: outputJSON, parallelism: 4}, configFactory)
+		require.NoError(t, err)
+
+		var out batchOutput
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &out))
+		results := out.Results
+		require.Len(t, results, len(names)+1)
+		for i, name := range names {
+			assert.Equal(t, name, results[i].Release)
+			assert.Equal(t, resultChanged, results[i].Result)
+
+			updated, err := store.Last(name)
+			require.NoError(t, err)
+			assert.Equal(t, release.StatusFailed, updated.Info.Status)
+		}
+		assert.Equal(t, "missing-release", results[len(names)].Release)
+		assert.Equal(t, resultNotFound, results[len(names)].Result)
+	})
+
+	t.Run("aggregates partial failures", func(t *testing.T) {
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		args := append(append([]string{}, names...), "deployed")
+		err := runWithConfigFactory(cmd, args, runOptions{output: outputJSON, parallelism: 8, fromStatuses: []string{"pending-upgrade"}}, configFactory)
+		require.Error(t, err)
+		assert.Equal(t, "failed to set status on 20 of 20 releases", err.Error())
+	})
+
+	t.Run("rejects parallelism below 1", func(t *testing.T) {
+		cmd := newRootCmd()
+		cmd.SetArgs([]string{"release-00", "failed", "--parallelism", "0"})
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetErr(&buf)
+
+		err := cmd.Execute()
+		require.Error(t, err)
+		assert.Equal(t, "--parallelism must be at least 1, got 0", err.Error())
+	})
+}
+
+func TestRunWithConfigFactory_Force(t *testing.T) {
+	newStore := func(t *testing.T) *storage.Storage {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		rel := &release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   1,
+			Info: &release.Info{
+				Status: release.StatusDeployed,
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}
+		require.NoError(t, store.Create(rel))
+		return store
+	}
+
+	t.Run("ignores --not-from", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		err := runWithConfigFactory(cmd, []string{"test-release", "failed"}, runOptions{notFromStatuses: []string{"deployed"}, force: true}, configFactory)
+		require.NoError(t, err)
+
+		updated, err := store.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusFailed, updated.Info.Status)
+	})
+
+	t.Run("cannot be combined with --from", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		err := runWithConfigFactory(cmd, []string{"test-release", "failed"}, runOptions{fromStatuses: []string{"deployed"}, force: true}, configFactory)
+		require.Error(t, err)
+		assert.Equal(t, "--force cannot be combined with --from", err.Error())
+
+		unchanged, err := store.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusDeployed, unchanged.Info.Status)
+	})
+}
+
+// failSecondUpdateDriver wraps a memory driver and fails the second Update.
+type failSecondUpdateDriver struct {
+	*driver.Memory
+	updates int
+}
+
+func (d *failSecondUpdateDriver) Update(key string, rls *release.Release) error {
+	d.updates++
+	if d.updates == 2 {
+		return errors.New("storage unavailable")
+	}
+	return d.Memory.Update(key, rls)
+}
+
+func TestRunWithConfigFactory_Atomic(t *testing.T) {
+	newStore := func(t *testing.T) *storage.Storage {
+		t.Helper()
+		store := storage.Init(&failSecondUpdateDriver{Memory: driver.NewMemory()})
+		for _, name := range []string{"first", "second"} {
+			rel := &release.Release{
+				Name:      name,
+				Namespace: "default",
+				Version:   1,
+				Info: &release.Info{
+					Status: release.StatusPendingUpgrade,
+				},
+				Chart: &chart.Chart{
+					Metadata: &chart.Metadata{
+						Name:    "test-chart",
+						Version: "1.0.0",
+					},
+				},
+			}
+			require.NoError(t, store.Create(rel))
+		}
+		return store
+	}
+
+	t.Run("restores earlier releases when one fails", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		err := runWithConfigFactory(cmd, []string{"first", "second", "deployed"}, runOptions{atomic: true}, configFactory)
+		var atomicErr *status.AtomicError
+		require.True(t, errors.As(err, &atomicErr), "error should be *AtomicError")
+		assert.Equal(t, []status.Target{{ReleaseName: "second"}, {ReleaseName: "first"}}, atomicErr.RolledBack)
+		assert.Empty(t, buf.String())
+
+		first, err := store.Last("first")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusPendingUpgrade, first.Info.Status)
+	})
+
+	t.Run("reports every release on success", func(t *testing.T) {
+		store := storage.Init(driver.NewMemory())
+		for _, name := range []string{"first", "second"} {
+			require.NoError(t, store.Create(&release.Release{
+				Name:      name,
+				Namespace: "default",
+				Version:   1,
+				Info: &release.Info{
+					Status: release.StatusPendingUpgrade,
+				},
+				Chart: &chart.Chart{
+					Metadata: &chart.Metadata{
+						Name:    "test-chart",
+						Version: "1.0.0",
+					},
+				},
+			}))
+		}
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		err := runWithConfigFactory(cmd, []string{"first", "second", "deployed"}, runOptions{atomic: true, output: outputJSON}, configFactory)
+		require.NoError(t, err)
+
+		var out batchOutput
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &out))
+		results := out.Results
+		require.Len(t, results, 2)
+		assert.Equal(t, resultChanged, results[0].Result)
+		assert.Equal(t, "pending-upgrade", results[1].PreviousStatus)
+		assert.Equal(t, resultChanged, results[1].Result)
+	})
+
+	t.Run("cannot be combined with --no-fail or --parallelism", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		err := runWithConfigFactory(newRootCmd(), []string{"first", "second", "deployed"}, runOptions{atomic: true, noFail: true}, configFactory)
+		require.Error(t, err)
+		assert.Equal(t, "--atomic cannot be combined with --no-fail", err.Error())
+
+		err = runWithConfigFactory(newRootCmd(), []string{"first", "second", "deployed"}, runOptions{atomic: true, parallelism: 2}, configFactory)
+		require.Error(t, err)
+		assert.Equal(t, "--atomic cannot be combined with --parallelism", err.Error())
+	})
+}
+
+func TestRunWithConfigFactory_SetAnnotation(t *testing.T) {
+	store := storage.Init(driver.NewMemory())
+	rel := &release.Release{
+		Name:      "test-release",
+		Namespace: "default",
+		Version:   1,
+		Info: &release.Info{
+			Status: release.StatusPendingUpgrade,
+		},
+		Chart: &chart.Chart{
+			Metadata: &chart.Metadata{
+				Name:    "test-chart",
+				Version: "1.0.0",
+			},
+		},
+	}
+	require.NoError(t, store.Create(rel))
+
+	configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+		return &action.Configuration{Releases: store}, nil
+	}
+
+	t.Run("records annotations as release labels", func(t *testing.T) {
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		opts := runOptions{annotations: []string{"helm-set-status/changed-by=ci-bot", "ticket=OPS-12"}}
+		err := runWithConfigFactory(cmd, []string{"test-release", "failed"}, opts, configFactory)
+		require.NoError(t, err)
+
+		updated, err := store.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"helm-set-status/changed-by": "ci-bot", "ticket": "OPS-12"}, updated.Labels)
+	})
+
+	t.Run("rejects malformed annotations", func(t *testing.T) {
+		for _, v := range []string{"no-equals", "=value"} {
+			err := runWithConfigFactory(newRootCmd(), []string{"test-release", "failed"}, runOptions{annotations: []string{v}}, configFactory)
+			require.Error(t, err)
+			assert.Equal(t, fmt.Sprintf("invalid --set-annotation %q: expected key=value", v), err.Error())
+		}
+	})
+}
+
+func TestParseRevision(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{"", 0},
+		{"latest", 0},
+		{"LATEST", 0},
+		{"0", 0},
+		{"3", 3},
+		{"-1", -1},
+	}
+	for _, tt := range tests {
+		got, err := parseRevision(tt.input)
+		require.NoError(t, err, tt.input)
+		assert.Equal(t, tt.expected, got, tt.input)
+	}
+
+	_, err := parseRevision("previous")
+	require.Error(t, err)
+	assert.Equal(t, `invalid --revision "previous": expected a revision number, "latest", or a negative offset such as -1`, err.Error())
+}
+
+func TestRun_RelativeRevision(t *testing.T) {
+	store := storage.Init(driver.NewMemory())
+	for _, v := range []int{1, 2} {
+		require.NoError(t, store.Create(&release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   v,
+			Info: &release.Info{
+				Status: release.StatusSuperseded,
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}))
+	}
+
+	originalFactory := ConfigurationFactory
+	defer func() { ConfigurationFactory = originalFactory }()
+	ConfigurationFactory = func(string, status.ClientOptions) (*action.Configuration, error) {
+		return &action.Configuration{Releases: store}, nil
+	}
+
+	cmd := newRootCmd()
+	var buf bytes.Buffer
+	cmd.SetOut(&buf)
+	cmd.SetErr(&buf)
+	cmd.SetArgs([]string{"test-release", "deployed", "--revision", "-1"})
+
+	require.NoError(t, cmd.Execute())
+
+	previous, err := store.Get("test-release", 1)
+	require.NoError(t, err)
+	assert.Equal(t, release.StatusDeployed, previous.Info.Status)
+
+	latest, err := store.Get("test-release", 2)
+	require.NoError(t, err)
+	assert.Equal(t, release.StatusSuperseded, latest.Info.Status)
+
+	cmd = newRootCmd()
+	cmd.SetOut(&buf)
+	cmd.SetErr(&buf)
+	cmd.SetArgs([]string{"test-release", "deployed", "--revision", "previous"})
+	assert.Error(t, cmd.Execute())
+}
+
+func TestRunWithConfigFactory_AppendDescription(t *testing.T) {
+	store := storage.Init(driver.NewMemory())
+	rel := &release.Release{
+		Name:      "test-release",
+		Namespace: "default",
+		Version:   1,
+		Info: &release.Info{
+			Status:      release.StatusDeployed,
+			Description: "Upgrade complete",
+		},
+		Chart: &chart.Chart{
+			Metadata: &chart.Metadata{
+				Name:    "test-chart",
+				Version: "1.0.0",
+			},
+		},
+	}
+	require.NoError(t, store.Create(rel))
+
+	configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+		return &action.Configuration{Releases: store}, nil
+	}
+
+	cmd := newRootCmd()
+	var buf bytes.Buffer
+	cmd.SetOut(&buf)
+
+	err := runWithConfigFactory(cmd, []string{"test-release", "failed"}, runOptions{description: "smoke tests failed", appendDesc: true}, configFactory)
+	require.NoError(t, err)
+
+	updated, err := store.Last("test-release")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(updated.Info.Description, "Upgrade complete\n"))
+	assert.True(t, strings.HasSuffix(updated.Info.Description, ": smoke tests failed"))
+}
+
+func TestRunWithConfigFactory_AllRevisions(t *testing.T) {
+	newStore := func(t *testing.T) *storage.Storage {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		for _, r := range []struct {
+			version int
+			status  release.Status
+		}{
+			{1, release.StatusDeployed},
+			{2, release.StatusFailed},
+			{3, release.StatusDeployed},
+		} {
+			require.NoError(t, store.Create(&release.Release{
+				Name:      "test-release",
+				Namespace: "default",
+				Version:   r.version,
+				Info: &release.Info{
+					Status: r.status,
+				},
+				Chart: &chart.Chart{
+					Metadata: &chart.Metadata{
+						Name:    "test-chart",
+						Version: "1.0.0",
+					},
+				},
+			}))
+		}
+		return store
+	}
+	statuses := func(t *testing.T, store *storage.Storage) []release.Status {
+		t.Helper()
+		var result []release.Status
+		for v := 1; v <= 3; v++ {
+			rel, err := store.Get("test-release", v)
+			require.NoError(t, err)
+			result = append(result, rel.Info.Status)
+		}
+		return result
+	}
+
+	t.Run("updates every revision", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		err := runWithConfigFactory(cmd, []string{"test-release", "superseded"}, runOptions{allRevisions: true}, configFactory)
+		require.NoError(t, err)
+		assert.Contains(t, buf.String(), `Release "test-release" revision 2 status changed from "failed" to "superseded"`)
+		assert.Contains(t, buf.String(), "Summary: 3 changed, 0 unchanged, 0 skipped, 0 not found, 0 errored (3 total)")
+		assert.Equal(t, []release.Status{release.StatusSuperseded, release.StatusSuperseded, release.StatusSuperseded}, statuses(t, store))
+	})
+
+	t.Run("leaves the latest revision with --exclude-latest", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		err := runWithConfigFactory(cmd, []string{"test-release", "superseded"}, runOptions{allRevisions: true, excludeLatest: true}, configFactory)
+		require.NoError(t, err)
+		assert.Contains(t, buf.String(), "Summary: 2 changed, 0 unchanged, 0 skipped, 0 not found, 0 errored (2 total)")
+		assert.Equal(t, []release.Status{release.StatusSuperseded, release.StatusSuperseded, release.StatusDeployed}, statuses(t, store))
+	})
+
+	t.Run("skips revisions that do not meet --from", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		err := runWithConfigFactory(cmd, []string{"test-release", "superseded"}, runOptions{allRevisions: true, fromStatuses: []string{"deployed"}}, configFactory)
+		require.NoError(t, err)
+		assert.Contains(t, buf.String(), "Skipped:")
+		assert.Contains(t, buf.String(), "Summary: 2 changed, 0 unchanged, 1 skipped, 0 not found, 0 errored (3 total)")
+		assert.Equal(t, []release.Status{release.StatusSuperseded, release.StatusFailed, release.StatusSuperseded}, statuses(t, store))
+	})
+
+	t.Run("reports a missing release", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		err := runWithConfigFactory(cmd, []string{"missing", "superseded"}, runOptions{allRevisions: true}, configFactory)
+		require.NoError(t, err)
+		assert.Contains(t, buf.String(), `Warning: release "missing" not found`)
+	})
+
+	t.Run("names --all-revisions when reading history fails", func(t *testing.T) {
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{}, nil
+		}
+
+		err := runWithConfigFactory(newRootCmd(), []string{"test-release", "superseded"}, runOptions{allRevisions: true}, configFactory)
+		require.Error(t, err)
+		require.ErrorIs(t, err, status.ErrStorageNotConfigured)
+		assert.True(t, strings.HasPrefix(err.Error(), "--all-revisions: "), err.Error())
+		assert.NotContains(t, err.Error(), "--revision-range")
+	})
+
+	t.Run("rejects invalid flag combinations", func(t *testing.T) {
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: newStore(t)}, nil
+		}
+
+		err := runWithConfigFactory(newRootCmd(), []string{"test-release", "superseded"}, runOptions{excludeLatest: true}, configFactory)
+		require.Error(t, err)
+		assert.Equal(t, "--exclude-latest requires --all-revisions", err.Error())
+
+		err = runWithConfigFactory(newRootCmd(), []string{"test-release", "superseded"}, runOptions{allRevisions: true, revision: 2}, configFactory)
+		require.Error(t, err)
+		assert.Equal(t, "--all-revisions cannot be combined with --revision", err.Error())
+	})
+}
+
+func TestParseRevisionRange(t *testing.T) {
+	got, err := parseRevisionRange("")
+	require.NoError(t, err)
+	assert.False(t, got.isSet())
+
+	got, err = parseRevisionRange("2-5")
+	require.NoError(t, err)
+	assert.Equal(t, revisionRange{from: 2, to: 5}, got)
+
+	got, err = parseRevisionRange("3-3")
+	require.NoError(t, err)
+	assert.Equal(t, revisionRange{from: 3, to: 3}, got)
+
+	for _, input := range []string{"2", "2-", "-5", "a-5", "0-5", "2-5-7"} {
+		_, err := parseRevisionRange(input)
+		assert.EqualError(t, err, `invalid --revision-range "`+input+`": expected FROM-TO, two revision numbers such as 2-5`, input)
+	}
+
+	_, err = parseRevisionRange("5-2")
+	assert.EqualError(t, err, `invalid --revision-range "5-2": revision 5 is after revision 2`)
+}
+
+func TestRunWithConfigFactory_RevisionRange(t *testing.T) {
+	newStore := func(t *testing.T) *storage.Storage {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		for v := 1; v <= 4; v++ {
+			require.NoError(t, store.Create(&release.Release{
+				Name:      "test-release",
+				Namespace: "default",
+				Version:   v,
+				Info: &release.Info{
+					Status: release.StatusFailed,
+				},
+				Chart: &chart.Chart{
+					Metadata: &chart.Metadata{
+						Name:    "test-chart",
+						Version: "1.0.0",
+					},
+				},
+			}))
+		}
+		return store
+	}
+	statuses := func(t *testing.T, store *storage.Storage) []release.Status {
+		t.Helper()
+		var result []release.Status
+		for v := 1; v <= 4; v++ {
+			rel, err := store.Get("test-release", v)
+			require.NoError(t, err)
+			result = append(result, rel.Info.Status)
+		}
+		return result
+	}
+
+	t.Run("updates the revisions in the range", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		opts := runOptions{revisionRange: revisionRange{from: 2, to: 3}}
+		require.NoError(t, runWithConfigFactory(cmd, []string{"test-release", "superseded"}, opts, configFactory))
+		assert.Contains(t, buf.String(), `Release "test-release" revision 2 status changed from "failed" to "superseded"`)
+		assert.Contains(t, buf.String(), "Summary: 2 changed, 0 unchanged, 0 skipped, 0 not found, 0 errored (2 total)")
+		assert.Equal(t, []release.Status{release.StatusFailed, release.StatusSuperseded, release.StatusSuperseded, release.StatusFailed}, statuses(t, store))
+	})
+
+	t.Run("rejects a range past the stored revisions", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		opts := runOptions{revisionRange: revisionRange{from: 3, to: 5}}
+		err := runWithConfigFactory(newRootCmd(), []string{"test-release", "superseded"}, opts, configFactory)
+		assert.EqualError(t, err, `--revision-range 3-5: release "test-release" revision 5 not found`)
+		assert.Equal(t, []release.Status{release.StatusFailed, release.StatusFailed, release.StatusFailed, release.StatusFailed}, statuses(t, store))
+	})
+
+	t.Run("reports a missing release", func(t *testing.T) {
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: newStore(t)}, nil
+		}
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		opts := runOptions{revisionRange: revisionRange{from: 1, to: 2}}
+		require.NoError(t, runWithConfigFactory(cmd, []string{"missing", "superseded"}, opts, configFactory))
+		assert.Contains(t, buf.String(), `Warning: release "missing" not found`)
+	})
+
+	t.Run("rejects invalid flag combinations", func(t *testing.T) {
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: newStore(t)}, nil
+		}
+		args := []string{"test-release", "superseded"}
+		rng := revisionRange{from: 1, to: 2}
+
+		err := runWithConfigFactory(newRootCmd(), args, runOptions{revisionRange: rng, revision: 2}, configFactory)
+		assert.EqualError(t, err, "--revision-range cannot be combined with --revision or --all-revisions")
+
+		err = runWithConfigFactory(newRootCmd(), args, runOptions{revisionRange: rng, allRevisions: true}, configFactory)
+		assert.EqualError(t, err, "--revision-range cannot be combined with --revision or --all-revisions")
+
+		err = runWithConfigFactory(newRootCmd(), args, runOptions{revisionRange: rng, pruneHistory: 1}, configFactory)
+		assert.EqualError(t, err, "--prune-history cannot be combined with --revision-range")
+	})
+
+	t.Run("reads the flag", func(t *testing.T) {
+		cmd := newRootCmd()
+		cmd.SetArgs([]string{"test-release", "superseded", "--revision-range", "4-1"})
+		cmd.SetOut(&bytes.Buffer{})
+		cmd.SetErr(&bytes.Buffer{})
+		assert.EqualError(t, cmd.Execute(), `invalid --revision-range "4-1": revision 4 is after revision 1`)
+	})
+}
+
+func TestRunWithConfigFactory_ReleaseStatusPairs(t *testing.T) {
+	newStore := func(t *testing.T) *storage.Storage {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		for _, name := range []string{"rel1", "rel2"} {
+			require.NoError(t, store.Create(&release.Release{
+				Name:      name,
+				Namespace: "default",
+				Version:   1,
+				Info: &release.Info{
+					Status: release.StatusPendingUpgrade,
+				},
+				Chart: &chart.Chart{
+					Metadata: &chart.Metadata{
+						Name:    "test-chart",
+						Version: "1.0.0",
+					},
+				},
+			}))
+		}
+		return store
+	}
+	statusOf := func(t *testing.T, store *storage.Storage, name string) release.Status {
+		t.Helper()
+		rel, err := store.Last(name)
+		require.NoError(t, err)
+		return rel.Info.Status
+	}
+
+	t.Run("sets a different status on each release", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		err := runWithConfigFactory(cmd, []string{"rel1=failed", "rel2=Deployed", "missing=failed"}, runOptions{}, configFactory)
+		require.NoError(t, err)
+		assert.Equal(t, `Release "rel1" revision 1 status changed from "pending-upgrade" to "failed"
+Release "rel2" revision 1 status changed from "pending-upgrade" to "deployed"
+Warning: release "missing" not found, skipping
+Summary: 2 changed, 0 unchanged, 0 skipped, 1 not found, 0 errored (3 total)
+`, buf.String())
+		assert.Equal(t, release.StatusFailed, statusOf(t, store, "rel1"))
+		assert.Equal(t, release.StatusDeployed, statusOf(t, store, "rel2"))
+	})
+
+	t.Run("rejects an invalid status before changing anything", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		err := runWithConfigFactory(newRootCmd(), []string{"rel1=failed", "rel2=bogus"}, runOptions{}, configFactory)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `invalid status in "rel2=bogus": invalid status: bogus`)
+		assert.Contains(t, err.Error(), "Valid statuses:")
+		assert.Equal(t, release.StatusPendingUpgrade, statusOf(t, store, "rel1"))
+	})
+
+	t.Run("rejects pairs mixed with release names", func(t *testing.T) {
+		err := runWithConfigFactory(newRootCmd(), []string{"rel1=failed", "rel2", "deployed"}, runOptions{}, nil)
+		assert.EqualError(t, err, `cannot mix RELEASE=STATUS pairs with release names or STATUS; got "rel2"`)
+	})
+
+	t.Run("rejects --atomic", func(t *testing.T) {
+		err := runWithConfigFactory(newRootCmd(), []string{"rel1=failed"}, runOptions{atomic: true}, nil)
+		assert.EqualError(t, err, "RELEASE=STATUS pairs cannot be combined with --atomic")
+	})
+}
+
+func TestRunWithConfigFactory_NamespacedReleases(t *testing.T) {
+	newStores := func(t *testing.T) map[string]*storage.Storage {
+		t.Helper()
+		stores := make(map[string]*storage.Storage)
+		for _, ns := range []string{"team-a", "team-b"} {
+			store := storage.Init(driver.NewMemory())
+			for _, name := range []string{"rel1", "rel2"} {
+				require.NoError(t, store.Create(&release.Release{
+					Name:      name,
+					Namespace: ns,
+					Version:   1,
+					Info: &release.Info{
+						Status: release.StatusPendingUpgrade,
+					},
+					Chart: &chart.Chart{
+						Metadata: &chart.Metadata{
+							Name:    "test-chart",
+							Version: "1.0.0",
+						},
+					},
+				}))
+			}
+			stores[ns] = store
+		}
+		return stores
+	}
+	factoryFor := func(stores map[string]*storage.Storage, calls map[string]int) configurationFactory {
+		return func(namespace string, _ status.ClientOptions) (*action.Configuration, error) {
+			calls[namespace]++
+			return &action.Configuration{Releases: stores[namespace]}, nil
+		}
+	}
+	statusOf := func(t *testing.T, store *storage.Storage, name string) release.Status {
+		t.Helper()
+		rel, err := store.Last(name)
+		require.NoError(t, err)
+		return rel.Info.Status
+	}
+	const want = `Namespace "team-a":
+Release "rel1" revision 1 status changed from "pending-upgrade" to "failed"
+Release "rel2" revision 1 status changed from "pending-upgrade" to "deployed"
+Namespace "team-b":
+Release "rel1" revision 1 status changed from "pending-upgrade" to "deployed"
+Release "rel2" revision 1 status changed from "pending-upgrade" to "failed"
+Summary: 4 changed, 0 unchanged, 0 skipped, 0 not found, 0 errored (4 total)
+`
+	assertChanged := func(t *testing.T, stores map[string]*storage.Storage) {
+		t.Helper()
+		assert.Equal(t, release.StatusFailed, statusOf(t, stores["team-a"], "rel1"))
+		assert.Equal(t, release.StatusDeployed, statusOf(t, stores["team-a"], "rel2"))
+		assert.Equal(t, release.StatusDeployed, statusOf(t, stores["team-b"], "rel1"))
+		assert.Equal(t, release.StatusFailed, statusOf(t, stores["team-b"], "rel2"))
+	}
+
+	t.Run("sets pairs in their own namespaces", func(t *testing.T) {
+		stores := newStores(t)
+		calls := make(map[string]int)
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		args := []string{"rel1=failed", "team-a/rel2=deployed", "team-b/rel1=deployed", "team-b/rel2=failed"}
+		require.NoError(t, runWithConfigFactory(cmd, args, runOptions{namespace: "team-a"}, factoryFor(stores, calls)))
+		assert.Equal(t, want, buf.String())
+		assertChanged(t, stores)
+		assert.Equal(t, map[string]int{"team-a": 1, "team-b": 1}, calls, "each namespace's configuration is created once")
+	})
+
+	t.Run("sets file entries in their own namespaces", func(t *testing.T) {
+		stores := newStores(t)
+		calls := make(map[string]int)
+		path := filepath.Join(t.TempDir(), "releases.txt")
+		require.NoError(t, os.WriteFile(path, []byte("team-a/rel1 failed\nteam-a/rel2,deployed\nteam-b/rel1 deployed\nteam-b/rel2 failed\n"), 0o644))
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		require.NoError(t, runWithConfigFactory(cmd, nil, runOptions{namespace: "team-a", fromFile: path}, factoryFor(stores, calls)))
+		assert.Equal(t, want, buf.String())
+		assertChanged(t, stores)
+		assert.Equal(t, map[string]int{"team-a": 1, "team-b": 1}, calls)
+	})
+
+	t.Run("reports the namespace of each result", func(t *testing.T) {
+		stores := newStores(t)
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		args := []string{"team-a/rel1=failed", "team-b/rel1=failed"}
+		require.NoError(t, runWithConfigFactory(cmd, args, runOptions{namespace: "team-a", output: outputJSON}, factoryFor(stores, map[string]int{})))
+
+		var out struct {
+			Results []changeResult `json:"results"`
+		}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &out))
+		require.Len(t, out.Results, 2)
+		assert.Equal(t, "team-a", out.Results[0].Namespace)
+		assert.Equal(t, "team-b", out.Results[1].Namespace)
+	})
+}
+
+func TestRunWithConfigFactory_PruneHistory(t *testing.T) {
+	newStore := func(t *testing.T) *storage.Storage {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		for v := 1; v <= 5; v++ {
+			st := release.StatusSuperseded
+			if v == 5 {
+				st = release.StatusPendingUpgrade
+			}
+			require.NoError(t, store.Create(&release.Release{
+				Name:      "my-release",
+				Namespace: "default",
+				Version:   v,
+				Info: &release.Info{
+					Status: st,
+				},
+				Chart: &chart.Chart{
+					Metadata: &chart.Metadata{
+						Name:    "test-chart",
+						Version: "1.0.0",
+					},
+				},
+			}))
+		}
+		return store
+	}
+	factoryFor := func(store *storage.Storage) configurationFactory {
+		return func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+	}
+	remaining := func(t *testing.T, store *storage.Storage) []int {
+		t.Helper()
+		revisions, err := store.History("my-release")
+		require.NoError(t, err)
+		versions := make([]int, 0, len(revisions))
+		for _, rel := range revisions {
+			versions = append(versions, rel.Version)
+		}
+		sort.Ints(versions)
+		return versions
+	}
+
+	t.Run("deletes older revisions after setting the status", func(t *testing.T) {
+		store := newStore(t)
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		require.NoError(t, runWithConfigFactory(cmd, []string{"my-release", "failed"}, runOptions{pruneHistory: 2}, factoryFor(store)))
+		assert.Equal(t, `Release "my-release" revision 5 status changed from "pending-upgrade" to "failed"
+Pruned release "my-release" revisions 1, 2, 3
+`, buf.String())
+		assert.Equal(t, []int{4, 5}, remaining(t, store))
+	})
+
+	t.Run("keeps the revision whose status was set", func(t *testing.T) {
+		store := newStore(t)
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		opts := runOptions{revision: 1, pruneHistory: 1, output: outputJSON}
+		require.NoError(t, runWithConfigFactory(cmd, []string{"my-release", "failed"}, opts, factoryFor(store)))
+		var res changeResult
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &res))
+		assert.Equal(t, []int{2, 3, 4}, res.Pruned)
+		assert.Equal(t, []int{1, 5}, remaining(t, store))
+	})
+
+	t.Run("reports without deleting under --dry-run", func(t *testing.T) {
+		store := newStore(t)
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		require.NoError(t, runWithConfigFactory(cmd, []string{"my-release", "failed"}, runOptions{pruneHistory: 3, dryRun: true}, factoryFor(store)))
+		assert.Contains(t, buf.String(), `Would prune release "my-release" revisions 1, 2`)
+		assert.Equal(t, []int{1, 2, 3, 4, 5}, remaining(t, store))
+	})
+
+	t.Run("deletes nothing when the status is not set", func(t *testing.T) {
+		store := newStore(t)
+
+		opts := runOptions{pruneHistory: 1, fromStatuses: []string{"deployed"}}
+		err := runWithConfigFactory(newRootCmd(), []string{"my-release", "failed"}, opts, factoryFor(store))
+		require.Error(t, err)
+		assert.Equal(t, []int{1, 2, 3, 4, 5}, remaining(t, store))
+	})
+
+	t.Run("rejects --all-revisions", func(t *testing.T) {
+		err := runWithConfigFactory(newRootCmd(), []string{"my-release", "failed"}, runOptions{pruneHistory: 1, allRevisions: true}, nil)
+		assert.EqualError(t, err, "--prune-history cannot be combined with --all-revisions or --atomic")
+	})
+}
+
+func TestRunWithConfigFactory_SupersedeOthers(t *testing.T) {
+	newStore := func(t *testing.T) *storage.Storage {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		for v, st := range []release.Status{release.StatusSuperseded, release.StatusDeployed, release.StatusDeployed, release.StatusPendingUpgrade} {
+			require.NoError(t, store.Create(&release.Release{
+				Name:      "my-release",
+				Namespace: "default",
+				Version:   v + 1,
+				Info: &release.Info{
+					Status: st,
+				},
+				Chart: &chart.Chart{
+					Metadata: &chart.Metadata{
+						Name:    "test-chart",
+						Version: "1.0.0",
+					},
+				},
+			}))
+		}
+		return store
+	}
+	factoryFor := func(store *storage.Storage) configurationFactory {
+		return func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+	}
+	deployed := func(t *testing.T, store *storage.Storage) []int {
+		t.Helper()
+		revisions, err := store.History("my-release")
+		require.NoError(t, err)
+		var versions []int
+		for _, rel := range revisions {
+			if rel.Info.Status == release.StatusDeployed {
+				versions = append(versions, rel.Version)
+			}
+		}
+		sort.Ints(versions)
+		return versions
+	}
+
+	t.Run("leaves the latest revision as the only deployed one", func(t *testing.T) {
+		store := newStore(t)
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		require.NoError(t, runWithConfigFactory(cmd, []string{"my-release", "deployed"}, runOptions{supersedeOthers: true}, factoryFor(store)))
+		assert.Equal(t, `Release "my-release" revision 4 status changed from "pending-upgrade" to "deployed"
+Superseded release "my-release" revisions 2, 3
+`, buf.String())
+		assert.Equal(t, []int{4}, deployed(t, store))
+	})
+
+	t.Run("leaves a chosen revision as the only deployed one", func(t *testing.T) {
+		store := newStore(t)
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		opts := runOptions{revision: 1, supersedeOthers: true, output: outputJSON}
+		require.NoError(t, runWithConfigFactory(cmd, []string{"my-release", "deployed"}, opts, factoryFor(store)))
+		var res changeResult
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &res))
+		assert.Equal(t, []int{2, 3}, res.Superseded)
+		assert.Equal(t, []int{1}, deployed(t, store))
+
+		rel, err := store.Get("my-release", 2)
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusSuperseded, rel.Info.Status)
+	})
+
+	t.Run("reports without changing under --dry-run", func(t *testing.T) {
+		store := newStore(t)
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		require.NoError(t, runWithConfigFactory(cmd, []string{"my-release", "deployed"}, runOptions{supersedeOthers: true, dryRun: true}, factoryFor(store)))
+		assert.Contains(t, buf.String(), `Would supersede release "my-release" revisions 2, 3`)
+		assert.Equal(t, []int{2, 3}, deployed(t, store))
+	})
+
+	t.Run("only applies when the status is deployed", func(t *testing.T) {
+		store := newStore(t)
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		require.NoError(t, runWithConfigFactory(cmd, []string{"my-release", "failed"}, runOptions{supersedeOthers: true}, factoryFor(store)))
+		assert.NotContains(t, buf.String(), "Superseded")
+		assert.Equal(t, []int{2, 3}, deployed(t, store))
+	})
+
+	t.Run("supersedes nothing when the status is not set", func(t *testing.T) {
+		store := newStore(t)
+
+		opts := runOptions{supersedeOthers: true, fromStatuses: []string{"failed"}}
+		err := runWithConfigFactory(newRootCmd(), []string{"my-release", "deployed"}, opts, factoryFor(store))
+		require.Error(t, err)
+		assert.Equal(t, []int{2, 3}, deployed(t, store))
+	})
+
+	t.Run("rejects --all-revisions and --atomic", func(t *testing.T) {
+		for _, opts := range []runOptions{
+			{supersedeOthers: true, allRevisions: true},
+			{supersedeOthers: true, revisionRange: revisionRange{from: 1, to: 2}},
+			{supersedeOthers: true, atomic: true},
+		} {
+			err := runWithConfigFactory(newRootCmd(), []string{"my-release", "deployed"}, opts, nil)
+			assert.EqualError(t, err, "--supersede-others cannot be combined with --all-revisions, --revision-range, or --atomic")
+		}
+	})
+}
+
+func TestRunWithConfigFactory_FailFast(t *testing.T) {
+	newStore := func(t *testing.T) *storage.Storage {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		for name, st := range map[string]release.Status{"app-a": release.StatusPendingUpgrade, "app-b": release.StatusDeployed, "app-c": release.StatusPendingUpgrade} {
+			require.NoError(t, store.Create(&release.Release{
+				Name:      name,
+				Namespace: "default",
+				Version:   1,
+				Info:      &release.Info{Status: st},
+				Chart: &chart.Chart{
+					Metadata: &chart.Metadata{
+						Name:    "test-chart",
+						Version: "1.0.0",
+					},
+				},
+			}))
+		}
+		return store
+	}
+	factoryFor := func(store *storage.Storage) configurationFactory {
+		return func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+	}
+	statusOf := func(t *testing.T, store *storage.Storage, name string) release.Status {
+		t.Helper()
+		rel, err := store.Last(name)
+		require.NoError(t, err)
+		return rel.Info.Status
+	}
+	// app-b is not pending-upgrade, so setting it fails in the middle of the batch
+	args := []string{"app-a", "app-b", "app-c", "failed"}
+
+	t.Run("attempts every release by default", func(t *testing.T) {
+		store := newStore(t)
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		run, err := runChanges(cmd, args, runOptions{fromStatuses: []string{"pending-upgrade"}}, factoryFor(store))
+		require.NoError(t, err)
+		assert.Equal(t, []string{resultChanged, resultError, resultChanged}, []string{run.results[0].Result, run.results[1].Result, run.results[2].Result})
+		assert.EqualError(t, runExit(cmd, runOptions{}, run), "failed to set status on 1 of 3 releases")
+		assert.Equal(t, release.StatusFailed, statusOf(t, store, "app-a"))
+		assert.Equal(t, release.StatusFailed, statusOf(t, store, "app-c"))
+	})
+
+	t.Run("stops at the first failure with --fail-fast", func(t *testing.T) {
+		store := newStore(t)
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		err := runWithConfigFactory(cmd, args, runOptions{fromStatuses: []string{"pending-upgrade"}, failFast: true}, factoryFor(store))
+		assert.EqualError(t, err, "failed to set status on 1 of 3 releases")
+		assert.Equal(t, `Release "app-a" revision 1 status changed from "pending-upgrade" to "failed"
+Error: release "app-b": current status "deployed" not in allowed list [pending-upgrade]; cannot set to "failed"
+Release "app-c" not attempted after release "app-b" failed (--fail-fast)
+Summary: 1 changed, 0 unchanged, 0 skipped, 0 not found, 1 errored, 1 not attempted (3 total)
+`, buf.String())
+		assert.Equal(t, release.StatusFailed, statusOf(t, store, "app-a"))
+		assert.Equal(t, release.StatusPendingUpgrade, statusOf(t, store, "app-c"))
+	})
+
+	t.Run("reports releases not attempted in JSON", func(t *testing.T) {
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		opts := runOptions{fromStatuses: []string{"pending-upgrade"}, failFast: true, output: outputJSON}
+		require.Error(t, runWithConfigFactory(cmd, args, opts, factoryFor(newStore(t))))
+
+		var out batchOutput
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &out))
+		require.Len(t, out.Results, 3)
+		assert.Equal(t, resultNotAttempted, out.Results[2].Result)
+		assert.Equal(t, batchSummary{Total: 3, Changed: 1, Errored: 1, NotAttempted: 1}, out.Summary)
+	})
+
+	t.Run("attempts every release when none fails", func(t *testing.T) {
+		store := newStore(t)
+
+		cmd := newRootCmd()
+		cmd.SetOut(&bytes.Buffer{})
+		require.NoError(t, runWithConfigFactory(cmd, []string{"app-a", "app-c", "failed"}, runOptions{failFast: true}, factoryFor(store)))
+		assert.Equal(t, release.StatusFailed, statusOf(t, store, "app-a"))
+		assert.Equal(t, release.StatusFailed, statusOf(t, store, "app-c"))
+	})
+
+	t.Run("rejects --parallelism", func(t *testing.T) {
+		err := runWithConfigFactory(newRootCmd(), args, runOptions{failFast: true, parallelism: 2}, nil)
+		assert.EqualError(t, err, "--fail-fast cannot be combined with --parallelism")
+	})
+}
+
+func TestRunWithConfigFactory_ResolvedRevision(t *testing.T) {
+	newStore := func(t *testing.T) *storage.Storage {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		for v := 1; v <= 3; v++ {
+			require.NoError(t, store.Create(&release.Release{
+				Name:      "my-release",
+				Namespace: "default",
+				Version:   v,
+				Info:      &release.Info{Status: release.StatusDeployed},
+				Chart: &chart.Chart{
+					Metadata: &chart.Metadata{
+						Name:    "test-chart",
+						Version: "1.0.0",
+					},
+				},
+			}))
+		}
+		return store
+	}
+	factoryFor := func(store *storage.Storage) configurationFactory {
+		return func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+	}
+
+	for _, tt := range []struct {
+		name     string
+		revision int
+		want     int
+	}{
+		{name: "latest revision", revision: 0, want: 3},
+		{name: "relative revision", revision: -1, want: 2},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := newRootCmd()
+			var buf bytes.Buffer
+			cmd.SetOut(&buf)
+			require.NoError(t, runWithConfigFactory(cmd, []string{"my-release", "failed"}, runOptions{revision: tt.revision}, factoryFor(newStore(t))))
+			assert.Equal(t, fmt.Sprintf("Release \"my-release\" revision %d status changed from \"deployed\" to \"failed\"\n", tt.want), buf.String())
+
+			cmd = newRootCmd()
+			buf.Reset()
+			cmd.SetOut(&buf)
+			require.NoError(t, runWithConfigFactory(cmd, []string{"my-release", "failed"}, runOptions{revision: tt.revision, output: outputJSON, dryRun: true}, factoryFor(newStore(t))))
+			var res changeResult
+			require.NoError(t, json.Unmarshal(buf.Bytes(), &res))
+			assert.Equal(t, tt.want, res.Revision)
+		})
+	}
+
+	t.Run("not resolved for a missing release", func(t *testing.T) {
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		require.NoError(t, runWithConfigFactory(cmd, []string{"missing", "my-release", "failed"}, runOptions{output: outputJSON}, factoryFor(newStore(t))))
+		var out batchOutput
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &out))
+		require.Len(t, out.Results, 2)
+		assert.Equal(t, 0, out.Results[0].Revision)
+		assert.Equal(t, 3, out.Results[1].Revision)
+	})
+}
+
+func TestRunWithConfigFactory_UnrecognizedStatus(t *testing.T) {
+	store := storage.Init(driver.NewMemory())
+	require.NoError(t, store.Create(&release.Release{
+		Name:      "my-release",
+		Namespace: "default",
+		Version:   1,
+		Info: &release.Info{
+			Status: "Superseeded",
+		},
+		Chart: &chart.Chart{
+			Metadata: &chart.Metadata{
+				Name:    "test-chart",
+				Version: "1.0.0",
+			},
+		},
+	}))
+	configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+		return &action.Configuration{Releases: store}, nil
+	}
+
+	cmd := newRootCmd()
+	var stdout, stderr bytes.Buffer
+	cmd.SetOut(&stdout)
+	cmd.SetErr(&stderr)
+
+	opts := runOptions{fromStatuses: []string{"deployed"}, noFail: true, output: outputJSON}
+	require.NoError(t, runWithConfigFactory(cmd, []string{"my-release", "failed"}, opts, configFactory))
+	assert.Equal(t, "Warning: release \"my-release\" has unrecognized stored status \"Superseeded\", treating it as \"unknown\"\n", stderr.String())
+
+	var res changeResult
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &res))
+	assert.Equal(t, resultSkipped, res.Result)
+	assert.Equal(t, "unknown", res.PreviousStatus)
+	assert.Equal(t, "Superseeded", res.StoredStatus)
+	assert.Contains(t, res.Reason, `current status "unknown" not in allowed list [deployed]`)
+}
+
+func TestRunWithConfigFactory_UninstalledStatus(t *testing.T) {
+	store := storage.Init(driver.NewMemory())
+	require.NoError(t, store.Create(&release.Release{
+		Name:      "my-release",
+		Namespace: "default",
+		Version:   1,
+		Info: &release.Info{
+			Status: release.StatusUninstalled,
+		},
+	}))
+	configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+		return &action.Configuration{Releases: store}, nil
+	}
+
+	cmd := newRootCmd()
+	var stdout, stderr bytes.Buffer
+	cmd.SetOut(&stdout)
+	cmd.SetErr(&stderr)
+
+	opts := runOptions{fromStatuses: []string{"unknown"}, noFail: true, output: outputJSON}
+	require.NoError(t, runWithConfigFactory(cmd, []string{"my-release", "failed"}, opts, configFactory))
+	assert.Empty(t, stderr.String())
+
+	var res changeResult
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &res))
+	assert.Equal(t, resultSkipped, res.Result)
+	assert.Equal(t, "uninstalled", res.PreviousStatus)
+	assert.Empty(t, res.StoredStatus)
+}
+
+func TestRunWithConfigFactory_InProgressWarning(t *testing.T) {
+	newStore := func(t *testing.T) *storage.Storage {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		for v, st := range []release.Status{release.StatusSuperseded, release.StatusDeployed} {
+			require.NoError(t, store.Create(&release.Release{
+				Name:      "my-release",
+				Namespace: "default",
+				Version:   v + 1,
+				Info: &release.Info{
+					Status: st,
+				},
+				Chart: &chart.Chart{
+					Metadata: &chart.Metadata{
+						Name:    "test-chart",
+						Version: "1.0.0",
+					},
+				},
+			}))
+		}
+		return store
+	}
+	runWithStderr := func(t *testing.T, args []string, opts runOptions) string {
+		t.Helper()
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+
+		cmd := newRootCmd()
+		var stderr bytes.Buffer
+		cmd.SetOut(&bytes.Buffer{})
+		cmd.SetErr(&stderr)
+		require.NoError(t, runWithConfigFactory(cmd, args, opts, configFactory))
+		return stderr.String()
+	}
+
+	for _, target := range []string{"pending-install", "pending-upgrade", "pending-rollback", "uninstalling"} {
+		t.Run("warns for "+target, func(t *testing.T) {
+			stderr := runWithStderr(t, []string{"my-release", target}, runOptions{})
+			assert.Contains(t, stderr, fmt.Sprintf(`Warning: release "my-release": %q on the latest revision tells Helm an operation is in progress`, target))
+			assert.Contains(t, stderr, "Use --force to silence this warning.")
+		})
+	}
+
+	t.Run("warns under --dry-run and for the latest revision by number", func(t *testing.T) {
+		assert.Contains(t, runWithStderr(t, []string{"my-release", "pending-upgrade"}, runOptions{dryRun: true}), "Warning:")
+		assert.Contains(t, runWithStderr(t, []string{"my-release", "pending-upgrade"}, runOptions{revision: 2}), "Warning:")
+	})
+
+	for _, target := range []string{"deployed", "failed", "superseded", "unknown"} {
+		t.Run("does not warn for "+target, func(t *testing.T) {
+			assert.Empty(t, runWithStderr(t, []string{"my-release", target}, runOptions{}))
+		})
+	}
+
+	t.Run("does not warn for an older revision", func(t *testing.T) {
+		assert.Empty(t, runWithStderr(t, []string{"my-release", "pending-upgrade"}, runOptions{revision: 1}))
+	})
+
+	t.Run("does not warn with --force", func(t *testing.T) {
+		assert.Empty(t, runWithStderr(t, []string{"my-release", "pending-upgrade"}, runOptions{force: true}))
+	})
+}
+
+func TestRunWithConfigFactory_FromFile(t *testing.T) {
+	newStore := func(t *testing.T) *storage.Storage {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		for _, r := range []struct {
+			name   string
+			status release.Status
+		}{
+			{"rel1", release.StatusPendingUpgrade},
+			{"rel2", release.StatusDeployed},
+			{"rel3", release.StatusPendingInstall},
+		} {
+			require.NoError(t, store.Create(&release.Release{
+				Name:      r.name,
+				Namespace: "default",
+				Version:   1,
+				Info: &release.Info{
+					Status: r.status,
+				},
+				Chart: &chart.Chart{
+					Metadata: &chart.Metadata{
+						Name:    "test-chart",
+						Version: "1.0.0",
+					},
+				},
+			}))
+		}
+		return store
+	}
+	writeFile := func(t *testing.T, content string) string {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "changes.txt")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+
+	t.Run("applies each pair and reports a summary", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+		path := writeFile(t, "# stuck upgrades\nrel1 deployed\n\nrel2,deployed\nrel3 failed\nmissing failed\n")
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		err := runWithConfigFactory(cmd, nil, runOptions{fromFile: path}, configFactory)
+		require.NoError(t, err)
+		assert.Contains(t, buf.String(), `Release "rel1" revision 1 status changed from "pending-upgrade" to "deployed"`)
+		assert.Contains(t, buf.String(), `Release "rel2" already "deployed", no change`)
+		assert.Contains(t, buf.String(), `Release "rel3" revision 1 status changed from "pending-install" to "failed"`)
+		assert.Contains(t, buf.String(), "Summary: 2 changed, 1 unchanged, 0 skipped, 1 not found, 0 errored (4 total)")
+
+		rel3, err := store.Last("rel3")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusFailed, rel3.Info.Status)
+	})
+
+	t.Run("rejects a malformed file before changing anything", func(t *testing.T) {
+		store := newStore(t)
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+		path := writeFile(t, "rel1 deployed\nrel2 deployed extra\n")
+
+		err := runWithConfigFactory(newRootCmd(), nil, runOptions{fromFile: path}, configFactory)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "line 2:")
+
+		rel1, err := store.Last("rel1")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusPendingUpgrade, rel1.Info.Status)
+	})
+
+	t.Run("rejects --atomic", func(t *testing.T) {
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: newStore(t)}, nil
+		}
+		path := writeFile(t, "rel1 deployed\n")
+
+		err := runWithConfigFactory(newRootCmd(), nil, runOptions{fromFile: path, atomic: true}, configFactory)
+		require.Error(t, err)
+		assert.Equal(t, "--from-file cannot be combined with --atomic", err.Error())
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: newStore(t)}, nil
+		}
+
+		err := runWithConfigFactory(newRootCmd(), nil, runOptions{fromFile: filepath.Join(t.TempDir(), "nope.txt")}, configFactory)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to read --from-file")
+	})
+}
+
+func TestRun_MaxRetries(t *testing.T) {
+	originalFactory := ConfigurationFactory
+	defer func() { ConfigurationFactory = originalFactory }()
+	ConfigurationFactory = func(string, status.ClientOptions) (*action.Configuration, error) {
+		return &action.Configuration{Releases: storage.Init(driver.NewMemory())}, nil
+	}
+
+	cmd := newRootCmd()
+	var buf bytes.Buffer
+	cmd.SetOut(&buf)
+	cmd.SetErr(&buf)
+	cmd.SetArgs([]string{"test-release", "failed", "--max-retries", "-1"})
+
+	err := cmd.Execute()
+	require.Error(t, err)
+	assert.Equal(t, "--max-retries must not be negative, got -1", err.Error())
+
+	setOpts := setStatusOptions(preconditions{}, nil, runOptions{maxRetries: 5}, nil)
+	assert.Equal(t, 5, setOpts.MaxRetries)
+}
+
+func TestRun_FromEnv(t *testing.T) {
+	var store *storage.Storage
+	originalFactory := ConfigurationFactory
+	defer func() { ConfigurationFactory = originalFactory }()
+	ConfigurationFactory = func(string, status.ClientOptions) (*action.Configuration, error) {
+		return &action.Configuration{Releases: store}, nil
+	}
+	execute := func(t *testing.T, args ...string) (release.Status, error) {
+		t.Helper()
+		store = storage.Init(driver.NewMemory())
+		require.NoError(t, store.Create(&release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   1,
+			Info:      &release.Info{Status: release.StatusPendingUpgrade},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}))
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetErr(&buf)
+		cmd.SetArgs(args)
+		err := cmd.Execute()
+		rel, getErr := store.Last("test-release")
+		require.NoError(t, getErr)
+		return rel.Info.Status, err
+	}
+
+	t.Run("supplies the default --from", func(t *testing.T) {
+		t.Setenv("HELM_SET_STATUS_FROM", "failed, pending-install")
+		got, err := execute(t, "test-release", "deployed")
+		var precondErr *status.PreconditionError
+		require.ErrorAs(t, err, &precondErr)
+		assert.Equal(t, []release.Status{release.StatusFailed, release.StatusPendingInstall}, precondErr.AllowedStatuses)
+		assert.Equal(t, release.StatusPendingUpgrade, got)
+
+		t.Setenv("HELM_SET_STATUS_FROM", "failed,PENDING_UPGRADE,")
+		got, err = execute(t, "test-release", "deployed")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusDeployed, got)
+	})
+
+	t.Run("is overridden by --from and --force", func(t *testing.T) {
+		t.Setenv("HELM_SET_STATUS_FROM", "failed")
+		got, err := execute(t, "test-release", "deployed", "--from", "pending-upgrade")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusDeployed, got)
+
+		got, err = execute(t, "test-release", "deployed", "--force")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusDeployed, got)
+	})
+
+	t.Run("is validated like --from", func(t *testing.T) {
+		t.Setenv("HELM_SET_STATUS_FROM", "failed,bogus")
+		_, err := execute(t, "test-release", "deployed")
+		assert.EqualError(t, err, "invalid $HELM_SET_STATUS_FROM status \"bogus\": invalid status: bogus\nValid statuses: "+status.ValidStatusesString())
+	})
+
+	t.Run("is ignored when empty", func(t *testing.T) {
+		t.Setenv("HELM_SET_STATUS_FROM", "")
+		got, err := execute(t, "test-release", "deployed")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusDeployed, got)
+	})
+}
+
+// conflictOnceDriver wraps a memory driver and rejects the first Update with
+// a Kubernetes conflict error. Query returns copies, as the Kubernetes
+// drivers do, so that the rejected update leaves the stored release alone.
+type conflictOnceDriver struct {
+	*driver.Memory
+	updates int
+}
+
+func (d *conflictOnceDriver) Query(labels map[string]string) ([]*release.Release, error) {
+	rels, err := d.Memory.Query(labels)
+	for i, rel := range rels {
+		copied := *rel
+		info := *rel.Info
+		copied.Info = &info
+		rels[i] = &copied
+	}
+	return rels, err
+}
+
+func (d *conflictOnceDriver) Update(key string, rls *release.Release) error {
+	d.updates++
+	if d.updates == 1 {
+		return apierrors.NewConflict(schema.GroupResource{Resource: "secrets"}, key, errors.New("the object has been modified"))
+	}
+	return d.Memory.Update(key, rls)
+}
+
+func TestRun_RetryOnConflict(t *testing.T) {
+	newDriver := func(t *testing.T) *conflictOnceDriver {
+		t.Helper()
+		d := &conflictOnceDriver{Memory: driver.NewMemory()}
+		require.NoError(t, d.Create("sh.helm.release.v1.test-release.v1", &release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   1,
+			Info:      &release.Info{Status: release.StatusPendingUpgrade},
+		}))
+		return d
+	}
+	execute := func(d *conflictOnceDriver, args ...string) (string, error) {
+		originalFactory := ConfigurationFactory
+		defer func() { ConfigurationFactory = originalFactory }()
+		ConfigurationFactory = func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: storage.Init(d)}, nil
+		}
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetErr(&buf)
+		cmd.SetArgs(args)
+		err := cmd.Execute()
+		return buf.String(), err
+	}
+
+	t.Run("retries an update rejected as a conflict", func(t *testing.T) {
+		d := newDriver(t)
+		out, err := execute(d, "test-release", "failed", "--retry-on-conflict", "--retry-delay", "1ms")
+		require.NoError(t, err)
+		assert.Equal(t, "Release \"test-release\" revision 1 status changed from \"pending-upgrade\" to \"failed\"\n", out)
+		assert.Equal(t, 2, d.updates)
+	})
+
+	t.Run("fails on the conflict without --retry-on-conflict", func(t *testing.T) {
+		d := newDriver(t)
+		_, err := execute(d, "test-release", "failed")
+		require.Error(t, err)
+		assert.True(t, apierrors.IsConflict(err))
+		assert.Equal(t, 1, d.updates)
+	})
+
+	t.Run("validates the flags", func(t *testing.T) {
+		_, err := execute(newDriver(t), "test-release", "failed", "--retry-on-conflict", "--max-retries", "0")
+		assert.EqualError(t, err, "--retry-on-conflict requires --max-retries of at least 1")
+
+		_, err = execute(newDriver(t), "test-release", "failed", "--retry-delay", "-1s")
+		assert.EqualError(t, err, "--retry-delay must not be negative, got -1s")
+
+		setOpts := setStatusOptions(preconditions{}, nil, runOptions{maxRetries: 2, retryOnConflict: true, retryDelay: time.Second}, nil)
+		assert.True(t, setOpts.RetryOnConflict)
+		assert.Equal(t, time.Second, setOpts.RetryDelay)
+	})
+}
+
+func TestRun_DryRunModes(t *testing.T) {
+	execute := func(t *testing.T, factory configurationFactory, args ...string) (string, error) {
+		t.Helper()
+		originalFactory := ConfigurationFactory
+		defer func() { ConfigurationFactory = originalFactory }()
+		ConfigurationFactory = factory
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetErr(&bytes.Buffer{})
+		cmd.SetArgs(args)
+		err := cmd.Execute()
+		return buf.String(), err
+	}
+	newStore := func(t *testing.T) *storage.Storage {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		require.NoError(t, store.Create(&release.Release{
+			Name:      "my-release",
+			Namespace: "default",
+			Version:   1,
+			Info:      &release.Info{Status: release.StatusDeployed},
+		}))
+		return store
+	}
+	factoryFor := func(store *storage.Storage) configurationFactory {
+		return func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+	}
+	noCluster := func(string, status.ClientOptions) (*action.Configuration, error) {
+		t.Fatal("--dry-run=client must not contact the cluster")
+		return nil, nil
+	}
+
+	t.Run("server reads the release and checks preconditions", func(t *testing.T) {
+		for _, flag := range []string{"--dry-run", "--dry-run=server", "--dry-run=true"} {
+			store := newStore(t)
+			out, err := execute(t, factoryFor(store), "my-release", "failed", flag)
+			require.NoError(t, err, flag)
+			assert.Equal(t, "Would set release \"my-release\" revision 1 status from \"deployed\" to \"failed\"\n", out, flag)
+
+			out, err = execute(t, factoryFor(store), "missing", "failed", flag)
+			require.NoError(t, err, flag)
+			assert.Equal(t, "Warning: release \"missing\" not found, skipping\n", out, flag)
+
+			_, err = execute(t, factoryFor(store), "my-release", "failed", "--from", "pending-upgrade", flag)
+			var precondErr *status.PreconditionError
+			assert.ErrorAs(t, err, &precondErr, flag)
+
+			rel, err := store.Last("my-release")
+			require.NoError(t, err)
+			assert.Equal(t, release.StatusDeployed, rel.Info.Status, flag)
+		}
+	})
+
+	t.Run("client validates the arguments without reading the release", func(t *testing.T) {
+		out, err := execute(t, noCluster, "my-release", "missing", "failed", "--dry-run=client", "--from", "pending-upgrade")
+		require.NoError(t, err)
+		assert.Equal(t, `Would set release "my-release" status to "failed" (release not read with --dry-run=client)
+Would set release "missing" status to "failed" (release not read with --dry-run=client)
+Summary: 0 changed, 2 would change, 0 unchanged, 0 skipped, 0 not found, 0 errored (2 total)
+`, out)
+
+		out, err = execute(t, noCluster, "team-a/my-release=failed", "--dry-run=client", "--revision", "2", "--output", "json")
+		require.NoError(t, err)
+		var batch struct {
+			Results []changeResult `json:"results"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(out), &batch))
+		assert.Equal(t, []changeResult{{
+			Release:   "my-release",
+			Namespace: "team-a",
+			Revision:  2,
+			NewStatus: "failed",
+			Result:    resultWouldChange,
+			Reason:    "release not read with --dry-run=client",
+		}}, batch.Results)
+	})
+
+	t.Run("client still rejects an invalid status", func(t *testing.T) {
+		_, err := execute(t, noCluster, "my-release", "bogus", "--dry-run=client")
+		assert.ErrorContains(t, err, "invalid status: bogus")
+	})
+
+	t.Run("client rejects flags that read releases", func(t *testing.T) {
+		_, err := execute(t, noCluster, "failed", "--selector", "app=web", "--dry-run=client")
+		assert.EqualError(t, err, "--dry-run=client cannot be combined with --selector, which reads releases from the cluster")
+
+		_, err = execute(t, noCluster, "my-release", "failed", "--all-revisions", "--dry-run=client")
+		assert.EqualError(t, err, "--dry-run=client cannot be combined with --all-revisions, which reads releases from the cluster")
+	})
+
+	t.Run("none writes the change", func(t *testing.T) {
+		store := newStore(t)
+		_, err := execute(t, factoryFor(store), "my-release", "failed", "--dry-run=none")
+		require.NoError(t, err)
+		rel, err := store.Last("my-release")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusFailed, rel.Info.Status)
+	})
+
+	t.Run("rejects an unknown mode", func(t *testing.T) {
+		_, err := execute(t, noCluster, "my-release", "failed", "--dry-run=local")
+		assert.EqualError(t, err, `invalid --dry-run value "local" (valid: none, server, client)`)
+	})
+}
+
+func TestRun_WaitMaxInterval(t *testing.T) {
+	originalFactory := ConfigurationFactory
+	defer func() { ConfigurationFactory = originalFactory }()
+	ConfigurationFactory = func(string, status.ClientOptions) (*action.Configuration, error) {
+		return &action.Configuration{Releases: storage.Init(driver.NewMemory())}, nil
+	}
+
+	cmd := newRootCmd()
+	var buf bytes.Buffer
+	cmd.SetOut(&buf)
+	cmd.SetErr(&buf)
+	cmd.SetArgs([]string{"test-release", "failed", "--wait", "--wait-max-interval", "-1s"})
+
+	err := cmd.Execute()
+	require.Error(t, err)
+	assert.Equal(t, "--wait-max-interval must not be negative, got -1s", err.Error())
+
+	setOpts := setStatusOptions(preconditions{}, nil, runOptions{wait: true, timeout: time.Minute, waitMaxInterval: 2 * time.Second}, nil)
+	assert.Equal(t, 2*time.Second, setOpts.WaitMaxInterval)
+	setOpts = setStatusOptions(preconditions{}, nil, runOptions{timeout: time.Minute, waitMaxInterval: 2 * time.Second}, nil)
+	assert.Zero(t, setOpts.WaitMaxInterval)
+}
+
+func TestRun_WaitRequiresTimeout(t *testing.T) {
+	originalFactory := ConfigurationFactory
+	defer func() { ConfigurationFactory = originalFactory }()
+	ConfigurationFactory = func(string, status.ClientOptions) (*action.Configuration, error) {
+		return &action.Configuration{Releases: storage.Init(driver.NewMemory())}, nil
+	}
+
+	for _, timeout := range []string{"0", "-1s"} {
+		t.Run(timeout, func(t *testing.T) {
+			cmd := newRootCmd()
+			var buf bytes.Buffer
+			cmd.SetOut(&buf)
+			cmd.SetErr(&buf)
+			cmd.SetArgs([]string{"test-release", "failed", "--wait", "--timeout", timeout})
+
+			err := cmd.Execute()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "--wait requires a positive --timeout")
+		})
+	}
+}
+
+// blockingUpdateDriver wraps a memory driver and blocks every Update until
+// release is closed.
+type blockingUpdateDriver struct {
+	*driver.Memory
+	release chan struct{}
+}
+
+func (d *blockingUpdateDriver) Update(key string, rls *release.Release) error {
+	<-d.release
+	return d.Memory.Update(key, rls)
+}
+
+func TestRunWithConfigFactory_Timeout(t *testing.T) {
+	mem := driver.NewMemory()
+	rel := &release.Release{
+		Name:      "test-release",
+		Namespace: "default",
+		Version:   1,
+		Info: &release.Info{
+			Status: release.StatusDeployed,
+		},
+		Chart: &chart.Chart{
+			Metadata: &chart.Metadata{
+				Name:    "test-chart",
+				Version: "1.0.0",
+			},
+		},
+	}
+	require.NoError(t, mem.Create("sh.helm.release.v1.test-release.v1", rel))
+	d := &blockingUpdateDriver{Memory: mem, release: make(chan struct{})}
+	t.Cleanup(func() { close(d.release) })
+
+	configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+		return &action.Configuration{Releases: storage.Init(d)}, nil
+	}
+
+	cmd := newRootCmd()
+	var buf bytes.Buffer
+	cmd.SetOut(&buf)
+
+	start := time.Now()
+	err := runWithConfigFactory(cmd, []string{"test-release", "failed"}, runOptions{timeout: 20 * time.Millisecond}, configFactory)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+// blockingListDriver wraps a memory driver and blocks every List until
+// release is closed.
+type blockingListDriver struct {
+	*driver.Memory
+	release chan struct{}
+}
+
+func (d *blockingListDriver) List(filter func(*release.Release) bool) ([]*release.Release, error) {
+	<-d.release
+	return d.Memory.List(filter)
+}
+
+func TestRunWithConfigFactory_TimeoutWhileListing(t *testing.T) {
+	d := &blockingListDriver{Memory: driver.NewMemory(), release: make(chan struct{})}
+	t.Cleanup(func() { close(d.release) })
+
+	configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+		return &action.Configuration{Releases: storage.Init(d)}, nil
+	}
+
+	cmd := newRootCmd()
+	cmd.SetOut(&bytes.Buffer{})
+
+	start := time.Now()
+	err := runWithConfigFactory(cmd, []string{"failed"}, runOptions{selector: "team=platform", timeout: 20 * time.Millisecond}, configFactory)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, "stopped reading releases: context deadline exceeded", err.Error())
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+// namespacedDriver gives a view of a shared memory driver scoped to one
+// namespace, or to every namespace if namespace is empty, as Helm's
+// Kubernetes drivers are.
+type namespacedDriver struct {
+	*driver.Memory
+	namespace string
+}
+
+func (d *namespacedDriver) Get(key string) (*release.Release, error) {
+	d.SetNamespace(d.namespace)
+	return d.Memory.Get(key)
+}
+
+func (d *namespacedDriver) List(filter func(*release.Release) bool) ([]*release.Release, error) {
+	d.SetNamespace(d.namespace)
+	return d.Memory.List(filter)
+}
+
+func (d *namespacedDriver) Query(keyvals map[string]string) ([]*release.Release, error) {
+	d.SetNamespace(d.namespace)
+	return d.Memory.Query(keyvals)
+}
+
+// forbiddenListDriver fails every List as a Kubernetes API server does when
+// the caller may not list secrets cluster-wide.
+type forbiddenListDriver struct {
+	*driver.Memory
+}
+
+func (f *forbiddenListDriver) List(func(*release.Release) bool) ([]*release.Release, error) {
+	return nil, apierrors.NewForbidden(schema.GroupResource{Resource: "secrets"}, "", errors.New("cannot list resource \"secrets\" at the cluster scope"))
+}
+
+func TestRunWithConfigFactory_AllNamespaces(t *testing.T) {
+	newMemory := func(t *testing.T) *driver.Memory {
+		t.Helper()
+		mem := driver.NewMemory()
+		store := storage.Init(mem)
+		for _, r := range []struct {
+			name      string
+			namespace string
+			app       string
+		}{
+			{"web", "staging", "frontend"},
+			{"web", "production", "frontend"},
+			{"api", "production", "backend"},
+		} {
+			require.NoError(t, store.Create(&release.Release{
+				Name:      r.name,
+				Namespace: r.namespace,
+				Version:   1,
+				Labels:    map[string]string{"app": r.app},
+				Info:      &release.Info{Status: release.StatusDeployed},
+				Chart: &chart.Chart{
+					Metadata: &chart.Metadata{
+						Name:    "test-chart",
+						Version: "1.0.0",
+					},
+				},
+			}))
+		}
+		return mem
+	}
+	configFactoryFor := func(mem *driver.Memory) configurationFactory {
+		return func(namespace string, opts status.ClientOptions) (*action.Configuration, error) {
+			if opts.AllNamespaces {
+				namespace = ""
+			}
+			return &action.Configuration{Releases: storage.Init(&namespacedDriver{Memory: mem, namespace: namespace})}, nil
+		}
+	}
+	statusIn := func(t *testing.T, mem *driver.Memory, namespace, name string) release.Status {
+		t.Helper()
+		rel, err := storage.Init(&namespacedDriver{Memory: mem, namespace: namespace}).Last(name)
+		require.NoError(t, err)
+		return rel.Info.Status
+	}
+
+	t.Run("sets status on matching releases in every namespace", func(t *testing.T) {
+		mem := newMemory(t)
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		err := runWithConfigFactory(cmd, []string{"failed"}, runOptions{selector: "app=frontend", allNamespaces: true}, configFactoryFor(mem))
+		require.NoError(t, err)
+		assert.Equal(t, "Namespace \"production\":\n"+
+			"Release \"web\" revision 1 status changed from \"deployed\" to \"failed\"\n"+
+			"Namespace \"staging\":\n"+
+			"Release \"web\" revision 1 status changed from \"deployed\" to \"failed\"\n"+
+			"Summary: 2 changed, 0 unchanged, 0 skipped, 0 not found, 0 errored (2 total)\n", buf.String())
+
+		assert.Equal(t, release.StatusFailed, statusIn(t, mem, "production", "web"))
+		assert.Equal(t, release.StatusFailed, statusIn(t, mem, "staging", "web"))
+		assert.Equal(t, release.StatusDeployed, statusIn(t, mem, "production", "api"))
+	})
+
+	t.Run("reports the namespace of each result", func(t *testing.T) {
+		mem := newMemory(t)
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		opts := runOptions{selector: "app=frontend", allNamespaces: true, output: outputJSON}
+		require.NoError(t, runWithConfigFactory(cmd, []string{"failed"}, opts, configFactoryFor(mem)))
+
+		var out batchOutput
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &out))
+		require.Len(t, out.Results, 2)
+		assert.Equal(t, "production", out.Results[0].Namespace)
+		assert.Equal(t, "staging", out.Results[1].Namespace)
+		assert.Equal(t, 2, out.Summary.Changed)
+	})
+
+	t.Run("explains a forbidden cluster-wide list", func(t *testing.T) {
+		configFactory := func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: storage.Init(&forbiddenListDriver{Memory: driver.NewMemory()})}, nil
+		}
+
+		err := runWithConfigFactory(newRootCmd(), []string{"failed"}, runOptions{selector: "app=frontend", allNamespaces: true}, configFactory)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "--all-namespaces: not allowed to list releases in every namespace")
+		assert.True(t, apierrors.IsForbidden(err))
+	})
+
+	t.Run("rejects incompatible flags", func(t *testing.T) {
+		for _, tc := range []struct {
+			opts runOptions
+			want string
+		}{
+			{runOptions{allNamespaces: true}, "--all-namespaces requires --selector"},
+			{runOptions{allNamespaces: true, selector: "app=frontend", namespace: "production"}, "--all-namespaces cannot be combined with --namespace"},
+			{runOptions{allNamespaces: true, selector: "app=frontend", atomic: true}, "--all-namespaces cannot be combined with --atomic"},
+		} {
+			err := runWithConfigFactory(newRootCmd(), []string{"failed"}, tc.opts, configFactoryFor(newMemory(t)))
+			assert.EqualError(t, err, tc.want)
+		}
+	})
+}
+
+func TestRunWithConfigFactory_Glob(t *testing.T) {
+	newStore := func(t *testing.T) *storage.Storage {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		for _, name := range []string{"frontend-a", "frontend-b", "backend"} {
+			require.NoError(t, store.Create(&release.Release{
+				Name:      name,
+				Namespace: "default",
+				Version:   1,
+				Info:      &release.Info{Status: release.StatusDeployed},
+				Chart: &chart.Chart{
+					Metadata: &chart.Metadata{
+						Name:    "test-chart",
+						Version: "1.0.0",
+					},
+				},
+			}))
+		}
+		return store
+	}
+	factoryFor := func(store *storage.Storage) configurationFactory {
+		return func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+	}
+
+	t.Run("sets status on the releases a pattern matches", func(t *testing.T) {
+		store := newStore(t)
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		err := runWithConfigFactory(cmd, []string{"frontend-*", "failed"}, runOptions{glob: true}, factoryFor(store))
+		require.NoError(t, err)
+		assert.Contains(t, buf.String(), "Pattern \"frontend-*\" matched frontend-a, frontend-b\n")
+		assert.Contains(t, buf.String(), "Summary: 2 changed, 0 unchanged, 0 skipped, 0 not found, 0 errored (2 total)")
+
+		for name, expected := range map[string]release.Status{
+			"frontend-a": release.StatusFailed,
+			"frontend-b": release.StatusFailed,
+			"backend":    release.StatusDeployed,
+		} {
+			rel, err := store.Last(name)
+			require.NoError(t, err)
+			assert.Equal(t, expected, rel.Info.Status, name)
+		}
+	})
+
+	t.Run("reports a pattern that matches nothing", func(t *testing.T) {
+		store := newStore(t)
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		err := runWithConfigFactory(cmd, []string{"worker-*", "backend", "failed"}, runOptions{glob: true}, factoryFor(store))
+		require.NoError(t, err)
+		assert.Contains(t, buf.String(), "Pattern \"worker-*\" matched no releases\n")
+		assert.Contains(t, buf.String(), "Pattern \"backend\" matched backend\n")
+		assert.Contains(t, buf.String(), "(1 total)")
+	})
+
+	t.Run("treats names literally without --glob", func(t *testing.T) {
+		store := newStore(t)
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		err := runWithConfigFactory(cmd, []string{"frontend-*", "failed"}, runOptions{}, factoryFor(store))
+		var nameErr *status.InvalidReleaseNameError
+		require.ErrorAs(t, err, &nameErr)
+
+		rel, err := store.Last("frontend-a")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusDeployed, rel.Info.Status)
+	})
+
+	t.Run("keeps JSON output free of match reports", func(t *testing.T) {
+		store := newStore(t)
+
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		err := runWithConfigFactory(cmd, []string{"frontend-*", "frontend-a", "failed"}, runOptions{glob: true, output: outputJSON}, factoryFor(store))
+		require.NoError(t, err)
+
+		var out batchOutput
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &out))
+		require.Len(t, out.Results, 2)
+		assert.Equal(t, "frontend-a", out.Results[0].Release)
+		assert.Equal(t, "frontend-b", out.Results[1].Release)
+	})
+
+	t.Run("rejects --glob with --selector", func(t *testing.T) {
+		err := runWithConfigFactory(newRootCmd(), []string{"failed"}, runOptions{glob: true, selector: "app=frontend"}, factoryFor(newStore(t)))
+		assert.EqualError(t, err, "--glob cannot be combined with --selector or --from-file")
+	})
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"helm.sh/helm/v3/pkg/release"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	outputText  = "text"
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+	// outputGitHub writes results as GitHub Actions workflow commands.
+	outputGitHub = "github"
+)
+
+// validOutputFormats lists the accepted values for --output.
+var validOutputFormats = []string{outputText, outputTable, outputJSON, outputYAML, outputGitHub}
+
+// validReportFormats lists the accepted values for --output of the commands
+// that report on releases without changing them, which have no GitHub
+// Actions form.
+var validReportFormats = []string{outputText, outputTable, outputJSON, outputYAML}
+
+// Values of changeResult.Result.
+const (
+	resultChanged     = "changed"
+	resultWouldChange = "would-change"
+	resultUnchanged   = "unchanged"
+	resultSkipped     = "skipped"
+	resultNotFound    = "not-found"
+	resultError       = "error"
+	// resultNotAttempted is a change --fail-fast or --atomic left undone
+	// after an earlier change failed.
+	resultNotAttempted = "not-attempted"
+	// resultRolledBack is a change --atomic undid after a later change
+	// failed. It is only recorded in --audit-log.
+	resultRolledBack = "rolled-back"
+)
+
+// changeResult describes the outcome of a single status change.
+type changeResult struct {
+	Release        string `json:"release"`
+	Namespace      string `json:"namespace"`
+	Revision       int    `json:"revision"`
+	PreviousStatus string `json:"previous_status,omitempty"`
+	NewStatus      string `json:"new_status"`
+	Changed        bool   `json:"changed"`
+	Skipped        bool   `json:"skipped"`
+	Result         string `json:"result"`
+	Reason         string `json:"reason,omitempty"`
+	Description    string `json:"description,omitempty"`
+	StoredStatus   string `json:"stored_status,omitempty"`
+	PostHookError  string `json:"post_hook_error,omitempty"`
+
+	// StoredRelease is the release as stored after the change, set by
+	// --print-release.
+	StoredRelease *release.Release `json:"stored_release,omitempty"`
+	Pruned        []int            `json:"pruned,omitempty"`
+	WouldPrune    []int            `json:"would_prune,omitempty"`
+
+	Superseded     []int `json:"superseded,omitempty"`
+	WouldSupersede []int `json:"would_supersede,omitempty"`
+
+	// storedRevision is the revision SetStatus resolved the change to, or 0
+	// if the release was not read. Revision is set to it too, but until then
+	// holds the revision asked for, which is 0 for the latest revision.
+	storedRevision int
+	// stored is the release SetStatus returned.
+	stored *release.Release
+	// previous is the release as SetStatus read it before the change.
+	previous *release.Release
+}
+
+// validateOutputFormat returns an error if format is not a supported --output value.
+// An empty format selects text output.
+func validateOutputFormat(format string) error {
+	return validateFormat(format, validOutputFormats)
+}
+
+// validateReportFormat is like validateOutputFormat, for the commands that
+// accept validReportFormats.
+func validateReportFormat(format string) error {
+	return validateFormat(format, validReportFormats)
+}
+
+// validateFormat returns an error if format is neither empty nor in valid.
+func validateFormat(format string, valid []string) error {
+	if format == "" || slices.Contains(valid, format) {
+		return nil
+	}
+	return fmt.Errorf("invalid --output format %q (valid: %s)", format, strings.Join(valid, ", "))
+}
+
+// writeStructured renders v to w as indented JSON or as YAML. YAML output
+// uses the same field names as the JSON form.
+func writeStructured(w io.Writer, format string, v any) error {
+	if format == outputYAML {
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// isStructured reports whether format is a machine-readable output format.
+func isStructured(format string) bool {
+	return format == outputJSON || format == outputYAML
+}
+
+// writeResult renders res to w in the given output format.
+func writeResult(w io.Writer, format string, res changeResult) error {
+	if isStructured(format) {
+		return writeStructured(w, format, res)
+	}
+	if format == outputTable {
+		return writeResultsTable(w, []changeResult{res})
+	}
+	if format == outputGitHub {
+		return writeWorkflowResult(w, res)
+	}
+
+	var err error
+	switch res.Result {
+	case resultNotFound:
+		_, err = fmt.Fprintf(w, "Warning: release %q not found, skipping\n", res.Release)
+	case resultError:
+		_, err = fmt.Fprintf(w, "Error: release %q: %s\n", res.Release, res.Reason)
+	case resultUnchanged:
+		_, err = fmt.Fprintf(w, "Release %q already %q, no change\n", res.Release, res.NewStatus)
+	case resultSkipped:
+		_, err = fmt.Fprintf(w, "Skipped: %s\n", res.Reason)
+	case resultNotAttempted:
+		_, err = fmt.Fprintf(w, "Release %q %s\n", res.Release, res.Reason)
+	case resultWouldChange:
+		switch {
+		case res.PreviousStatus == "" && res.Revision > 0:
+			// The release was not read, as with --dry-run=client
+			_, err = fmt.Fprintf(w, "Would set release %q revision %d status to %q (%s)\n", res.Release, res.Revision, res.NewStatus, res.Reason)
+		case res.PreviousStatus == "":
+			_, err = fmt.Fprintf(w, "Would set release %q status to %q (%s)\n", res.Release, res.NewStatus, res.Reason)
+		case res.Revision > 0:
+			_, err = fmt.Fprintf(w, "Would set release %q revision %d status from %q to %q\n", res.Release, res.Revision, res.PreviousStatus, res.NewStatus)
+		default:
+			_, err = fmt.Fprintf(w, "Would set release %q status from %q to %q\n", res.Release, res.PreviousStatus, res.NewStatus)
+		}
+	default:
+		if res.Revision > 0 {
+			_, err = fmt.Fprintf(w, "Release %q revision %d status changed from %q to %q\n", res.Release, res.Revision, res.PreviousStatus, res.NewStatus)
+		} else {
+			_, err = fmt.Fprintf(w, "Release %q status changed from %q to %q\n", res.Release, res.PreviousStatus, res.NewStatus)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if len(res.Superseded) > 0 {
+		_, err = fmt.Fprintf(w, "Superseded release %q revisions %s\n", res.Release, joinRevisions(res.Superseded))
+	} else if len(res.WouldSupersede) > 0 {
+		_, err = fmt.Fprintf(w, "Would supersede release %q revisions %s\n", res.Release, joinRevisions(res.WouldSupersede))
+	}
+	if err != nil {
+		return err
+	}
+	if len(res.Pruned) > 0 {
+		_, err = fmt.Fprintf(w, "Pruned release %q revisions %s\n", res.Release, joinRevisions(res.Pruned))
+	} else if len(res.WouldPrune) > 0 {
+		_, err = fmt.Fprintf(w, "Would prune release %q revisions %s\n", res.Release, joinRevisions(res.WouldPrune))
+	}
+	return err
+}
+
+// joinRevisions returns revisions as a comma-separated list.
+func joinRevisions(revisions []int) string {
+	s := make([]string, len(revisions))
+	for i, v := range revisions {
+		s[i] = strconv.Itoa(v)
+	}
+	return strings.Join(s, ", ")
+}
+
+// batchSummary counts the outcomes of a batch of status changes.
+type batchSummary struct {
+	Total        int `json:"total"`
+	Changed      int `json:"changed"`
+	WouldChange  int `json:"would_change,omitempty"`
+	Unchanged    int `json:"unchanged"`
+	Skipped      int `json:"skipped"`
+	NotFound     int `json:"not_found"`
+	Errored      int `json:"errored"`
+	NotAttempted int `json:"not_attempted,omitempty"`
+}
+
+// summarize counts results by outcome.
+func summarize(results []changeResult) batchSummary {
+	s := batchSummary{Total: len(results)}
+	for _, res := range results {
+		switch res.Result {
+		case resultChanged:
+			s.Changed++
+		case resultWouldChange:
+			s.WouldChange++
+		case resultUnchanged:
+			s.Unchanged++
+		case resultSkipped:
+			s.Skipped++
+		case resultNotFound:
+			s.NotFound++
+		case resultError:
+			s.Errored++
+		case resultNotAttempted:
+			s.NotAttempted++
+		}
+	}
+	return s
+}
+
+// String returns the summary as a single line of text output.
+func (s batchSummary) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Summary: %d changed, ", s.Changed)
+	if s.WouldChange > 0 {
+		fmt.Fprintf(&b, "%d would change, ", s.WouldChange)
+	}
+	fmt.Fprintf(&b, "%d unchanged, %d skipped, %d not found, %d errored",
+		s.Unchanged, s.Skipped, s.NotFound, s.Errored)
+	if s.NotAttempted > 0 {
+		fmt.Fprintf(&b, ", %d not attempted", s.NotAttempted)
+	}
+	fmt.Fprintf(&b, " (%d total)", s.Total)
+	return b.String()
+}
+
+// batchOutput is the JSON and YAML form of a batch of status changes.
+type batchOutput struct {
+	Results []changeResult `json:"results"`
+	Summary batchSummary   `json:"summary"`
+}
+
+// writeResults renders the outcome of a batch of status changes to w,
+// followed by a summary of the outcomes. In JSON or YAML mode the results and
+// summary are written as one object; in text mode each release is written on
+// its own line, and in table mode as a row of a table, with the summary on the
+// last line.
+func writeResults(w io.Writer, format string, results []changeResult) error {
+	summary := summarize(results)
+	if isStructured(format) {
+		if results == nil {
+			results = []changeResult{}
+		}
+		return writeStructured(w, format, batchOutput{Results: results, Summary: summary})
+	}
+
+	if format == outputTable {
+		if err := writeResultsTable(w, results); err != nil {
+			return err
+		}
+	} else {
+		for _, res := range results {
+			if err := writeResult(w, format, res); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := fmt.Fprintln(w, summary)
+	return err
+}
+
+// writeResultsByNamespace renders results like writeResults, except that in
+// text mode the results of each namespace follow a heading naming it.
+// Results of the same namespace must be adjacent.
+func writeResultsByNamespace(w io.Writer, format string, results []changeResult) error {
+	if format != outputText && format != "" {
+		return writeResults(w, format, results)
+	}
+
+	for i, res := range results {
+		if i == 0 || res.Namespace != results[i-1].Namespace {
+			if _, err := fmt.Fprintf(w, "Namespace %q:\n", res.Namespace); err != nil {
+				return err
+			}
+		}
+		if err := writeResult(w, format, res); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w, summarize(results))
+	return err
+}
+
+// spansNamespaces reports whether results are for releases in more than one
+// namespace.
+func spansNamespaces(results []changeResult) bool {
+	for i := 1; i < len(results); i++ {
+		if results[i].Namespace != results[0].Namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// writeResultsTable renders results to w as a table with a row per release,
+// aligned with a tabwriter. The STATUS column shows the previous and new
+// status, and REVISION the revision changed when it is known.
+func writeResultsTable(w io.Writer, results []changeResult) error {
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "RELEASE\tNAMESPACE\tREVISION\tSTATUS\tRESULT\tREASON")
+	for _, res := range results {
+		revision := "-"
+		if res.storedRevision > 0 {
+			revision = strconv.Itoa(res.storedRevision)
+		} else if res.Revision > 0 {
+			revision = strconv.Itoa(res.Revision)
+		}
+		previous := res.PreviousStatus
+		if previous == "" {
+			previous = "-"
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s -> %s\t%s\t%s\n",
+			res.Release, res.Namespace, revision, previous, res.NewStatus, res.Result, res.Reason)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	// Rows without a reason would otherwise end in the padding of the
+	// RESULT column
+	for line := range strings.Lines(buf.String()) {
+		if _, err := fmt.Fprintln(w, strings.TrimRight(line, " \n")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/yaml"
+)
+
+func TestValidateOutputFormat(t *testing.T) {
+	assert.NoError(t, validateOutputFormat(""))
+	assert.NoError(t, validateOutputFormat("text"))
+	assert.NoError(t, validateOutputFormat("table"))
+	assert.NoError(t, validateOutputFormat("json"))
+	assert.NoError(t, validateOutputFormat("yaml"))
+
+	err := validateOutputFormat("xml")
+	assert.Error(t, err)
+	assert.Equal(t, `invalid --output format "xml" (valid: text, table, json, yaml, github)`, err.Error())
+}
+
+func TestValidateReportFormat(t *testing.T) {
+	assert.NoError(t, validateReportFormat(""))
+	assert.NoError(t, validateReportFormat("table"))
+
+	err := validateReportFormat("github")
+	assert.Error(t, err)
+	assert.Equal(t, `invalid --output format "github" (valid: text, table, json, yaml)`, err.Error())
+}
+
+func TestWriteResults_Table(t *testing.T) {
+	results := []changeResult{
+		{Release: "frontend", Namespace: "default", PreviousStatus: "pending-upgrade", NewStatus: "failed", Changed: true, Result: resultChanged, storedRevision: 12},
+		{Release: "api", Namespace: "production", Revision: 3, PreviousStatus: "failed", NewStatus: "failed", Result: resultUnchanged, Reason: "already failed, no change", storedRevision: 3},
+		{Release: "missing-release", Namespace: "default", NewStatus: "failed", Result: resultNotFound, Reason: `release "missing-release" not found`},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, writeResults(&buf, outputTable, results))
+	assert.Equal(t, `RELEASE          NAMESPACE   REVISION  STATUS                     RESULT     REASON
+frontend         default     12        pending-upgrade -> failed  changed
+api              production  3         failed -> failed           unchanged  already failed, no change
+missing-release  default     -         - -> failed                not-found  release "missing-release" not found
+Summary: 1 changed, 1 unchanged, 0 skipped, 1 not found, 0 errored (3 total)
+`, buf.String())
+
+	t.Run("single release", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, writeResult(&buf, outputTable, results[0]))
+		assert.Equal(t, `RELEASE   NAMESPACE  REVISION  STATUS                     RESULT   REASON
+frontend  default    12        pending-upgrade -> failed  changed
+`, buf.String())
+	})
+
+	t.Run("by namespace", func(t *testing.T) {
+		var byNamespace, plain bytes.Buffer
+		require.NoError(t, writeResultsByNamespace(&byNamespace, outputTable, results))
+		require.NoError(t, writeResults(&plain, outputTable, results))
+		assert.Equal(t, plain.String(), byNamespace.String())
+	})
+}
+
+func TestWriteResult_Text(t *testing.T) {
+	tests := []struct {
+		name     string
+		res      changeResult
+		expected string
+	}{
+		{
+			name:     "changed latest",
+			res:      changeResult{Release: "my-release", PreviousStatus: "deployed", NewStatus: "failed", Changed: true, Result: resultChanged},
+			expected: "Release \"my-release\" status changed from \"deployed\" to \"failed\"\n",
+		},
+		{
+			name:     "changed revision",
+			res:      changeResult{Release: "my-release", Revision: 2, PreviousStatus: "deployed", NewStatus: "failed", Changed: true, Result: resultChanged},
+			expected: "Release \"my-release\" revision 2 status changed from \"deployed\" to \"failed\"\n",
+		},
+		{
+			name:     "dry run latest",
+			res:      changeResult{Release: "my-release", PreviousStatus: "deployed", NewStatus: "failed", Result: resultWouldChange},
+			expected: "Would set release \"my-release\" status from \"deployed\" to \"failed\"\n",
+		},
+		{
+			name:     "dry run revision",
+			res:      changeResult{Release: "my-release", Revision: 2, PreviousStatus: "deployed", NewStatus: "failed", Result: resultWouldChange},
+			expected: "Would set release \"my-release\" revision 2 status from \"deployed\" to \"failed\"\n",
+		},
+		{
+			name:     "unchanged",
+			res:      changeResult{Release: "my-release", PreviousStatus: "deployed", NewStatus: "deployed", Result: resultUnchanged},
+			expected: "Release \"my-release\" already \"deployed\", no change\n",
+		},
+		{
+			name:     "skipped",
+			res:      changeResult{Release: "my-release", NewStatus: "failed", Result: resultSkipped, Reason: "precondition"},
+			expected: "Skipped: precondition\n",
+		},
+		{
+			name:     "error",
+			res:      changeResult{Release: "my-release", NewStatus: "failed", Result: resultError, Reason: "boom"},
+			expected: "Error: release \"my-release\": boom\n",
+		},
+		{
+			name:     "not found",
+			res:      changeResult{Release: "my-release", NewStatus: "failed", Result: resultNotFound},
+			expected: "Warning: release \"my-release\" not found, skipping\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, writeResult(&buf, outputText, tt.res))
+			assert.Equal(t, tt.expected, buf.String())
+		})
+	}
+}
+
+func TestWriteResult_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	res := changeResult{
+		Release:        "my-release",
+		Namespace:      "default",
+		Revision:       3,
+		PreviousStatus: "deployed",
+		NewStatus:      "failed",
+		Changed:        true,
+		Result:         resultChanged,
+	}
+	require.NoError(t, writeResult(&buf, outputJSON, res))
+
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, "my-release", decoded["release"])
+	assert.Equal(t, "default", decoded["namespace"])
+	assert.Equal(t, float64(3), decoded["revision"])
+	assert.Equal(t, "deployed", decoded["previous_status"])
+	assert.Equal(t, "failed", decoded["new_status"])
+	assert.Equal(t, true, decoded["changed"])
+	assert.Equal(t, "changed", decoded["result"])
+	assert.NotContains(t, decoded, "reason")
+}
+
+func TestWriteResult_YAML(t *testing.T) {
+	var buf bytes.Buffer
+	res := changeResult{
+		Release:        "my-release",
+		Namespace:      "default",
+		Revision:       3,
+		PreviousStatus: "deployed",
+		NewStatus:      "failed",
+		Changed:        true,
+		Result:         resultChanged,
+	}
+	require.NoError(t, writeResult(&buf, outputYAML, res))
+
+	assert.Equal(t, `changed: true
+namespace: default
+new_status: failed
+previous_status: deployed
+release: my-release
+result: changed
+revision: 3
+skipped: false
+`, buf.String())
+
+	var decoded changeResult
+	require.NoError(t, yaml.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, res, decoded)
+}
+
+func TestWriteResults(t *testing.T) {
+	results := []changeResult{
+		{Release: "rel1", PreviousStatus: "deployed", NewStatus: "failed", Changed: true, Result: resultChanged},
+		{Release: "rel2", NewStatus: "failed", Result: resultNotFound},
+	}
+
+	t.Run("results are written as JSON with a summary", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, writeResults(&buf, outputJSON, results))
+
+		var decoded batchOutput
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+		assert.Equal(t, results, decoded.Results)
+		assert.Equal(t, batchSummary{Total: 2, Changed: 1, NotFound: 1}, decoded.Summary)
+	})
+
+	t.Run("empty results are written as an empty JSON list", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, writeResults(&buf, outputJSON, nil))
+		assert.JSONEq(t, `{"results": [], "summary": {"total": 0, "changed": 0, "unchanged": 0, "skipped": 0, "not_found": 0, "errored": 0}}`, buf.String())
+	})
+
+	t.Run("results are written as YAML with a summary", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, writeResults(&buf, outputYAML, results))
+		assert.True(t, strings.HasPrefix(buf.String(), "results:\n- changed: true\n"))
+
+		var decoded batchOutput
+		require.NoError(t, yaml.Unmarshal(buf.Bytes(), &decoded))
+		assert.Equal(t, results, decoded.Results)
+		assert.Equal(t, batchSummary{Total: 2, Changed: 1, NotFound: 1}, decoded.Summary)
+	})
+
+	t.Run("results are written one per line as text", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, writeResults(&buf, outputText, results))
+		assert.Equal(t, "Release \"rel1\" status changed from \"deployed\" to \"failed\"\n"+
+			"Warning: release \"rel2\" not found, skipping\n"+
+			"Summary: 1 changed, 0 unchanged, 0 skipped, 1 not found, 0 errored (2 total)\n", buf.String())
+	})
+}
+
+func TestSummarize(t *testing.T) {
+	results := []changeResult{
+		{Result: resultChanged},
+		{Result: resultChanged},
+		{Result: resultWouldChange},
+		{Result: resultUnchanged},
+		{Result: resultSkipped},
+		{Result: resultNotFound},
+		{Result: resultError},
+	}
+
+	summary := summarize(results)
+	assert.Equal(t, batchSummary{Total: 7, Changed: 2, WouldChange: 1, Unchanged: 1, Skipped: 1, NotFound: 1, Errored: 1}, summary)
+	assert.Equal(t, "Summary: 2 changed, 1 would change, 1 unchanged, 1 skipped, 1 not found, 1 errored (7 total)", summary.String())
+
+	summary = summarize(append(results, changeResult{Result: resultNotAttempted}))
+	assert.Equal(t, 1, summary.NotAttempted)
+	assert.Equal(t, "Summary: 2 changed, 1 would change, 1 unchanged, 1 skipped, 1 not found, 1 errored, 1 not attempted (8 total)", summary.String())
+}
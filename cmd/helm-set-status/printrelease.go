@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// printableRelease returns the copy of rel printed by --print-release. Unless
+// unsafe is set, everything that may hold secrets is left out: the values
+// the release was installed with, its rendered manifest, hooks, and notes,
+// and the chart's templates, files, and default values, leaving only the
+// chart's metadata.
+func printableRelease(rel *release.Release, unsafe bool) *release.Release {
+	if unsafe {
+		return rel
+	}
+
+	printed := *rel
+	printed.Config = nil
+	printed.Manifest = ""
+	printed.Hooks = nil
+	if rel.Info != nil {
+		info := *rel.Info
+		info.Notes = ""
+		info.Resources = nil
+		printed.Info = &info
+	}
+	if rel.Chart != nil {
+		printed.Chart = &chart.Chart{Metadata: rel.Chart.Metadata}
+	}
+	return &printed
+}
+
+// attachStoredReleases sets StoredRelease on each result whose status was
+// set or already had the target status, for --print-release.
+func attachStoredReleases(results []changeResult, unsafe bool) {
+	for i, res := range results {
+		if res.stored == nil || (res.Result != resultChanged && res.Result != resultUnchanged) {
+			continue
+		}
+		results[i].StoredRelease = printableRelease(res.stored, unsafe)
+	}
+}
+
+// writeStoredReleases writes the StoredRelease of each result to w as
+// indented JSON, for --print-release with text or table output. Structured
+// output includes them in the results instead.
+func writeStoredReleases(w io.Writer, results []changeResult) error {
+	for _, res := range results {
+		if res.StoredRelease == nil {
+			continue
+		}
+		data, err := json.MarshalIndent(res.StoredRelease, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode release %q: %w", res.Release, err)
+		}
+		if _, err := fmt.Fprintf(w, "%s\n", data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
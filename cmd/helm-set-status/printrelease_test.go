@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/josegonzalez/helm-set-status/pkg/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+func TestRunWithConfigFactory_PrintRelease(t *testing.T) {
+	newFactory := func(t *testing.T) configurationFactory {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		require.NoError(t, store.Create(&release.Release{
+			Name:      "my-release",
+			Namespace: "default",
+			Version:   1,
+			Info: &release.Info{
+				Status: release.StatusPendingUpgrade,
+				Notes:  "The admin password is hunter2",
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+				Values: map[string]any{"password": "changeme"},
+			},
+			Config:   map[string]any{"password": "hunter2"},
+			Manifest: "kind: Secret\nstringData:\n  password: hunter2\n",
+		}))
+		return func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+	}
+	run := func(t *testing.T, opts runOptions) string {
+		t.Helper()
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		require.NoError(t, runWithConfigFactory(cmd, []string{"my-release", "failed"}, opts, newFactory(t)))
+		return buf.String()
+	}
+
+	t.Run("prints the release after the result without secrets", func(t *testing.T) {
+		out := run(t, runOptions{printRelease: true})
+
+		result, data, ok := strings.Cut(out, "\n")
+		require.True(t, ok)
+		assert.Equal(t, `Release "my-release" revision 1 status changed from "pending-upgrade" to "failed"`, result)
+
+		var rel release.Release
+		require.NoError(t, json.Unmarshal([]byte(data), &rel))
+		assert.Equal(t, "my-release", rel.Name)
+		assert.Equal(t, 1, rel.Version)
+		assert.Equal(t, release.StatusFailed, rel.Info.Status)
+		assert.Equal(t, "status set to failed", rel.Info.Description)
+		assert.Equal(t, "test-chart", rel.Chart.Metadata.Name)
+		assert.NotContains(t, out, "hunter2")
+		assert.NotContains(t, out, "changeme")
+	})
+
+	t.Run("includes secrets with --unsafe", func(t *testing.T) {
+		out := run(t, runOptions{printRelease: true, unsafe: true})
+
+		_, data, _ := strings.Cut(out, "\n")
+		var rel release.Release
+		require.NoError(t, json.Unmarshal([]byte(data), &rel))
+		assert.Equal(t, release.StatusFailed, rel.Info.Status)
+		assert.Equal(t, map[string]any{"password": "hunter2"}, rel.Config)
+		assert.Contains(t, rel.Manifest, "password: hunter2")
+		assert.Equal(t, "The admin password is hunter2", rel.Info.Notes)
+	})
+
+	t.Run("adds the release to structured output", func(t *testing.T) {
+		out := run(t, runOptions{printRelease: true, output: outputJSON})
+
+		var res changeResult
+		require.NoError(t, json.Unmarshal([]byte(out), &res))
+		require.NotNil(t, res.StoredRelease)
+		assert.Equal(t, release.StatusFailed, res.StoredRelease.Info.Status)
+		assert.NotContains(t, out, "hunter2")
+	})
+
+	t.Run("leaves the release out of a dry run", func(t *testing.T) {
+		out := run(t, runOptions{printRelease: true, dryRun: true})
+		assert.Equal(t, "Would set release \"my-release\" revision 1 status from \"pending-upgrade\" to \"failed\"\n", out)
+	})
+
+	t.Run("rejects --unsafe without --print-release", func(t *testing.T) {
+		err := runWithConfigFactory(newRootCmd(), []string{"my-release", "failed"}, runOptions{unsafe: true}, newFactory(t))
+		assert.EqualError(t, err, "--unsafe requires --print-release")
+	})
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/josegonzalez/helm-set-status/pkg/status"
+	"github.com/spf13/cobra"
+)
+
+func newReconcileCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "reconcile --desired-file PATH",
+		Short: "Bring release statuses in line with a desired-state file",
+		Long: `Compare the statuses of the releases listed in --desired-file with the
+statuses they should have, and print the changes needed to reconcile them.
+
+The file lists one "RELEASE STATUS" or "RELEASE,STATUS" pair per line, like
+--from-file; blank lines and lines starting with # are ignored. Nothing is
+written unless --apply is set, in which case each release that differs is
+changed and the results are reported like a batch of changes. Releases that
+already have their desired status are left untouched. Setting a failed
+release to deployed is refused unless --i-understand is set.`,
+		Args:              cobra.NoArgs,
+		ValidArgsFunction: cobra.NoFileCompletions,
+		RunE:              runReconcile,
+	}
+
+	cmd.Flags().String("desired-file", "", "file listing the desired status of each release")
+	cmd.Flags().Bool("apply", false, "make the changes instead of only printing them")
+	cmd.Flags().String("description", "", "description to record on each release changed (default: \"status set to <STATUS>\")")
+	cmd.Flags().Bool("i-understand", false, "allow setting a failed release to deployed, which is refused otherwise")
+	cmd.Flags().StringP("output", "o", outputText, "output format: text, table, json, yaml, or github")
+
+	return cmd
+}
+
+func runReconcile(cmd *cobra.Command, _ []string) error {
+	var opts runOptions
+	readGlobalFlags(cmd, &opts)
+	opts.desiredFile, _ = cmd.Flags().GetString("desired-file")
+	opts.apply, _ = cmd.Flags().GetBool("apply")
+	opts.description, _ = cmd.Flags().GetString("description")
+	opts.iUnderstand, _ = cmd.Flags().GetBool("i-understand")
+	opts.output, _ = cmd.Flags().GetString("output")
+	return reportError(cmd, cmd.ErrOrStderr(), opts.output, runReconcileWithConfigFactory(cmd, opts, ConfigurationFactory))
+}
+
+func runReconcileWithConfigFactory(cmd *cobra.Command, opts runOptions, newConfig configurationFactory) error {
+	if err := validateOutputFormat(opts.output); err != nil {
+		return err
+	}
+	if opts.desiredFile == "" {
+		return errors.New("--desired-file is required")
+	}
+	if err := status.ValidateDescription(opts.description); err != nil {
+		return fmt.Errorf("--description: %w", err)
+	}
+
+	entries, err := readReleaseStatusFile("--desired-file", opts.desiredFile)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := newConfig(opts.namespace, opts.clientOptions())
+	if err != nil {
+		return fmt.Errorf("failed to create configuration: %w", err)
+	}
+
+	// Without --apply, the plan is a dry run of the same changes
+	opts.dryRun = !opts.apply
+	changes, err := namespacedChanges(cfg, entries, opts, newConfig)
+	if err != nil {
+		return err
+	}
+	setOpts := status.SetStatusOptions{
+		DryRun:      opts.dryRun,
+		Description: opts.description,
+		SafeMode:    !opts.iUnderstand,
+	}
+	results, errs := setReleaseStatuses(commandContext(cmd), changes, setOpts, opts)
+	failed := 0
+	for i, err := range errs {
+		if err != nil {
+			failed++
+			results[i].Result = resultError
+			results[i].Reason = err.Error()
+		}
+	}
+
+	warnInProgressStatuses(cmd.ErrOrStderr(), changes, results)
+	write := writeResults
+	if spansNamespaces(results) {
+		write = writeResultsByNamespace
+	}
+	if err := write(cmd.OutOrStdout(), opts.output, results); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to reconcile %d of %d releases", failed, len(changes))
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/josegonzalez/helm-set-status/pkg/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+func TestNewReconcileCmd(t *testing.T) {
+	cmd := newReconcileCmd()
+
+	assert.Equal(t, "reconcile --desired-file PATH", cmd.Use)
+
+	applyFlag := cmd.Flags().Lookup("apply")
+	assert.NotNil(t, applyFlag)
+	assert.Equal(t, "false", applyFlag.DefValue)
+	assert.NotNil(t, cmd.Flags().Lookup("desired-file"))
+	assert.NotNil(t, cmd.Flags().Lookup("i-understand"))
+}
+
+func TestRunReconcileWithConfigFactory(t *testing.T) {
+	newStore := func(t *testing.T) *storage.Storage {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		for _, r := range []struct {
+			name   string
+			status release.Status
+		}{
+			{"frontend", release.StatusPendingUpgrade},
+			{"backend", release.StatusDeployed},
+		} {
+			require.NoError(t, store.Create(&release.Release{
+				Name:      r.name,
+				Namespace: "default",
+				Version:   1,
+				Info: &release.Info{
+					Status: r.status,
+				},
+				Chart: &chart.Chart{
+					Metadata: &chart.Metadata{
+						Name:    "test-chart",
+						Version: "1.0.0",
+					},
+				},
+			}))
+		}
+		return store
+	}
+	factoryFor := func(store *storage.Storage) configurationFactory {
+		return func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+	}
+	writeDesired := func(t *testing.T) string {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "desired.txt")
+		require.NoError(t, os.WriteFile(path, []byte("# desired state\nfrontend deployed\nbackend deployed\nmissing failed\n"), 0o600))
+		return path
+	}
+	statusOf := func(t *testing.T, store *storage.Storage, name string) release.Status {
+		t.Helper()
+		rel, err := store.Last(name)
+		require.NoError(t, err)
+		return rel.Info.Status
+	}
+
+	t.Run("prints the plan without writing", func(t *testing.T) {
+		store := newStore(t)
+
+		cmd := newReconcileCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		require.NoError(t, runReconcileWithConfigFactory(cmd, runOptions{desiredFile: writeDesired(t)}, factoryFor(store)))
+		assert.Equal(t, `Would set release "frontend" revision 1 status from "pending-upgrade" to "deployed"
+Release "backend" already "deployed", no change
+Warning: release "missing" not found, skipping
+Summary: 0 changed, 1 would change, 1 unchanged, 0 skipped, 1 not found, 0 errored (3 total)
+`, buf.String())
+		assert.Equal(t, release.StatusPendingUpgrade, statusOf(t, store, "frontend"))
+	})
+
+	t.Run("applies the changes with --apply", func(t *testing.T) {
+		store := newStore(t)
+
+		cmd := newReconcileCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		opts := runOptions{desiredFile: writeDesired(t), apply: true, output: outputJSON}
+		require.NoError(t, runReconcileWithConfigFactory(cmd, opts, factoryFor(store)))
+
+		var out batchOutput
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &out))
+		assert.Equal(t, batchSummary{Total: 3, Changed: 1, Unchanged: 1, NotFound: 1}, out.Summary)
+		assert.Equal(t, release.StatusDeployed, statusOf(t, store, "frontend"))
+		assert.Equal(t, release.StatusDeployed, statusOf(t, store, "backend"))
+	})
+
+	t.Run("refuses to set a failed release to deployed", func(t *testing.T) {
+		store := newStore(t)
+		rel, err := store.Last("backend")
+		require.NoError(t, err)
+		rel.Info.Status = release.StatusFailed
+
+		cmd := newReconcileCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		opts := runOptions{desiredFile: writeDesired(t), apply: true}
+		err = runReconcileWithConfigFactory(cmd, opts, factoryFor(store))
+		assert.EqualError(t, err, "failed to reconcile 1 of 3 releases")
+		assert.Contains(t, buf.String(), `setting it to "deployed" would hide the failure; use --i-understand to set it anyway`)
+		assert.Equal(t, release.StatusFailed, statusOf(t, store, "backend"))
+
+		opts.iUnderstand = true
+		require.NoError(t, runReconcileWithConfigFactory(cmd, opts, factoryFor(store)))
+		assert.Equal(t, release.StatusDeployed, statusOf(t, store, "backend"))
+	})
+
+	t.Run("requires --desired-file", func(t *testing.T) {
+		err := runReconcileWithConfigFactory(newReconcileCmd(), runOptions{}, nil)
+		assert.EqualError(t, err, "--desired-file is required")
+	})
+
+	t.Run("rejects a malformed file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "desired.txt")
+		require.NoError(t, os.WriteFile(path, []byte("frontend bogus\n"), 0o600))
+
+		err := runReconcileWithConfigFactory(newReconcileCmd(), runOptions{desiredFile: path}, nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid --desired-file")
+		assert.Contains(t, err.Error(), "line 1: invalid status: bogus")
+	})
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/josegonzalez/helm-set-status/pkg/status"
+	"github.com/spf13/cobra"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// recordedChange is a status change saved by --record: the revision that was
+// changed, the status it had before, and the status it was given, along with
+// the labels the change set and the values those labels had before, if any.
+type recordedChange struct {
+	Release        string            `json:"release"`
+	Namespace      string            `json:"namespace"`
+	Revision       int               `json:"revision"`
+	PreviousStatus string            `json:"previous_status"`
+	Status         string            `json:"status"`
+	Labels         []string          `json:"labels,omitempty"`
+	PreviousLabels map[string]string `json:"previous_labels,omitempty"`
+}
+
+// changeRecord is the file written by --record and read by the undo command.
+type changeRecord struct {
+	Changes []recordedChange `json:"changes"`
+}
+
+// writeChangeRecord writes the changed results to path for the undo command,
+// along with labels, those set by --set-annotation. Results that did not
+// change a release are left out.
+func writeChangeRecord(path string, results []changeResult, labels map[string]string) error {
+	rec := changeRecord{Changes: []recordedChange{}}
+	for _, res := range results {
+		if res.Result != resultChanged {
+			continue
+		}
+		c := recordedChange{
+			Release:        res.Release,
+			Namespace:      res.Namespace,
+			Revision:       res.storedRevision,
+			PreviousStatus: res.PreviousStatus,
+			Status:         res.NewStatus,
+		}
+		for _, k := range slices.Sorted(maps.Keys(labels)) {
+			c.Labels = append(c.Labels, k)
+			if res.previous == nil {
+				continue
+			}
+			if v, ok := res.previous.Labels[k]; ok {
+				if c.PreviousLabels == nil {
+					c.PreviousLabels = make(map[string]string)
+				}
+				c.PreviousLabels[k] = v
+			}
+		}
+		rec.Changes = append(rec.Changes, c)
+	}
+
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write --record: %w", err)
+	}
+	return nil
+}
+
+// readChangeRecord reads a file written by --record.
+func readChangeRecord(path string) (changeRecord, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return changeRecord{}, fmt.Errorf("failed to read record: %w", err)
+	}
+
+	var rec changeRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return changeRecord{}, fmt.Errorf("invalid record %s: %w", path, err)
+	}
+	for i, c := range rec.Changes {
+		if c.Release == "" || c.Namespace == "" || c.Revision < 1 {
+			return changeRecord{}, fmt.Errorf("invalid record %s: change %d needs a release, namespace, and revision", path, i+1)
+		}
+		for _, s := range []string{c.PreviousStatus, c.Status} {
+			if _, err := status.ParseStatus(s); err != nil {
+				return changeRecord{}, fmt.Errorf("invalid record %s: change %d: %w", path, i+1, err)
+			}
+		}
+	}
+	return rec, nil
+}
+
+func newUndoCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "undo FILE",
+		Short: "Restore the statuses saved by --record",
+		Long: `Restore the previous status of every release changed by a run with
+--record FILE.
+
+Changes are undone in reverse order, and labels set by --set-annotation are
+put back as they were. A revision is only restored if it still has the status
+it was given, so that a later change by Helm or another job is not
+overwritten; use --force to restore it anyway. Restoring a failed revision to
+deployed is refused unless --i-understand or --force is set. The command
+exits non-zero if any change could not be undone.`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: cobra.FixedCompletions(nil, cobra.ShellCompDirectiveDefault),
+		RunE:              runUndo,
+	}
+
+	cmd.Flags().String("description", "", "description to record on each release (default: \"status set to <STATUS>\")")
+	cmd.Flags().Bool("force", false, "restore a revision even if its status has changed since it was recorded")
+	cmd.Flags().Bool("i-understand", false, "allow restoring a failed revision to deployed, which is refused otherwise, without skipping the other checks like --force")
+	cmd.Flags().Bool("dry-run", false, "report the changes that would be undone without writing them")
+	cmd.Flags().StringP("output", "o", outputText, "output format: text, table, json, yaml, or github")
+
+	return cmd
+}
+
+func runUndo(cmd *cobra.Command, args []string) error {
+	var opts runOptions
+	readGlobalFlags(cmd, &opts)
+	opts.description, _ = cmd.Flags().GetString("description")
+	opts.force, _ = cmd.Flags().GetBool("force")
+	opts.iUnderstand, _ = cmd.Flags().GetBool("i-understand")
+	opts.dryRun, _ = cmd.Flags().GetBool("dry-run")
+	opts.output, _ = cmd.Flags().GetString("output")
+	return reportError(cmd, cmd.ErrOrStderr(), opts.output, runUndoWithConfigFactory(cmd, args, opts, ConfigurationFactory))
+}
+
+func runUndoWithConfigFactory(cmd *cobra.Command, args []string, opts runOptions, newConfig configurationFactory) error {
+	if err := validateOutputFormat(opts.output); err != nil {
+		return err
+	}
+	if err := status.ValidateDescription(opts.description); err != nil {
+		return fmt.Errorf("--description: %w", err)
+	}
+	if opts.namespace != "" {
+		return errors.New("undo cannot be combined with --namespace; each change is undone in the namespace it was recorded in")
+	}
+
+	rec, err := readChangeRecord(args[0])
+	if err != nil {
+		return err
+	}
+
+	// Each namespace gets its own configuration, since Helm reads and writes
+	// releases one namespace at a time
+	configs := make(map[string]*action.Configuration)
+	results := make([]changeResult, 0, len(rec.Changes))
+	failed := 0
+	for i := len(rec.Changes) - 1; i >= 0; i-- {
+		c := rec.Changes[i]
+		cfg, ok := configs[c.Namespace]
+		if !ok {
+			if cfg, err = newConfig(c.Namespace, opts.clientOptions()); err != nil {
+				return fmt.Errorf("failed to create configuration for namespace %q: %w", c.Namespace, err)
+			}
+			configs[c.Namespace] = cfg
+		}
+
+		// readChangeRecord has validated both statuses
+		previous, _ := status.ParseStatus(c.PreviousStatus)
+		recorded, _ := status.ParseStatus(c.Status)
+		setOpts := status.SetStatusOptions{
+			DryRun:      opts.dryRun,
+			Description: opts.description,
+			Force:       opts.force,
+			SafeMode:    !opts.iUnderstand,
+		}
+		if !opts.force {
+			setOpts.AllowedFromStatuses = []release.Status{recorded}
+		}
+		// Labels the change set are put back as they were, or removed if the
+		// release did not have them
+		for _, k := range c.Labels {
+			v, ok := c.PreviousLabels[k]
+			if !ok {
+				setOpts.RemoveLabels = append(setOpts.RemoveLabels, k)
+				continue
+			}
+			if setOpts.Labels == nil {
+				setOpts.Labels = make(map[string]string)
+			}
+			setOpts.Labels[k] = v
+		}
+
+		target := status.Target{ReleaseName: c.Release, Revision: c.Revision}
+		res, err := setReleaseStatus(commandContext(cmd), change{cfg: cfg, namespace: c.Namespace, target: target, status: previous}, setOpts, opts)
+		if err != nil {
+			failed++
+			res.Result = resultError
+			res.Reason = err.Error()
+		}
+		results = append(results, res)
+	}
+
+	if err := writeResults(cmd.OutOrStdout(), opts.output, results); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to undo %d of %d changes", failed, len(results))
+	}
+	return nil
+}
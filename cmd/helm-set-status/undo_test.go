@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/josegonzalez/helm-set-status/pkg/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+func TestNewUndoCmd(t *testing.T) {
+	cmd := newUndoCmd()
+
+	assert.Equal(t, "undo FILE", cmd.Use)
+	assert.NotNil(t, cmd.Flags().Lookup("force"))
+	assert.NotNil(t, cmd.Flags().Lookup("dry-run"))
+	assert.NotNil(t, cmd.Flags().Lookup("i-understand"))
+	assert.NotNil(t, newRootCmd().Flags().Lookup("record"))
+}
+
+func TestRecordAndUndo(t *testing.T) {
+	newStore := func(t *testing.T, statuses ...release.Status) *storage.Storage {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		for _, name := range []string{"app-a", "app-b"} {
+			for i, st := range statuses {
+				require.NoError(t, store.Create(&release.Release{
+					Name:      name,
+					Namespace: "default",
+					Version:   i + 1,
+					Info:      &release.Info{Status: st, Description: "Upgrade complete"},
+					Chart: &chart.Chart{
+						Metadata: &chart.Metadata{
+							Name:    "test-chart",
+							Version: "1.0.0",
+						},
+					},
+				}))
+			}
+		}
+		return store
+	}
+	factoryFor := func(store *storage.Storage) configurationFactory {
+		return func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+	}
+	statusOf := func(t *testing.T, store *storage.Storage, name string, revision int) release.Status {
+		t.Helper()
+		rel, err := store.Get(name, revision)
+		require.NoError(t, err)
+		return rel.Info.Status
+	}
+	recordChanges := func(t *testing.T, store *storage.Storage, args []string, opts runOptions) string {
+		t.Helper()
+		opts.record = filepath.Join(t.TempDir(), "record.json")
+		cmd := newRootCmd()
+		cmd.SetOut(&bytes.Buffer{})
+		require.NoError(t, runWithConfigFactory(cmd, args, opts, factoryFor(store)))
+		return opts.record
+	}
+
+	t.Run("records and restores the previous statuses", func(t *testing.T) {
+		store := newStore(t, release.StatusSuperseded, release.StatusDeployed)
+		path := recordChanges(t, store, []string{"app-a", "app-b", "failed"}, runOptions{})
+		assert.Equal(t, release.StatusFailed, statusOf(t, store, "app-a", 2))
+		assert.Equal(t, release.StatusFailed, statusOf(t, store, "app-b", 2))
+
+		rec, err := readChangeRecord(path)
+		require.NoError(t, err)
+		assert.Equal(t, []recordedChange{
+			{Release: "app-a", Namespace: "default", Revision: 2, PreviousStatus: "deployed", Status: "failed"},
+			{Release: "app-b", Namespace: "default", Revision: 2, PreviousStatus: "deployed", Status: "failed"},
+		}, rec.Changes)
+
+		cmd := newUndoCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		require.NoError(t, runUndoWithConfigFactory(cmd, []string{path}, runOptions{iUnderstand: true}, factoryFor(store)))
+		assert.Equal(t, "Release \"app-b\" revision 2 status changed from \"failed\" to \"deployed\"\n"+
+			"Release \"app-a\" revision 2 status changed from \"failed\" to \"deployed\"\n"+
+			"Summary: 2 changed, 0 unchanged, 0 skipped, 0 not found, 0 errored (2 total)\n", buf.String())
+		assert.Equal(t, release.StatusDeployed, statusOf(t, store, "app-a", 2))
+		assert.Equal(t, release.StatusDeployed, statusOf(t, store, "app-b", 2))
+	})
+
+	t.Run("restores the labels set with --set-annotation", func(t *testing.T) {
+		store := newStore(t, release.StatusDeployed)
+		rel, err := store.Get("app-a", 1)
+		require.NoError(t, err)
+		rel.Labels = map[string]string{"team": "platform", "ticket": "OPS-0"}
+		require.NoError(t, store.Update(rel))
+
+		path := recordChanges(t, store, []string{"app-a", "failed"}, runOptions{iUnderstand: true, annotations: []string{"ticket=OPS-1", "approved-by=alice"}})
+		rel, err = store.Get("app-a", 1)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"team": "platform", "ticket": "OPS-1", "approved-by": "alice"}, rel.Labels)
+
+		rec, err := readChangeRecord(path)
+		require.NoError(t, err)
+		assert.Equal(t, []recordedChange{{
+			Release: "app-a", Namespace: "default", Revision: 1, PreviousStatus: "deployed", Status: "failed",
+			Labels: []string{"approved-by", "ticket"}, PreviousLabels: map[string]string{"ticket": "OPS-0"},
+		}}, rec.Changes)
+
+		cmd := newUndoCmd()
+		cmd.SetOut(&bytes.Buffer{})
+		require.NoError(t, runUndoWithConfigFactory(cmd, []string{path}, runOptions{iUnderstand: true}, factoryFor(store)))
+		rel, err = store.Get("app-a", 1)
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusDeployed, rel.Info.Status)
+		assert.Equal(t, map[string]string{"team": "platform", "ticket": "OPS-0"}, rel.Labels)
+	})
+
+	t.Run("restores the revision that was changed, not the latest", func(t *testing.T) {
+		store := newStore(t, release.StatusDeployed, release.StatusPendingUpgrade)
+		path := recordChanges(t, store, []string{"app-a", "superseded"}, runOptions{revision: 1})
+		assert.Equal(t, release.StatusSuperseded, statusOf(t, store, "app-a", 1))
+
+		cmd := newUndoCmd()
+		cmd.SetOut(&bytes.Buffer{})
+		require.NoError(t, runUndoWithConfigFactory(cmd, []string{path}, runOptions{}, factoryFor(store)))
+		assert.Equal(t, release.StatusDeployed, statusOf(t, store, "app-a", 1))
+		assert.Equal(t, release.StatusPendingUpgrade, statusOf(t, store, "app-a", 2))
+	})
+
+	t.Run("leaves out releases that were not changed", func(t *testing.T) {
+		store := newStore(t, release.StatusFailed)
+		path := recordChanges(t, store, []string{"app-a", "failed"}, runOptions{})
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"changes": []}`, string(data))
+	})
+
+	t.Run("refuses a revision changed since it was recorded", func(t *testing.T) {
+		store := newStore(t, release.StatusDeployed)
+		path := recordChanges(t, store, []string{"app-a", "failed"}, runOptions{})
+
+		rel, err := store.Get("app-a", 1)
+		require.NoError(t, err)
+		rel.Info.Status = release.StatusSuperseded
+
+		cmd := newUndoCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		err = runUndoWithConfigFactory(cmd, []string{path}, runOptions{}, factoryFor(store))
+		assert.EqualError(t, err, "failed to undo 1 of 1 changes")
+		assert.Contains(t, buf.String(), `current status "superseded" not in allowed list [failed]`)
+		assert.Equal(t, release.StatusSuperseded, statusOf(t, store, "app-a", 1))
+
+		require.NoError(t, runUndoWithConfigFactory(cmd, []string{path}, runOptions{force: true}, factoryFor(store)))
+		assert.Equal(t, release.StatusDeployed, statusOf(t, store, "app-a", 1))
+	})
+
+	t.Run("refuses to restore a failed revision to deployed", func(t *testing.T) {
+		store := newStore(t, release.StatusDeployed)
+		path := recordChanges(t, store, []string{"app-a", "failed"}, runOptions{})
+
+		cmd := newUndoCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		err := runUndoWithConfigFactory(cmd, []string{path}, runOptions{}, factoryFor(store))
+		assert.EqualError(t, err, "failed to undo 1 of 1 changes")
+		assert.Contains(t, buf.String(), `setting it to "deployed" would hide the failure`)
+		assert.Contains(t, buf.String(), "use --i-understand to set it anyway")
+		assert.Equal(t, release.StatusFailed, statusOf(t, store, "app-a", 1))
+	})
+
+	t.Run("reports without writing under --dry-run", func(t *testing.T) {
+		store := newStore(t, release.StatusDeployed)
+		path := recordChanges(t, store, []string{"app-a", "failed"}, runOptions{})
+
+		cmd := newUndoCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		require.NoError(t, runUndoWithConfigFactory(cmd, []string{path}, runOptions{dryRun: true, iUnderstand: true}, factoryFor(store)))
+		assert.Contains(t, buf.String(), "Would set release \"app-a\" revision 1 status from \"failed\" to \"deployed\"\n")
+		assert.Equal(t, release.StatusFailed, statusOf(t, store, "app-a", 1))
+	})
+
+	t.Run("records an --atomic change that could not be rolled back", func(t *testing.T) {
+		store := storage.Init(&failSecondUpdateDriver{Memory: driver.NewMemory()})
+		require.NoError(t, store.Create(&release.Release{
+			Name:      "app-a",
+			Namespace: "default",
+			Version:   1,
+			Info:      &release.Info{Status: release.StatusDeployed},
+		}))
+
+		opts := runOptions{atomic: true, record: filepath.Join(t.TempDir(), "record.json")}
+		err := runWithConfigFactory(newRootCmd(), []string{"app-a", "missing", "failed"}, opts, factoryFor(store))
+		var atomicErr *status.AtomicError
+		require.ErrorAs(t, err, &atomicErr)
+		assert.Contains(t, atomicErr.RollbackErrors, status.Target{ReleaseName: "app-a"})
+		assert.Equal(t, release.StatusFailed, statusOf(t, store, "app-a", 1))
+
+		rec, err := readChangeRecord(opts.record)
+		require.NoError(t, err)
+		assert.Equal(t, []recordedChange{
+			{Release: "app-a", Namespace: "default", Revision: 1, PreviousStatus: "deployed", Status: "failed"},
+		}, rec.Changes)
+	})
+
+	t.Run("rejects --record with --dry-run", func(t *testing.T) {
+		opts := runOptions{dryRun: true, record: filepath.Join(t.TempDir(), "record.json")}
+		err := runWithConfigFactory(newRootCmd(), []string{"app-a", "failed"}, opts, factoryFor(nil))
+		assert.EqualError(t, err, "--record cannot be combined with --dry-run")
+	})
+}
+
+func TestReadChangeRecord(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "not json", content: "app-a failed", wantErr: "invalid character"},
+		{name: "missing revision", content: `{"changes": [{"release": "app-a", "namespace": "default", "previous_status": "deployed", "status": "failed"}]}`, wantErr: "change 1 needs a release, namespace, and revision"},
+		{name: "invalid status", content: `{"changes": [{"release": "app-a", "namespace": "default", "revision": 1, "previous_status": "bogus", "status": "failed"}]}`, wantErr: "change 1: invalid status: bogus"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "record.json")
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0o644))
+
+			_, err := readChangeRecord(path)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+
+	_, err := readChangeRecord(filepath.Join(t.TempDir(), "missing.json"))
+	assert.ErrorContains(t, err, "failed to read record")
+}
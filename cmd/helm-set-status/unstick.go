@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/josegonzalez/helm-set-status/pkg/status"
+	"github.com/spf13/cobra"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func newUnstickCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "unstick RELEASE",
+		Short: "Mark a Helm release stuck in a pending status as failed",
+		Long: `Mark a Helm release stuck in pending-install, pending-upgrade, or
+pending-rollback as failed, so that it can be upgraded or rolled back again.
+
+Only the latest revision is changed, and only if it is pending and was last
+deployed at least --pending-timeout ago; any other release is left alone and
+the command exits non-zero. Use --assume-deployed to mark the release as
+deployed instead, when the interrupted operation is known to have completed.`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completePendingReleaseArg,
+		RunE:              runUnstick,
+	}
+
+	cmd.Flags().Duration("pending-timeout", 5*time.Minute, "only change a release that has been pending at least this long")
+	cmd.Flags().Bool("assume-deployed", false, "mark the release as deployed instead of failed")
+	cmd.Flags().String("description", "", "description to record on the release (default: the pending status and --pending-timeout)")
+	cmd.Flags().Bool("dry-run", false, "report the intended change without writing it")
+	cmd.Flags().StringP("output", "o", outputText, "output format: text, table, json, yaml, or github")
+
+	return cmd
+}
+
+func runUnstick(cmd *cobra.Command, args []string) error {
+	var opts runOptions
+	readGlobalFlags(cmd, &opts)
+	opts.pendingTimeout, _ = cmd.Flags().GetDuration("pending-timeout")
+	opts.assumeDeployed, _ = cmd.Flags().GetBool("assume-deployed")
+	opts.description, _ = cmd.Flags().GetString("description")
+	opts.dryRun, _ = cmd.Flags().GetBool("dry-run")
+	opts.output, _ = cmd.Flags().GetString("output")
+	return reportError(cmd, cmd.ErrOrStderr(), opts.output, runUnstickWithConfigFactory(cmd, args, opts, ConfigurationFactory))
+}
+
+func runUnstickWithConfigFactory(cmd *cobra.Command, args []string, opts runOptions, newConfig configurationFactory) error {
+	releaseName := args[0]
+
+	if err := validateOutputFormat(opts.output); err != nil {
+		return err
+	}
+	if opts.pendingTimeout < 0 {
+		return fmt.Errorf("--pending-timeout must not be negative, got %s", opts.pendingTimeout)
+	}
+	if err := status.ValidateDescription(opts.description); err != nil {
+		return fmt.Errorf("--description: %w", err)
+	}
+
+	targetStatus := release.StatusFailed
+	if opts.assumeDeployed {
+		targetStatus = release.StatusDeployed
+	}
+	description := opts.description
+	if description == "" {
+		description = fmt.Sprintf("stuck in {{.Previous}} for at least %s, set to {{.Status}}", opts.pendingTimeout)
+	}
+
+	cfg, err := newConfig(opts.namespace, opts.clientOptions())
+	if err != nil {
+		return fmt.Errorf("failed to create configuration: %w", err)
+	}
+
+	setResult, err := status.SetStatus(commandContext(cmd), cfg, releaseName, targetStatus, status.SetStatusOptions{
+		AllowedFromStatuses: status.PendingStatuses,
+		IfOlderThan:         opts.pendingTimeout,
+		DryRun:              opts.dryRun,
+		Description:         description,
+	})
+	if err != nil {
+		var precondErr *status.PreconditionError
+		if errors.As(err, &precondErr) {
+			return fmt.Errorf("refusing to unstick release %q: %w", releaseName, err)
+		}
+		return err
+	}
+
+	res := newChangeResult(status.Target{ReleaseName: releaseName}, status.ResolveNamespace(opts.namespace), targetStatus)
+	describeResult(&res, setResult, opts.dryRun)
+	return writeResult(cmd.OutOrStdout(), opts.output, res)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/josegonzalez/helm-set-status/pkg/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	helmtime "helm.sh/helm/v3/pkg/time"
+)
+
+func TestNewUnstickCmd(t *testing.T) {
+	cmd := newUnstickCmd()
+
+	assert.Equal(t, "unstick RELEASE", cmd.Use)
+
+	pendingTimeoutFlag := cmd.Flags().Lookup("pending-timeout")
+	assert.NotNil(t, pendingTimeoutFlag)
+	assert.Equal(t, "5m0s", pendingTimeoutFlag.DefValue)
+
+	assumeDeployedFlag := cmd.Flags().Lookup("assume-deployed")
+	assert.NotNil(t, assumeDeployedFlag)
+	assert.Equal(t, "false", assumeDeployedFlag.DefValue)
+}
+
+func TestRunUnstickWithConfigFactory(t *testing.T) {
+	newStore := func(t *testing.T, current release.Status, age time.Duration) *storage.Storage {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		require.NoError(t, store.Create(&release.Release{
+			Name:      "my-release",
+			Namespace: "default",
+			Version:   1,
+			Info: &release.Info{
+				Status:       current,
+				Description:  "Preparing upgrade",
+				LastDeployed: helmtime.Time{Time: time.Now().Add(-age)},
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}))
+		return store
+	}
+	factoryFor := func(store *storage.Storage) configurationFactory {
+		return func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}
+	}
+	lastRelease := func(t *testing.T, store *storage.Storage) *release.Release {
+		t.Helper()
+		rel, err := store.Last("my-release")
+		require.NoError(t, err)
+		return rel
+	}
+
+	t.Run("marks a stale pending release as failed", func(t *testing.T) {
+		store := newStore(t, release.StatusPendingUpgrade, time.Hour)
+
+		cmd := newUnstickCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		err := runUnstickWithConfigFactory(cmd, []string{"my-release"}, runOptions{pendingTimeout: 10 * time.Minute}, factoryFor(store))
+		require.NoError(t, err)
+		assert.Equal(t, "Release \"my-release\" revision 1 status changed from \"pending-upgrade\" to \"failed\"\n", buf.String())
+
+		rel := lastRelease(t, store)
+		assert.Equal(t, release.StatusFailed, rel.Info.Status)
+		assert.Equal(t, "stuck in pending-upgrade for at least 10m0s, set to failed", rel.Info.Description)
+	})
+
+	t.Run("marks the release as deployed with --assume-deployed", func(t *testing.T) {
+		store := newStore(t, release.StatusPendingInstall, time.Hour)
+
+		cmd := newUnstickCmd()
+		cmd.SetOut(&bytes.Buffer{})
+
+		opts := runOptions{pendingTimeout: 10 * time.Minute, assumeDeployed: true}
+		require.NoError(t, runUnstickWithConfigFactory(cmd, []string{"my-release"}, opts, factoryFor(store)))
+		assert.Equal(t, release.StatusDeployed, lastRelease(t, store).Info.Status)
+	})
+
+	t.Run("refuses a release that is not pending", func(t *testing.T) {
+		store := newStore(t, release.StatusDeployed, time.Hour)
+
+		err := runUnstickWithConfigFactory(newUnstickCmd(), []string{"my-release"}, runOptions{}, factoryFor(store))
+		require.Error(t, err)
+		var precondErr *status.PreconditionError
+		assert.True(t, errors.As(err, &precondErr))
+		assert.Contains(t, err.Error(), `refusing to unstick release "my-release": current status "deployed" not in allowed list`)
+		assert.Equal(t, release.StatusDeployed, lastRelease(t, store).Info.Status)
+	})
+
+	t.Run("refuses a release pending for less than --pending-timeout", func(t *testing.T) {
+		store := newStore(t, release.StatusPendingUpgrade, time.Minute)
+
+		err := runUnstickWithConfigFactory(newUnstickCmd(), []string{"my-release"}, runOptions{pendingTimeout: 10 * time.Minute}, factoryFor(store))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "less than 10m0s ago")
+		assert.Equal(t, release.StatusPendingUpgrade, lastRelease(t, store).Info.Status)
+	})
+
+	t.Run("reports without writing under --dry-run", func(t *testing.T) {
+		store := newStore(t, release.StatusPendingRollback, time.Hour)
+
+		cmd := newUnstickCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		require.NoError(t, runUnstickWithConfigFactory(cmd, []string{"my-release"}, runOptions{dryRun: true}, factoryFor(store)))
+		assert.Equal(t, "Would set release \"my-release\" revision 1 status from \"pending-rollback\" to \"failed\"\n", buf.String())
+		assert.Equal(t, release.StatusPendingRollback, lastRelease(t, store).Info.Status)
+	})
+
+	t.Run("rejects a negative --pending-timeout", func(t *testing.T) {
+		err := runUnstickWithConfigFactory(newUnstickCmd(), []string{"my-release"}, runOptions{pendingTimeout: -time.Minute}, factoryFor(nil))
+		assert.EqualError(t, err, "--pending-timeout must not be negative, got -1m0s")
+	})
+}
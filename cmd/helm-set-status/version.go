@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/spf13/cobra"
+)
+
+// commit and date are the git commit and build date, set with -ldflags like
+// version.
+var commit string
+var date string
+
+// buildInfo describes the build of the running binary.
+type buildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	Date      string `json:"date"`
+	GoVersion string `json:"go_version"`
+}
+
+// currentBuildInfo returns the build info set with -ldflags. A commit or date
+// not set that way is taken from the version control information the Go
+// toolchain embeds when building from a checkout, if there is any.
+func currentBuildInfo() buildInfo {
+	info := buildInfo{Version: version, Commit: commit, Date: date, GoVersion: runtime.Version()}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range bi.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = setting.Value
+			case setting.Key == "vcs.time" && info.Date == "":
+				info.Date = setting.Value
+			}
+		}
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.Date == "" {
+		info.Date = "unknown"
+	}
+	return info
+}
+
+func newVersionCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "version",
+		Short: "Print the version and build information",
+		Long: `Print the plugin version, the git commit and date it was built from, and the
+Go version it was built with. Include this when reporting an issue.`,
+		Args:              cobra.NoArgs,
+		ValidArgsFunction: cobra.NoFileCompletions,
+		RunE:              runVersion,
+	}
+
+	cmd.Flags().StringP("output", "o", outputText, "output format: text, json, or yaml")
+
+	return cmd
+}
+
+func runVersion(cmd *cobra.Command, _ []string) error {
+	output, _ := cmd.Flags().GetString("output")
+	return writeBuildInfo(cmd, output, currentBuildInfo())
+}
+
+// writeBuildInfo prints info to the command's output in the given format.
+// Table output is the same as text.
+func writeBuildInfo(cmd *cobra.Command, format string, info buildInfo) error {
+	if err := validateReportFormat(format); err != nil {
+		return err
+	}
+	w := cmd.OutOrStdout()
+	if isStructured(format) {
+		return writeStructured(w, format, info)
+	}
+
+	_, err := fmt.Fprintf(w, "Version:    %s\nGit commit: %s\nBuild date: %s\nGo version: %s\n",
+		info.Version, info.Commit, info.Date, info.GoVersion)
+	return err
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionCmd(t *testing.T) {
+	originalVersion, originalCommit, originalDate := version, commit, date
+	defer func() { version, commit, date = originalVersion, originalCommit, originalDate }()
+	version, commit, date = "v1.2.3", "0123abc", "2026-01-02T03:04:05Z"
+
+	t.Run("prints the build info", func(t *testing.T) {
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetArgs([]string{"version"})
+
+		require.NoError(t, cmd.Execute())
+		assert.Equal(t, "Version:    v1.2.3\n"+
+			"Git commit: 0123abc\n"+
+			"Build date: 2026-01-02T03:04:05Z\n"+
+			"Go version: "+runtime.Version()+"\n", buf.String())
+	})
+
+	t.Run("prints json", func(t *testing.T) {
+		cmd := newRootCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetArgs([]string{"version", "--output", "json"})
+
+		require.NoError(t, cmd.Execute())
+		var info buildInfo
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &info))
+		assert.Equal(t, buildInfo{Version: "v1.2.3", Commit: "0123abc", Date: "2026-01-02T03:04:05Z", GoVersion: runtime.Version()}, info)
+	})
+
+	t.Run("rejects an unknown output format", func(t *testing.T) {
+		cmd := newRootCmd()
+		cmd.SetOut(&bytes.Buffer{})
+		cmd.SetErr(&bytes.Buffer{})
+		cmd.SetArgs([]string{"version", "--output", "xml"})
+
+		assert.EqualError(t, cmd.Execute(), `invalid --output format "xml" (valid: text, table, json, yaml)`)
+	})
+
+	t.Run("fills in a commit and date not set with -ldflags", func(t *testing.T) {
+		commit, date = "", ""
+		defer func() { commit, date = "0123abc", "2026-01-02T03:04:05Z" }()
+
+		info := currentBuildInfo()
+		assert.Equal(t, "v1.2.3", info.Version)
+		assert.NotEmpty(t, info.Commit)
+		assert.NotEmpty(t, info.Date)
+	})
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/josegonzalez/helm-set-status/pkg/status"
+	"github.com/spf13/cobra"
+)
+
+func newWatchCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "watch RELEASE STATUS",
+		Short: "Wait until a Helm release reaches a status",
+		Long: `Wait until the latest revision of a Helm release reaches a status.
+
+The release is read every --interval until its status matches STATUS. The
+command exits non-zero if --timeout elapses or it is interrupted first.`,
+		Args:              cobra.ExactArgs(2),
+		ValidArgsFunction: completeReleaseStatusArgs,
+		RunE:              runWatch,
+	}
+
+	cmd.Flags().Duration("interval", 2*time.Second, "how often to read the release")
+	cmd.Flags().Duration("timeout", 5*time.Minute, "how long to wait for the status")
+
+	return cmd
+}
+
+func runWatch(cmd *cobra.Command, args []string) error {
+	var opts runOptions
+	readGlobalFlags(cmd, &opts)
+	opts.timeout, _ = cmd.Flags().GetDuration("timeout")
+	opts.interval, _ = cmd.Flags().GetDuration("interval")
+	return runWatchWithConfigFactory(cmd, args, opts, ConfigurationFactory)
+}
+
+func runWatchWithConfigFactory(cmd *cobra.Command, args []string, opts runOptions, newConfig configurationFactory) error {
+	releaseName := args[0]
+
+	expected, err := status.ParseStatus(args[1])
+	if err != nil {
+		return fmt.Errorf("%w\nValid statuses: %s", err, status.ValidStatusesString())
+	}
+	if opts.interval <= 0 {
+		return fmt.Errorf("--interval must be positive, got %s", opts.interval)
+	}
+
+	cfg, err := newConfig(opts.namespace, opts.clientOptions())
+	if err != nil {
+		return fmt.Errorf("failed to create configuration: %w", err)
+	}
+
+	if err := status.WatchStatus(commandContext(cmd), cfg, releaseName, expected, opts.interval, opts.timeout); err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Release %q reached status %q\n", releaseName, expected)
+	return nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/josegonzalez/helm-set-status/pkg/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+// changingDriver wraps a memory driver and moves every release to a new
+// status once it has been queried a number of times.
+type changingDriver struct {
+	*driver.Memory
+	queriesBeforeChange int
+	queries             int
+	next                release.Status
+}
+
+func (d *changingDriver) Query(labels map[string]string) ([]*release.Release, error) {
+	d.queries++
+	rels, err := d.Memory.Query(labels)
+	if err != nil || d.queries <= d.queriesBeforeChange {
+		return rels, err
+	}
+	for _, rel := range rels {
+		rel.Info.Status = d.next
+	}
+	return rels, nil
+}
+
+func TestNewWatchCmd(t *testing.T) {
+	cmd := newWatchCmd()
+
+	assert.Equal(t, "watch RELEASE STATUS", cmd.Use)
+
+	intervalFlag := cmd.Flags().Lookup("interval")
+	assert.NotNil(t, intervalFlag)
+	assert.Equal(t, "2s", intervalFlag.DefValue)
+
+	timeoutFlag := cmd.Flags().Lookup("timeout")
+	assert.NotNil(t, timeoutFlag)
+	assert.Equal(t, "5m0s", timeoutFlag.DefValue)
+}
+
+func TestRunWatchWithConfigFactory(t *testing.T) {
+	newConfigFactory := func(t *testing.T, queriesBeforeChange int) (configurationFactory, *changingDriver) {
+		t.Helper()
+
+		mem := driver.NewMemory()
+		rel := &release.Release{
+			Name:      "my-release",
+			Namespace: "default",
+			Version:   1,
+			Info: &release.Info{
+				Status: release.StatusPendingUpgrade,
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}
+		require.NoError(t, mem.Create("sh.helm.release.v1.my-release.v1", rel))
+
+		d := &changingDriver{Memory: mem, queriesBeforeChange: queriesBeforeChange, next: release.StatusDeployed}
+		store := storage.Init(d)
+		return func(string, status.ClientOptions) (*action.Configuration, error) {
+			return &action.Configuration{Releases: store}, nil
+		}, d
+	}
+
+	t.Run("returns once the release reaches the status", func(t *testing.T) {
+		configFactory, d := newConfigFactory(t, 2)
+
+		cmd := newWatchCmd()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+
+		err := runWatchWithConfigFactory(cmd, []string{"my-release", "deployed"}, runOptions{timeout: time.Second, interval: time.Millisecond}, configFactory)
+		require.NoError(t, err)
+		assert.Equal(t, "Release \"my-release\" reached status \"deployed\"\n", buf.String())
+		assert.Equal(t, 3, d.queries)
+	})
+
+	t.Run("fails on timeout", func(t *testing.T) {
+		configFactory, _ := newConfigFactory(t, 1<<30)
+
+		cmd := newWatchCmd()
+		err := runWatchWithConfigFactory(cmd, []string{"my-release", "deployed"}, runOptions{timeout: 10 * time.Millisecond, interval: time.Millisecond}, configFactory)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "timed out waiting for release my-release")
+	})
+
+	t.Run("stops when the command context is cancelled", func(t *testing.T) {
+		configFactory, _ := newConfigFactory(t, 1<<30)
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+
+		cmd := newWatchCmd()
+		cmd.SetContext(ctx)
+		err := runWatchWithConfigFactory(cmd, []string{"my-release", "deployed"}, runOptions{timeout: time.Hour, interval: time.Hour}, configFactory)
+		require.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("invalid status", func(t *testing.T) {
+		configFactory, _ := newConfigFactory(t, 0)
+
+		cmd := newWatchCmd()
+		err := runWatchWithConfigFactory(cmd, []string{"my-release", "bogus"}, runOptions{timeout: time.Second, interval: time.Millisecond}, configFactory)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid status: bogus")
+	})
+
+	t.Run("invalid interval", func(t *testing.T) {
+		configFactory, _ := newConfigFactory(t, 0)
+
+		cmd := newWatchCmd()
+		err := runWatchWithConfigFactory(cmd, []string{"my-release", "deployed"}, runOptions{timeout: time.Second}, configFactory)
+		require.Error(t, err)
+		assert.Equal(t, "--interval must be positive, got 0s", err.Error())
+	})
+}
@@ -1,29 +1,70 @@
 package status
 
 import (
+	"fmt"
 	"os"
+	"strings"
 
 	"helm.sh/helm/v3/pkg/action"
 )
 
+// ResolveNamespace returns namespace if it is set, falling back to
+// HELM_NAMESPACE and then "default".
+func ResolveNamespace(namespace string) string {
+	if namespace == "" {
+		namespace = os.Getenv("HELM_NAMESPACE")
+	}
+	if namespace == "" {
+		namespace = "default"
+	}
+	return namespace
+}
+
+// ValidStorageDrivers lists the release storage backends that can be selected
+// with ClientOptions.StorageDriver or HELM_DRIVER. The plural forms "secrets"
+// and "configmaps" are also accepted.
+var ValidStorageDrivers = []string{"secret", "configmap", "sql", "memory"}
+
+// ResolveStorageDriver returns storageDriver if it is set, falling back to
+// HELM_DRIVER and then "secrets". It returns an error for unknown drivers.
+func ResolveStorageDriver(storageDriver string) (string, error) {
+	if storageDriver == "" {
+		storageDriver = os.Getenv("HELM_DRIVER")
+	}
+	if storageDriver == "" {
+		storageDriver = "secrets"
+	}
+
+	switch storageDriver {
+	case "secret", "secrets", "configmap", "configmaps", "sql", "memory":
+		return storageDriver, nil
+	default:
+		return "", fmt.Errorf("invalid storage driver %q (valid: %s)", storageDriver, strings.Join(ValidStorageDrivers, ", "))
+	}
+}
+
 // NewConfiguration creates a new Helm action configuration.
-// It reads configuration from environment variables set by Helm.
-func NewConfiguration() (*action.Configuration, error) {
+// The namespace is resolved with ResolveNamespace, unless opts.AllNamespaces
+// is set, in which case it is ignored.
+// Settings not given in opts are read from environment variables set by Helm.
+func NewConfiguration(namespace string, opts ClientOptions) (*action.Configuration, error) {
 	cfg := new(action.Configuration)
 
-	namespace := os.Getenv("HELM_NAMESPACE")
-	if namespace == "" {
-		namespace = "default"
+	namespace = ResolveNamespace(namespace)
+	storageNamespace := namespace
+	if opts.AllNamespaces {
+		// Helm's storage drivers list every namespace when none is set
+		storageNamespace = ""
 	}
 
-	driver := os.Getenv("HELM_DRIVER")
-	if driver == "" {
-		driver = "secrets"
+	driver, err := ResolveStorageDriver(opts.StorageDriver)
+	if err != nil {
+		return nil, err
 	}
 
 	if err := cfg.Init(
-		NewRESTClientGetter(namespace),
-		namespace,
+		NewRESTClientGetter(namespace, opts),
+		storageNamespace,
 		driver,
 		func(format string, v ...interface{}) {},
 	); err != nil {
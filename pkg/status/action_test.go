@@ -6,6 +6,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/storage/driver"
 )
 
 func TestNewConfiguration_EnvironmentVariables(t *testing.T) {
@@ -23,7 +24,7 @@ func TestNewConfiguration_EnvironmentVariables(t *testing.T) {
 		_ = os.Unsetenv("HELM_NAMESPACE")
 		_ = os.Unsetenv("HELM_DRIVER")
 
-		cfg, err := NewConfiguration()
+		cfg, err := NewConfiguration("", ClientOptions{})
 		// Helm's Init succeeds even without a valid kubeconfig
 		// The error occurs when actually trying to use the client
 		require.NoError(t, err)
@@ -33,7 +34,7 @@ func TestNewConfiguration_EnvironmentVariables(t *testing.T) {
 	t.Run("with HELM_NAMESPACE set", func(t *testing.T) {
 		_ = os.Setenv("HELM_NAMESPACE", "custom-namespace")
 
-		cfg, err := NewConfiguration()
+		cfg, err := NewConfiguration("", ClientOptions{})
 		require.NoError(t, err)
 		assert.NotNil(t, cfg)
 	})
@@ -41,7 +42,7 @@ func TestNewConfiguration_EnvironmentVariables(t *testing.T) {
 	t.Run("with HELM_DRIVER set to memory", func(t *testing.T) {
 		_ = os.Setenv("HELM_DRIVER", "memory")
 
-		cfg, err := NewConfiguration()
+		cfg, err := NewConfiguration("", ClientOptions{})
 		require.NoError(t, err)
 		assert.NotNil(t, cfg)
 	})
@@ -49,7 +50,7 @@ func TestNewConfiguration_EnvironmentVariables(t *testing.T) {
 	t.Run("with empty HELM_NAMESPACE defaults to 'default'", func(t *testing.T) {
 		_ = os.Setenv("HELM_NAMESPACE", "")
 
-		cfg, err := NewConfiguration()
+		cfg, err := NewConfiguration("", ClientOptions{})
 		require.NoError(t, err)
 		assert.NotNil(t, cfg)
 	})
@@ -57,7 +58,16 @@ func TestNewConfiguration_EnvironmentVariables(t *testing.T) {
 	t.Run("with empty HELM_DRIVER defaults to 'secrets'", func(t *testing.T) {
 		_ = os.Setenv("HELM_DRIVER", "")
 
-		cfg, err := NewConfiguration()
+		cfg, err := NewConfiguration("", ClientOptions{})
+		require.NoError(t, err)
+		assert.NotNil(t, cfg)
+	})
+
+	t.Run("with explicit namespace", func(t *testing.T) {
+		_ = os.Setenv("HELM_NAMESPACE", "env-namespace")
+		_ = os.Setenv("HELM_DRIVER", "memory")
+
+		cfg, err := NewConfiguration("explicit-namespace", ClientOptions{})
 		require.NoError(t, err)
 		assert.NotNil(t, cfg)
 	})
@@ -65,8 +75,84 @@ func TestNewConfiguration_EnvironmentVariables(t *testing.T) {
 	t.Run("with invalid HELM_DRIVER returns error", func(t *testing.T) {
 		_ = os.Setenv("HELM_DRIVER", "invalid-driver-that-does-not-exist")
 
-		_, err := NewConfiguration()
+		_, err := NewConfiguration("", ClientOptions{})
 		// Invalid driver should cause an error
 		assert.Error(t, err)
 	})
 }
+
+func TestNewConfiguration_StorageDriver(t *testing.T) {
+	origDriver := os.Getenv("HELM_DRIVER")
+	defer func() { _ = os.Setenv("HELM_DRIVER", origDriver) }()
+
+	tests := []struct {
+		name       string
+		env        string
+		driver     string
+		driverName string
+	}{
+		{name: "option selects memory", env: "secrets", driver: "memory", driverName: driver.MemoryDriverName},
+		{name: "option selects configmap", env: "memory", driver: "configmap", driverName: driver.ConfigMapsDriverName},
+		{name: "option selects secret", env: "memory", driver: "secret", driverName: driver.SecretsDriverName},
+		{name: "falls back to HELM_DRIVER", env: "memory", driver: "", driverName: driver.MemoryDriverName},
+		{name: "defaults to secrets", env: "", driver: "", driverName: driver.SecretsDriverName},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_ = os.Setenv("HELM_DRIVER", tt.env)
+
+			cfg, err := NewConfiguration("default", ClientOptions{StorageDriver: tt.driver})
+			require.NoError(t, err)
+			assert.Equal(t, tt.driverName, cfg.Releases.Name())
+		})
+	}
+
+	t.Run("unknown driver", func(t *testing.T) {
+		_ = os.Setenv("HELM_DRIVER", "memory")
+
+		_, err := NewConfiguration("default", ClientOptions{StorageDriver: "etcd"})
+		require.Error(t, err)
+		assert.Equal(t, `invalid storage driver "etcd" (valid: secret, configmap, sql, memory)`, err.Error())
+	})
+}
+
+func TestResolveStorageDriver(t *testing.T) {
+	origDriver := os.Getenv("HELM_DRIVER")
+	defer func() { _ = os.Setenv("HELM_DRIVER", origDriver) }()
+
+	_ = os.Setenv("HELM_DRIVER", "configmaps")
+
+	for _, name := range []string{"secret", "secrets", "configmap", "configmaps", "sql", "memory"} {
+		got, err := ResolveStorageDriver(name)
+		require.NoError(t, err)
+		assert.Equal(t, name, got)
+	}
+
+	got, err := ResolveStorageDriver("")
+	require.NoError(t, err)
+	assert.Equal(t, "configmaps", got)
+
+	_, err = ResolveStorageDriver("SQL")
+	assert.Error(t, err)
+}
+
+func TestResolveNamespace(t *testing.T) {
+	origNamespace := os.Getenv("HELM_NAMESPACE")
+	defer func() { _ = os.Setenv("HELM_NAMESPACE", origNamespace) }()
+
+	t.Run("explicit namespace wins", func(t *testing.T) {
+		_ = os.Setenv("HELM_NAMESPACE", "env-namespace")
+		assert.Equal(t, "explicit", ResolveNamespace("explicit"))
+	})
+
+	t.Run("falls back to HELM_NAMESPACE", func(t *testing.T) {
+		_ = os.Setenv("HELM_NAMESPACE", "env-namespace")
+		assert.Equal(t, "env-namespace", ResolveNamespace(""))
+	})
+
+	t.Run("falls back to default", func(t *testing.T) {
+		_ = os.Unsetenv("HELM_NAMESPACE")
+		assert.Equal(t, "default", ResolveNamespace(""))
+	})
+}
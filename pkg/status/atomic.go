@@ -0,0 +1,193 @@
+package status
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/release"
+	helmtime "helm.sh/helm/v3/pkg/time"
+)
+
+// Target identifies a release revision to update. A zero Revision selects
+// the latest revision.
+type Target struct {
+	ReleaseName string
+	Revision    int
+}
+
+func (t Target) String() string {
+	if t.Revision > 0 {
+		return fmt.Sprintf("%s (revision %d)", t.ReleaseName, t.Revision)
+	}
+	return t.ReleaseName
+}
+
+// AtomicError is returned by SetStatusAtomic when one of the updates fails.
+// Every target updated before the failure, and the failed target if its
+// update may have been written, is restored to its original state;
+// RolledBack lists those that were restored and RollbackErrors those that
+// could not be.
+type AtomicError struct {
+	Failed         Target
+	Err            error
+	RolledBack     []Target
+	RollbackErrors map[Target]error
+}
+
+func (e *AtomicError) Error() string {
+	msg := fmt.Sprintf("failed to set status on %s: %v", e.Failed, e.Err)
+	if len(e.RolledBack) > 0 {
+		names := make([]string, len(e.RolledBack))
+		for i, t := range e.RolledBack {
+			names[i] = t.String()
+		}
+		msg += fmt.Sprintf("; rolled back %s", strings.Join(names, ", "))
+	}
+	for _, t := range sortedTargets(e.RollbackErrors) {
+		msg += fmt.Sprintf("; failed to roll back %s: %v", t, e.RollbackErrors[t])
+	}
+	return msg
+}
+
+func (e *AtomicError) Unwrap() error {
+	return e.Err
+}
+
+// sortedTargets returns the keys of errs in a stable order.
+func sortedTargets(errs map[Target]error) []Target {
+	targets := make([]Target, 0, len(errs))
+	for t := range errs {
+		targets = append(targets, t)
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].String() < targets[j].String() })
+	return targets
+}
+
+// snapshot records the fields of a release that SetStatus modifies. Only
+// custom labels are recorded, as drivers differ in whether they return the
+// labels Helm manages itself.
+type snapshot struct {
+	status       release.Status
+	description  string
+	lastDeployed helmtime.Time
+	labels       map[string]string
+}
+
+// takeSnapshot records the fields of rel that SetStatus modifies.
+func takeSnapshot(rel *release.Release) snapshot {
+	return snapshot{
+		status:       rel.Info.Status,
+		description:  rel.Info.Description,
+		lastDeployed: rel.Info.LastDeployed,
+		labels:       customLabels(rel.Labels),
+	}
+}
+
+// equal reports whether s and other record the same release state.
+func (s snapshot) equal(other snapshot) bool {
+	return s.status == other.status &&
+		s.description == other.description &&
+		s.lastDeployed.Equal(other.lastDeployed) &&
+		maps.Equal(s.labels, other.labels)
+}
+
+// SetStatusAtomic sets the status of each target in order. If any update
+// fails, the targets already updated, and the failed one if it may have been
+// written, are restored to their original status, description,
+// last-deployed time, and labels, and an *AtomicError is returned.
+// opts.Revision is ignored in favour of each target's Revision. Cancelling
+// ctx fails the next update, which rolls back those already made.
+func SetStatusAtomic(ctx context.Context, cfg *action.Configuration, targets []Target, status release.Status, opts SetStatusOptions) ([]Result, error) {
+	results := make([]Result, 0, len(targets))
+	var updated []Target
+	snapshots := make(map[Target]snapshot)
+
+	for _, target := range targets {
+		rel, err := getRelease(cfg, target.ReleaseName, target.Revision)
+		if err == nil {
+			snapshots[target] = takeSnapshot(rel)
+		}
+
+		targetOpts := opts
+		targetOpts.Revision = target.Revision
+		result, err := SetStatus(ctx, cfg, target.ReleaseName, status, targetOpts)
+		// A failed update may still have been written, as when waiting for
+		// the new status times out, so it is restored along with the others
+		if _, ok := snapshots[target]; ok && result.Changed && !opts.DryRun {
+			updated = append(updated, target)
+		}
+		if err != nil {
+			return results, rollback(cfg, updated, snapshots, &AtomicError{Failed: target, Err: err})
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// rollback restores each updated target from its snapshot, most recent
+// first, recording the outcome in atomicErr.
+func rollback(cfg *action.Configuration, updated []Target, snapshots map[Target]snapshot, atomicErr *AtomicError) *AtomicError {
+	for i := len(updated) - 1; i >= 0; i-- {
+		target := updated[i]
+		if err := restore(cfg, target, snapshots[target]); err != nil {
+			if atomicErr.RollbackErrors == nil {
+				atomicErr.RollbackErrors = make(map[Target]error)
+			}
+			atomicErr.RollbackErrors[target] = err
+			continue
+		}
+		atomicErr.RolledBack = append(atomicErr.RolledBack, target)
+	}
+	return atomicErr
+}
+
+// restore writes the fields recorded in snap back to target.
+func restore(cfg *action.Configuration, target Target, snap snapshot) error {
+	rel, err := getRelease(cfg, target.ReleaseName, target.Revision)
+	if err != nil {
+		return err
+	}
+	return writeSnapshot(cfg, rel, snap)
+}
+
+// writeSnapshot writes a copy of rel with the fields recorded in snap.
+func writeSnapshot(cfg *action.Configuration, rel *release.Release, snap snapshot) error {
+	restored := cloneForUpdate(rel)
+	restored.Info.Status = snap.status
+	restored.Info.Description = snap.description
+	restored.Info.LastDeployed = snap.lastDeployed
+	restored.Labels = replaceCustomLabels(restored.Labels, snap.labels)
+	return cfg.Releases.Update(restored)
+}
+
+// RestoreStatus undoes a change made by SetStatus, writing the status,
+// description, last-deployed time, and labels of previous, the Result's
+// PreviousRelease, back to the revision it was read from. The revision is
+// only restored if it still has status, the status it was given; otherwise
+// a PreconditionError is returned, so that a later change is not overwritten.
+func RestoreStatus(cfg *action.Configuration, previous *release.Release, status release.Status) error {
+	if previous == nil || previous.Info == nil {
+		return errors.New("no previous release to restore")
+	}
+	rel, err := getRelease(cfg, previous.Name, previous.Version)
+	if err != nil {
+		return err
+	}
+	if rel.Info.Status != status {
+		return &PreconditionError{
+			ReleaseName:        rel.Name,
+			Revision:           rel.Version,
+			CurrentStatus:      rel.Info.Status,
+			CurrentDescription: rel.Info.Description,
+			TargetStatus:       previous.Info.Status,
+			AllowedStatuses:    []release.Status{status},
+		}
+	}
+	return writeSnapshot(cfg, rel, takeSnapshot(previous))
+}
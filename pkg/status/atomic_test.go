@@ -0,0 +1,221 @@
+package status
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+// failNthUpdateDriver wraps a memory driver and fails the Nth call to Update.
+// With failRollbacks set, every Update after the Nth fails too.
+type failNthUpdateDriver struct {
+	*driver.Memory
+	failOn        int
+	failRollbacks bool
+	updates       int
+}
+
+func (d *failNthUpdateDriver) Update(key string, rls *release.Release) error {
+	d.updates++
+	if d.updates == d.failOn || (d.failRollbacks && d.updates > d.failOn) {
+		return errors.New("storage unavailable")
+	}
+	return d.Memory.Update(key, rls)
+}
+
+func newAtomicConfig(t *testing.T, d *failNthUpdateDriver, names ...string) *action.Configuration {
+	t.Helper()
+
+	store := storage.Init(d)
+	for _, name := range names {
+		rel := &release.Release{
+			Name:      name,
+			Namespace: "default",
+			Version:   1,
+			Info: &release.Info{
+				Status:      release.StatusPendingUpgrade,
+				Description: "Preparing upgrade",
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}
+		require.NoError(t, store.Create(rel))
+	}
+	return &action.Configuration{Releases: store}
+}
+
+func TestSetStatusAtomic(t *testing.T) {
+	targets := []Target{{ReleaseName: "first"}, {ReleaseName: "second"}, {ReleaseName: "third"}}
+
+	t.Run("updates every target", func(t *testing.T) {
+		d := &failNthUpdateDriver{Memory: driver.NewMemory()}
+		cfg := newAtomicConfig(t, d, "first", "second", "third")
+
+		results, err := SetStatusAtomic(t.Context(), cfg, targets, release.StatusDeployed, SetStatusOptions{})
+		require.NoError(t, err)
+		require.Len(t, results, 3)
+		for _, target := range targets {
+			rel, err := cfg.Releases.Last(target.ReleaseName)
+			require.NoError(t, err)
+			assert.Equal(t, release.StatusDeployed, rel.Info.Status)
+		}
+	})
+
+	t.Run("rolls back when the second update fails", func(t *testing.T) {
+		d := &failNthUpdateDriver{Memory: driver.NewMemory(), failOn: 2}
+		cfg := newAtomicConfig(t, d, "first", "second", "third")
+
+		_, err := SetStatusAtomic(t.Context(), cfg, targets, release.StatusDeployed, SetStatusOptions{})
+		var atomicErr *AtomicError
+		require.True(t, errors.As(err, &atomicErr), "error should be *AtomicError")
+		assert.Equal(t, Target{ReleaseName: "second"}, atomicErr.Failed)
+		// The failed update may have been written, so it is restored too
+		assert.Equal(t, []Target{{ReleaseName: "second"}, {ReleaseName: "first"}}, atomicErr.RolledBack)
+		assert.Empty(t, atomicErr.RollbackErrors)
+		assert.Equal(t, "failed to set status on second: failed to update release second: storage unavailable; rolled back second, first", err.Error())
+
+		first, err := cfg.Releases.Last("first")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusPendingUpgrade, first.Info.Status)
+		assert.Equal(t, "Preparing upgrade", first.Info.Description)
+
+		// The third target is never attempted
+		third, err := cfg.Releases.Last("third")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusPendingUpgrade, third.Info.Status)
+	})
+
+	t.Run("rolls back labels set by the change", func(t *testing.T) {
+		d := &failNthUpdateDriver{Memory: driver.NewMemory(), failOn: 2}
+		cfg := newAtomicConfig(t, d, "first", "second")
+		first, err := cfg.Releases.Last("first")
+		require.NoError(t, err)
+		first.Labels = map[string]string{"team": "platform"}
+		require.NoError(t, cfg.Releases.Update(first))
+		d.updates = 0
+
+		targets := []Target{{ReleaseName: "first"}, {ReleaseName: "second"}}
+		opts := SetStatusOptions{Labels: map[string]string{"team": "payments", "ticket": "OPS-1"}}
+		_, err = SetStatusAtomic(t.Context(), cfg, targets, release.StatusDeployed, opts)
+		var atomicErr *AtomicError
+		require.True(t, errors.As(err, &atomicErr), "error should be *AtomicError")
+		assert.Equal(t, []Target{{ReleaseName: "second"}, {ReleaseName: "first"}}, atomicErr.RolledBack)
+
+		first, err = cfg.Releases.Last("first")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"team": "platform"}, first.Labels)
+		second, err := cfg.Releases.Last("second")
+		require.NoError(t, err)
+		assert.Empty(t, second.Labels)
+	})
+
+	t.Run("reports targets that could not be rolled back", func(t *testing.T) {
+		d := &failNthUpdateDriver{Memory: driver.NewMemory(), failOn: 3, failRollbacks: true}
+		cfg := newAtomicConfig(t, d, "first", "second", "third")
+
+		_, err := SetStatusAtomic(t.Context(), cfg, targets, release.StatusDeployed, SetStatusOptions{})
+		var atomicErr *AtomicError
+		require.True(t, errors.As(err, &atomicErr), "error should be *AtomicError")
+		assert.Empty(t, atomicErr.RolledBack)
+		assert.Len(t, atomicErr.RollbackErrors, 3)
+		assert.Contains(t, err.Error(), "failed to roll back first: storage unavailable; failed to roll back second: storage unavailable; failed to roll back third: storage unavailable")
+	})
+
+	t.Run("rolls back an update whose wait times out", func(t *testing.T) {
+		origInterval := waitPollInterval
+		waitPollInterval = time.Millisecond
+		defer func() { waitPollInterval = origInterval }()
+
+		cfg, d := newStaleReadConfig(t, 1<<30)
+		target := Target{ReleaseName: "test-release", Revision: 1}
+
+		_, err := SetStatusAtomic(t.Context(), cfg, []Target{target}, release.StatusDeployed, SetStatusOptions{WaitTimeout: 20 * time.Millisecond})
+		var atomicErr *AtomicError
+		require.True(t, errors.As(err, &atomicErr), "error should be *AtomicError")
+		assert.Equal(t, target, atomicErr.Failed)
+		assert.Contains(t, err.Error(), "timed out waiting for release test-release to report status deployed")
+		assert.Equal(t, []Target{target}, atomicErr.RolledBack)
+		assert.Empty(t, atomicErr.RollbackErrors)
+
+		rel, err := d.Memory.Get("sh.helm.release.v1.test-release.v1")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusPendingUpgrade, rel.Info.Status)
+	})
+
+	t.Run("precondition failure rolls back", func(t *testing.T) {
+		d := &failNthUpdateDriver{Memory: driver.NewMemory()}
+		cfg := newAtomicConfig(t, d, "first", "second")
+		second, err := cfg.Releases.Last("second")
+		require.NoError(t, err)
+		second.Info.Status = release.StatusFailed
+		require.NoError(t, cfg.Releases.Update(second))
+
+		targets := []Target{{ReleaseName: "first"}, {ReleaseName: "second", Revision: 1}}
+		_, err = SetStatusAtomic(t.Context(), cfg, targets, release.StatusDeployed, SetStatusOptions{AllowedFromStatuses: []release.Status{release.StatusPendingUpgrade}})
+		var precondErr *PreconditionError
+		require.True(t, errors.As(err, &precondErr), "error should wrap *PreconditionError")
+		assert.Contains(t, err.Error(), "failed to set status on second (revision 1)")
+
+		first, err := cfg.Releases.Last("first")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusPendingUpgrade, first.Info.Status)
+	})
+}
+
+func TestRestoreStatus(t *testing.T) {
+	newChangedConfig := func(t *testing.T) (*action.Configuration, Result) {
+		t.Helper()
+		cfg := newAtomicConfig(t, &failNthUpdateDriver{Memory: driver.NewMemory()}, "test-release")
+		result, err := SetStatus(t.Context(), cfg, "test-release", release.StatusDeployed, SetStatusOptions{})
+		require.NoError(t, err)
+		return cfg, result
+	}
+
+	t.Run("restores the release as it was before the change", func(t *testing.T) {
+		cfg, result := newChangedConfig(t)
+
+		require.NoError(t, RestoreStatus(cfg, result.PreviousRelease, release.StatusDeployed))
+		rel, err := cfg.Releases.Get("test-release", 1)
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusPendingUpgrade, rel.Info.Status)
+		assert.Equal(t, "Preparing upgrade", rel.Info.Description)
+		assert.True(t, rel.Info.LastDeployed.Equal(result.PreviousRelease.Info.LastDeployed))
+	})
+
+	t.Run("removes labels set by the change", func(t *testing.T) {
+		cfg := newAtomicConfig(t, &failNthUpdateDriver{Memory: driver.NewMemory()}, "test-release")
+		result, err := SetStatus(t.Context(), cfg, "test-release", release.StatusDeployed, SetStatusOptions{Labels: map[string]string{"ticket": "OPS-1"}})
+		require.NoError(t, err)
+
+		require.NoError(t, RestoreStatus(cfg, result.PreviousRelease, release.StatusDeployed))
+		rel, err := cfg.Releases.Get("test-release", 1)
+		require.NoError(t, err)
+		assert.Empty(t, rel.Labels)
+	})
+
+	t.Run("leaves a release changed since alone", func(t *testing.T) {
+		cfg, result := newChangedConfig(t)
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, SetStatusOptions{})
+		require.NoError(t, err)
+
+		err = RestoreStatus(cfg, result.PreviousRelease, release.StatusDeployed)
+		var precondErr *PreconditionError
+		require.True(t, errors.As(err, &precondErr), "error should be *PreconditionError")
+		assert.Equal(t, release.StatusFailed, precondErr.CurrentStatus)
+		rel, err := cfg.Releases.Get("test-release", 1)
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusFailed, rel.Info.Status)
+	})
+}
@@ -0,0 +1,65 @@
+package status
+
+import (
+	"context"
+	"sync"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// SetStatusRequest is a single status change in a BatchSetStatus call.
+type SetStatusRequest struct {
+	ReleaseName string
+	Status      release.Status
+	Options     SetStatusOptions
+}
+
+// SetStatusResult is the outcome of a SetStatusRequest. Err is the error
+// SetStatus returned for it, such as a ReleaseNotFoundError or a
+// PreconditionError; Result is only meaningful when Err is nil.
+type SetStatusResult struct {
+	Request SetStatusRequest
+	Result  Result
+	Err     error
+}
+
+// BatchSetStatus calls SetStatus for each request in turn and returns their
+// outcomes in the same order. Unlike SetStatusAtomic, a failed request does
+// not stop the batch or undo the requests already made.
+//
+// BatchSetStatus may be called concurrently with other calls to it and to
+// SetStatus with the same cfg, with the guarantees documented on SetStatus.
+// requests and the options in them are only read.
+func BatchSetStatus(ctx context.Context, cfg *action.Configuration, requests []SetStatusRequest) []SetStatusResult {
+	return BatchSetStatusParallel(ctx, cfg, requests, 1)
+}
+
+// BatchSetStatusParallel is BatchSetStatus with up to parallelism requests
+// in flight at once. Outcomes are still returned in the order of requests,
+// regardless of the order in which they finish. Requests for the same
+// release may be in flight together; they behave as concurrent SetStatus
+// calls, so which status is kept is unspecified.
+func BatchSetStatusParallel(ctx context.Context, cfg *action.Configuration, requests []SetStatusRequest, parallelism int) []SetStatusResult {
+	results := make([]SetStatusResult, len(requests))
+
+	workers := max(min(parallelism, len(requests)), 1)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Go(func() {
+			for i := range jobs {
+				req := requests[i]
+				result, err := SetStatus(ctx, cfg, req.ReleaseName, req.Status, req.Options)
+				results[i] = SetStatusResult{Request: req, Result: result, Err: err}
+			}
+		})
+	}
+	for i := range requests {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
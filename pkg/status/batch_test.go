@@ -0,0 +1,161 @@
+package status
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+func TestBatchSetStatus(t *testing.T) {
+	newConfig := func(t *testing.T) *action.Configuration {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		for _, r := range []struct {
+			name   string
+			status release.Status
+		}{
+			{"frontend", release.StatusPendingUpgrade},
+			{"backend", release.StatusDeployed},
+		} {
+			require.NoError(t, store.Create(&release.Release{
+				Name:      r.name,
+				Namespace: "default",
+				Version:   1,
+				Info: &release.Info{
+					Status: r.status,
+				},
+				Chart: &chart.Chart{
+					Metadata: &chart.Metadata{
+						Name:    "test-chart",
+						Version: "1.0.0",
+					},
+				},
+			}))
+		}
+		return &action.Configuration{Releases: store}
+	}
+	requests := []SetStatusRequest{
+		{ReleaseName: "frontend", Status: release.StatusFailed},
+		{ReleaseName: "missing", Status: release.StatusFailed},
+		{
+			ReleaseName: "backend",
+			Status:      release.StatusFailed,
+			Options:     SetStatusOptions{AllowedFromStatuses: []release.Status{release.StatusPendingUpgrade}},
+		},
+	}
+	check := func(t *testing.T, cfg *action.Configuration, results []SetStatusResult) {
+		t.Helper()
+		require.Len(t, results, 3)
+
+		assert.Equal(t, requests[0], results[0].Request)
+		require.NoError(t, results[0].Err)
+		assert.True(t, results[0].Result.Changed)
+		assert.Equal(t, release.StatusPendingUpgrade, results[0].Result.PreviousStatus)
+
+		var notFoundErr *ReleaseNotFoundError
+		require.ErrorAs(t, results[1].Err, &notFoundErr)
+		assert.Equal(t, "missing", notFoundErr.ReleaseName)
+
+		var precondErr *PreconditionError
+		require.ErrorAs(t, results[2].Err, &precondErr)
+		assert.Equal(t, "backend", precondErr.ReleaseName)
+
+		frontend, err := cfg.Releases.Last("frontend")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusFailed, frontend.Info.Status)
+		backend, err := cfg.Releases.Last("backend")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusDeployed, backend.Info.Status)
+	}
+
+	t.Run("in turn", func(t *testing.T) {
+		cfg := newConfig(t)
+		check(t, cfg, BatchSetStatus(t.Context(), cfg, requests))
+	})
+
+	t.Run("in parallel", func(t *testing.T) {
+		cfg := newConfig(t)
+		check(t, cfg, BatchSetStatusParallel(t.Context(), cfg, requests, 3))
+	})
+
+	t.Run("no requests", func(t *testing.T) {
+		assert.Empty(t, BatchSetStatus(t.Context(), newConfig(t), nil))
+	})
+}
+
+// yieldDriver yields the processor after each read, so that concurrent
+// callers interleave between reading a release and writing it back even on a
+// single CPU.
+type yieldDriver struct {
+	*driver.Memory
+}
+
+func (d *yieldDriver) Get(key string) (*release.Release, error) {
+	defer runtime.Gosched()
+	return d.Memory.Get(key)
+}
+
+func (d *yieldDriver) Query(labels map[string]string) ([]*release.Release, error) {
+	defer runtime.Gosched()
+	return d.Memory.Query(labels)
+}
+
+func TestBatchSetStatus_Concurrent(t *testing.T) {
+	store := storage.Init(&yieldDriver{Memory: driver.NewMemory()})
+	names := make([]string, 20)
+	for i := range names {
+		names[i] = fmt.Sprintf("release-%d", i)
+		require.NoError(t, store.Create(&release.Release{
+			Name:      names[i],
+			Namespace: "default",
+			Version:   1,
+			Labels:    map[string]string{"team": "web"},
+			Info:      &release.Info{Status: release.StatusPendingUpgrade},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}))
+	}
+	cfg := &action.Configuration{Releases: store}
+	opts := SetStatusOptions{ForceWrite: true, MaxRetries: 100, Labels: map[string]string{"changed-by": "test"}}
+
+	var requests []SetStatusRequest
+	for _, name := range names {
+		requests = append(requests, SetStatusRequest{ReleaseName: name, Status: release.StatusFailed, Options: opts})
+	}
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Go(func() {
+			for _, res := range BatchSetStatusParallel(t.Context(), cfg, requests, 8) {
+				assert.NoError(t, res.Err)
+			}
+		})
+		wg.Go(func() {
+			for _, name := range names {
+				_, err := SetStatus(t.Context(), cfg, name, release.StatusFailed, opts)
+				assert.NoError(t, err)
+			}
+		})
+	}
+	wg.Wait()
+
+	for _, name := range names {
+		rel, err := store.Last(name)
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusFailed, rel.Info.Status, name)
+		assert.Equal(t, "test", rel.Labels["changed-by"], name)
+	}
+}
@@ -0,0 +1,51 @@
+package status
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// DescriptionData holds the fields available to a description template:
+// the status being set, the status the release had before, the revision
+// being updated, and the time of the change in RFC 3339 format (UTC).
+type DescriptionData struct {
+	Status   string
+	Previous string
+	Revision int
+	Time     string
+}
+
+// ValidateDescription returns an error if description is not a valid
+// text/template or refers to a field DescriptionData does not have.
+func ValidateDescription(description string) error {
+	_, err := renderDescription(description, DescriptionData{})
+	return err
+}
+
+// renderDescription renders description as a text/template with data.
+// Text without template actions is returned unchanged.
+func renderDescription(description string, data DescriptionData) (string, error) {
+	tmpl, err := template.New("description").Option("missingkey=error").Parse(description)
+	if err != nil {
+		return "", fmt.Errorf("invalid description template: %w", err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("invalid description template: %w", err)
+	}
+	return b.String(), nil
+}
+
+// newDescriptionData returns the template data for setting rel to status.
+func newDescriptionData(rel *release.Release, status release.Status) DescriptionData {
+	return DescriptionData{
+		Status:   status.String(),
+		Previous: rel.Info.Status.String(),
+		Revision: rel.Version,
+		Time:     now().UTC().Format(time.RFC3339),
+	}
+}
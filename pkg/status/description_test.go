@@ -0,0 +1,88 @@
+package status
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+func TestRenderDescription(t *testing.T) {
+	data := DescriptionData{Status: "failed", Previous: "deployed", Revision: 3, Time: "2024-01-02T03:04:05Z"}
+
+	t.Run("renders every field", func(t *testing.T) {
+		got, err := renderDescription("{{.Previous}} -> {{.Status}} (revision {{.Revision}}) at {{.Time}}", data)
+		require.NoError(t, err)
+		assert.Equal(t, "deployed -> failed (revision 3) at 2024-01-02T03:04:05Z", got)
+	})
+
+	t.Run("leaves plain text unchanged", func(t *testing.T) {
+		got, err := renderDescription("smoke tests failed", data)
+		require.NoError(t, err)
+		assert.Equal(t, "smoke tests failed", got)
+	})
+}
+
+func TestValidateDescription(t *testing.T) {
+	assert.NoError(t, ValidateDescription(""))
+	assert.NoError(t, ValidateDescription("set to {{.Status}} at {{.Time}}"))
+
+	err := ValidateDescription("set to {{.Status")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid description template")
+
+	err = ValidateDescription("set by {{.User}}")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid description template")
+	assert.Contains(t, err.Error(), "User")
+}
+
+func TestSetStatus_DescriptionTemplate(t *testing.T) {
+	origNow := now
+	now = func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }
+	defer func() { now = origNow }()
+
+	store := storage.Init(driver.NewMemory())
+	require.NoError(t, store.Create(&release.Release{
+		Name:      "test-release",
+		Namespace: "default",
+		Version:   2,
+		Info: &release.Info{
+			Status: release.StatusPendingUpgrade,
+		},
+		Chart: &chart.Chart{
+			Metadata: &chart.Metadata{
+				Name:    "test-chart",
+				Version: "1.0.0",
+			},
+		},
+	}))
+	cfg := &action.Configuration{Releases: store}
+
+	t.Run("renders the template", func(t *testing.T) {
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, SetStatusOptions{
+			Description: "{{.Previous}} -> {{.Status}} (revision {{.Revision}}) at {{.Time}}",
+		})
+		require.NoError(t, err)
+
+		updated, err := store.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, "pending-upgrade -> failed (revision 2) at 2024-01-02T03:04:05Z", updated.Info.Description)
+	})
+
+	t.Run("rejects an invalid template before writing", func(t *testing.T) {
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusDeployed, SetStatusOptions{Description: "{{.Bogus}}"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid description template")
+
+		updated, err := store.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusFailed, updated.Info.Status)
+	})
+}
@@ -0,0 +1,62 @@
+package status
+
+import (
+	"time"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// ReleaseState holds the fields of a release revision that SetStatus modifies.
+type ReleaseState struct {
+	Status       release.Status
+	Description  string
+	LastDeployed time.Time
+}
+
+// Diff describes how SetStatus would change a release revision.
+type Diff struct {
+	ReleaseName string
+	Revision    int
+	Before      ReleaseState
+	After       ReleaseState
+}
+
+// Changed reports whether SetStatus would modify any field.
+func (d Diff) Changed() bool {
+	return d.Before.Status != d.After.Status ||
+		d.Before.Description != d.After.Description ||
+		!d.Before.LastDeployed.Equal(d.After.LastDeployed)
+}
+
+// DiffStatus returns the change SetStatus would make to a release given the
+// same arguments, without writing anything. Preconditions are not checked.
+func DiffStatus(cfg *action.Configuration, releaseName string, status release.Status, opts SetStatusOptions) (Diff, error) {
+	rel, err := getRelease(cfg, releaseName, opts.Revision)
+	if err != nil {
+		return Diff{}, err
+	}
+
+	before := releaseState(rel)
+	diff := Diff{ReleaseName: releaseName, Revision: rel.Version, Before: before, After: before}
+	if rel.Info.Status == status && !opts.ForceWrite {
+		return diff, nil
+	}
+
+	updated := cloneForUpdate(rel)
+	if err := applyStatus(updated, status, opts); err != nil {
+		return Diff{}, err
+	}
+	diff.After = releaseState(updated)
+
+	return diff, nil
+}
+
+// releaseState records the fields of rel that SetStatus modifies.
+func releaseState(rel *release.Release) ReleaseState {
+	return ReleaseState{
+		Status:       rel.Info.Status,
+		Description:  rel.Info.Description,
+		LastDeployed: rel.Info.LastDeployed.Time,
+	}
+}
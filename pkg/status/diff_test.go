@@ -0,0 +1,87 @@
+package status
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	helmtime "helm.sh/helm/v3/pkg/time"
+)
+
+func TestDiffStatus(t *testing.T) {
+	deployed := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	newConfig := func(t *testing.T) (*action.Configuration, *storage.Storage) {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		require.NoError(t, store.Create(&release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   1,
+			Labels:    map[string]string{"team": "payments"},
+			Info: &release.Info{
+				Status:       release.StatusDeployed,
+				Description:  "Upgrade complete",
+				LastDeployed: helmtime.Time{Time: deployed},
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}))
+		return &action.Configuration{Releases: store}, store
+	}
+
+	t.Run("reports before and after without writing", func(t *testing.T) {
+		cfg, store := newConfig(t)
+
+		diff, err := DiffStatus(cfg, "test-release", release.StatusFailed, SetStatusOptions{Labels: map[string]string{"changed-by": "ci"}})
+		require.NoError(t, err)
+		assert.True(t, diff.Changed())
+		assert.Equal(t, 1, diff.Revision)
+		assert.Equal(t, ReleaseState{Status: release.StatusDeployed, Description: "Upgrade complete", LastDeployed: deployed}, diff.Before)
+		assert.Equal(t, release.StatusFailed, diff.After.Status)
+		assert.Equal(t, "status set to failed", diff.After.Description)
+		assert.True(t, diff.After.LastDeployed.After(deployed))
+
+		stored, err := store.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusDeployed, stored.Info.Status)
+		assert.Equal(t, "Upgrade complete", stored.Info.Description)
+		assert.True(t, stored.Info.LastDeployed.Equal(helmtime.Time{Time: deployed}))
+		assert.Equal(t, map[string]string{"team": "payments"}, stored.Labels)
+	})
+
+	t.Run("honours description options", func(t *testing.T) {
+		cfg, _ := newConfig(t)
+
+		diff, err := DiffStatus(cfg, "test-release", release.StatusFailed, SetStatusOptions{Description: "smoke tests failed", KeepLastDeployed: true})
+		require.NoError(t, err)
+		assert.Equal(t, "smoke tests failed", diff.After.Description)
+		assert.Equal(t, deployed, diff.After.LastDeployed)
+	})
+
+	t.Run("reports no change at the target status", func(t *testing.T) {
+		cfg, _ := newConfig(t)
+
+		diff, err := DiffStatus(cfg, "test-release", release.StatusDeployed, SetStatusOptions{})
+		require.NoError(t, err)
+		assert.False(t, diff.Changed())
+		assert.Equal(t, diff.Before, diff.After)
+	})
+
+	t.Run("release not found", func(t *testing.T) {
+		cfg, _ := newConfig(t)
+
+		_, err := DiffStatus(cfg, "missing", release.StatusFailed, SetStatusOptions{})
+		var notFoundErr *ReleaseNotFoundError
+		require.ErrorAs(t, err, &notFoundErr)
+	})
+}
@@ -0,0 +1,161 @@
+package status
+
+import (
+	"fmt"
+	"sort"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// History returns every stored revision of a release, oldest first.
+// It returns a ReleaseNotFoundError if the release has no history.
+func History(cfg *action.Configuration, releaseName string) ([]*release.Release, error) {
+	if err := checkStorage(cfg); err != nil {
+		return nil, err
+	}
+	revisions, err := cfg.Releases.History(releaseName)
+	if err != nil || len(revisions) == 0 {
+		return nil, &ReleaseNotFoundError{ReleaseName: releaseName}
+	}
+
+	sort.Slice(revisions, func(i, j int) bool { return revisions[i].Version < revisions[j].Version })
+	return revisions, nil
+}
+
+// RevisionTargets returns a Target for every stored revision of a release,
+// oldest first. If excludeLatest is set, the latest revision is left out.
+// It returns a ReleaseNotFoundError if the release has no history.
+func RevisionTargets(cfg *action.Configuration, releaseName string, excludeLatest bool) ([]Target, error) {
+	revisions, err := History(cfg, releaseName)
+	if err != nil {
+		return nil, err
+	}
+	if excludeLatest {
+		revisions = revisions[:len(revisions)-1]
+	}
+
+	targets := make([]Target, len(revisions))
+	for i, rel := range revisions {
+		targets[i] = Target{ReleaseName: releaseName, Revision: rel.Version}
+	}
+	return targets, nil
+}
+
+// RevisionRangeTargets returns a Target for every stored revision of a
+// release from revision from through revision to inclusive, oldest first.
+// It returns a ReleaseNotFoundError if the release has no history, and a
+// RevisionNotFoundError for from or to if the range reaches past the oldest
+// or latest stored revision.
+func RevisionRangeTargets(cfg *action.Configuration, releaseName string, from, to int) ([]Target, error) {
+	if from < 1 || to < from {
+		return nil, fmt.Errorf("invalid revision range %d-%d", from, to)
+	}
+	revisions, err := History(cfg, releaseName)
+	if err != nil {
+		return nil, err
+	}
+	if from < revisions[0].Version {
+		return nil, &RevisionNotFoundError{ReleaseName: releaseName, Revision: from}
+	}
+	if to > revisions[len(revisions)-1].Version {
+		return nil, &RevisionNotFoundError{ReleaseName: releaseName, Revision: to}
+	}
+
+	var targets []Target
+	for _, rel := range revisions {
+		if rel.Version >= from && rel.Version <= to {
+			targets = append(targets, Target{ReleaseName: releaseName, Revision: rel.Version})
+		}
+	}
+	return targets, nil
+}
+
+// PrunableRevisions returns the revisions of a release that PruneHistory
+// would delete, oldest first: every revision except the keep most recent and
+// the protected revision. keep must be at least 1.
+// It returns a ReleaseNotFoundError if the release has no history.
+func PrunableRevisions(cfg *action.Configuration, releaseName string, keep, protected int) ([]int, error) {
+	if keep < 1 {
+		return nil, fmt.Errorf("must keep at least 1 revision, got %d", keep)
+	}
+	revisions, err := History(cfg, releaseName)
+	if err != nil {
+		return nil, err
+	}
+
+	var prunable []int
+	for _, rel := range revisions[:max(len(revisions)-keep, 0)] {
+		if rel.Version != protected {
+			prunable = append(prunable, rel.Version)
+		}
+	}
+	return prunable, nil
+}
+
+// PruneHistory deletes every revision of a release except the keep most
+// recent and the protected revision, and returns the deleted revisions,
+// oldest first. Revisions deleted before an error are still returned.
+// It returns a ReleaseNotFoundError if the release has no history.
+func PruneHistory(cfg *action.Configuration, releaseName string, keep, protected int) ([]int, error) {
+	prunable, err := PrunableRevisions(cfg, releaseName, keep, protected)
+	if err != nil {
+		return nil, err
+	}
+
+	var deleted []int
+	for _, version := range prunable {
+		if _, err := cfg.Releases.Delete(releaseName, version); err != nil {
+			return deleted, fmt.Errorf("failed to delete release %s revision %d: %w", releaseName, version, err)
+		}
+		deleted = append(deleted, version)
+	}
+	return deleted, nil
+}
+
+// SupersedableRevisions returns the revisions of a release that
+// SupersedeRevisions would mark superseded, oldest first: every deployed
+// revision except kept.
+// It returns a ReleaseNotFoundError if the release has no history.
+func SupersedableRevisions(cfg *action.Configuration, releaseName string, kept int) ([]int, error) {
+	revisions, err := History(cfg, releaseName)
+	if err != nil {
+		return nil, err
+	}
+
+	var supersedable []int
+	for _, rel := range revisions {
+		if rel.Version != kept && rel.Info != nil && rel.Info.Status == release.StatusDeployed {
+			supersedable = append(supersedable, rel.Version)
+		}
+	}
+	return supersedable, nil
+}
+
+// SupersedeRevisions sets every deployed revision of a release except kept
+// to superseded, as helm upgrade does for the revision it replaces, so that
+// kept is left as the only deployed revision. It returns the revisions
+// changed, oldest first. Revisions changed before an error are still
+// returned. Only the status is changed; the description and last-deployed
+// time are kept.
+// It returns a ReleaseNotFoundError if the release has no history.
+func SupersedeRevisions(cfg *action.Configuration, releaseName string, kept int) ([]int, error) {
+	revisions, err := History(cfg, releaseName)
+	if err != nil {
+		return nil, err
+	}
+
+	var superseded []int
+	for _, rel := range revisions {
+		if rel.Version == kept || rel.Info == nil || rel.Info.Status != release.StatusDeployed {
+			continue
+		}
+		updated := cloneForUpdate(rel)
+		updated.Info.Status = release.StatusSuperseded
+		if err := cfg.Releases.Update(updated); err != nil {
+			return superseded, fmt.Errorf("failed to supersede release %s revision %d: %w", releaseName, rel.Version, err)
+		}
+		superseded = append(superseded, rel.Version)
+	}
+	return superseded, nil
+}
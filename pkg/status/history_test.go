@@ -0,0 +1,304 @@
+package status
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+func TestHistory(t *testing.T) {
+	store := storage.Init(driver.NewMemory())
+
+	for _, r := range []struct {
+		version int
+		status  release.Status
+	}{
+		{2, release.StatusDeployed},
+		{1, release.StatusSuperseded},
+		{3, release.StatusFailed},
+	} {
+		require.NoError(t, store.Create(&release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   r.version,
+			Info: &release.Info{
+				Status: r.status,
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}))
+	}
+
+	cfg := &action.Configuration{Releases: store}
+
+	t.Run("returns revisions oldest first", func(t *testing.T) {
+		revisions, err := History(cfg, "test-release")
+		require.NoError(t, err)
+		require.Len(t, revisions, 3)
+		assert.Equal(t, 1, revisions[0].Version)
+		assert.Equal(t, release.StatusSuperseded, revisions[0].Info.Status)
+		assert.Equal(t, 2, revisions[1].Version)
+		assert.Equal(t, 3, revisions[2].Version)
+		assert.Equal(t, release.StatusFailed, revisions[2].Info.Status)
+	})
+
+	t.Run("release not found", func(t *testing.T) {
+		_, err := History(cfg, "nonexistent")
+		var notFoundErr *ReleaseNotFoundError
+		require.True(t, errors.As(err, &notFoundErr), "error should be *ReleaseNotFoundError")
+		assert.Equal(t, "nonexistent", notFoundErr.ReleaseName)
+	})
+}
+
+func TestRevisionTargets(t *testing.T) {
+	store := storage.Init(driver.NewMemory())
+	for _, v := range []int{2, 1, 3} {
+		require.NoError(t, store.Create(&release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   v,
+			Info: &release.Info{
+				Status: release.StatusDeployed,
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}))
+	}
+
+	cfg := &action.Configuration{Releases: store}
+
+	t.Run("returns every revision oldest first", func(t *testing.T) {
+		targets, err := RevisionTargets(cfg, "test-release", false)
+		require.NoError(t, err)
+		assert.Equal(t, []Target{
+			{ReleaseName: "test-release", Revision: 1},
+			{ReleaseName: "test-release", Revision: 2},
+			{ReleaseName: "test-release", Revision: 3},
+		}, targets)
+	})
+
+	t.Run("excludes the latest revision", func(t *testing.T) {
+		targets, err := RevisionTargets(cfg, "test-release", true)
+		require.NoError(t, err)
+		assert.Equal(t, []Target{
+			{ReleaseName: "test-release", Revision: 1},
+			{ReleaseName: "test-release", Revision: 2},
+		}, targets)
+	})
+
+	t.Run("release not found", func(t *testing.T) {
+		_, err := RevisionTargets(cfg, "nonexistent", false)
+		var notFoundErr *ReleaseNotFoundError
+		require.True(t, errors.As(err, &notFoundErr), "error should be *ReleaseNotFoundError")
+	})
+}
+
+func TestRevisionRangeTargets(t *testing.T) {
+	store := storage.Init(driver.NewMemory())
+	for _, v := range []int{2, 3, 4, 6} {
+		require.NoError(t, store.Create(&release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   v,
+			Info: &release.Info{
+				Status: release.StatusSuperseded,
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}))
+	}
+
+	cfg := &action.Configuration{Releases: store}
+
+	t.Run("returns the stored revisions in the range", func(t *testing.T) {
+		targets, err := RevisionRangeTargets(cfg, "test-release", 3, 6)
+		require.NoError(t, err)
+		assert.Equal(t, []Target{
+			{ReleaseName: "test-release", Revision: 3},
+			{ReleaseName: "test-release", Revision: 4},
+			{ReleaseName: "test-release", Revision: 6},
+		}, targets)
+
+		targets, err = RevisionRangeTargets(cfg, "test-release", 2, 2)
+		require.NoError(t, err)
+		assert.Equal(t, []Target{{ReleaseName: "test-release", Revision: 2}}, targets)
+	})
+
+	t.Run("rejects a range past the stored revisions", func(t *testing.T) {
+		_, err := RevisionRangeTargets(cfg, "test-release", 1, 3)
+		var revErr *RevisionNotFoundError
+		require.ErrorAs(t, err, &revErr)
+		assert.Equal(t, 1, revErr.Revision)
+
+		_, err = RevisionRangeTargets(cfg, "test-release", 4, 7)
+		require.ErrorAs(t, err, &revErr)
+		assert.Equal(t, 7, revErr.Revision)
+	})
+
+	t.Run("rejects a reversed range", func(t *testing.T) {
+		_, err := RevisionRangeTargets(cfg, "test-release", 4, 3)
+		assert.EqualError(t, err, "invalid revision range 4-3")
+	})
+
+	t.Run("release not found", func(t *testing.T) {
+		_, err := RevisionRangeTargets(cfg, "nonexistent", 1, 2)
+		var notFoundErr *ReleaseNotFoundError
+		require.ErrorAs(t, err, &notFoundErr)
+	})
+}
+
+func TestPruneHistory(t *testing.T) {
+	newConfig := func(t *testing.T, revisions int) *action.Configuration {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		for v := 1; v <= revisions; v++ {
+			require.NoError(t, store.Create(&release.Release{
+				Name:      "test-release",
+				Namespace: "default",
+				Version:   v,
+				Info:      &release.Info{Status: release.StatusSuperseded},
+			}))
+		}
+		return &action.Configuration{Releases: store}
+	}
+	remaining := func(t *testing.T, cfg *action.Configuration) []int {
+		t.Helper()
+		revisions, err := History(cfg, "test-release")
+		require.NoError(t, err)
+		versions := make([]int, len(revisions))
+		for i, rel := range revisions {
+			versions[i] = rel.Version
+		}
+		return versions
+	}
+
+	t.Run("keeps the most recent revisions", func(t *testing.T) {
+		cfg := newConfig(t, 5)
+
+		deleted, err := PruneHistory(cfg, "test-release", 2, 5)
+		require.NoError(t, err)
+		assert.Equal(t, []int{1, 2, 3}, deleted)
+		assert.Equal(t, []int{4, 5}, remaining(t, cfg))
+	})
+
+	t.Run("never deletes the protected revision", func(t *testing.T) {
+		cfg := newConfig(t, 5)
+
+		deleted, err := PruneHistory(cfg, "test-release", 2, 2)
+		require.NoError(t, err)
+		assert.Equal(t, []int{1, 3}, deleted)
+		assert.Equal(t, []int{2, 4, 5}, remaining(t, cfg))
+	})
+
+	t.Run("deletes nothing when there are few revisions", func(t *testing.T) {
+		cfg := newConfig(t, 2)
+
+		deleted, err := PruneHistory(cfg, "test-release", 3, 2)
+		require.NoError(t, err)
+		assert.Empty(t, deleted)
+		assert.Equal(t, []int{1, 2}, remaining(t, cfg))
+	})
+
+	t.Run("reports without deleting", func(t *testing.T) {
+		cfg := newConfig(t, 4)
+
+		prunable, err := PrunableRevisions(cfg, "test-release", 1, 4)
+		require.NoError(t, err)
+		assert.Equal(t, []int{1, 2, 3}, prunable)
+		assert.Equal(t, []int{1, 2, 3, 4}, remaining(t, cfg))
+	})
+
+	t.Run("rejects keeping no revisions", func(t *testing.T) {
+		_, err := PruneHistory(newConfig(t, 2), "test-release", 0, 2)
+		assert.EqualError(t, err, "must keep at least 1 revision, got 0")
+	})
+
+	t.Run("release not found", func(t *testing.T) {
+		_, err := PruneHistory(newConfig(t, 0), "test-release", 1, 0)
+		var notFoundErr *ReleaseNotFoundError
+		assert.ErrorAs(t, err, &notFoundErr)
+	})
+}
+
+func TestSupersedeRevisions(t *testing.T) {
+	newConfig := func(t *testing.T, statuses ...release.Status) *action.Configuration {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		for i, st := range statuses {
+			require.NoError(t, store.Create(&release.Release{
+				Name:      "test-release",
+				Namespace: "default",
+				Version:   i + 1,
+				Info:      &release.Info{Status: st, Description: fmt.Sprintf("revision %d", i+1)},
+			}))
+		}
+		return &action.Configuration{Releases: store}
+	}
+	statuses := func(t *testing.T, cfg *action.Configuration) []release.Status {
+		t.Helper()
+		revisions, err := History(cfg, "test-release")
+		require.NoError(t, err)
+		result := make([]release.Status, len(revisions))
+		for i, rel := range revisions {
+			result[i] = rel.Info.Status
+		}
+		return result
+	}
+
+	t.Run("leaves the kept revision as the only deployed one", func(t *testing.T) {
+		cfg := newConfig(t, release.StatusDeployed, release.StatusFailed, release.StatusDeployed, release.StatusDeployed)
+
+		superseded, err := SupersedeRevisions(cfg, "test-release", 3)
+		require.NoError(t, err)
+		assert.Equal(t, []int{1, 4}, superseded)
+		assert.Equal(t, []release.Status{release.StatusSuperseded, release.StatusFailed, release.StatusDeployed, release.StatusSuperseded}, statuses(t, cfg))
+
+		rel, err := cfg.Releases.Get("test-release", 4)
+		require.NoError(t, err)
+		assert.Equal(t, "revision 4", rel.Info.Description)
+	})
+
+	t.Run("changes nothing when no other revision is deployed", func(t *testing.T) {
+		cfg := newConfig(t, release.StatusSuperseded, release.StatusDeployed)
+
+		superseded, err := SupersedeRevisions(cfg, "test-release", 2)
+		require.NoError(t, err)
+		assert.Empty(t, superseded)
+		assert.Equal(t, []release.Status{release.StatusSuperseded, release.StatusDeployed}, statuses(t, cfg))
+	})
+
+	t.Run("reports without changing", func(t *testing.T) {
+		cfg := newConfig(t, release.StatusDeployed, release.StatusDeployed, release.StatusPendingUpgrade)
+
+		supersedable, err := SupersedableRevisions(cfg, "test-release", 3)
+		require.NoError(t, err)
+		assert.Equal(t, []int{1, 2}, supersedable)
+		assert.Equal(t, []release.Status{release.StatusDeployed, release.StatusDeployed, release.StatusPendingUpgrade}, statuses(t, cfg))
+	})
+
+	t.Run("release not found", func(t *testing.T) {
+		_, err := SupersedeRevisions(newConfig(t), "test-release", 1)
+		var notFoundErr *ReleaseNotFoundError
+		assert.ErrorAs(t, err, &notFoundErr)
+	})
+}
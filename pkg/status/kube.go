@@ -2,6 +2,8 @@ package status
 
 import (
 	"os"
+	"strings"
+	"sync"
 
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/client-go/discovery"
@@ -11,45 +13,122 @@ import (
 	"k8s.io/client-go/tools/clientcmd"
 )
 
-// RESTClientGetter implements genericclioptions.RESTClientGetter interface
+// ClientOptions overrides how the plugin reaches the cluster and its release
+// storage. Empty fields fall back to the environment variables set by Helm.
+// The API server, bearer token, and impersonation settings Helm passes to
+// plugins (HELM_KUBEAPISERVER, HELM_KUBETOKEN, HELM_KUBEASUSER, and
+// HELM_KUBEASGROUPS) override the kubeconfig.
+type ClientOptions struct {
+	// KubeContext selects the kubeconfig context, overriding HELM_KUBECONTEXT.
+	KubeContext string
+	// KubeConfig is the path to a kubeconfig file, overriding KUBECONFIG.
+	KubeConfig string
+	// StorageDriver selects the release storage backend, overriding
+	// HELM_DRIVER. See ValidStorageDrivers.
+	StorageDriver string
+	// Token is a bearer token to authenticate with, overriding HELM_KUBETOKEN
+	// and the kubeconfig.
+	Token string
+	// AsUser is a user to impersonate, overriding HELM_KUBEASUSER.
+	AsUser string
+	// AsGroups are groups to impersonate, overriding HELM_KUBEASGROUPS.
+	AsGroups []string
+	// InsecureSkipTLSVerify disables verification of the API server's
+	// certificate, ignoring any certificate authority in the kubeconfig. The
+	// connection is then open to interception, so this is only suitable for
+	// throwaway clusters with self-signed certificates.
+	InsecureSkipTLSVerify bool
+	// AllNamespaces gives the configuration access to releases in every
+	// namespace, as helm list --all-namespaces does, instead of a single
+	// namespace. Such a configuration is only suitable for listing releases.
+	AllNamespaces bool
+}
+
+// newDiscoveryClient creates the discovery client behind a RESTClientGetter.
+// This can be overridden for testing.
+var newDiscoveryClient = func(config *rest.Config) (discovery.DiscoveryInterface, error) {
+	return discovery.NewDiscoveryClientForConfig(config)
+}
+
+// RESTClientGetter implements genericclioptions.RESTClientGetter interface.
+// The discovery client and REST mapper are built on first use and shared by
+// later calls, which may be made concurrently.
 type RESTClientGetter struct {
 	namespace string
+	opts      ClientOptions
+
+	mu              sync.Mutex
+	discoveryClient discovery.CachedDiscoveryInterface
+	restMapper      meta.RESTMapper
 }
 
-// NewRESTClientGetter creates a new RESTClientGetter
-func NewRESTClientGetter(namespace string) *RESTClientGetter {
-	return &RESTClientGetter{namespace: namespace}
+// NewRESTClientGetter creates a new RESTClientGetter. The namespace is
+// resolved with ResolveNamespace, so an empty namespace falls back to
+// HELM_NAMESPACE like Helm itself.
+func NewRESTClientGetter(namespace string, opts ClientOptions) *RESTClientGetter {
+	return &RESTClientGetter{namespace: ResolveNamespace(namespace), opts: opts}
 }
 
 // ToRESTConfig returns a REST config
 func (r *RESTClientGetter) ToRESTConfig() (*rest.Config, error) {
-	return r.ToRawKubeConfigLoader().ClientConfig()
+	config, err := r.ToRawKubeConfigLoader().ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if r.opts.InsecureSkipTLSVerify {
+		// client-go rejects a CA alongside Insecure, so drop it
+		config.Insecure = true
+		config.CAData = nil
+		config.CAFile = ""
+	}
+	return config, nil
 }
 
-// ToDiscoveryClient returns a discovery client
+// ToDiscoveryClient returns a discovery client, creating it on the first call
 func (r *RESTClientGetter) ToDiscoveryClient() (discovery.CachedDiscoveryInterface, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.discoveryClientLocked()
+}
+
+// discoveryClientLocked returns the cached discovery client, creating it if
+// needed. A failure is not cached, so a later call tries again. r.mu must be
+// held.
+func (r *RESTClientGetter) discoveryClientLocked() (discovery.CachedDiscoveryInterface, error) {
+	if r.discoveryClient != nil {
+		return r.discoveryClient, nil
+	}
+
 	config, err := r.ToRESTConfig()
 	if err != nil {
 		return nil, err
 	}
 
-	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	discoveryClient, err := newDiscoveryClient(config)
 	if err != nil {
 		return nil, err
 	}
 
-	return memory.NewMemCacheClient(discoveryClient), nil
+	r.discoveryClient = memory.NewMemCacheClient(discoveryClient)
+	return r.discoveryClient, nil
 }
 
-// ToRESTMapper returns a REST mapper
+// ToRESTMapper returns a REST mapper, creating it on the first call
 func (r *RESTClientGetter) ToRESTMapper() (meta.RESTMapper, error) {
-	discoveryClient, err := r.ToDiscoveryClient()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.restMapper != nil {
+		return r.restMapper, nil
+	}
+
+	discoveryClient, err := r.discoveryClientLocked()
 	if err != nil {
 		return nil, err
 	}
 
-	mapper := restmapper.NewDeferredDiscoveryRESTMapper(discoveryClient)
-	return mapper, nil
+	r.restMapper = restmapper.NewDeferredDiscoveryRESTMapper(discoveryClient)
+	return r.restMapper, nil
 }
 
 // ToRawKubeConfigLoader returns a clientcmd loader
@@ -59,12 +138,41 @@ func (r *RESTClientGetter) ToRawKubeConfigLoader() clientcmd.ClientConfig {
 	if kubeconfig := os.Getenv("KUBECONFIG"); kubeconfig != "" {
 		loadingRules.ExplicitPath = kubeconfig
 	}
+	if r.opts.KubeConfig != "" {
+		loadingRules.ExplicitPath = r.opts.KubeConfig
+	}
 
 	configOverrides := &clientcmd.ConfigOverrides{}
 	if context := os.Getenv("HELM_KUBECONTEXT"); context != "" {
 		configOverrides.CurrentContext = context
 	}
+	if r.opts.KubeContext != "" {
+		configOverrides.CurrentContext = r.opts.KubeContext
+	}
 	configOverrides.Context.Namespace = r.namespace
 
+	// Connection settings Helm passes to plugins from its own global flags
+	if server := os.Getenv("HELM_KUBEAPISERVER"); server != "" {
+		configOverrides.ClusterInfo.Server = server
+	}
+	if token := os.Getenv("HELM_KUBETOKEN"); token != "" {
+		configOverrides.AuthInfo.Token = token
+	}
+	if user := os.Getenv("HELM_KUBEASUSER"); user != "" {
+		configOverrides.AuthInfo.Impersonate = user
+	}
+	if groups := os.Getenv("HELM_KUBEASGROUPS"); groups != "" {
+		configOverrides.AuthInfo.ImpersonateGroups = strings.Split(groups, ",")
+	}
+	if r.opts.Token != "" {
+		configOverrides.AuthInfo.Token = r.opts.Token
+	}
+	if r.opts.AsUser != "" {
+		configOverrides.AuthInfo.Impersonate = r.opts.AsUser
+	}
+	if len(r.opts.AsGroups) > 0 {
+		configOverrides.AuthInfo.ImpersonateGroups = r.opts.AsGroups
+	}
+
 	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, configOverrides)
 }
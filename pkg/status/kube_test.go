@@ -3,25 +3,54 @@ package status
 import (
 	"os"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
 )
 
 func TestNewRESTClientGetter(t *testing.T) {
-	getter := NewRESTClientGetter("test-namespace")
+	getter := NewRESTClientGetter("test-namespace", ClientOptions{})
 	assert.NotNil(t, getter)
 	assert.Equal(t, "test-namespace", getter.namespace)
 }
 
+func TestNewRESTClientGetter_NamespaceResolution(t *testing.T) {
+	t.Run("falls back to HELM_NAMESPACE", func(t *testing.T) {
+		t.Setenv("HELM_NAMESPACE", "env-namespace")
+		getter := NewRESTClientGetter("", ClientOptions{})
+		assert.Equal(t, "env-namespace", getter.namespace)
+
+		ns, _, err := getter.ToRawKubeConfigLoader().Namespace()
+		require.NoError(t, err)
+		assert.Equal(t, "env-namespace", ns)
+	})
+
+	t.Run("explicit namespace overrides HELM_NAMESPACE", func(t *testing.T) {
+		t.Setenv("HELM_NAMESPACE", "env-namespace")
+		getter := NewRESTClientGetter("flag-namespace", ClientOptions{})
+		assert.Equal(t, "flag-namespace", getter.namespace)
+	})
+
+	t.Run("defaults to default", func(t *testing.T) {
+		t.Setenv("HELM_NAMESPACE", "")
+		getter := NewRESTClientGetter("", ClientOptions{})
+		assert.Equal(t, "default", getter.namespace)
+	})
+}
+
 func TestRESTClientGetter_ToRawKubeConfigLoader(t *testing.T) {
 	t.Run("with default settings", func(t *testing.T) {
 		// Clear environment variables
 		_ = os.Unsetenv("KUBECONFIG")
 		_ = os.Unsetenv("HELM_KUBECONTEXT")
 
-		getter := NewRESTClientGetter("default")
+		getter := NewRESTClientGetter("default", ClientOptions{})
 		loader := getter.ToRawKubeConfigLoader()
 		assert.NotNil(t, loader)
 	})
@@ -30,7 +59,7 @@ func TestRESTClientGetter_ToRawKubeConfigLoader(t *testing.T) {
 		_ = os.Setenv("KUBECONFIG", "/tmp/test-kubeconfig")
 		defer func() { _ = os.Unsetenv("KUBECONFIG") }()
 
-		getter := NewRESTClientGetter("default")
+		getter := NewRESTClientGetter("default", ClientOptions{})
 		loader := getter.ToRawKubeConfigLoader()
 		assert.NotNil(t, loader)
 	})
@@ -39,7 +68,7 @@ func TestRESTClientGetter_ToRawKubeConfigLoader(t *testing.T) {
 		_ = os.Setenv("HELM_KUBECONTEXT", "test-context")
 		defer func() { _ = os.Unsetenv("HELM_KUBECONTEXT") }()
 
-		getter := NewRESTClientGetter("default")
+		getter := NewRESTClientGetter("default", ClientOptions{})
 		loader := getter.ToRawKubeConfigLoader()
 		assert.NotNil(t, loader)
 	})
@@ -52,7 +81,7 @@ func TestRESTClientGetter_ToRawKubeConfigLoader(t *testing.T) {
 			_ = os.Unsetenv("HELM_KUBECONTEXT")
 		}()
 
-		getter := NewRESTClientGetter("custom-ns")
+		getter := NewRESTClientGetter("custom-ns", ClientOptions{})
 		loader := getter.ToRawKubeConfigLoader()
 		assert.NotNil(t, loader)
 	})
@@ -63,7 +92,7 @@ func TestRESTClientGetter_ToRESTConfig_Error(t *testing.T) {
 	_ = os.Setenv("KUBECONFIG", "/nonexistent/kubeconfig")
 	defer func() { _ = os.Unsetenv("KUBECONFIG") }()
 
-	getter := NewRESTClientGetter("default")
+	getter := NewRESTClientGetter("default", ClientOptions{})
 	_, err := getter.ToRESTConfig()
 	assert.Error(t, err)
 }
@@ -73,7 +102,7 @@ func TestRESTClientGetter_ToDiscoveryClient_Error(t *testing.T) {
 	_ = os.Setenv("KUBECONFIG", "/nonexistent/kubeconfig")
 	defer func() { _ = os.Unsetenv("KUBECONFIG") }()
 
-	getter := NewRESTClientGetter("default")
+	getter := NewRESTClientGetter("default", ClientOptions{})
 	_, err := getter.ToDiscoveryClient()
 	assert.Error(t, err)
 }
@@ -83,7 +112,7 @@ func TestRESTClientGetter_ToRESTMapper_Error(t *testing.T) {
 	_ = os.Setenv("KUBECONFIG", "/nonexistent/kubeconfig")
 	defer func() { _ = os.Unsetenv("KUBECONFIG") }()
 
-	getter := NewRESTClientGetter("default")
+	getter := NewRESTClientGetter("default", ClientOptions{})
 	_, err := getter.ToRESTMapper()
 	assert.Error(t, err)
 }
@@ -122,7 +151,7 @@ func TestRESTClientGetter_ToRESTConfig_Success(t *testing.T) {
 	_ = os.Setenv("KUBECONFIG", kubeconfigPath)
 	defer func() { _ = os.Unsetenv("KUBECONFIG") }()
 
-	getter := NewRESTClientGetter("default")
+	getter := NewRESTClientGetter("default", ClientOptions{})
 	config, err := getter.ToRESTConfig()
 	require.NoError(t, err)
 	assert.NotNil(t, config)
@@ -134,7 +163,7 @@ func TestRESTClientGetter_ToDiscoveryClient_Success(t *testing.T) {
 	_ = os.Setenv("KUBECONFIG", kubeconfigPath)
 	defer func() { _ = os.Unsetenv("KUBECONFIG") }()
 
-	getter := NewRESTClientGetter("default")
+	getter := NewRESTClientGetter("default", ClientOptions{})
 	client, err := getter.ToDiscoveryClient()
 	// This should succeed in creating the client even if there's no server
 	require.NoError(t, err)
@@ -146,9 +175,274 @@ func TestRESTClientGetter_ToRESTMapper_Success(t *testing.T) {
 	_ = os.Setenv("KUBECONFIG", kubeconfigPath)
 	defer func() { _ = os.Unsetenv("KUBECONFIG") }()
 
-	getter := NewRESTClientGetter("default")
+	getter := NewRESTClientGetter("default", ClientOptions{})
 	mapper, err := getter.ToRESTMapper()
 	// This should succeed in creating the mapper even if there's no server
 	require.NoError(t, err)
 	assert.NotNil(t, mapper)
 }
+
+// createMultiContextKubeconfig creates a kubeconfig with two contexts that
+// point at different servers, with "first" as the current context
+func createMultiContextKubeconfig(t *testing.T) string {
+	t.Helper()
+
+	kubeconfig := `apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: https://first.example.com:6443
+  name: first-cluster
+- cluster:
+    server: https://second.example.com:6443
+  name: second-cluster
+contexts:
+- context:
+    cluster: first-cluster
+    user: test-user
+  name: first
+- context:
+    cluster: second-cluster
+    user: test-user
+  name: second
+current-context: first
+users:
+- name: test-user
+  user:
+    token: test-token
+`
+	kubeconfigPath := filepath.Join(t.TempDir(), "kubeconfig")
+	require.NoError(t, os.WriteFile(kubeconfigPath, []byte(kubeconfig), 0600))
+	return kubeconfigPath
+}
+
+func TestRESTClientGetter_KubeContext(t *testing.T) {
+	_ = os.Setenv("KUBECONFIG", createMultiContextKubeconfig(t))
+	defer func() {
+		_ = os.Unsetenv("KUBECONFIG")
+		_ = os.Unsetenv("HELM_KUBECONTEXT")
+	}()
+
+	t.Run("uses current context by default", func(t *testing.T) {
+		_ = os.Unsetenv("HELM_KUBECONTEXT")
+
+		config, err := NewRESTClientGetter("default", ClientOptions{}).ToRESTConfig()
+		require.NoError(t, err)
+		assert.Equal(t, "https://first.example.com:6443", config.Host)
+	})
+
+	t.Run("uses HELM_KUBECONTEXT", func(t *testing.T) {
+		_ = os.Setenv("HELM_KUBECONTEXT", "second")
+
+		config, err := NewRESTClientGetter("default", ClientOptions{}).ToRESTConfig()
+		require.NoError(t, err)
+		assert.Equal(t, "https://second.example.com:6443", config.Host)
+	})
+
+	t.Run("option overrides HELM_KUBECONTEXT", func(t *testing.T) {
+		_ = os.Setenv("HELM_KUBECONTEXT", "second")
+
+		config, err := NewRESTClientGetter("default", ClientOptions{KubeContext: "first"}).ToRESTConfig()
+		require.NoError(t, err)
+		assert.Equal(t, "https://first.example.com:6443", config.Host)
+	})
+}
+
+func TestRESTClientGetter_KubeConfig(t *testing.T) {
+	defer func() { _ = os.Unsetenv("KUBECONFIG") }()
+
+	t.Run("option overrides KUBECONFIG", func(t *testing.T) {
+		_ = os.Setenv("KUBECONFIG", createTestKubeconfig(t))
+
+		config, err := NewRESTClientGetter("default", ClientOptions{KubeConfig: createMultiContextKubeconfig(t)}).ToRESTConfig()
+		require.NoError(t, err)
+		assert.Equal(t, "https://first.example.com:6443", config.Host)
+	})
+
+	t.Run("falls back to KUBECONFIG", func(t *testing.T) {
+		_ = os.Setenv("KUBECONFIG", createTestKubeconfig(t))
+
+		config, err := NewRESTClientGetter("default", ClientOptions{}).ToRESTConfig()
+		require.NoError(t, err)
+		assert.Equal(t, "https://127.0.0.1:6443", config.Host)
+	})
+
+	t.Run("combines with KubeContext", func(t *testing.T) {
+		_ = os.Setenv("KUBECONFIG", createTestKubeconfig(t))
+
+		config, err := NewRESTClientGetter("default", ClientOptions{
+			KubeConfig:  createMultiContextKubeconfig(t),
+			KubeContext: "second",
+		}).ToRESTConfig()
+		require.NoError(t, err)
+		assert.Equal(t, "https://second.example.com:6443", config.Host)
+	})
+
+	t.Run("missing file errors", func(t *testing.T) {
+		_ = os.Setenv("KUBECONFIG", createTestKubeconfig(t))
+
+		_, err := NewRESTClientGetter("default", ClientOptions{KubeConfig: "/nonexistent/kubeconfig"}).ToRESTConfig()
+		assert.Error(t, err)
+	})
+}
+
+func TestRESTClientGetter_HelmConnectionEnvironment(t *testing.T) {
+	t.Setenv("KUBECONFIG", createTestKubeconfig(t))
+
+	t.Run("HELM_KUBEAPISERVER overrides the server", func(t *testing.T) {
+		t.Setenv("HELM_KUBEAPISERVER", "https://api.example.com:6443")
+
+		config, err := NewRESTClientGetter("default", ClientOptions{}).ToRESTConfig()
+		require.NoError(t, err)
+		assert.Equal(t, "https://api.example.com:6443", config.Host)
+	})
+
+	t.Run("HELM_KUBETOKEN overrides the bearer token", func(t *testing.T) {
+		t.Setenv("HELM_KUBETOKEN", "env-token")
+
+		config, err := NewRESTClientGetter("default", ClientOptions{}).ToRESTConfig()
+		require.NoError(t, err)
+		assert.Equal(t, "env-token", config.BearerToken)
+	})
+
+	t.Run("HELM_KUBEASUSER and HELM_KUBEASGROUPS set impersonation", func(t *testing.T) {
+		t.Setenv("HELM_KUBEASUSER", "deployer")
+		t.Setenv("HELM_KUBEASGROUPS", "ops,release-managers")
+
+		config, err := NewRESTClientGetter("default", ClientOptions{}).ToRESTConfig()
+		require.NoError(t, err)
+		assert.Equal(t, "deployer", config.Impersonate.UserName)
+		assert.Equal(t, []string{"ops", "release-managers"}, config.Impersonate.Groups)
+	})
+
+	t.Run("kubeconfig settings apply when unset", func(t *testing.T) {
+		config, err := NewRESTClientGetter("default", ClientOptions{}).ToRESTConfig()
+		require.NoError(t, err)
+		assert.Equal(t, "https://127.0.0.1:6443", config.Host)
+		assert.Equal(t, "test-token", config.BearerToken)
+		assert.Empty(t, config.Impersonate.UserName)
+	})
+}
+
+func TestRESTClientGetter_TokenAndImpersonation(t *testing.T) {
+	t.Setenv("KUBECONFIG", createTestKubeconfig(t))
+
+	t.Run("options appear in the REST config", func(t *testing.T) {
+		getter := NewRESTClientGetter("default", ClientOptions{
+			Token:    "flag-token",
+			AsUser:   "deployer",
+			AsGroups: []string{"ops"},
+		})
+		config, err := getter.ToRESTConfig()
+		require.NoError(t, err)
+		assert.Equal(t, "flag-token", config.BearerToken)
+		assert.Equal(t, "deployer", config.Impersonate.UserName)
+		assert.Equal(t, []string{"ops"}, config.Impersonate.Groups)
+	})
+
+	t.Run("options override Helm's environment", func(t *testing.T) {
+		t.Setenv("HELM_KUBETOKEN", "env-token")
+		t.Setenv("HELM_KUBEASUSER", "env-user")
+		t.Setenv("HELM_KUBEASGROUPS", "env-group")
+
+		getter := NewRESTClientGetter("default", ClientOptions{
+			Token:    "flag-token",
+			AsUser:   "deployer",
+			AsGroups: []string{"ops", "release-managers"},
+		})
+		config, err := getter.ToRESTConfig()
+		require.NoError(t, err)
+		assert.Equal(t, "flag-token", config.BearerToken)
+		assert.Equal(t, "deployer", config.Impersonate.UserName)
+		assert.Equal(t, []string{"ops", "release-managers"}, config.Impersonate.Groups)
+	})
+}
+
+func TestRESTClientGetter_InsecureSkipTLSVerify(t *testing.T) {
+	kubeconfig := `apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: https://127.0.0.1:6443
+    certificate-authority-data: dGVzdC1jYQ==
+  name: test-cluster
+contexts:
+- context:
+    cluster: test-cluster
+    user: test-user
+  name: test-context
+current-context: test-context
+users:
+- name: test-user
+  user:
+    token: test-token
+`
+	path := filepath.Join(t.TempDir(), "kubeconfig")
+	require.NoError(t, os.WriteFile(path, []byte(kubeconfig), 0600))
+	t.Setenv("KUBECONFIG", path)
+
+	t.Run("skips verification and clears the CA", func(t *testing.T) {
+		config, err := NewRESTClientGetter("default", ClientOptions{InsecureSkipTLSVerify: true}).ToRESTConfig()
+		require.NoError(t, err)
+		assert.True(t, config.Insecure)
+		assert.Empty(t, config.CAData)
+		assert.Empty(t, config.CAFile)
+
+		_, err = rest.TransportFor(config)
+		assert.NoError(t, err)
+	})
+
+	t.Run("verifies by default", func(t *testing.T) {
+		config, err := NewRESTClientGetter("default", ClientOptions{}).ToRESTConfig()
+		require.NoError(t, err)
+		assert.False(t, config.Insecure)
+		assert.Equal(t, []byte("test-ca"), config.CAData)
+	})
+}
+
+func TestRESTClientGetter_CachesDiscoveryClient(t *testing.T) {
+	t.Setenv("KUBECONFIG", createTestKubeconfig(t))
+
+	origNewDiscoveryClient := newDiscoveryClient
+	defer func() { newDiscoveryClient = origNewDiscoveryClient }()
+	var created atomic.Int32
+	newDiscoveryClient = func(config *rest.Config) (discovery.DiscoveryInterface, error) {
+		created.Add(1)
+		return origNewDiscoveryClient(config)
+	}
+
+	getter := NewRESTClientGetter("default", ClientOptions{})
+
+	var wg sync.WaitGroup
+	clients := make([]discovery.CachedDiscoveryInterface, 8)
+	mappers := make([]meta.RESTMapper, 8)
+	for i := range clients {
+		wg.Go(func() {
+			var err error
+			clients[i], err = getter.ToDiscoveryClient()
+			assert.NoError(t, err)
+			mappers[i], err = getter.ToRESTMapper()
+			assert.NoError(t, err)
+		})
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), created.Load())
+	for i := range clients {
+		assert.Same(t, clients[0], clients[i])
+		assert.Equal(t, mappers[0], mappers[i])
+	}
+}
+
+func TestRESTClientGetter_DoesNotCacheDiscoveryFailure(t *testing.T) {
+	t.Setenv("KUBECONFIG", "/nonexistent/kubeconfig")
+	getter := NewRESTClientGetter("default", ClientOptions{})
+
+	_, err := getter.ToDiscoveryClient()
+	require.Error(t, err)
+
+	t.Setenv("KUBECONFIG", createTestKubeconfig(t))
+	client, err := getter.ToDiscoveryClient()
+	require.NoError(t, err)
+	assert.NotNil(t, client)
+}
@@ -0,0 +1,59 @@
+package status
+
+import (
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/storage/driver"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// validateLabels returns an error if any label would be rejected by
+// Kubernetes or would overwrite one of the labels Helm manages itself.
+func validateLabels(labels map[string]string) error {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if isSystemLabel(k) {
+			return fmt.Errorf("invalid label %q: reserved by Helm", k)
+		}
+		if errs := validation.IsQualifiedName(k); len(errs) > 0 {
+			return fmt.Errorf("invalid label key %q: %s", k, strings.Join(errs, "; "))
+		}
+		if errs := validation.IsValidLabelValue(labels[k]); len(errs) > 0 {
+			return fmt.Errorf("invalid value %q for label %q: %s", labels[k], k, strings.Join(errs, "; "))
+		}
+	}
+	return nil
+}
+
+// isSystemLabel reports whether key is one of the labels Helm manages itself.
+func isSystemLabel(key string) bool {
+	return driver.ContainsSystemLabels(map[string]string{key: ""})
+}
+
+// customLabels returns a copy of labels without the labels Helm manages
+// itself, which some storage drivers return alongside a release's own.
+func customLabels(labels map[string]string) map[string]string {
+	custom := maps.Clone(labels)
+	maps.DeleteFunc(custom, func(k, _ string) bool { return isSystemLabel(k) })
+	return custom
+}
+
+// replaceCustomLabels returns a copy of current with its custom labels
+// replaced by custom, keeping the labels Helm manages itself.
+func replaceCustomLabels(current, custom map[string]string) map[string]string {
+	labels := maps.Clone(current)
+	maps.DeleteFunc(labels, func(k, _ string) bool { return !isSystemLabel(k) })
+	if labels == nil {
+		return maps.Clone(custom)
+	}
+	maps.Copy(labels, custom)
+	return labels
+}
@@ -0,0 +1,173 @@
+package status
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"time"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ListOptions selects the releases List returns.
+type ListOptions struct {
+	// Selector is a label selector the releases' labels must match. An
+	// empty selector matches every release.
+	Selector string
+	// Offset is the number of matching releases to leave out, for paging
+	// through them with Limit.
+	Offset int
+	// Limit caps the number of releases returned. Zero returns every
+	// matching release.
+	Limit int
+}
+
+// List returns the latest revision of every release matching opts, in any
+// status, sorted by namespace and then name, using Helm's list action.
+// Offset and Limit are applied after sorting, so that pages are stable.
+// Releases are listed from every namespace the configuration can see; see
+// ClientOptions.AllNamespaces. A revision without info metadata cannot be
+// listed and is left out.
+func List(cfg *action.Configuration, opts ListOptions) ([]*release.Release, error) {
+	if err := checkStorage(cfg); err != nil {
+		return nil, err
+	}
+	if _, err := labels.Parse(opts.Selector); err != nil {
+		return nil, fmt.Errorf("invalid selector %q: %w", opts.Selector, err)
+	}
+	if opts.Offset < 0 || opts.Limit < 0 {
+		return nil, fmt.Errorf("invalid list offset %d or limit %d: must not be negative", opts.Offset, opts.Limit)
+	}
+
+	// The list action only uses the Kubernetes client to check that the
+	// cluster can be reached, which listing the release storage checks
+	// anyway, and cannot read a release without info metadata
+	listCfg := *cfg
+	listCfg.KubeClient = reachableClient{}
+	listCfg.Releases = &storage.Storage{Driver: infoDriver{cfg.Releases.Driver}, Log: cfg.Releases.Log}
+
+	list := action.NewList(&listCfg)
+	list.StateMask = action.ListAll | action.ListUnknown
+	list.Selector = opts.Selector
+	rels, err := list.Run()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list releases: %w", err)
+	}
+
+	sort.SliceStable(rels, func(i, j int) bool {
+		if rels[i].Namespace != rels[j].Namespace {
+			return rels[i].Namespace < rels[j].Namespace
+		}
+		return rels[i].Name < rels[j].Name
+	})
+	if opts.Offset >= len(rels) {
+		return nil, nil
+	}
+	rels = rels[opts.Offset:]
+	if opts.Limit > 0 && opts.Limit < len(rels) {
+		rels = rels[:opts.Limit]
+	}
+	return rels, nil
+}
+
+// ListReleases returns the latest revision of every release whose labels
+// match selector, sorted by namespace and then name. An empty selector
+// matches every release. It is List with only a selector.
+func ListReleases(cfg *action.Configuration, selector string) ([]*release.Release, error) {
+	return List(cfg, ListOptions{Selector: selector})
+}
+
+// reachableClient is the Kubernetes client List gives Helm's list action,
+// which reports the cluster as reachable without calling it.
+type reachableClient struct {
+	kube.Interface
+}
+
+func (reachableClient) IsReachable() error {
+	return nil
+}
+
+// infoDriver leaves the revisions without info metadata out of List calls.
+type infoDriver struct {
+	driver.Driver
+}
+
+func (d infoDriver) List(filter func(*release.Release) bool) ([]*release.Release, error) {
+	return d.Driver.List(func(rel *release.Release) bool {
+		return rel.Info != nil && filter(rel)
+	})
+}
+
+// InStatus returns the releases whose current status is one of statuses,
+// keeping their order. Stored statuses are matched as ParseStatus matches
+// them, and one Helm does not define is matched as StatusUnknown. An empty
+// statuses returns rels unchanged.
+func InStatus(rels []*release.Release, statuses []release.Status) []*release.Release {
+	if len(statuses) == 0 {
+		return rels
+	}
+
+	var matched []*release.Release
+	for _, rel := range rels {
+		if rel.Info == nil {
+			continue
+		}
+		current, _ := storedStatus(rel)
+		if slices.Contains(statuses, current) {
+			matched = append(matched, rel)
+		}
+	}
+	return matched
+}
+
+// DeployedBetween returns the releases last deployed after after and before
+// before, keeping their order. A zero time leaves that side unbounded. When a
+// bound is set, releases with no last-deployed time are left out.
+func DeployedBetween(rels []*release.Release, after, before time.Time) []*release.Release {
+	if after.IsZero() && before.IsZero() {
+		return rels
+	}
+
+	var matched []*release.Release
+	for _, rel := range rels {
+		if rel.Info == nil || rel.Info.LastDeployed.IsZero() {
+			continue
+		}
+		deployed := rel.Info.LastDeployed.Time
+		if !after.IsZero() && !deployed.After(after) {
+			continue
+		}
+		if !before.IsZero() && !deployed.Before(before) {
+			continue
+		}
+		matched = append(matched, rel)
+	}
+	return matched
+}
+
+// MatchReleaseNames returns the names of the releases whose name matches the
+// shell glob pattern, using the syntax of path.Match, sorted by name.
+func MatchReleaseNames(cfg *action.Configuration, pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+
+	rels, err := ListReleases(cfg, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, rel := range rels {
+		if ok, _ := path.Match(pattern, rel.Name); ok {
+			names = append(names, rel.Name)
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,310 @@
+package status
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	helmtime "helm.sh/helm/v3/pkg/time"
+)
+
+func TestListReleases(t *testing.T) {
+	store := storage.Init(driver.NewMemory())
+
+	for _, r := range []struct {
+		name    string
+		version int
+		labels  map[string]string
+	}{
+		{"frontend", 1, map[string]string{"app": "frontend"}},
+		{"frontend", 2, map[string]string{"app": "frontend", "tier": "web"}},
+		{"backend", 1, map[string]string{"app": "backend"}},
+		{"admin", 1, map[string]string{"app": "frontend"}},
+		{"unlabeled", 1, nil},
+	} {
+		rel := &release.Release{
+			Name:      r.name,
+			Namespace: "default",
+			Version:   r.version,
+			Labels:    r.labels,
+			Info: &release.Info{
+				Status: release.StatusDeployed,
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}
+		require.NoError(t, store.Create(rel))
+	}
+
+	cfg := &action.Configuration{Releases: store}
+
+	names := func(rels []*release.Release) []string {
+		var out []string
+		for _, rel := range rels {
+			out = append(out, rel.Name)
+		}
+		return out
+	}
+
+	t.Run("empty selector matches all releases", func(t *testing.T) {
+		rels, err := ListReleases(cfg, "")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"admin", "backend", "frontend", "unlabeled"}, names(rels))
+	})
+
+	t.Run("returns latest revision of matching releases", func(t *testing.T) {
+		rels, err := ListReleases(cfg, "app=frontend")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"admin", "frontend"}, names(rels))
+		assert.Equal(t, 2, rels[1].Version)
+	})
+
+	t.Run("matches labels of the latest revision only", func(t *testing.T) {
+		rels, err := ListReleases(cfg, "tier=web")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"frontend"}, names(rels))
+	})
+
+	t.Run("returns no releases when nothing matches", func(t *testing.T) {
+		rels, err := ListReleases(cfg, "app=missing")
+		require.NoError(t, err)
+		assert.Empty(t, rels)
+	})
+
+	t.Run("rejects invalid selector", func(t *testing.T) {
+		_, err := ListReleases(cfg, "app in (")
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid selector")
+	})
+}
+
+// failingListDriver wraps a memory driver but fails on List
+type failingListDriver struct {
+	*driver.Memory
+}
+
+func (f *failingListDriver) List(func(*release.Release) bool) ([]*release.Release, error) {
+	return nil, errors.New("list failed")
+}
+
+func TestListReleases_ListError(t *testing.T) {
+	cfg := &action.Configuration{Releases: storage.Init(&failingListDriver{Memory: driver.NewMemory()})}
+
+	_, err := ListReleases(cfg, "")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to list releases")
+}
+
+func TestListReleases_AllNamespaces(t *testing.T) {
+	mem := driver.NewMemory()
+	store := storage.Init(mem)
+
+	for _, r := range []struct {
+		name      string
+		namespace string
+		version   int
+	}{
+		{"frontend", "staging", 1},
+		{"frontend", "production", 1},
+		{"frontend", "production", 2},
+		{"backend", "production", 1},
+	} {
+		require.NoError(t, store.Create(&release.Release{
+			Name:      r.name,
+			Namespace: r.namespace,
+			Version:   r.version,
+			Labels:    map[string]string{"app": r.name},
+			Info:      &release.Info{Status: release.StatusDeployed},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}))
+	}
+	mem.SetNamespace("")
+
+	rels, err := ListReleases(&action.Configuration{Releases: store}, "app=frontend")
+	require.NoError(t, err)
+	require.Len(t, rels, 2)
+	assert.Equal(t, "production", rels[0].Namespace)
+	assert.Equal(t, 2, rels[0].Version)
+	assert.Equal(t, "staging", rels[1].Namespace)
+	assert.Equal(t, "frontend", rels[1].Name)
+}
+
+func TestList(t *testing.T) {
+	mem := driver.NewMemory()
+	store := storage.Init(mem)
+	for _, r := range []struct {
+		name      string
+		namespace string
+		status    release.Status
+		labels    map[string]string
+	}{
+		{"web-b", "staging", release.StatusDeployed, map[string]string{"tier": "web"}},
+		{"web-a", "staging", release.StatusPendingUpgrade, map[string]string{"tier": "web"}},
+		{"web-a", "production", release.StatusUnknown, map[string]string{"tier": "web"}},
+		{"web-c", "production", release.Status("DEPLOYED"), map[string]string{"tier": "web"}},
+		{"db", "production", release.StatusSuperseded, map[string]string{"tier": "data"}},
+	} {
+		require.NoError(t, store.Create(&release.Release{
+			Name:      r.name,
+			Namespace: r.namespace,
+			Version:   1,
+			Labels:    r.labels,
+			Info:      &release.Info{Status: r.status},
+		}))
+	}
+	// The memory driver stores the release itself, so clearing its info
+	// afterwards leaves a revision without info metadata in storage
+	broken := &release.Release{Name: "broken", Namespace: "production", Version: 1, Labels: map[string]string{"tier": "web"}, Info: &release.Info{}}
+	require.NoError(t, store.Create(broken))
+	broken.Info = nil
+	mem.SetNamespace("")
+	cfg := &action.Configuration{Releases: store}
+
+	refs := func(rels []*release.Release) []string {
+		var out []string
+		for _, rel := range rels {
+			out = append(out, rel.Namespace+"/"+rel.Name)
+		}
+		return out
+	}
+
+	t.Run("lists releases in every status, leaving out those without info", func(t *testing.T) {
+		rels, err := List(cfg, ListOptions{Selector: "tier=web"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"production/web-a", "production/web-c", "staging/web-a", "staging/web-b"}, refs(rels))
+	})
+
+	t.Run("pages through the releases", func(t *testing.T) {
+		var pages [][]string
+		for offset := 0; ; offset += 2 {
+			rels, err := List(cfg, ListOptions{Selector: "tier=web", Offset: offset, Limit: 2})
+			require.NoError(t, err)
+			if len(rels) == 0 {
+				break
+			}
+			pages = append(pages, refs(rels))
+		}
+		assert.Equal(t, [][]string{
+			{"production/web-a", "production/web-c"},
+			{"staging/web-a", "staging/web-b"},
+		}, pages)
+	})
+
+	t.Run("rejects a negative limit", func(t *testing.T) {
+		_, err := List(cfg, ListOptions{Limit: -1})
+		assert.EqualError(t, err, "invalid list offset 0 or limit -1: must not be negative")
+	})
+}
+
+func TestMatchReleaseNames(t *testing.T) {
+	store := storage.Init(driver.NewMemory())
+	for _, name := range []string{"frontend-a", "frontend-b", "backend", "frontend"} {
+		require.NoError(t, store.Create(&release.Release{
+			Name:      name,
+			Namespace: "default",
+			Version:   1,
+			Info:      &release.Info{Status: release.StatusDeployed},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}))
+	}
+	cfg := &action.Configuration{Releases: store}
+
+	t.Run("matches a pattern", func(t *testing.T) {
+		names, err := MatchReleaseNames(cfg, "frontend-*")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"frontend-a", "frontend-b"}, names)
+	})
+
+	t.Run("matches a literal name", func(t *testing.T) {
+		names, err := MatchReleaseNames(cfg, "backend")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"backend"}, names)
+	})
+
+	t.Run("returns nothing when no release matches", func(t *testing.T) {
+		names, err := MatchReleaseNames(cfg, "worker-?")
+		require.NoError(t, err)
+		assert.Empty(t, names)
+	})
+
+	t.Run("rejects a malformed pattern", func(t *testing.T) {
+		_, err := MatchReleaseNames(cfg, "frontend-[")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `invalid pattern "frontend-["`)
+	})
+}
+
+func TestDeployedBetween(t *testing.T) {
+	base := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	newRelease := func(name string, deployed time.Time) *release.Release {
+		return &release.Release{Name: name, Info: &release.Info{LastDeployed: helmtime.Time{Time: deployed}}}
+	}
+	rels := []*release.Release{
+		newRelease("january", base.AddDate(0, -5, 0)),
+		newRelease("may", base.AddDate(0, -1, 0)),
+		newRelease("june", base),
+		newRelease("july", base.AddDate(0, 1, 0)),
+		newRelease("never", time.Time{}),
+		{Name: "no-info"},
+	}
+	names := func(rels []*release.Release) []string {
+		var names []string
+		for _, rel := range rels {
+			names = append(names, rel.Name)
+		}
+		return names
+	}
+
+	assert.Equal(t, rels, DeployedBetween(rels, time.Time{}, time.Time{}))
+	assert.Equal(t, []string{"january", "may"}, names(DeployedBetween(rels, time.Time{}, base)))
+	assert.Equal(t, []string{"july"}, names(DeployedBetween(rels, base, time.Time{})))
+	assert.Equal(t, []string{"may", "june"}, names(DeployedBetween(rels, base.AddDate(0, -2, 0), base.AddDate(0, 0, 1))))
+	assert.Empty(t, DeployedBetween(rels, base, base))
+}
+
+func TestInStatus(t *testing.T) {
+	newRelease := func(name string, st release.Status) *release.Release {
+		return &release.Release{Name: name, Info: &release.Info{Status: st}}
+	}
+	rels := []*release.Release{
+		newRelease("deployed", release.StatusDeployed),
+		newRelease("failed", release.StatusFailed),
+		newRelease("helm2", "PENDING_UPGRADE"),
+		newRelease("bogus", "bogus"),
+		{Name: "no-info"},
+	}
+	names := func(rels []*release.Release) []string {
+		var names []string
+		for _, rel := range rels {
+			names = append(names, rel.Name)
+		}
+		return names
+	}
+
+	assert.Equal(t, rels, InStatus(rels, nil))
+	assert.Equal(t, []string{"failed"}, names(InStatus(rels, []release.Status{release.StatusFailed})))
+	assert.Equal(t, []string{"deployed", "helm2"}, names(InStatus(rels, []release.Status{release.StatusPendingUpgrade, release.StatusDeployed})))
+	assert.Equal(t, []string{"bogus"}, names(InStatus(rels, []release.Status{release.StatusUnknown})))
+	assert.Empty(t, InStatus(rels, []release.Status{release.StatusUninstalled}))
+}
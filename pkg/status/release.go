@@ -2,7 +2,9 @@ package status
 
 import (
 	"fmt"
+	"strings"
 
+	"helm.sh/helm/v3/pkg/chartutil"
 	"helm.sh/helm/v3/pkg/release"
 )
 
@@ -18,39 +20,119 @@ var ValidStatuses = []string{
 	"pending-rollback",
 }
 
+// PendingStatuses lists the statuses Helm gives a release while an install,
+// upgrade, or rollback is in progress. A release left in one of them after
+// the operation was interrupted blocks further upgrades.
+var PendingStatuses = []release.Status{
+	release.StatusPendingInstall,
+	release.StatusPendingUpgrade,
+	release.StatusPendingRollback,
+}
+
+// validStatusSet maps each valid status value to its release.Status.
+var validStatusSet = map[string]release.Status{
+	"unknown":          release.StatusUnknown,
+	"deployed":         release.StatusDeployed,
+	"superseded":       release.StatusSuperseded,
+	"failed":           release.StatusFailed,
+	"uninstalling":     release.StatusUninstalling,
+	"pending-install":  release.StatusPendingInstall,
+	"pending-upgrade":  release.StatusPendingUpgrade,
+	"pending-rollback": release.StatusPendingRollback,
+}
+
+// helmStatusSet maps every status Helm defines to its release.Status: the
+// valid statuses, and "uninstalled", which Helm stores when a release is
+// uninstalled with --keep-history but which cannot be set.
+var helmStatusSet = map[string]release.Status{
+	"unknown":          release.StatusUnknown,
+	"deployed":         release.StatusDeployed,
+	"uninstalled":      release.StatusUninstalled,
+	"superseded":       release.StatusSuperseded,
+	"failed":           release.StatusFailed,
+	"uninstalling":     release.StatusUninstalling,
+	"pending-install":  release.StatusPendingInstall,
+	"pending-upgrade":  release.StatusPendingUpgrade,
+	"pending-rollback": release.StatusPendingRollback,
+}
+
+// normalizeStatus lowercases s, trims surrounding whitespace, and replaces
+// underscores with hyphens.
+func normalizeStatus(s string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(s)), "_", "-")
+}
+
+// storedStatus returns the status recorded in rel's info. A status stored in
+// another spelling, such as Helm 2's "DEPLOYED" or "PENDING_UPGRADE", is
+// matched as ParseStatus matches it, and "uninstalled" is recognized too. A
+// status that is not one Helm defines is reported as StatusUnknown, with
+// recognized false.
+func storedStatus(rel *release.Release) (status release.Status, recognized bool) {
+	status, recognized = helmStatusSet[normalizeStatus(rel.Info.Status.String())]
+	if !recognized {
+		return release.StatusUnknown, false
+	}
+	return status, true
+}
+
+// IsValidStatus reports whether s names a valid Helm release status, using
+// the same matching rules as ParseStatus.
+func IsValidStatus(s string) bool {
+	_, ok := validStatusSet[normalizeStatus(s)]
+	return ok
+}
+
 // ParseStatus converts a string to a release.Status.
+// Matching is case-insensitive and ignores leading and trailing whitespace.
+// Underscores are accepted in place of hyphens (e.g. "pending_install").
 // Returns an error if the status string is not valid.
 func ParseStatus(s string) (release.Status, error) {
-	switch s {
-	case "unknown":
-		return release.StatusUnknown, nil
-	case "deployed":
-		return release.StatusDeployed, nil
-	case "superseded":
-		return release.StatusSuperseded, nil
-	case "failed":
-		return release.StatusFailed, nil
-	case "uninstalling":
-		return release.StatusUninstalling, nil
-	case "pending-install":
-		return release.StatusPendingInstall, nil
-	case "pending-upgrade":
-		return release.StatusPendingUpgrade, nil
-	case "pending-rollback":
-		return release.StatusPendingRollback, nil
-	default:
-		return release.StatusUnknown, fmt.Errorf("invalid status: %s", s)
+	if status, ok := validStatusSet[normalizeStatus(s)]; ok {
+		return status, nil
+	}
+	return release.StatusUnknown, fmt.Errorf("invalid status: %s", strings.ToLower(strings.TrimSpace(s)))
+}
+
+// ParseStatusStrict is like ParseStatus, but also returns an error for
+// "unknown", which is not a status Helm can act on. Use it where a release
+// should never be set to unknown by mistake.
+func ParseStatusStrict(s string) (release.Status, error) {
+	status, err := ParseStatus(s)
+	if err != nil {
+		return status, err
+	}
+	if status == release.StatusUnknown {
+		return status, fmt.Errorf("invalid status: %s is not allowed", release.StatusUnknown)
+	}
+	return status, nil
+}
+
+// StatusString returns the canonical name of every status Helm defines, the
+// inverse of ParseStatus for those in ValidStatuses. A status Helm does not
+// define is reported as "unknown".
+func StatusString(s release.Status) string {
+	if _, ok := helmStatusSet[s.String()]; ok {
+		return s.String()
 	}
+	return release.StatusUnknown.String()
 }
 
 // ValidStatusesString returns a comma-separated string of valid status values.
 func ValidStatusesString() string {
-	result := ""
-	for i, s := range ValidStatuses {
-		if i > 0 {
-			result += ", "
-		}
-		result += s
+	return ValidStatusesJoined(", ")
+}
+
+// ValidStatusesJoined returns the valid status values joined by sep, in the
+// order they are listed in ValidStatuses.
+func ValidStatusesJoined(sep string) string {
+	return strings.Join(ValidStatuses, sep)
+}
+
+// validateReleaseName returns an InvalidReleaseNameError if name is empty,
+// is not a valid DNS-1123 subdomain, or is longer than Helm allows.
+func validateReleaseName(name string) error {
+	if err := chartutil.ValidateReleaseName(name); err != nil {
+		return &InvalidReleaseNameError{ReleaseName: name, Err: err}
 	}
-	return result
+	return nil
 }
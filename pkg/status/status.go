@@ -1,13 +1,36 @@
 package status
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"maps"
+	"sync"
+	"time"
 
+	"github.com/Masterminds/semver/v3"
 	"helm.sh/helm/v3/pkg/action"
 	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
 	helmtime "helm.sh/helm/v3/pkg/time"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 )
 
+// ErrStorageNotConfigured is returned when the action.Configuration passed
+// in is nil or has no Releases storage, as happens when it was built by hand
+// rather than with NewConfiguration or action.Configuration.Init.
+var ErrStorageNotConfigured = errors.New("storage not configured")
+
+// checkStorage returns ErrStorageNotConfigured if cfg cannot be used to read
+// or write releases.
+func checkStorage(cfg *action.Configuration) error {
+	if cfg == nil || cfg.Releases == nil {
+		return ErrStorageNotConfigured
+	}
+	return nil
+}
+
 // ReleaseNotFoundError is returned when a release is not found in storage.
 type ReleaseNotFoundError struct {
 	ReleaseName string
@@ -17,15 +40,157 @@ func (e *ReleaseNotFoundError) Error() string {
 	return fmt.Sprintf("release %q not found", e.ReleaseName)
 }
 
+// RevisionNotFoundError is returned when a specific revision of a release is
+// not found in storage.
+type RevisionNotFoundError struct {
+	ReleaseName string
+	Revision    int
+}
+
+func (e *RevisionNotFoundError) Error() string {
+	return fmt.Sprintf("release %q revision %d not found", e.ReleaseName, e.Revision)
+}
+
+// InvalidReleaseNameError is returned when a release name is empty or breaks
+// Helm's naming rules, before storage is consulted.
+type InvalidReleaseNameError struct {
+	ReleaseName string
+	Err         error
+}
+
+func (e *InvalidReleaseNameError) Error() string {
+	return fmt.Sprintf("invalid release name %q: %v", e.ReleaseName, e.Err)
+}
+
+func (e *InvalidReleaseNameError) Unwrap() error {
+	return e.Err
+}
+
+// ConflictError is returned when a release is modified by another process
+// between being read and being written, on every one of Attempts tries.
+type ConflictError struct {
+	ReleaseName string
+	Revision    int
+	Attempts    int
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("release %q revision %d was modified by another process while its status was being set (%d attempts)",
+		e.ReleaseName, e.Revision, e.Attempts)
+}
+
+// BeforeUpdateError is returned when SetStatusOptions.BeforeUpdate fails,
+// aborting the change before anything is written.
+type BeforeUpdateError struct {
+	ReleaseName string
+	Revision    int
+	Err         error
+}
+
+func (e *BeforeUpdateError) Error() string {
+	return fmt.Sprintf("update of release %q revision %d aborted: %v", e.ReleaseName, e.Revision, e.Err)
+}
+
+func (e *BeforeUpdateError) Unwrap() error {
+	return e.Err
+}
+
+// OutcomeUnknownError is returned when ctx is done while SetStatus is
+// writing the new status, so that it cannot tell whether the write took
+// effect. Callers should treat the release as possibly changed.
+type OutcomeUnknownError struct {
+	ReleaseName string
+	Err         error
+}
+
+func (e *OutcomeUnknownError) Error() string {
+	return fmt.Sprintf("stopped setting status of release %s while it was being written, so it may or may not have changed: %v", e.ReleaseName, e.Err)
+}
+
+func (e *OutcomeUnknownError) Unwrap() error {
+	return e.Err
+}
+
+// MissingInfoError is returned when a stored release has no info metadata,
+// as happens with a corrupt or partially written storage entry. Its status
+// cannot be read or set.
+type MissingInfoError struct {
+	ReleaseName string
+	Revision    int
+}
+
+func (e *MissingInfoError) Error() string {
+	return fmt.Sprintf("release %q revision %d has no info metadata", e.ReleaseName, e.Revision)
+}
+
+// ChartMismatchError is returned when the release was not installed from
+// the chart the caller expected, as happens when a release name is reused
+// for something else. ExpectedChart is set when ExpectChart did not match the
+// chart's name; ExpectedVersion is set when ExpectChartVersion did not match
+// its version, Version. Chart is empty if the release records no chart.
+type ChartMismatchError struct {
+	ReleaseName     string
+	Revision        int
+	Chart           string
+	ExpectedChart   string
+	Version         string
+	ExpectedVersion string
+}
+
+func (e *ChartMismatchError) Error() string {
+	chart := fmt.Sprintf("chart %q", e.Chart)
+	if e.Chart == "" {
+		chart = "no chart"
+	}
+	if e.ExpectedVersion != "" {
+		return fmt.Sprintf("release %q revision %d has %s version %q, which does not match %q", e.ReleaseName, e.Revision, chart, e.Version, e.ExpectedVersion)
+	}
+	return fmt.Sprintf("release %q revision %d has %s, expected %q", e.ReleaseName, e.Revision, chart, e.ExpectedChart)
+}
+
+// now returns the current time. Tests replace it to fix the clock.
+var now = time.Now
+
 // PreconditionError is returned when a precondition check fails.
+// AllowedStatuses is set when the current status is not in the --from list;
+// DisallowedStatuses is set when it is in the --not-from list; MinAge is set
+// when the release was last deployed more recently than --if-older-than.
+// TargetStatus is the status the caller was trying to set, and ReleaseName
+// and Revision identify the release revision that was checked.
+// CurrentDescription is the description stored on that revision, which often
+// carries the reason Helm gave for its current status.
 type PreconditionError struct {
-	CurrentStatus   release.Status
-	AllowedStatuses []release.Status
+	ReleaseName        string
+	Revision           int
+	CurrentStatus      release.Status
+	CurrentDescription string
+	TargetStatus       release.Status
+	AllowedStatuses    []release.Status
+	DisallowedStatuses []release.Status
+	MinAge             time.Duration
+	LastDeployed       time.Time
 }
 
 func (e *PreconditionError) Error() string {
-	return fmt.Sprintf("current status %q is not in allowed list: %v",
-		e.CurrentStatus, statusListToStrings(e.AllowedStatuses))
+	var reason string
+	switch {
+	case e.MinAge > 0:
+		reason = fmt.Sprintf("release was last deployed at %s, less than %s ago",
+			e.LastDeployed.Format(time.RFC3339), e.MinAge)
+	case len(e.DisallowedStatuses) > 0:
+		reason = fmt.Sprintf("current status %q in disallowed list %v",
+			e.CurrentStatus, statusListToStrings(e.DisallowedStatuses))
+	default:
+		reason = fmt.Sprintf("current status %q not in allowed list %v",
+			e.CurrentStatus, statusListToStrings(e.AllowedStatuses))
+	}
+	if e.TargetStatus != "" {
+		reason = fmt.Sprintf("%s; cannot set to %q", reason, e.TargetStatus)
+	}
+	if e.CurrentDescription != "" {
+		reason = fmt.Sprintf("%s (release description: %q)", reason, e.CurrentDescription)
+	}
+	return reason
 }
 
 // statusListToStrings converts a slice of release.Status to a slice of strings.
@@ -37,55 +202,610 @@ func statusListToStrings(statuses []release.Status) []string {
 	return result
 }
 
-// SetStatus sets the status of a Helm release.
-// If revision is 0, it updates the latest release.
-// If revision is > 0, it updates that specific revision.
-// If allowedFromStatuses is non-empty, the status change only proceeds if
-// the current release status is in the allowed list.
-func SetStatus(cfg *action.Configuration, releaseName string, status release.Status, revision int, allowedFromStatuses []release.Status) error {
-	var rel *release.Release
-	var err error
+// Result describes the outcome of a SetStatus call.
+type Result struct {
+	// PreviousStatus is the status the release had before the change.
+	PreviousStatus release.Status
+	// Status is the status the release was set to.
+	Status release.Status
+	// Changed is false when the release already had the target status and
+	// nothing was written. When SetStatus returns an error, Changed reports
+	// whether the release may have been written regardless, as when the
+	// write succeeded but waiting for it to be read back did not.
+	Changed bool
+	// Skipped is true when SkipUnmetPreconditions is set and the release did
+	// not meet its preconditions, so nothing was written. SkipReason
+	// describes the unmet precondition.
+	Skipped    bool
+	SkipReason string
+	// UnrecognizedStatus is the status stored on the release when it is not
+	// one Helm defines, as may be left by an older Helm version. The release
+	// is then treated as having StatusUnknown, which PreviousStatus reports
+	// and preconditions are checked against.
+	UnrecognizedStatus string
+	// Release is the release revision as persisted by the change, so that
+	// callers need not read it back. When nothing was written, because the
+	// release already had the target status or DryRun is set, it is the
+	// release as read from storage. Callers must not modify it.
+	Release *release.Release
+	// PreviousRelease is the release revision as read before the change,
+	// which RestoreStatus can write back to undo it. Callers must not modify
+	// it.
+	PreviousRelease *release.Release
+}
+
+// containsStatus reports whether status is in statuses.
+func containsStatus(statuses []release.Status, status release.Status) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// getRelease fetches a release from storage.
+// If revision is 0, it returns the latest release.
+// If revision is > 0, it returns that specific revision.
+// If revision is < 0, it counts back from the latest stored revision, so -1
+// returns the revision before the latest.
+// The release name is validated before storage is consulted, and a
+// MissingInfoError is returned for a stored release without info metadata.
+func getRelease(cfg *action.Configuration, releaseName string, revision int) (*release.Release, error) {
+	if err := checkStorage(cfg); err != nil {
+		return nil, err
+	}
+	rel, err := lookupRelease(cfg, releaseName, revision)
+	if err != nil {
+		return nil, err
+	}
+	if rel.Info == nil {
+		return nil, &MissingInfoError{ReleaseName: releaseName, Revision: rel.Version}
+	}
+	return rel, nil
+}
+
+// lookupRelease fetches a release from storage as described by getRelease,
+// without checking its info metadata.
+func lookupRelease(cfg *action.Configuration, releaseName string, revision int) (*release.Release, error) {
+	if err := validateReleaseName(releaseName); err != nil {
+		return nil, err
+	}
+
+	if revision < 0 {
+		revisions, err := History(cfg, releaseName)
+		if err != nil {
+			return nil, err
+		}
+		i := len(revisions) - 1 + revision
+		if i < 0 {
+			return nil, fmt.Errorf("revision offset %d is out of range: release %q has %d revisions", revision, releaseName, len(revisions))
+		}
+		return revisions[i], nil
+	}
 
 	if revision > 0 {
 		// Get specific revision
-		rel, err = cfg.Releases.Get(releaseName, revision)
-		if err != nil {
-			return fmt.Errorf("failed to get release %s revision %d: %w", releaseName, revision, err)
+		rel, err := cfg.Releases.Get(releaseName, revision)
+		if errors.Is(err, driver.ErrReleaseNotFound) {
+			return nil, &RevisionNotFoundError{ReleaseName: releaseName, Revision: revision}
 		}
-	} else {
-		// Get latest release from storage
-		rel, err = cfg.Releases.Last(releaseName)
 		if err != nil {
-			return &ReleaseNotFoundError{ReleaseName: releaseName}
+			return nil, fmt.Errorf("failed to get release %s revision %d: %w", releaseName, revision, err)
 		}
+		return rel, nil
+	}
+
+	// Get latest release from storage
+	rel, err := cfg.Releases.Last(releaseName)
+	if err != nil {
+		return nil, &ReleaseNotFoundError{ReleaseName: releaseName}
+	}
+	return rel, nil
+}
+
+// GetStatus returns the current status of a Helm release.
+// If revision is 0, it reads the latest release.
+// If revision is > 0, it reads that specific revision.
+// If revision is < 0, it reads the revision that many before the latest.
+func GetStatus(cfg *action.Configuration, releaseName string, revision int) (release.Status, error) {
+	rel, err := getRelease(cfg, releaseName, revision)
+	if err != nil {
+		return release.StatusUnknown, err
 	}
+	return rel.Info.Status, nil
+}
+
+// SetStatusOptions configures a SetStatus call. The zero value updates the
+// latest revision unconditionally, replaces its description and refreshes
+// its last deployed time.
+type SetStatusOptions struct {
+	// Revision selects the revision to update. Zero updates the latest
+	// release, and a negative value counts back from it (-1 is the revision
+	// before the latest).
+	Revision int
+	// AllowedFromStatuses, if non-empty, only allows the change when the
+	// current release status is in the list.
+	AllowedFromStatuses []release.Status
+	// DisallowedFromStatuses, if non-empty, blocks the change when the current
+	// release status is in the list. Both lists must pass when both are set.
+	DisallowedFromStatuses []release.Status
+	// DryRun looks up the release and checks preconditions without writing
+	// anything to storage.
+	DryRun bool
+	// Description overrides the default "status set to <status>" text
+	// recorded in the release info. It is rendered as a text/template with
+	// DescriptionData, so it may refer to {{.Status}}, {{.Previous}},
+	// {{.Revision}}, and {{.Time}}.
+	Description string
+	// AppendDescription keeps the release's existing description and adds
+	// the new one on a timestamped line below it, instead of replacing it.
+	AppendDescription bool
+	// KeepLastDeployed leaves the release's LastDeployed timestamp untouched
+	// instead of setting it to the current time.
+	KeepLastDeployed bool
+	// WaitTimeout, if positive, re-reads the release after the update until it
+	// reports the new status, failing if that takes longer than WaitTimeout.
+	WaitTimeout time.Duration
+	// WaitMaxInterval caps the delay between read-backs while waiting, which
+	// otherwise doubles after each read. Zero uses DefaultWaitMaxInterval.
+	WaitMaxInterval time.Duration
+	// ForceWrite writes the release even when it already has the target
+	// status, refreshing its description and timestamp.
+	ForceWrite bool
+	// IfOlderThan, if positive, only allows the change when the release was
+	// last deployed at least this long ago, so that a release recently
+	// modified by another process is left alone. A release with no
+	// LastDeployed time always passes.
+	IfOlderThan time.Duration
+	// Force skips every precondition and transition check, setting the status
+	// whatever the release's current state.
+	Force bool
+	// SkipUnmetPreconditions reports a release that does not meet
+	// AllowedFromStatuses, DisallowedFromStatuses, or IfOlderThan as skipped
+	// in the Result instead of returning a PreconditionError.
+	SkipUnmetPreconditions bool
+	// StrictTransitions rejects status changes that do not follow Helm's
+	// release lifecycle with an InvalidTransitionError.
+	StrictTransitions bool
+	// SafeMode refuses to set a failed release to deployed, which would hide
+	// a real failure, with a MaskedFailureError. Force bypasses it, like the
+	// other checks.
+	SafeMode bool
+	// ExpectChart, if set, refuses to change a release whose chart has a
+	// different name, with a ChartMismatchError. It guards against acting on
+	// a release name that now belongs to a different chart, so unlike the
+	// other checks it applies even with Force.
+	ExpectChart string
+	// ExpectChartVersion, if set, refuses to change a release whose chart
+	// version does not match it, with a ChartMismatchError. It is an exact
+	// version such as 1.2.0 or a semver constraint such as ">=1.2.0, <2.0.0",
+	// checked with ParseChartVersionConstraint, and like ExpectChart it
+	// applies even with Force.
+	ExpectChartVersion string
+	// Labels are merged into the release's labels when it is written. Helm
+	// stores release labels alongside the release, so they can record who
+	// changed the status and when. Helm's own labels (name, owner, status,
+	// version, modifiedAt) cannot be set.
+	Labels map[string]string
+	// RemoveLabels are removed from the release's labels when it is written,
+	// after Labels are merged, as when undoing a change that set them. Helm's
+	// own labels cannot be removed.
+	RemoveLabels []string
+	// MaxRetries is how many times the read-modify-write is retried when the
+	// release is modified by another process between being read and being
+	// written. Once the retries are used up a ConflictError is returned.
+	MaxRetries int
+	// RetryOnConflict also retries, within MaxRetries, an update that the
+	// storage backend rejects with a Kubernetes conflict error because the
+	// stored object changed after it was read.
+	RetryOnConflict bool
+	// RetryDelay is how long to wait before each retry. Zero retries at once.
+	RetryDelay time.Duration
+	// BeforeUpdate, if set, is called with the release as read once a change
+	// is due, just before the new status is written. It is not called for a
+	// release that is skipped, already has the status, or under DryRun, and
+	// is called at most once even if the update is retried. An error aborts
+	// the change and is returned as a *BeforeUpdateError.
+	BeforeUpdate func(ctx context.Context, rel *release.Release, status release.Status) error
+	// Logger receives debug-level messages describing each step. A nil
+	// Logger discards them.
+	Logger *slog.Logger
+}
 
-	// Check precondition if allowedFromStatuses is specified
-	if len(allowedFromStatuses) > 0 {
-		currentStatus := rel.Info.Status
-		allowed := false
-		for _, s := range allowedFromStatuses {
-			if currentStatus == s {
-				allowed = true
-				break
+// SetStatus sets the status of a Helm release and returns a Result recording
+// the status it had before the change. Nothing is read or written if ctx is
+// already cancelled, and nothing is written once ctx is done. SetStatus
+// returns as soon as ctx is done, even if a storage call is blocked. Helm
+// storage calls cannot be interrupted, so if the write is under way by then,
+// SetStatus returns an *OutcomeUnknownError with Changed set in the Result,
+// and the write may still complete after SetStatus has returned.
+//
+// SetStatus is safe for concurrent use, including with the same cfg and
+// release. It never modifies a release as returned by storage, only a copy of
+// it, so callers sharing a storage driver that hands out stored releases, such
+// as the memory driver, do not race on them. Helm storage has no
+// compare-and-swap, so concurrent writes to the same release are only
+// detected as described for MaxRetries, and the last write wins.
+func SetStatus(ctx context.Context, cfg *action.Configuration, releaseName string, status release.Status, opts SetStatusOptions) (Result, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	logger = logger.With("release", releaseName)
+
+	if err := checkStorage(cfg); err != nil {
+		return Result{}, err
+	}
+	if err := validateLabels(opts.Labels); err != nil {
+		return Result{}, err
+	}
+	for _, k := range opts.RemoveLabels {
+		if isSystemLabel(k) {
+			return Result{}, fmt.Errorf("invalid label %q: reserved by Helm", k)
+		}
+	}
+	if err := ValidateDescription(opts.Description); err != nil {
+		return Result{}, err
+	}
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+
+	type outcome struct {
+		result Result
+		err    error
+	}
+	done := make(chan outcome, 1)
+	gate := &writeGate{}
+	go func() {
+		result, err := setStatus(ctx, cfg, releaseName, status, opts, logger, gate)
+		done <- outcome{result: result, err: err}
+	}()
+
+	select {
+	case o := <-done:
+		return o.result, o.err
+	case <-ctx.Done():
+		if gate.close() {
+			return Result{Status: status, Changed: true}, &OutcomeUnknownError{ReleaseName: releaseName, Err: ctx.Err()}
+		}
+		return Result{}, fmt.Errorf("stopped setting status of release %s: %w", releaseName, ctx.Err())
+	}
+}
+
+// writeGate decides between SetStatus giving up when ctx is done and
+// setStatus starting to write, so that SetStatus knows whether a write may be
+// under way when it returns.
+type writeGate struct {
+	mu      sync.Mutex
+	closed  bool
+	writing bool
+}
+
+// open reports whether a write may start, and if so records that one has.
+// No write may start once ctx is done or the gate is closed.
+func (g *writeGate) open(ctx context.Context) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed || ctx.Err() != nil {
+		return false
+	}
+	g.writing = true
+	return true
+}
+
+// close stops any further write from starting and reports whether one
+// already has.
+func (g *writeGate) close() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.closed = true
+	return g.writing
+}
+
+// setStatus performs the read-modify-write for SetStatus, retrying when the
+// release is modified concurrently. It writes only once gate opens, checking
+// ctx after the release is read.
+func setStatus(ctx context.Context, cfg *action.Configuration, releaseName string, status release.Status, opts SetStatusOptions, logger *slog.Logger, gate *writeGate) (Result, error) {
+	beforeUpdate := opts.BeforeUpdate
+	for attempt := 1; ; attempt++ {
+		rel, result, done, err := prepareUpdate(cfg, releaseName, status, opts, logger)
+		if done || err != nil {
+			return result, err
+		}
+
+		if beforeUpdate != nil {
+			logger.Debug("running before-update callback", "revision", rel.Version)
+			if err := beforeUpdate(ctx, rel, status); err != nil {
+				result.Changed = false
+				return result, &BeforeUpdateError{ReleaseName: releaseName, Revision: rel.Version, Err: err}
 			}
+			beforeUpdate = nil
 		}
-		if !allowed {
-			return &PreconditionError{
-				CurrentStatus:   currentStatus,
-				AllowedStatuses: allowedFromStatuses,
+
+		// Another process may have written the release since it was read;
+		// Helm storage has no compare-and-swap, so check just before writing.
+		conflict := changedSince(cfg, rel)
+		if !conflict {
+			if !gate.open(ctx) {
+				result.Changed = false
+				return result, fmt.Errorf("stopped setting status of release %s: %w", releaseName, ctx.Err())
+			}
+			var written *release.Release
+			written, err = writeStatus(ctx, cfg, rel, status, opts, logger)
+			conflict = opts.RetryOnConflict && apierrors.IsConflict(err)
+			if !conflict {
+				if written != nil {
+					result.Release = written
+				}
+				return result, err
 			}
 		}
+
+		// Nothing was written, so a failure from here on leaves the release
+		// as it was
+		result.Changed = false
+		if attempt > opts.MaxRetries {
+			return result, &ConflictError{ReleaseName: releaseName, Revision: rel.Version, Attempts: attempt}
+		}
+		logger.Debug("release modified concurrently, retrying", "revision", rel.Version, "attempt", attempt, "delay", opts.RetryDelay.String())
+		if err := sleepContext(ctx, opts.RetryDelay); err != nil {
+			return result, fmt.Errorf("stopped retrying update of release %s: %w", releaseName, err)
+		}
 	}
+}
 
-	// Update status
-	rel.Info.Status = status
-	rel.Info.Description = fmt.Sprintf("status set to %s", status.String())
-	rel.Info.LastDeployed = helmtime.Now()
+// sleepContext waits for d, returning early with ctx's error if ctx is done
+// first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// prepareUpdate reads the release and checks the preconditions. done is true
+// when there is nothing to write, because the release already has the
+// target status, because it was skipped for unmet preconditions, or because
+// opts.DryRun is set.
+func prepareUpdate(cfg *action.Configuration, releaseName string, status release.Status, opts SetStatusOptions, logger *slog.Logger) (rel *release.Release, result Result, done bool, err error) {
+	rel, err = getRelease(cfg, releaseName, opts.Revision)
+	if err != nil {
+		return nil, Result{}, false, err
+	}
+
+	currentStatus, recognized := storedStatus(rel)
+	result = Result{PreviousStatus: currentStatus, Status: status, Release: rel, PreviousRelease: rel}
+	if !recognized {
+		result.UnrecognizedStatus = rel.Info.Status.String()
+	}
+	logger.Debug("resolved release", "revision", rel.Version, "current_status", currentStatus.String())
+
+	if err := checkChart(rel, opts); err != nil {
+		return rel, result, false, err
+	}
+
+	if opts.Force {
+		logger.Debug("force set, skipping preconditions")
+	} else {
+		if err := checkPreconditions(rel, status, opts); err != nil {
+			var precondErr *PreconditionError
+			if opts.SkipUnmetPreconditions && errors.As(err, &precondErr) {
+				logger.Debug("preconditions not met, skipping", "reason", err.Error())
+				result.Skipped = true
+				result.SkipReason = err.Error()
+				return rel, result, true, nil
+			}
+			return rel, result, false, err
+		}
+		logger.Debug("preconditions passed")
+	}
+
+	// Nothing to do if the release already has the target status, spelled
+	// as Helm spells it
+	if rel.Info.Status == status && !opts.ForceWrite {
+		logger.Debug("release already has target status, not writing", "status", status.String())
+		return rel, result, true, nil
+	}
+	result.Changed = true
+
+	if opts.DryRun {
+		logger.Debug("dry run, not writing", "status", status.String())
+		return rel, result, true, nil
+	}
+
+	return rel, result, false, nil
+}
+
+// changedSince reports whether the stored copy of rel no longer matches it.
+// A revision that can no longer be read is left for the update to report.
+// A stored copy without info metadata counts as changed, so that the retry
+// reports it.
+func changedSince(cfg *action.Configuration, rel *release.Release) bool {
+	stored, err := cfg.Releases.Get(rel.Name, rel.Version)
+	if err != nil {
+		return false
+	}
+	if stored.Info == nil {
+		return true
+	}
+	return !takeSnapshot(stored).equal(takeSnapshot(rel))
+}
+
+// writeStatus persists a copy of rel with status set, and waits for it to be
+// read back if opts.WaitTimeout is set. It returns the copy once it has been
+// persisted, even if waiting for it fails, and nil if it was not.
+func writeStatus(ctx context.Context, cfg *action.Configuration, rel *release.Release, status release.Status, opts SetStatusOptions, logger *slog.Logger) (*release.Release, error) {
+	releaseName := rel.Name
+	updated := cloneForUpdate(rel)
+	if err := applyStatus(updated, status, opts); err != nil {
+		return nil, err
+	}
 
 	// Persist back to storage
-	if err := cfg.Releases.Update(rel); err != nil {
-		return fmt.Errorf("failed to update release %s: %w", releaseName, err)
+	if err := cfg.Releases.Update(updated); err != nil {
+		return nil, fmt.Errorf("failed to update release %s: %w", releaseName, err)
+	}
+	logger.Debug("release updated", "revision", updated.Version, "status", status.String())
+
+	if opts.WaitTimeout > 0 {
+		logger.Debug("waiting for status to be read back", "timeout", opts.WaitTimeout.String())
+		if err := waitForStatus(ctx, cfg, releaseName, updated.Version, status, opts.WaitMaxInterval, opts.WaitTimeout); err != nil {
+			return updated, err
+		}
+		logger.Debug("status read back")
+	}
+
+	return updated, nil
+}
+
+// cloneForUpdate returns a copy of rel whose info and labels can be changed
+// without changing rel. Storage drivers may hand out the stored release
+// itself, as the memory driver does, which other callers may be reading
+// concurrently, so a release read from storage is never modified in place.
+func cloneForUpdate(rel *release.Release) *release.Release {
+	updated := *rel
+	info := *rel.Info
+	updated.Info = &info
+	updated.Labels = maps.Clone(rel.Labels)
+	return &updated
+}
+
+// applyStatus sets status on rel along with the description, last-deployed
+// time, and labels configured in opts.
+func applyStatus(rel *release.Release, status release.Status, opts SetStatusOptions) error {
+	description, err := renderDescription(opts.Description, newDescriptionData(rel, status))
+	if err != nil {
+		return err
+	}
+	if description == "" {
+		description = fmt.Sprintf("status set to %s", status.String())
+	}
+
+	rel.Info.Status = status
+	if opts.AppendDescription {
+		description = appendDescription(rel.Info.Description, description)
+	}
+	rel.Info.Description = description
+	if !opts.KeepLastDeployed {
+		rel.Info.LastDeployed = helmtime.Now()
+	}
+	if len(opts.Labels) > 0 {
+		if rel.Labels == nil {
+			rel.Labels = make(map[string]string, len(opts.Labels))
+		}
+		for k, v := range opts.Labels {
+			rel.Labels[k] = v
+		}
+	}
+	for _, k := range opts.RemoveLabels {
+		delete(rel.Labels, k)
+	}
+	return nil
+}
+
+// appendDescription adds description to prior on a new line prefixed with
+// the current UTC time.
+func appendDescription(prior, description string) string {
+	line := fmt.Sprintf("%s: %s", now().UTC().Format(time.RFC3339), description)
+	if prior == "" {
+		return line
+	}
+	return prior + "\n" + line
+}
+
+// ParseChartVersionConstraint parses an ExpectChartVersion value: an exact
+// chart version such as 1.2.0, or a semver constraint such as ">=1.2.0" or
+// "~1.2".
+func ParseChartVersionConstraint(s string) (*semver.Constraints, error) {
+	constraint, err := semver.NewConstraint(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid chart version constraint %q: %w", s, err)
+	}
+	return constraint, nil
+}
+
+// checkChart returns a ChartMismatchError if the chart rel was installed
+// from does not have the name or version opts expects.
+func checkChart(rel *release.Release, opts SetStatusOptions) error {
+	var name, version string
+	if rel.Chart != nil && rel.Chart.Metadata != nil {
+		name, version = rel.Chart.Metadata.Name, rel.Chart.Metadata.Version
+	}
+	if opts.ExpectChart != "" && name != opts.ExpectChart {
+		return &ChartMismatchError{ReleaseName: rel.Name, Revision: rel.Version, Chart: name, ExpectedChart: opts.ExpectChart}
+	}
+	if opts.ExpectChartVersion == "" {
+		return nil
+	}
+	constraint, err := ParseChartVersionConstraint(opts.ExpectChartVersion)
+	if err != nil {
+		return err
+	}
+	// A version that is not valid semver cannot match
+	if v, err := semver.NewVersion(version); err != nil || !constraint.Check(v) {
+		return &ChartMismatchError{ReleaseName: rel.Name, Revision: rel.Version, Chart: name, Version: version, ExpectedVersion: opts.ExpectChartVersion}
+	}
+	return nil
+}
+
+// checkPreconditions returns an error if rel does not satisfy the
+// preconditions and transition rules configured in opts.
+func checkPreconditions(rel *release.Release, status release.Status, opts SetStatusOptions) error {
+	currentStatus, _ := storedStatus(rel)
+
+	// Check precondition if AllowedFromStatuses is specified
+	if len(opts.AllowedFromStatuses) > 0 && !containsStatus(opts.AllowedFromStatuses, currentStatus) {
+		return &PreconditionError{
+			ReleaseName:        rel.Name,
+			Revision:           rel.Version,
+			CurrentStatus:      currentStatus,
+			CurrentDescription: rel.Info.Description,
+			TargetStatus:       status,
+			AllowedStatuses:    opts.AllowedFromStatuses,
+		}
+	}
+
+	// Check precondition if DisallowedFromStatuses is specified
+	if containsStatus(opts.DisallowedFromStatuses, currentStatus) {
+		return &PreconditionError{
+			ReleaseName:        rel.Name,
+			Revision:           rel.Version,
+			CurrentStatus:      currentStatus,
+			CurrentDescription: rel.Info.Description,
+			TargetStatus:       status,
+			DisallowedStatuses: opts.DisallowedFromStatuses,
+		}
+	}
+
+	// Check precondition if IfOlderThan is specified
+	lastDeployed := rel.Info.LastDeployed.Time
+	if opts.IfOlderThan > 0 && !lastDeployed.IsZero() && now().Sub(lastDeployed) < opts.IfOlderThan {
+		return &PreconditionError{
+			ReleaseName:        rel.Name,
+			Revision:           rel.Version,
+			CurrentStatus:      currentStatus,
+			CurrentDescription: rel.Info.Description,
+			TargetStatus:       status,
+			MinAge:             opts.IfOlderThan,
+			LastDeployed:       lastDeployed,
+		}
+	}
+
+	if opts.StrictTransitions && !IsValidTransition(currentStatus, status) {
+		return &InvalidTransitionError{ReleaseName: rel.Name, From: currentStatus, To: status}
+	}
+
+	if opts.SafeMode && currentStatus == release.StatusFailed && status == release.StatusDeployed {
+		return &MaskedFailureError{ReleaseName: rel.Name, Revision: rel.Version, CurrentDescription: rel.Info.Description}
 	}
 
 	return nil
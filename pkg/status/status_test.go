@@ -1,8 +1,14 @@
 package status
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -11,6 +17,10 @@ import (
 	"helm.sh/helm/v3/pkg/release"
 	"helm.sh/helm/v3/pkg/storage"
 	"helm.sh/helm/v3/pkg/storage/driver"
+	helmtime "helm.sh/helm/v3/pkg/time"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 func TestParseStatus(t *testing.T) {
@@ -80,6 +90,66 @@ func TestParseStatus(t *testing.T) {
 			expected:    release.StatusUnknown,
 			expectError: true,
 		},
+		{
+			name:        "capitalized status",
+			input:       "Deployed",
+			expected:    release.StatusDeployed,
+			expectError: false,
+		},
+		{
+			name:        "uppercase status",
+			input:       "DEPLOYED",
+			expected:    release.StatusDeployed,
+			expectError: false,
+		},
+		{
+			name:        "mixed-case hyphenated status",
+			input:       "Pending-Install",
+			expected:    release.StatusPendingInstall,
+			expectError: false,
+		},
+		{
+			name:        "status with surrounding whitespace",
+			input:       "  failed\n",
+			expected:    release.StatusFailed,
+			expectError: false,
+		},
+		{
+			name:        "pending_install underscore alias",
+			input:       "pending_install",
+			expected:    release.StatusPendingInstall,
+			expectError: false,
+		},
+		{
+			name:        "pending_upgrade underscore alias",
+			input:       "pending_upgrade",
+			expected:    release.StatusPendingUpgrade,
+			expectError: false,
+		},
+		{
+			name:        "pending_rollback underscore alias",
+			input:       "pending_rollback",
+			expected:    release.StatusPendingRollback,
+			expectError: false,
+		},
+		{
+			name:        "mixed-case underscore alias",
+			input:       "Pending_Upgrade",
+			expected:    release.StatusPendingUpgrade,
+			expectError: false,
+		},
+		{
+			name:        "invalid underscore status",
+			input:       "pending_foo",
+			expected:    release.StatusUnknown,
+			expectError: true,
+		},
+		{
+			name:        "whitespace-only status",
+			input:       " \t ",
+			expected:    release.StatusUnknown,
+			expectError: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -96,6 +166,131 @@ func TestParseStatus(t *testing.T) {
 	}
 }
 
+func TestParseStatus_ErrorUsesNormalizedInput(t *testing.T) {
+	_, err := ParseStatus("  BOGUS ")
+	require.Error(t, err)
+	assert.Equal(t, "invalid status: bogus", err.Error())
+}
+
+func TestParseStatusStrict(t *testing.T) {
+	for _, input := range []string{"unknown", " UNKNOWN "} {
+		_, err := ParseStatusStrict(input)
+		assert.EqualError(t, err, "invalid status: unknown is not allowed", input)
+
+		parsed, err := ParseStatus(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, release.StatusUnknown, parsed)
+	}
+
+	parsed, err := ParseStatusStrict("Pending_Upgrade")
+	require.NoError(t, err)
+	assert.Equal(t, release.StatusPendingUpgrade, parsed)
+
+	_, err = ParseStatusStrict("bogus")
+	assert.EqualError(t, err, "invalid status: bogus")
+}
+
+func TestPreconditionError_Error(t *testing.T) {
+	t.Run("without target status", func(t *testing.T) {
+		err := &PreconditionError{
+			CurrentStatus:   release.StatusDeployed,
+			AllowedStatuses: []release.Status{release.StatusPendingUpgrade},
+		}
+		assert.Equal(t, `current status "deployed" not in allowed list [pending-upgrade]`, err.Error())
+	})
+
+	t.Run("with target status", func(t *testing.T) {
+		err := &PreconditionError{
+			CurrentStatus:   release.StatusDeployed,
+			TargetStatus:    release.StatusFailed,
+			AllowedStatuses: []release.Status{release.StatusPendingUpgrade},
+		}
+		assert.Equal(t, `current status "deployed" not in allowed list [pending-upgrade]; cannot set to "failed"`, err.Error())
+	})
+
+	t.Run("with current description", func(t *testing.T) {
+		err := &PreconditionError{
+			CurrentStatus:      release.StatusFailed,
+			CurrentDescription: "Upgrade \"my-release\" failed: context deadline exceeded",
+			TargetStatus:       release.StatusDeployed,
+			AllowedStatuses:    []release.Status{release.StatusPendingUpgrade},
+		}
+		assert.Equal(t, `current status "failed" not in allowed list [pending-upgrade]; cannot set to "deployed"`+
+			` (release description: "Upgrade \"my-release\" failed: context deadline exceeded")`, err.Error())
+	})
+}
+
+func TestSetStatus_PreconditionErrorDescription(t *testing.T) {
+	newConfig := func(t *testing.T) *action.Configuration {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		require.NoError(t, store.Create(&release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   1,
+			Info: &release.Info{
+				Status:       release.StatusFailed,
+				Description:  "Upgrade \"test-release\" failed: timed out waiting for the condition",
+				LastDeployed: helmtime.Time{Time: now()},
+			},
+		}))
+		return &action.Configuration{Releases: store}
+	}
+
+	for name, opts := range map[string]SetStatusOptions{
+		"allowed":       {AllowedFromStatuses: []release.Status{release.StatusPendingUpgrade}},
+		"disallowed":    {DisallowedFromStatuses: []release.Status{release.StatusFailed}},
+		"if older than": {IfOlderThan: time.Hour},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := SetStatus(t.Context(), newConfig(t), "test-release", release.StatusDeployed, opts)
+			var precondErr *PreconditionError
+			require.ErrorAs(t, err, &precondErr)
+			assert.Equal(t, "Upgrade \"test-release\" failed: timed out waiting for the condition", precondErr.CurrentDescription)
+			assert.Contains(t, err.Error(), `(release description: "Upgrade \"test-release\" failed: timed out waiting for the condition")`)
+		})
+	}
+}
+
+func TestIsValidStatus(t *testing.T) {
+	for _, s := range ValidStatuses {
+		assert.True(t, IsValidStatus(s), s)
+	}
+
+	assert.True(t, IsValidStatus("DEPLOYED"))
+	assert.True(t, IsValidStatus("  pending_upgrade "))
+	assert.False(t, IsValidStatus(""))
+	assert.False(t, IsValidStatus("bogus"))
+	assert.False(t, IsValidStatus("pending"))
+}
+
+func TestValidStatusSetMatchesValidStatuses(t *testing.T) {
+	// Every listed status must be parseable, and nothing else
+	assert.Len(t, validStatusSet, len(ValidStatuses))
+	for _, s := range ValidStatuses {
+		parsed, ok := validStatusSet[s]
+		require.True(t, ok, s)
+		assert.Equal(t, s, parsed.String())
+	}
+}
+
+func TestStatusString(t *testing.T) {
+	for _, s := range ValidStatuses {
+		parsed, err := ParseStatus(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, s, StatusString(parsed))
+
+		roundTripped, err := ParseStatus(StatusString(parsed))
+		require.NoError(t, err, s)
+		assert.Equal(t, parsed, roundTripped)
+	}
+
+	assert.Equal(t, "pending-upgrade", StatusString(release.StatusPendingUpgrade))
+	assert.Equal(t, "uninstalled", StatusString(release.StatusUninstalled))
+	assert.Equal(t, "unknown", StatusString(release.Status("bogus")))
+	assert.Equal(t, "unknown", StatusString(release.Status("")))
+}
+
 func TestValidStatusesString(t *testing.T) {
 	result := ValidStatusesString()
 	assert.Contains(t, result, "unknown")
@@ -109,6 +304,13 @@ func TestValidStatusesString(t *testing.T) {
 	assert.Contains(t, result, ", ")
 }
 
+func TestValidStatusesJoined(t *testing.T) {
+	assert.Equal(t, strings.Join(ValidStatuses, "|"), ValidStatusesJoined("|"))
+	assert.Equal(t, strings.Join(ValidStatuses, "\n"), ValidStatusesJoined("\n"))
+	assert.Equal(t, ValidStatusesJoined(", "), ValidStatusesString())
+	assert.Equal(t, ValidStatuses, strings.Split(ValidStatusesJoined(" "), " "))
+}
+
 func TestSetStatus(t *testing.T) {
 	t.Run("successfully sets status of latest revision", func(t *testing.T) {
 		// Create in-memory storage
@@ -137,7 +339,7 @@ func TestSetStatus(t *testing.T) {
 		cfg := &action.Configuration{Releases: store}
 
 		// Test status change to failed (revision 0 = latest)
-		err = SetStatus(cfg, "test-release", release.StatusFailed, 0, nil)
+		_, err = SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, SetStatusOptions{})
 		require.NoError(t, err)
 
 		// Verify status was updated
@@ -188,7 +390,7 @@ func TestSetStatus(t *testing.T) {
 		cfg := &action.Configuration{Releases: store}
 
 		// Update revision 1 specifically
-		err = SetStatus(cfg, "test-release", release.StatusFailed, 1, nil)
+		_, err = SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, SetStatusOptions{Revision: 1})
 		require.NoError(t, err)
 
 		// Verify revision 1 was updated
@@ -239,7 +441,7 @@ func TestSetStatus(t *testing.T) {
 
 				cfg := &action.Configuration{Releases: store}
 
-				err = SetStatus(cfg, "test-release", targetStatus, 0, nil)
+				_, err = SetStatus(t.Context(), cfg, "test-release", targetStatus, SetStatusOptions{})
 				require.NoError(t, err)
 
 				updated, err := store.Last("test-release")
@@ -255,7 +457,7 @@ func TestSetStatus(t *testing.T) {
 
 		cfg := &action.Configuration{Releases: store}
 
-		err := SetStatus(cfg, "non-existent", release.StatusFailed, 0, nil)
+		_, err := SetStatus(t.Context(), cfg, "non-existent", release.StatusFailed, SetStatusOptions{})
 		assert.Error(t, err)
 
 		var notFoundErr *ReleaseNotFoundError
@@ -287,10 +489,17 @@ func TestSetStatus(t *testing.T) {
 		cfg := &action.Configuration{Releases: store}
 
 		// Try to update non-existent revision 5
-		err = SetStatus(cfg, "test-release", release.StatusFailed, 5, nil)
+		_, err = SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, SetStatusOptions{Revision: 5})
 		assert.Error(t, err)
-		assert.Contains(t, err.Error(), "failed to get release")
 		assert.Contains(t, err.Error(), "revision 5")
+
+		var revErr *RevisionNotFoundError
+		assert.True(t, errors.As(err, &revErr), "error should be *RevisionNotFoundError")
+		assert.Equal(t, "test-release", revErr.ReleaseName)
+		assert.Equal(t, 5, revErr.Revision)
+
+		var notFoundErr *ReleaseNotFoundError
+		assert.False(t, errors.As(err, &notFoundErr), "error should not be *ReleaseNotFoundError")
 	})
 }
 
@@ -348,7 +557,7 @@ func TestSetStatus_UpdateError(t *testing.T) {
 
 	cfg := &action.Configuration{Releases: store}
 
-	err = SetStatus(cfg, "test-release", release.StatusFailed, 0, nil)
+	_, err = SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, SetStatusOptions{})
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to update release")
 }
@@ -379,7 +588,7 @@ func TestSetStatus_FromPrecondition(t *testing.T) {
 
 		// Should succeed because current status (pending-upgrade) is in allowed list
 		allowedFrom := []release.Status{release.StatusPendingUpgrade, release.StatusPendingRollback}
-		err = SetStatus(cfg, "test-release", release.StatusDeployed, 0, allowedFrom)
+		_, err = SetStatus(t.Context(), cfg, "test-release", release.StatusDeployed, SetStatusOptions{AllowedFromStatuses: allowedFrom})
 		require.NoError(t, err)
 
 		updated, err := store.Last("test-release")
@@ -412,7 +621,7 @@ func TestSetStatus_FromPrecondition(t *testing.T) {
 
 		// Should fail because current status (deployed) is NOT in allowed list
 		allowedFrom := []release.Status{release.StatusPendingUpgrade, release.StatusPendingRollback}
-		err = SetStatus(cfg, "test-release", release.StatusFailed, 0, allowedFrom)
+		_, err = SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, SetStatusOptions{AllowedFromStatuses: allowedFrom})
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "current status")
 		assert.Contains(t, err.Error(), "deployed")
@@ -448,7 +657,7 @@ func TestSetStatus_FromPrecondition(t *testing.T) {
 		cfg := &action.Configuration{Releases: store}
 
 		// Should succeed with empty allowed list (any status can transition)
-		err = SetStatus(cfg, "test-release", release.StatusFailed, 0, []release.Status{})
+		_, err = SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, SetStatusOptions{AllowedFromStatuses: []release.Status{}})
 		require.NoError(t, err)
 
 		updated, err := store.Last("test-release")
@@ -481,7 +690,7 @@ func TestSetStatus_FromPrecondition(t *testing.T) {
 
 		// Should succeed with single matching status
 		allowedFrom := []release.Status{release.StatusPendingInstall}
-		err = SetStatus(cfg, "test-release", release.StatusFailed, 0, allowedFrom)
+		_, err = SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, SetStatusOptions{AllowedFromStatuses: allowedFrom})
 		require.NoError(t, err)
 
 		updated, err := store.Last("test-release")
@@ -514,13 +723,1664 @@ func TestSetStatus_FromPrecondition(t *testing.T) {
 
 		// Should return PreconditionError because current status (deployed) is NOT in allowed list
 		allowedFrom := []release.Status{release.StatusPendingUpgrade, release.StatusPendingRollback}
-		err = SetStatus(cfg, "test-release", release.StatusFailed, 0, allowedFrom)
+		_, err = SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, SetStatusOptions{AllowedFromStatuses: allowedFrom})
 		assert.Error(t, err)
 
 		// Verify error type using errors.As
 		var precondErr *PreconditionError
 		assert.True(t, errors.As(err, &precondErr), "error should be *PreconditionError")
 		assert.Equal(t, release.StatusDeployed, precondErr.CurrentStatus)
+		assert.Equal(t, release.StatusFailed, precondErr.TargetStatus)
 		assert.Equal(t, allowedFrom, precondErr.AllowedStatuses)
+		assert.Equal(t, `current status "deployed" not in allowed list [pending-upgrade pending-rollback]; cannot set to "failed"`, err.Error())
+	})
+}
+
+func TestSetStatus_DryRun(t *testing.T) {
+	t.Run("returns current status without writing", func(t *testing.T) {
+		mem := driver.NewMemory()
+		store := storage.Init(mem)
+
+		rel := &release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   1,
+			Info: &release.Info{
+				Status:      release.StatusDeployed,
+				Description: "Install complete",
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}
+		err := store.Create(rel)
+		require.NoError(t, err)
+
+		cfg := &action.Configuration{Releases: store}
+
+		result, err := SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, SetStatusOptions{DryRun: true})
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusDeployed, result.PreviousStatus)
+
+		// Verify nothing was written
+		unchanged, err := store.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusDeployed, unchanged.Info.Status)
+		assert.Equal(t, "Install complete", unchanged.Info.Description)
+	})
+
+	t.Run("still enforces preconditions", func(t *testing.T) {
+		mem := driver.NewMemory()
+		store := storage.Init(mem)
+
+		rel := &release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   1,
+			Info: &release.Info{
+				Status: release.StatusDeployed,
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}
+		err := store.Create(rel)
+		require.NoError(t, err)
+
+		cfg := &action.Configuration{Releases: store}
+
+		allowedFrom := []release.Status{release.StatusPendingUpgrade}
+		_, err = SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, SetStatusOptions{AllowedFromStatuses: allowedFrom, DryRun: true})
+		var precondErr *PreconditionError
+		assert.True(t, errors.As(err, &precondErr), "error should be *PreconditionError")
+		assert.Equal(t, release.StatusFailed, precondErr.TargetStatus)
+	})
+
+	t.Run("returns ReleaseNotFoundError for non-existent release", func(t *testing.T) {
+		cfg := &action.Configuration{Releases: storage.Init(driver.NewMemory())}
+
+		_, err := SetStatus(t.Context(), cfg, "non-existent", release.StatusFailed, SetStatusOptions{DryRun: true})
+		var notFoundErr *ReleaseNotFoundError
+		assert.True(t, errors.As(err, &notFoundErr), "error should be *ReleaseNotFoundError")
+	})
+}
+
+func TestGetStatus(t *testing.T) {
+	mem := driver.NewMemory()
+	store := storage.Init(mem)
+
+	rel1 := &release.Release{
+		Name:      "test-release",
+		Namespace: "default",
+		Version:   1,
+		Info: &release.Info{
+			Status: release.StatusSuperseded,
+		},
+		Chart: &chart.Chart{
+			Metadata: &chart.Metadata{
+				Name:    "test-chart",
+				Version: "1.0.0",
+			},
+		},
+	}
+	rel2 := &release.Release{
+		Name:      "test-release",
+		Namespace: "default",
+		Version:   2,
+		Info: &release.Info{
+			Status: release.StatusPendingUpgrade,
+		},
+		Chart: &chart.Chart{
+			Metadata: &chart.Metadata{
+				Name:    "test-chart",
+				Version: "1.0.0",
+			},
+		},
+	}
+	require.NoError(t, store.Create(rel1))
+	require.NoError(t, store.Create(rel2))
+
+	cfg := &action.Configuration{Releases: store}
+
+	t.Run("returns status of latest revision", func(t *testing.T) {
+		current, err := GetStatus(cfg, "test-release", 0)
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusPendingUpgrade, current)
+	})
+
+	t.Run("returns status of specific revision", func(t *testing.T) {
+		current, err := GetStatus(cfg, "test-release", 1)
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusSuperseded, current)
+	})
+
+	t.Run("returns ReleaseNotFoundError for non-existent release", func(t *testing.T) {
+		_, err := GetStatus(cfg, "non-existent", 0)
+		var notFoundErr *ReleaseNotFoundError
+		assert.True(t, errors.As(err, &notFoundErr), "error should be *ReleaseNotFoundError")
+	})
+
+	t.Run("fails for non-existent revision", func(t *testing.T) {
+		_, err := GetStatus(cfg, "test-release", 5)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "revision 5")
+	})
+}
+
+func TestSetStatus_Result(t *testing.T) {
+	mem := driver.NewMemory()
+	store := storage.Init(mem)
+
+	rel1 := &release.Release{
+		Name:      "test-release",
+		Namespace: "default",
+		Version:   1,
+		Info: &release.Info{
+			Status: release.StatusSuperseded,
+		},
+		Chart: &chart.Chart{
+			Metadata: &chart.Metadata{
+				Name:    "test-chart",
+				Version: "1.0.0",
+			},
+		},
+	}
+	rel2 := &release.Release{
+		Name:      "test-release",
+		Namespace: "default",
+		Version:   2,
+		Info: &release.Info{
+			Status: release.StatusPendingUpgrade,
+		},
+		Chart: &chart.Chart{
+			Metadata: &chart.Metadata{
+				Name:    "test-chart",
+				Version: "1.0.0",
+			},
+		},
+	}
+	require.NoError(t, store.Create(rel1))
+	require.NoError(t, store.Create(rel2))
+
+	cfg := &action.Configuration{Releases: store}
+
+	t.Run("returns previous status of latest revision", func(t *testing.T) {
+		result, err := SetStatus(t.Context(), cfg, "test-release", release.StatusDeployed, SetStatusOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusPendingUpgrade, result.PreviousStatus)
+		assert.Equal(t, release.StatusDeployed, result.Status)
+	})
+
+	t.Run("returns previous status of specific revision", func(t *testing.T) {
+		result, err := SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, SetStatusOptions{Revision: 1})
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusSuperseded, result.PreviousStatus)
+		assert.Equal(t, release.StatusFailed, result.Status)
+	})
+
+	t.Run("returns the updated release", func(t *testing.T) {
+		result, err := SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, SetStatusOptions{Description: "smoke tests failed"})
+		require.NoError(t, err)
+		require.NotNil(t, result.Release)
+		assert.Equal(t, 2, result.Release.Version)
+		assert.Equal(t, release.StatusFailed, result.Release.Info.Status)
+		assert.Equal(t, "smoke tests failed", result.Release.Info.Description)
+
+		stored, err := store.Get("test-release", 2)
+		require.NoError(t, err)
+		assert.Equal(t, stored.Info.Status, result.Release.Info.Status)
+		assert.Equal(t, stored.Info.Description, result.Release.Info.Description)
+	})
+
+	t.Run("returns the stored release when nothing is written", func(t *testing.T) {
+		result, err := SetStatus(t.Context(), cfg, "test-release", release.StatusDeployed, SetStatusOptions{DryRun: true, Description: "not written"})
+		require.NoError(t, err)
+		require.NotNil(t, result.Release)
+		assert.True(t, result.Changed)
+		assert.Equal(t, release.StatusFailed, result.Release.Info.Status)
+		assert.Equal(t, "smoke tests failed", result.Release.Info.Description)
 	})
 }
+
+func TestSetStatus_Description(t *testing.T) {
+	t.Run("writes default description", func(t *testing.T) {
+		mem := driver.NewMemory()
+		store := storage.Init(mem)
+
+		rel := &release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   1,
+			Info: &release.Info{
+				Status:      release.StatusDeployed,
+				Description: "Install complete",
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}
+		require.NoError(t, store.Create(rel))
+
+		cfg := &action.Configuration{Releases: store}
+
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, SetStatusOptions{})
+		require.NoError(t, err)
+
+		updated, err := store.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, "status set to failed", updated.Info.Description)
+	})
+
+	t.Run("writes custom description", func(t *testing.T) {
+		mem := driver.NewMemory()
+		store := storage.Init(mem)
+
+		rel := &release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   1,
+			Info: &release.Info{
+				Status:      release.StatusDeployed,
+				Description: "Install complete",
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}
+		require.NoError(t, store.Create(rel))
+
+		cfg := &action.Configuration{Releases: store}
+
+		opts := SetStatusOptions{Description: "rolled back due to failed smoke tests"}
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, opts)
+		require.NoError(t, err)
+
+		updated, err := store.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusFailed, updated.Info.Status)
+		assert.Equal(t, "rolled back due to failed smoke tests", updated.Info.Description)
+	})
+}
+
+func TestSetStatus_KeepLastDeployed(t *testing.T) {
+	deployedAt := helmtime.Date(2024, time.January, 2, 3, 4, 5, 0, time.UTC)
+
+	newStore := func(t *testing.T) *storage.Storage {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		rel := &release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   1,
+			Info: &release.Info{
+				Status:       release.StatusPendingUpgrade,
+				LastDeployed: deployedAt,
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}
+		require.NoError(t, store.Create(rel))
+		return store
+	}
+
+	t.Run("updates LastDeployed by default", func(t *testing.T) {
+		store := newStore(t)
+		cfg := &action.Configuration{Releases: store}
+
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusDeployed, SetStatusOptions{})
+		require.NoError(t, err)
+
+		updated, err := store.Last("test-release")
+		require.NoError(t, err)
+		assert.True(t, updated.Info.LastDeployed.After(deployedAt))
+	})
+
+	t.Run("keeps LastDeployed when requested", func(t *testing.T) {
+		store := newStore(t)
+		cfg := &action.Configuration{Releases: store}
+
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusDeployed, SetStatusOptions{KeepLastDeployed: true})
+		require.NoError(t, err)
+
+		updated, err := store.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusDeployed, updated.Info.Status)
+		assert.True(t, updated.Info.LastDeployed.Equal(deployedAt))
+	})
+}
+
+// failingGetDriver wraps a memory driver but fails on Get
+type failingGetDriver struct {
+	*driver.Memory
+}
+
+func (f *failingGetDriver) Get(key string) (*release.Release, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestSetStatus_GetError(t *testing.T) {
+	cfg := &action.Configuration{Releases: storage.Init(&failingGetDriver{Memory: driver.NewMemory()})}
+
+	_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, SetStatusOptions{Revision: 1})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to get release test-release revision 1")
+	assert.Contains(t, err.Error(), "connection refused")
+
+	var revErr *RevisionNotFoundError
+	assert.False(t, errors.As(err, &revErr), "error should not be *RevisionNotFoundError")
+}
+
+func TestSetStatus_StorageNotConfigured(t *testing.T) {
+	for name, cfg := range map[string]*action.Configuration{
+		"nil configuration": nil,
+		"nil releases":      {},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusDeployed, SetStatusOptions{})
+			assert.ErrorIs(t, err, ErrStorageNotConfigured)
+			assert.EqualError(t, err, "storage not configured")
+
+			results := BatchSetStatus(t.Context(), cfg, []SetStatusRequest{{ReleaseName: "test-release", Status: release.StatusDeployed}})
+			require.Len(t, results, 1)
+			assert.ErrorIs(t, results[0].Err, ErrStorageNotConfigured)
+
+			_, err = SetStatusAtomic(t.Context(), cfg, []Target{{ReleaseName: "test-release"}}, release.StatusDeployed, SetStatusOptions{})
+			assert.ErrorIs(t, err, ErrStorageNotConfigured)
+
+			_, err = GetStatus(cfg, "test-release", 0)
+			assert.ErrorIs(t, err, ErrStorageNotConfigured)
+
+			_, err = DiffStatus(cfg, "test-release", release.StatusDeployed, SetStatusOptions{})
+			assert.ErrorIs(t, err, ErrStorageNotConfigured)
+
+			_, err = History(cfg, "test-release")
+			assert.ErrorIs(t, err, ErrStorageNotConfigured)
+
+			_, err = List(cfg, ListOptions{})
+			assert.ErrorIs(t, err, ErrStorageNotConfigured)
+
+			err = WatchStatus(t.Context(), cfg, "test-release", release.StatusDeployed, time.Millisecond, time.Second)
+			assert.ErrorIs(t, err, ErrStorageNotConfigured)
+		})
+	}
+}
+
+func TestRevisionNotFoundError(t *testing.T) {
+	err := &RevisionNotFoundError{ReleaseName: "my-release", Revision: 3}
+	assert.Equal(t, `release "my-release" revision 3 not found`, err.Error())
+}
+
+func TestSetStatus_MissingInfo(t *testing.T) {
+	newConfig := func(t *testing.T) *action.Configuration {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		for v := 1; v <= 2; v++ {
+			require.NoError(t, store.Create(&release.Release{
+				Name:      "test-release",
+				Namespace: "default",
+				Version:   v,
+				Info:      &release.Info{Status: release.StatusDeployed},
+			}))
+		}
+		// The memory driver keeps the stored pointer, so clearing Info here
+		// leaves a release in storage without info metadata, which it would
+		// otherwise refuse to create.
+		latest, err := store.Get("test-release", 2)
+		require.NoError(t, err)
+		latest.Info = nil
+		return &action.Configuration{Releases: store}
+	}
+
+	tests := []struct {
+		name     string
+		revision int
+	}{
+		{name: "latest revision", revision: 0},
+		{name: "specific revision", revision: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newConfig(t)
+
+			_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, SetStatusOptions{Revision: tt.revision})
+			var infoErr *MissingInfoError
+			require.ErrorAs(t, err, &infoErr)
+			assert.Equal(t, "test-release", infoErr.ReleaseName)
+			assert.Equal(t, 2, infoErr.Revision)
+			assert.Equal(t, `release "test-release" revision 2 has no info metadata`, err.Error())
+
+			_, err = GetStatus(cfg, "test-release", tt.revision)
+			require.ErrorAs(t, err, &infoErr)
+		})
+	}
+
+	t.Run("other revisions are still readable", func(t *testing.T) {
+		cfg := newConfig(t)
+
+		result, err := SetStatus(t.Context(), cfg, "test-release", release.StatusSuperseded, SetStatusOptions{Revision: 1})
+		require.NoError(t, err)
+		assert.True(t, result.Changed)
+	})
+}
+
+func TestSetStatus_UnrecognizedStatus(t *testing.T) {
+	newConfig := func(t *testing.T, stored release.Status) (*action.Configuration, *storage.Storage) {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		require.NoError(t, store.Create(&release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   1,
+			Info:      &release.Info{Status: stored},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}))
+		return &action.Configuration{Releases: store}, store
+	}
+
+	t.Run("treats an unrecognized status as unknown", func(t *testing.T) {
+		cfg, store := newConfig(t, "Superseeded")
+		var logs bytes.Buffer
+		logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+		result, err := SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, SetStatusOptions{
+			AllowedFromStatuses: []release.Status{release.StatusUnknown},
+			Logger:              logger,
+		})
+		require.NoError(t, err)
+		assert.True(t, result.Changed)
+		assert.Equal(t, release.StatusUnknown, result.PreviousStatus)
+		assert.Equal(t, "Superseeded", result.UnrecognizedStatus)
+		// Callers report Result.UnrecognizedStatus themselves
+		assert.NotContains(t, logs.String(), "level=WARN")
+
+		rel, err := store.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusFailed, rel.Info.Status)
+	})
+
+	t.Run("checks --from against unknown", func(t *testing.T) {
+		cfg, _ := newConfig(t, "")
+
+		result, err := SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, SetStatusOptions{
+			AllowedFromStatuses: []release.Status{release.StatusDeployed},
+		})
+		var precondErr *PreconditionError
+		require.ErrorAs(t, err, &precondErr)
+		assert.Equal(t, release.StatusUnknown, precondErr.CurrentStatus)
+		assert.Equal(t, release.StatusUnknown, result.PreviousStatus)
+	})
+
+	t.Run("recognizes uninstalled", func(t *testing.T) {
+		cfg, _ := newConfig(t, release.StatusUninstalled)
+
+		result, err := SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, SetStatusOptions{
+			AllowedFromStatuses: []release.Status{release.StatusUnknown},
+		})
+		var precondErr *PreconditionError
+		require.ErrorAs(t, err, &precondErr)
+		assert.Equal(t, release.StatusUninstalled, precondErr.CurrentStatus)
+		assert.Equal(t, release.StatusUninstalled, result.PreviousStatus)
+		assert.Empty(t, result.UnrecognizedStatus)
+
+		_, err = SetStatus(t.Context(), cfg, "test-release", release.StatusDeployed, SetStatusOptions{StrictTransitions: true})
+		var transitionErr *InvalidTransitionError
+		require.ErrorAs(t, err, &transitionErr)
+		assert.Equal(t, release.StatusUninstalled, transitionErr.From)
+	})
+
+	t.Run("matches a legacy spelling of a known status", func(t *testing.T) {
+		cfg, store := newConfig(t, "DEPLOYED")
+
+		result, err := SetStatus(t.Context(), cfg, "test-release", release.StatusDeployed, SetStatusOptions{
+			AllowedFromStatuses: []release.Status{release.StatusDeployed},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusDeployed, result.PreviousStatus)
+		assert.Empty(t, result.UnrecognizedStatus)
+		assert.True(t, result.Changed, "the legacy spelling should be rewritten")
+
+		rel, err := store.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusDeployed, rel.Info.Status)
+	})
+}
+
+func TestSetStatus_InvalidReleaseName(t *testing.T) {
+	// Any storage call would fail with "connection refused", so an
+	// InvalidReleaseNameError shows the name was rejected up front.
+	cfg := &action.Configuration{Releases: storage.Init(&failingGetDriver{Memory: driver.NewMemory()})}
+
+	for _, name := range []string{"", "My_Release", "-leading-dash", strings.Repeat("a", 54)} {
+		t.Run(name, func(t *testing.T) {
+			_, err := SetStatus(t.Context(), cfg, name, release.StatusFailed, SetStatusOptions{Revision: 1})
+			var nameErr *InvalidReleaseNameError
+			require.ErrorAs(t, err, &nameErr)
+			assert.Equal(t, name, nameErr.ReleaseName)
+			assert.Contains(t, err.Error(), fmt.Sprintf("invalid release name %q", name))
+			assert.NotContains(t, err.Error(), "connection refused")
+		})
+	}
+}
+
+func TestSetStatus_NotFromPrecondition(t *testing.T) {
+	newConfig := func(t *testing.T, current release.Status) (*action.Configuration, *storage.Storage) {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		rel := &release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   1,
+			Info: &release.Info{
+				Status: current,
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}
+		require.NoError(t, store.Create(rel))
+		return &action.Configuration{Releases: store}, store
+	}
+
+	disallowed := []release.Status{release.StatusDeployed, release.StatusSuperseded}
+
+	t.Run("fails when current status is in disallowed list", func(t *testing.T) {
+		cfg, store := newConfig(t, release.StatusDeployed)
+
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, SetStatusOptions{DisallowedFromStatuses: disallowed})
+		var precondErr *PreconditionError
+		require.True(t, errors.As(err, &precondErr), "error should be *PreconditionError")
+		assert.Equal(t, release.StatusDeployed, precondErr.CurrentStatus)
+		assert.Equal(t, release.StatusFailed, precondErr.TargetStatus)
+		assert.Equal(t, disallowed, precondErr.DisallowedStatuses)
+		assert.Equal(t, `current status "deployed" in disallowed list [deployed superseded]; cannot set to "failed"`, err.Error())
+
+		unchanged, err := store.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusDeployed, unchanged.Info.Status)
+	})
+
+	t.Run("succeeds when current status is not in disallowed list", func(t *testing.T) {
+		cfg, store := newConfig(t, release.StatusPendingInstall)
+
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, SetStatusOptions{DisallowedFromStatuses: disallowed})
+		require.NoError(t, err)
+
+		updated, err := store.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusFailed, updated.Info.Status)
+	})
+
+	t.Run("requires both --from and --not-from to pass", func(t *testing.T) {
+		cfg, _ := newConfig(t, release.StatusDeployed)
+
+		opts := SetStatusOptions{
+			AllowedFromStatuses:    []release.Status{release.StatusDeployed, release.StatusPendingUpgrade},
+			DisallowedFromStatuses: []release.Status{release.StatusDeployed},
+		}
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, opts)
+		var precondErr *PreconditionError
+		require.True(t, errors.As(err, &precondErr), "error should be *PreconditionError")
+		assert.Equal(t, []release.Status{release.StatusDeployed}, precondErr.DisallowedStatuses)
+		assert.Equal(t, release.StatusFailed, precondErr.TargetStatus)
+	})
+}
+
+func TestSetStatus_AlreadyAtTargetStatus(t *testing.T) {
+	deployedAt := helmtime.Date(2024, time.January, 2, 3, 4, 5, 0, time.UTC)
+
+	newStore := func(t *testing.T) *storage.Storage {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		rel := &release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   1,
+			Info: &release.Info{
+				Status:       release.StatusDeployed,
+				Description:  "Upgrade complete",
+				LastDeployed: deployedAt,
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}
+		require.NoError(t, store.Create(rel))
+		return store
+	}
+
+	t.Run("skips the write", func(t *testing.T) {
+		store := newStore(t)
+		cfg := &action.Configuration{Releases: store}
+
+		result, err := SetStatus(t.Context(), cfg, "test-release", release.StatusDeployed, SetStatusOptions{})
+		require.NoError(t, err)
+		assert.False(t, result.Changed)
+
+		unchanged, err := store.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, "Upgrade complete", unchanged.Info.Description)
+		assert.True(t, unchanged.Info.LastDeployed.Equal(deployedAt))
+	})
+
+	t.Run("writes anyway with ForceWrite", func(t *testing.T) {
+		store := newStore(t)
+		cfg := &action.Configuration{Releases: store}
+
+		result, err := SetStatus(t.Context(), cfg, "test-release", release.StatusDeployed, SetStatusOptions{ForceWrite: true})
+		require.NoError(t, err)
+		assert.True(t, result.Changed)
+
+		updated, err := store.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, "status set to deployed", updated.Info.Description)
+		assert.True(t, updated.Info.LastDeployed.After(deployedAt))
+	})
+
+	t.Run("reports changed when status differs", func(t *testing.T) {
+		store := newStore(t)
+		cfg := &action.Configuration{Releases: store}
+
+		result, err := SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, SetStatusOptions{})
+		require.NoError(t, err)
+		assert.True(t, result.Changed)
+	})
+}
+
+func TestSetStatus_Logger(t *testing.T) {
+	store := storage.Init(driver.NewMemory())
+	rel := &release.Release{
+		Name:      "test-release",
+		Namespace: "default",
+		Version:   1,
+		Info: &release.Info{
+			Status: release.StatusPendingUpgrade,
+		},
+		Chart: &chart.Chart{
+			Metadata: &chart.Metadata{
+				Name:    "test-chart",
+				Version: "1.0.0",
+			},
+		},
+	}
+	require.NoError(t, store.Create(rel))
+
+	cfg := &action.Configuration{Releases: store}
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusDeployed, SetStatusOptions{Logger: logger})
+	require.NoError(t, err)
+
+	out := buf.String()
+	assert.Contains(t, out, `msg="resolved release" release=test-release revision=1 current_status=pending-upgrade`)
+	assert.Contains(t, out, `msg="preconditions passed"`)
+	assert.Contains(t, out, `msg="release updated" release=test-release revision=1 status=deployed`)
+}
+
+func TestSetStatus_IfOlderThan(t *testing.T) {
+	fixedNow := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	originalNow := now
+	now = func() time.Time { return fixedNow }
+	defer func() { now = originalNow }()
+
+	newConfig := func(t *testing.T, lastDeployed time.Time) (*action.Configuration, *storage.Storage) {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		rel := &release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   1,
+			Info: &release.Info{
+				Status:       release.StatusPendingUpgrade,
+				LastDeployed: helmtime.Time{Time: lastDeployed},
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}
+		require.NoError(t, store.Create(rel))
+		return &action.Configuration{Releases: store}, store
+	}
+
+	t.Run("recently deployed release is rejected", func(t *testing.T) {
+		cfg, store := newConfig(t, fixedNow.Add(-10*time.Minute))
+
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, SetStatusOptions{IfOlderThan: time.Hour})
+		var precondErr *PreconditionError
+		require.True(t, errors.As(err, &precondErr), "error should be *PreconditionError")
+		assert.Equal(t, time.Hour, precondErr.MinAge)
+		assert.Equal(t, release.StatusPendingUpgrade, precondErr.CurrentStatus)
+		assert.Equal(t, release.StatusFailed, precondErr.TargetStatus)
+		assert.Equal(t, `release was last deployed at 2024-06-01T11:50:00Z, less than 1h0m0s ago; cannot set to "failed"`, err.Error())
+
+		unchanged, err := store.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusPendingUpgrade, unchanged.Info.Status)
+	})
+
+	t.Run("old enough release is updated", func(t *testing.T) {
+		cfg, store := newConfig(t, fixedNow.Add(-2*time.Hour))
+
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, SetStatusOptions{IfOlderThan: time.Hour})
+		require.NoError(t, err)
+
+		updated, err := store.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusFailed, updated.Info.Status)
+	})
+
+	t.Run("release without last deployed time is updated", func(t *testing.T) {
+		cfg, store := newConfig(t, time.Time{})
+
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, SetStatusOptions{IfOlderThan: time.Hour})
+		require.NoError(t, err)
+
+		updated, err := store.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusFailed, updated.Info.Status)
+	})
+}
+
+func TestSetStatus_Force(t *testing.T) {
+	newConfig := func(t *testing.T) (*action.Configuration, *storage.Storage) {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		rel := &release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   1,
+			Info: &release.Info{
+				Status:       release.StatusUninstalling,
+				LastDeployed: helmtime.Now(),
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}
+		require.NoError(t, store.Create(rel))
+		return &action.Configuration{Releases: store}, store
+	}
+
+	opts := SetStatusOptions{
+		AllowedFromStatuses:    []release.Status{release.StatusPendingUpgrade},
+		DisallowedFromStatuses: []release.Status{release.StatusUninstalling},
+		IfOlderThan:            time.Hour,
+		StrictTransitions:      true,
+	}
+
+	t.Run("checks apply without force", func(t *testing.T) {
+		cfg, _ := newConfig(t)
+
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusPendingInstall, opts)
+		require.Error(t, err)
+	})
+
+	t.Run("force ignores preconditions and transitions", func(t *testing.T) {
+		cfg, store := newConfig(t)
+
+		forced := opts
+		forced.Force = true
+		result, err := SetStatus(t.Context(), cfg, "test-release", release.StatusPendingInstall, forced)
+		require.NoError(t, err)
+		assert.True(t, result.Changed)
+		assert.Equal(t, release.StatusUninstalling, result.PreviousStatus)
+
+		updated, err := store.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusPendingInstall, updated.Info.Status)
+	})
+}
+
+func TestSetStatus_ExpectChart(t *testing.T) {
+	newConfig := func(t *testing.T, chrt *chart.Chart) (*action.Configuration, *storage.Storage) {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		require.NoError(t, store.Create(&release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   2,
+			Info:      &release.Info{Status: release.StatusPendingUpgrade},
+			Chart:     chrt,
+		}))
+		return &action.Configuration{Releases: store}, store
+	}
+	testChart := &chart.Chart{Metadata: &chart.Metadata{Name: "test-chart", Version: "1.0.0"}}
+
+	t.Run("changes a release of the expected chart", func(t *testing.T) {
+		cfg, store := newConfig(t, testChart)
+
+		result, err := SetStatus(t.Context(), cfg, "test-release", release.StatusDeployed, SetStatusOptions{ExpectChart: "test-chart"})
+		require.NoError(t, err)
+		assert.True(t, result.Changed)
+
+		updated, err := store.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusDeployed, updated.Info.Status)
+	})
+
+	t.Run("refuses a release of another chart, even with force", func(t *testing.T) {
+		for _, opts := range []SetStatusOptions{
+			{ExpectChart: "other-chart"},
+			{ExpectChart: "other-chart", Force: true},
+			{ExpectChart: "other-chart", SkipUnmetPreconditions: true},
+			{ExpectChart: "other-chart", DryRun: true},
+		} {
+			cfg, store := newConfig(t, testChart)
+
+			_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusDeployed, opts)
+			var mismatchErr *ChartMismatchError
+			require.ErrorAs(t, err, &mismatchErr)
+			assert.Equal(t, ChartMismatchError{ReleaseName: "test-release", Revision: 2, Chart: "test-chart", ExpectedChart: "other-chart"}, *mismatchErr)
+			assert.EqualError(t, err, `release "test-release" revision 2 has chart "test-chart", expected "other-chart"`)
+
+			unchanged, err := store.Last("test-release")
+			require.NoError(t, err)
+			assert.Equal(t, release.StatusPendingUpgrade, unchanged.Info.Status)
+		}
+	})
+
+	t.Run("refuses a release with no chart", func(t *testing.T) {
+		cfg, _ := newConfig(t, nil)
+
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusDeployed, SetStatusOptions{ExpectChart: "test-chart"})
+		assert.EqualError(t, err, `release "test-release" revision 2 has no chart, expected "test-chart"`)
+	})
+}
+
+func TestSetStatus_ExpectChartVersion(t *testing.T) {
+	newConfig := func(t *testing.T, version string) (*action.Configuration, *storage.Storage) {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		require.NoError(t, store.Create(&release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   2,
+			Info:      &release.Info{Status: release.StatusPendingUpgrade},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: version,
+				},
+			},
+		}))
+		return &action.Configuration{Releases: store}, store
+	}
+
+	t.Run("changes a release whose chart version matches", func(t *testing.T) {
+		for _, expected := range []string{"1.2.3", "=1.2.3", "v1.2.3", ">=1.2.0", "~1.2", "^1", ">=1.0.0, <2.0.0", "<1.0.0 || >=1.2.0"} {
+			cfg, store := newConfig(t, "1.2.3")
+
+			result, err := SetStatus(t.Context(), cfg, "test-release", release.StatusDeployed, SetStatusOptions{ExpectChart: "test-chart", ExpectChartVersion: expected})
+			require.NoError(t, err, expected)
+			assert.True(t, result.Changed, expected)
+
+			updated, err := store.Last("test-release")
+			require.NoError(t, err)
+			assert.Equal(t, release.StatusDeployed, updated.Info.Status, expected)
+		}
+	})
+
+	t.Run("refuses a release whose chart version does not match, even with force", func(t *testing.T) {
+		for _, expected := range []string{"1.2.4", ">=1.3.0", "~1.1", "^2", "<1.2.3"} {
+			for _, force := range []bool{false, true} {
+				cfg, store := newConfig(t, "1.2.3")
+
+				_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusDeployed, SetStatusOptions{ExpectChartVersion: expected, Force: force})
+				var mismatchErr *ChartMismatchError
+				require.ErrorAs(t, err, &mismatchErr, expected)
+				assert.Equal(t, ChartMismatchError{ReleaseName: "test-release", Revision: 2, Chart: "test-chart", Version: "1.2.3", ExpectedVersion: expected}, *mismatchErr)
+
+				unchanged, err := store.Last("test-release")
+				require.NoError(t, err)
+				assert.Equal(t, release.StatusPendingUpgrade, unchanged.Info.Status, expected)
+			}
+		}
+
+		cfg, _ := newConfig(t, "1.2.3")
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusDeployed, SetStatusOptions{ExpectChartVersion: ">=1.3.0"})
+		assert.EqualError(t, err, `release "test-release" revision 2 has chart "test-chart" version "1.2.3", which does not match ">=1.3.0"`)
+	})
+
+	t.Run("refuses a chart version that is not semver", func(t *testing.T) {
+		cfg, _ := newConfig(t, "latest")
+
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusDeployed, SetStatusOptions{ExpectChartVersion: ">=0.0.0"})
+		var mismatchErr *ChartMismatchError
+		assert.ErrorAs(t, err, &mismatchErr)
+	})
+
+	t.Run("rejects an invalid constraint", func(t *testing.T) {
+		cfg, _ := newConfig(t, "1.2.3")
+
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusDeployed, SetStatusOptions{ExpectChartVersion: "not a version"})
+		assert.ErrorContains(t, err, `invalid chart version constraint "not a version"`)
+	})
+}
+
+func TestSetStatus_SkipUnmetPreconditions(t *testing.T) {
+	newConfig := func(t *testing.T) (*action.Configuration, *storage.Storage) {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		rel := &release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   1,
+			Info: &release.Info{
+				Status:      release.StatusDeployed,
+				Description: "Upgrade complete",
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}
+		require.NoError(t, store.Create(rel))
+		return &action.Configuration{Releases: store}, store
+	}
+
+	t.Run("reports an unmet precondition as skipped", func(t *testing.T) {
+		cfg, store := newConfig(t)
+
+		result, err := SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, SetStatusOptions{
+			AllowedFromStatuses:    []release.Status{release.StatusPendingUpgrade},
+			SkipUnmetPreconditions: true,
+		})
+		require.NoError(t, err)
+		assert.True(t, result.Skipped)
+		assert.False(t, result.Changed)
+		assert.Equal(t, `current status "deployed" not in allowed list [pending-upgrade]; cannot set to "failed" (release description: "Upgrade complete")`, result.SkipReason)
+		assert.Equal(t, release.StatusDeployed, result.PreviousStatus)
+		assert.Equal(t, 1, result.Release.Version)
+
+		rel, err := store.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusDeployed, rel.Info.Status)
+	})
+
+	t.Run("does not skip when preconditions are met", func(t *testing.T) {
+		cfg, _ := newConfig(t)
+
+		result, err := SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, SetStatusOptions{
+			AllowedFromStatuses:    []release.Status{release.StatusDeployed},
+			SkipUnmetPreconditions: true,
+		})
+		require.NoError(t, err)
+		assert.False(t, result.Skipped)
+		assert.Empty(t, result.SkipReason)
+		assert.True(t, result.Changed)
+	})
+
+	t.Run("still returns transition errors", func(t *testing.T) {
+		cfg, _ := newConfig(t)
+
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusPendingInstall, SetStatusOptions{
+			StrictTransitions:      true,
+			SkipUnmetPreconditions: true,
+		})
+		var transitionErr *InvalidTransitionError
+		assert.ErrorAs(t, err, &transitionErr)
+	})
+}
+
+func TestSetStatus_Labels(t *testing.T) {
+	newRelease := func() *release.Release {
+		return &release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   1,
+			Info: &release.Info{
+				Status: release.StatusPendingUpgrade,
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+			Labels: map[string]string{"team": "platform"},
+		}
+	}
+
+	labels := map[string]string{
+		"helm-set-status/changed-by": "ci-bot",
+		"helm-set-status/changed-at": "1717243200",
+	}
+
+	t.Run("labels survive a round trip through the secrets driver", func(t *testing.T) {
+		secrets := driver.NewSecrets(fake.NewClientset().CoreV1().Secrets("default"))
+		store := storage.Init(secrets)
+		require.NoError(t, store.Create(newRelease()))
+		cfg := &action.Configuration{Releases: store}
+
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, SetStatusOptions{Labels: labels})
+		require.NoError(t, err)
+
+		updated, err := store.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusFailed, updated.Info.Status)
+		assert.Equal(t, "ci-bot", updated.Labels["helm-set-status/changed-by"])
+		assert.Equal(t, "1717243200", updated.Labels["helm-set-status/changed-at"])
+		assert.Equal(t, "platform", updated.Labels["team"])
+	})
+
+	t.Run("labels are added to a release without labels", func(t *testing.T) {
+		store := storage.Init(driver.NewMemory())
+		rel := newRelease()
+		rel.Labels = nil
+		require.NoError(t, store.Create(rel))
+		cfg := &action.Configuration{Releases: store}
+
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, SetStatusOptions{Labels: labels})
+		require.NoError(t, err)
+
+		updated, err := store.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, labels, updated.Labels)
+	})
+
+	t.Run("invalid labels are rejected before reading the release", func(t *testing.T) {
+		cfg := &action.Configuration{Releases: storage.Init(driver.NewMemory())}
+
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, SetStatusOptions{Labels: map[string]string{"status": "x"}})
+		require.Error(t, err)
+		assert.Equal(t, `invalid label "status": reserved by Helm`, err.Error())
+
+		_, err = SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, SetStatusOptions{Labels: map[string]string{"bad key!": "x"}})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `invalid label key "bad key!"`)
+
+		_, err = SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, SetStatusOptions{Labels: map[string]string{"changed-at": "2024-06-01T12:00:00Z"}})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `invalid value "2024-06-01T12:00:00Z" for label "changed-at"`)
+	})
+
+	t.Run("removes labels", func(t *testing.T) {
+		store := storage.Init(driver.NewMemory())
+		rel := newRelease()
+		rel.Labels = map[string]string{"team": "platform", "ticket": "OPS-1"}
+		require.NoError(t, store.Create(rel))
+		cfg := &action.Configuration{Releases: store}
+
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, SetStatusOptions{RemoveLabels: []string{"ticket", "missing"}})
+		require.NoError(t, err)
+
+		updated, err := store.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"team": "platform"}, updated.Labels)
+
+		_, err = SetStatus(t.Context(), cfg, "test-release", release.StatusDeployed, SetStatusOptions{RemoveLabels: []string{"owner"}})
+		require.Error(t, err)
+		assert.Equal(t, `invalid label "owner": reserved by Helm`, err.Error())
+	})
+}
+
+func TestSetStatus_RelativeRevision(t *testing.T) {
+	newConfig := func(t *testing.T) (*action.Configuration, *storage.Storage) {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		for _, r := range []struct {
+			version int
+			status  release.Status
+		}{
+			{1, release.StatusSuperseded},
+			{2, release.StatusSuperseded},
+			{4, release.StatusDeployed},
+		} {
+			require.NoError(t, store.Create(&release.Release{
+				Name:      "test-release",
+				Namespace: "default",
+				Version:   r.version,
+				Info: &release.Info{
+					Status: r.status,
+				},
+				Chart: &chart.Chart{
+					Metadata: &chart.Metadata{
+						Name:    "test-chart",
+						Version: "1.0.0",
+					},
+				},
+			}))
+		}
+		return &action.Configuration{Releases: store}, store
+	}
+
+	t.Run("-1 selects the revision before the latest", func(t *testing.T) {
+		cfg, store := newConfig(t)
+
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, SetStatusOptions{Revision: -1})
+		require.NoError(t, err)
+
+		// Revision 3 does not exist, so the one before the latest is 2
+		previous, err := store.Get("test-release", 2)
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusFailed, previous.Info.Status)
+
+		latest, err := store.Get("test-release", 4)
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusDeployed, latest.Info.Status)
+	})
+
+	t.Run("reads relative revisions", func(t *testing.T) {
+		cfg, _ := newConfig(t)
+
+		current, err := GetStatus(cfg, "test-release", -2)
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusSuperseded, current)
+	})
+
+	t.Run("offset out of range", func(t *testing.T) {
+		cfg, _ := newConfig(t)
+
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, SetStatusOptions{Revision: -3})
+		require.Error(t, err)
+		assert.Equal(t, `revision offset -3 is out of range: release "test-release" has 3 revisions`, err.Error())
+	})
+
+	t.Run("release not found", func(t *testing.T) {
+		cfg := &action.Configuration{Releases: storage.Init(driver.NewMemory())}
+
+		_, err := SetStatus(t.Context(), cfg, "missing", release.StatusFailed, SetStatusOptions{Revision: -1})
+		var notFoundErr *ReleaseNotFoundError
+		assert.True(t, errors.As(err, &notFoundErr), "error should be *ReleaseNotFoundError")
+	})
+}
+
+func TestSetStatus_AppendDescription(t *testing.T) {
+	fixedNow := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	originalNow := now
+	now = func() time.Time { return fixedNow }
+	defer func() { now = originalNow }()
+
+	newConfig := func(t *testing.T, description string) (*action.Configuration, *storage.Storage) {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		rel := &release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   1,
+			Info: &release.Info{
+				Status:      release.StatusDeployed,
+				Description: description,
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}
+		require.NoError(t, store.Create(rel))
+		return &action.Configuration{Releases: store}, store
+	}
+
+	t.Run("retains the prior description", func(t *testing.T) {
+		cfg, store := newConfig(t, "Upgrade complete")
+
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, SetStatusOptions{Description: "smoke tests failed", AppendDescription: true})
+		require.NoError(t, err)
+
+		updated, err := store.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, "Upgrade complete\n2024-06-01T12:00:00Z: smoke tests failed", updated.Info.Description)
+	})
+
+	t.Run("appends the default description", func(t *testing.T) {
+		cfg, store := newConfig(t, "Upgrade complete")
+
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, SetStatusOptions{AppendDescription: true})
+		require.NoError(t, err)
+
+		updated, err := store.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, "Upgrade complete\n2024-06-01T12:00:00Z: status set to failed", updated.Info.Description)
+	})
+
+	t.Run("empty prior description", func(t *testing.T) {
+		cfg, store := newConfig(t, "")
+
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, SetStatusOptions{AppendDescription: true})
+		require.NoError(t, err)
+
+		updated, err := store.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, "2024-06-01T12:00:00Z: status set to failed", updated.Info.Description)
+	})
+}
+
+// concurrentWriterDriver wraps a memory driver and, for a number of Get
+// calls, replaces the stored release with a copy modified by "another
+// process" before returning it.
+type concurrentWriterDriver struct {
+	*driver.Memory
+	conflicts int
+	updates   int
+}
+
+func (d *concurrentWriterDriver) Get(key string) (*release.Release, error) {
+	rel, err := d.Memory.Get(key)
+	if err != nil || d.conflicts == 0 {
+		return rel, err
+	}
+	d.conflicts--
+	modified := *rel
+	info := *rel.Info
+	info.Description = fmt.Sprintf("upgraded by another process (%d left)", d.conflicts)
+	modified.Info = &info
+	if err := d.Memory.Update(key, &modified); err != nil {
+		return nil, err
+	}
+	return &modified, nil
+}
+
+func (d *concurrentWriterDriver) Update(key string, rls *release.Release) error {
+	d.updates++
+	return d.Memory.Update(key, rls)
+}
+
+func TestSetStatus_ConcurrentModification(t *testing.T) {
+	newConfig := func(t *testing.T, conflicts int) (*action.Configuration, *concurrentWriterDriver) {
+		t.Helper()
+		mem := driver.NewMemory()
+		rel := &release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   1,
+			Info: &release.Info{
+				Status: release.StatusPendingUpgrade,
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}
+		require.NoError(t, mem.Create("sh.helm.release.v1.test-release.v1", rel))
+		d := &concurrentWriterDriver{Memory: mem, conflicts: conflicts}
+		return &action.Configuration{Releases: storage.Init(d)}, d
+	}
+
+	t.Run("retries after a concurrent write", func(t *testing.T) {
+		cfg, d := newConfig(t, 1)
+
+		result, err := SetStatus(t.Context(), cfg, "test-release", release.StatusDeployed, SetStatusOptions{MaxRetries: 1})
+		require.NoError(t, err)
+		assert.True(t, result.Changed)
+		assert.Equal(t, 1, d.updates)
+
+		updated, err := cfg.Releases.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusDeployed, updated.Info.Status)
+	})
+
+	t.Run("returns a ConflictError once retries are used up", func(t *testing.T) {
+		cfg, d := newConfig(t, 3)
+
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusDeployed, SetStatusOptions{MaxRetries: 2})
+		var conflictErr *ConflictError
+		require.ErrorAs(t, err, &conflictErr)
+		assert.Equal(t, 3, conflictErr.Attempts)
+		assert.Equal(t, `release "test-release" revision 1 was modified by another process while its status was being set (3 attempts)`, err.Error())
+		assert.Equal(t, 0, d.updates)
+
+		stored, err := cfg.Releases.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusPendingUpgrade, stored.Info.Status)
+		assert.Equal(t, "upgraded by another process (0 left)", stored.Info.Description)
+	})
+}
+
+// conflictingUpdateDriver wraps a memory driver and rejects the first
+// conflicts Update calls with a Kubernetes conflict error, as the API server
+// does when the stored object changed after it was read. Get and Query
+// return copies so that a rejected update leaves the stored release
+// untouched.
+type conflictingUpdateDriver struct {
+	*driver.Memory
+	conflicts int
+	updates   int
+}
+
+func (d *conflictingUpdateDriver) Get(key string) (*release.Release, error) {
+	rel, err := d.Memory.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return copyRelease(rel), nil
+}
+
+func (d *conflictingUpdateDriver) Query(labels map[string]string) ([]*release.Release, error) {
+	rels, err := d.Memory.Query(labels)
+	for i := range rels {
+		rels[i] = copyRelease(rels[i])
+	}
+	return rels, err
+}
+
+// copyRelease returns a copy of rel that can be modified without changing
+// the stored release.
+func copyRelease(rel *release.Release) *release.Release {
+	copied := *rel
+	info := *rel.Info
+	copied.Info = &info
+	return &copied
+}
+
+func (d *conflictingUpdateDriver) Update(key string, rls *release.Release) error {
+	d.updates++
+	if d.updates <= d.conflicts {
+		return apierrors.NewConflict(schema.GroupResource{Resource: "secrets"}, key, errors.New("the object has been modified"))
+	}
+	return d.Memory.Update(key, rls)
+}
+
+func TestSetStatus_RetryOnConflict(t *testing.T) {
+	newConfig := func(t *testing.T, conflicts int) (*action.Configuration, *conflictingUpdateDriver) {
+		t.Helper()
+		mem := driver.NewMemory()
+		rel := &release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   1,
+			Info: &release.Info{
+				Status: release.StatusPendingUpgrade,
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}
+		require.NoError(t, mem.Create("sh.helm.release.v1.test-release.v1", rel))
+		d := &conflictingUpdateDriver{Memory: mem, conflicts: conflicts}
+		return &action.Configuration{Releases: storage.Init(d)}, d
+	}
+
+	t.Run("retries an update rejected as a conflict", func(t *testing.T) {
+		cfg, d := newConfig(t, 1)
+
+		start := time.Now()
+		result, err := SetStatus(t.Context(), cfg, "test-release", release.StatusDeployed, SetStatusOptions{
+			MaxRetries:      3,
+			RetryOnConflict: true,
+			RetryDelay:      20 * time.Millisecond,
+		})
+		require.NoError(t, err)
+		assert.True(t, result.Changed)
+		assert.Equal(t, 2, d.updates)
+		assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond, "the retry should wait for RetryDelay")
+
+		updated, err := cfg.Releases.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusDeployed, updated.Info.Status)
+	})
+
+	t.Run("returns a ConflictError once retries are used up", func(t *testing.T) {
+		cfg, d := newConfig(t, 5)
+
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusDeployed, SetStatusOptions{MaxRetries: 2, RetryOnConflict: true})
+		var conflictErr *ConflictError
+		require.ErrorAs(t, err, &conflictErr)
+		assert.Equal(t, 3, conflictErr.Attempts)
+		assert.Equal(t, 3, d.updates)
+	})
+
+	t.Run("does not retry without RetryOnConflict", func(t *testing.T) {
+		cfg, d := newConfig(t, 1)
+
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusDeployed, SetStatusOptions{MaxRetries: 3})
+		require.Error(t, err)
+		assert.True(t, apierrors.IsConflict(err))
+		assert.Equal(t, 1, d.updates)
+	})
+
+	t.Run("does not retry other update errors", func(t *testing.T) {
+		mem := driver.NewMemory()
+		require.NoError(t, mem.Create("test-release.v1", &release.Release{
+			Name:    "test-release",
+			Version: 1,
+			Info:    &release.Info{Status: release.StatusDeployed},
+		}))
+		cfg := &action.Configuration{Releases: storage.Init(&failingUpdateDriver{Memory: mem})}
+
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusFailed, SetStatusOptions{MaxRetries: 3, RetryOnConflict: true})
+		assert.ErrorContains(t, err, "failed to update release")
+		var conflictErr *ConflictError
+		assert.False(t, errors.As(err, &conflictErr))
+	})
+
+	t.Run("stops waiting when the context is cancelled", func(t *testing.T) {
+		cfg, _ := newConfig(t, 1)
+		ctx, cancel := context.WithTimeout(t.Context(), 20*time.Millisecond)
+		defer cancel()
+
+		_, err := SetStatus(ctx, cfg, "test-release", release.StatusDeployed, SetStatusOptions{
+			MaxRetries:      1,
+			RetryOnConflict: true,
+			RetryDelay:      time.Hour,
+		})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestSetStatus_BeforeUpdate(t *testing.T) {
+	newConfig := func(t *testing.T) *action.Configuration {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		require.NoError(t, store.Create(&release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   1,
+			Info:      &release.Info{Status: release.StatusPendingUpgrade},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}))
+		return &action.Configuration{Releases: store}
+	}
+
+	t.Run("is called before the status is written", func(t *testing.T) {
+		cfg := newConfig(t)
+		var calls int
+		var gotStatus, gotTarget release.Status
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusDeployed, SetStatusOptions{
+			BeforeUpdate: func(_ context.Context, rel *release.Release, status release.Status) error {
+				calls++
+				gotStatus, gotTarget = rel.Info.Status, status
+				return nil
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, release.StatusPendingUpgrade, gotStatus)
+		assert.Equal(t, release.StatusDeployed, gotTarget)
+	})
+
+	t.Run("aborts the change when it fails", func(t *testing.T) {
+		cfg := newConfig(t)
+		result, err := SetStatus(t.Context(), cfg, "test-release", release.StatusDeployed, SetStatusOptions{
+			BeforeUpdate: func(context.Context, *release.Release, release.Status) error {
+				return errors.New("ticket is closed")
+			},
+		})
+		var hookErr *BeforeUpdateError
+		require.ErrorAs(t, err, &hookErr)
+		assert.Equal(t, `update of release "test-release" revision 1 aborted: ticket is closed`, err.Error())
+		assert.False(t, result.Changed)
+
+		rel, err := cfg.Releases.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusPendingUpgrade, rel.Info.Status)
+	})
+
+	t.Run("is called once when the update is retried", func(t *testing.T) {
+		mem := driver.NewMemory()
+		require.NoError(t, mem.Create("sh.helm.release.v1.test-release.v1", &release.Release{
+			Name:    "test-release",
+			Version: 1,
+			Info:    &release.Info{Status: release.StatusPendingUpgrade},
+		}))
+		cfg := &action.Configuration{Releases: storage.Init(&conflictingUpdateDriver{Memory: mem, conflicts: 1})}
+
+		calls := 0
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusDeployed, SetStatusOptions{
+			MaxRetries:      1,
+			RetryOnConflict: true,
+			BeforeUpdate: func(context.Context, *release.Release, release.Status) error {
+				calls++
+				return nil
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("is not called when nothing is written", func(t *testing.T) {
+		called := false
+		beforeUpdate := func(context.Context, *release.Release, release.Status) error {
+			called = true
+			return nil
+		}
+		for _, opts := range []SetStatusOptions{
+			{DryRun: true, BeforeUpdate: beforeUpdate},
+			{AllowedFromStatuses: []release.Status{release.StatusFailed}, SkipUnmetPreconditions: true, BeforeUpdate: beforeUpdate},
+		} {
+			_, err := SetStatus(t.Context(), newConfig(t), "test-release", release.StatusDeployed, opts)
+			require.NoError(t, err)
+		}
+		_, err := SetStatus(t.Context(), newConfig(t), "test-release", release.StatusPendingUpgrade, SetStatusOptions{BeforeUpdate: beforeUpdate})
+		require.NoError(t, err)
+		assert.False(t, called)
+	})
+}
+
+// blockingUpdateDriver wraps a memory driver and blocks every Update until
+// release is closed.
+type blockingUpdateDriver struct {
+	*driver.Memory
+	release chan struct{}
+}
+
+func (d *blockingUpdateDriver) Update(key string, rls *release.Release) error {
+	<-d.release
+	return d.Memory.Update(key, rls)
+}
+
+func TestSetStatus_SlowStorage(t *testing.T) {
+	mem := driver.NewMemory()
+	rel := &release.Release{
+		Name:      "test-release",
+		Namespace: "default",
+		Version:   1,
+		Info: &release.Info{
+			Status: release.StatusDeployed,
+		},
+		Chart: &chart.Chart{
+			Metadata: &chart.Metadata{
+				Name:    "test-chart",
+				Version: "1.0.0",
+			},
+		},
+	}
+	require.NoError(t, mem.Create("sh.helm.release.v1.test-release.v1", rel))
+	d := &blockingUpdateDriver{Memory: mem, release: make(chan struct{})}
+	t.Cleanup(func() { close(d.release) })
+	cfg := &action.Configuration{Releases: storage.Init(d)}
+
+	ctx, cancel := context.WithTimeout(t.Context(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	result, err := SetStatus(ctx, cfg, "test-release", release.StatusFailed, SetStatusOptions{})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	var unknownErr *OutcomeUnknownError
+	require.True(t, errors.As(err, &unknownErr), "error should be *OutcomeUnknownError")
+	assert.Equal(t, "stopped setting status of release test-release while it was being written, so it may or may not have changed: context deadline exceeded", err.Error())
+	assert.True(t, result.Changed)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+// cancelOnReadDriver wraps a memory driver and cancels a context whenever
+// releases are queried, as if it were cancelled while the release was read.
+type cancelOnReadDriver struct {
+	*driver.Memory
+	cancel  context.CancelFunc
+	updates int
+}
+
+func (d *cancelOnReadDriver) Query(labels map[string]string) ([]*release.Release, error) {
+	d.cancel()
+	return d.Memory.Query(labels)
+}
+
+func (d *cancelOnReadDriver) Update(key string, rls *release.Release) error {
+	d.updates++
+	return d.Memory.Update(key, rls)
+}
+
+func TestSetStatus_CancelledAfterRead(t *testing.T) {
+	mem := driver.NewMemory()
+	rel := &release.Release{
+		Name:      "test-release",
+		Namespace: "default",
+		Version:   1,
+		Info: &release.Info{
+			Status: release.StatusDeployed,
+		},
+	}
+	require.NoError(t, mem.Create("sh.helm.release.v1.test-release.v1", rel))
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	d := &cancelOnReadDriver{Memory: mem, cancel: cancel}
+	cfg := &action.Configuration{Releases: storage.Init(d)}
+
+	result, err := setStatus(ctx, cfg, "test-release", release.StatusFailed, SetStatusOptions{}, slog.New(slog.DiscardHandler), &writeGate{})
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, "stopped setting status of release test-release: context canceled", err.Error())
+	assert.False(t, result.Changed)
+	assert.Equal(t, 0, d.updates)
+
+	stored, err := mem.Get("sh.helm.release.v1.test-release.v1")
+	require.NoError(t, err)
+	assert.Equal(t, release.StatusDeployed, stored.Info.Status)
+}
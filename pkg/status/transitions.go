@@ -0,0 +1,94 @@
+package status
+
+import (
+	"fmt"
+
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// transitions maps each status to the statuses Helm itself moves a release
+// to from it during install, upgrade, rollback, and uninstall.
+var transitions = map[release.Status][]release.Status{
+	// Unknown carries no lifecycle information, so any status may follow it.
+	release.StatusUnknown: {
+		release.StatusDeployed,
+		release.StatusSuperseded,
+		release.StatusFailed,
+		release.StatusUninstalling,
+		release.StatusPendingInstall,
+		release.StatusPendingUpgrade,
+		release.StatusPendingRollback,
+	},
+	// A deployed release is superseded by an upgrade or rollback, or uninstalled.
+	release.StatusDeployed: {
+		release.StatusSuperseded,
+		release.StatusFailed,
+		release.StatusUninstalling,
+	},
+	// A superseded revision can be brought back by a rollback, or uninstalled.
+	release.StatusSuperseded: {
+		release.StatusDeployed,
+		release.StatusUninstalling,
+	},
+	// A failed revision is superseded by the next revision, or uninstalled.
+	release.StatusFailed: {
+		release.StatusSuperseded,
+		release.StatusUninstalling,
+	},
+	// An uninstall that does not complete leaves the release failed.
+	release.StatusUninstalling: {
+		release.StatusFailed,
+	},
+	// Pending operations complete as deployed or failed.
+	release.StatusPendingInstall: {
+		release.StatusDeployed,
+		release.StatusFailed,
+	},
+	release.StatusPendingUpgrade: {
+		release.StatusDeployed,
+		release.StatusFailed,
+	},
+	release.StatusPendingRollback: {
+		release.StatusDeployed,
+		release.StatusFailed,
+	},
+}
+
+// InvalidTransitionError is returned when strict transitions are enabled and
+// the requested status change does not follow Helm's release lifecycle.
+type InvalidTransitionError struct {
+	ReleaseName string
+	From        release.Status
+	To          release.Status
+}
+
+func (e *InvalidTransitionError) Error() string {
+	return fmt.Sprintf("invalid transition from %q to %q", e.From, e.To)
+}
+
+// MaskedFailureError is returned when SafeMode is set and a failed release
+// would be set to deployed, which would hide the failure from Helm and from
+// anyone reading the release.
+type MaskedFailureError struct {
+	ReleaseName        string
+	Revision           int
+	CurrentDescription string
+}
+
+func (e *MaskedFailureError) Error() string {
+	msg := fmt.Sprintf("release %q revision %d is %q; setting it to %q would hide the failure",
+		e.ReleaseName, e.Revision, release.StatusFailed, release.StatusDeployed)
+	if e.CurrentDescription != "" {
+		msg += fmt.Sprintf(" (release description: %q)", e.CurrentDescription)
+	}
+	return msg
+}
+
+// IsValidTransition reports whether Helm's release lifecycle allows a release
+// to move from one status to another. Keeping the same status is always valid.
+func IsValidTransition(from, to release.Status) bool {
+	if from == to {
+		return true
+	}
+	return containsStatus(transitions[from], to)
+}
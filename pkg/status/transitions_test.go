@@ -0,0 +1,168 @@
+package status
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+func TestIsValidTransition(t *testing.T) {
+	allowed := map[release.Status][]release.Status{
+		release.StatusUnknown: {
+			release.StatusDeployed, release.StatusSuperseded, release.StatusFailed, release.StatusUninstalling,
+			release.StatusPendingInstall, release.StatusPendingUpgrade, release.StatusPendingRollback,
+		},
+		release.StatusDeployed:        {release.StatusSuperseded, release.StatusFailed, release.StatusUninstalling},
+		release.StatusSuperseded:      {release.StatusDeployed, release.StatusUninstalling},
+		release.StatusFailed:          {release.StatusSuperseded, release.StatusUninstalling},
+		release.StatusUninstalling:    {release.StatusFailed},
+		release.StatusPendingInstall:  {release.StatusDeployed, release.StatusFailed},
+		release.StatusPendingUpgrade:  {release.StatusDeployed, release.StatusFailed},
+		release.StatusPendingRollback: {release.StatusDeployed, release.StatusFailed},
+	}
+
+	all := []release.Status{
+		release.StatusUnknown,
+		release.StatusDeployed,
+		release.StatusSuperseded,
+		release.StatusFailed,
+		release.StatusUninstalling,
+		release.StatusPendingInstall,
+		release.StatusPendingUpgrade,
+		release.StatusPendingRollback,
+	}
+
+	for _, from := range all {
+		for _, to := range all {
+			expected := from == to || containsStatus(allowed[from], to)
+			assert.Equal(t, expected, IsValidTransition(from, to), "%s -> %s", from, to)
+		}
+	}
+}
+
+func TestSetStatus_StrictTransitions(t *testing.T) {
+	newConfig := func(t *testing.T, current release.Status) (*action.Configuration, *storage.Storage) {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		rel := &release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   1,
+			Info: &release.Info{
+				Status: current,
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}
+		require.NoError(t, store.Create(rel))
+		return &action.Configuration{Releases: store}, store
+	}
+
+	t.Run("rejects invalid transition", func(t *testing.T) {
+		cfg, store := newConfig(t, release.StatusUninstalling)
+
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusPendingInstall, SetStatusOptions{StrictTransitions: true})
+		var transitionErr *InvalidTransitionError
+		require.True(t, errors.As(err, &transitionErr), "error should be *InvalidTransitionError")
+		assert.Equal(t, release.StatusUninstalling, transitionErr.From)
+		assert.Equal(t, release.StatusPendingInstall, transitionErr.To)
+		assert.Equal(t, `invalid transition from "uninstalling" to "pending-install"`, err.Error())
+
+		unchanged, err := store.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusUninstalling, unchanged.Info.Status)
+	})
+
+	t.Run("allows valid transition", func(t *testing.T) {
+		cfg, store := newConfig(t, release.StatusPendingUpgrade)
+
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusDeployed, SetStatusOptions{StrictTransitions: true})
+		require.NoError(t, err)
+
+		updated, err := store.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusDeployed, updated.Info.Status)
+	})
+
+	t.Run("is permissive by default", func(t *testing.T) {
+		cfg, store := newConfig(t, release.StatusUninstalling)
+
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusPendingInstall, SetStatusOptions{})
+		require.NoError(t, err)
+
+		updated, err := store.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusPendingInstall, updated.Info.Status)
+	})
+}
+
+func TestSetStatus_SafeMode(t *testing.T) {
+	newConfig := func(t *testing.T, current release.Status) (*action.Configuration, *storage.Storage) {
+		t.Helper()
+		store := storage.Init(driver.NewMemory())
+		require.NoError(t, store.Create(&release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   2,
+			Info: &release.Info{
+				Status:      current,
+				Description: "Upgrade \"test-release\" failed: timed out waiting for the condition",
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}))
+		return &action.Configuration{Releases: store}, store
+	}
+
+	t.Run("refuses to set a failed release to deployed", func(t *testing.T) {
+		cfg, store := newConfig(t, release.StatusFailed)
+
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusDeployed, SetStatusOptions{SafeMode: true})
+		var maskedErr *MaskedFailureError
+		require.ErrorAs(t, err, &maskedErr)
+		assert.Equal(t, 2, maskedErr.Revision)
+		assert.Equal(t, `release "test-release" revision 2 is "failed"; setting it to "deployed" would hide the failure `+
+			`(release description: "Upgrade \"test-release\" failed: timed out waiting for the condition")`, err.Error())
+
+		unchanged, err := store.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusFailed, unchanged.Info.Status)
+	})
+
+	t.Run("is bypassed by Force", func(t *testing.T) {
+		cfg, store := newConfig(t, release.StatusFailed)
+
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusDeployed, SetStatusOptions{SafeMode: true, Force: true})
+		require.NoError(t, err)
+
+		updated, err := store.Last("test-release")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusDeployed, updated.Info.Status)
+	})
+
+	t.Run("allows other changes", func(t *testing.T) {
+		for _, tc := range []struct{ from, to release.Status }{
+			{release.StatusPendingUpgrade, release.StatusDeployed},
+			{release.StatusFailed, release.StatusSuperseded},
+		} {
+			cfg, _ := newConfig(t, tc.from)
+			_, err := SetStatus(t.Context(), cfg, "test-release", tc.to, SetStatusOptions{SafeMode: true})
+			assert.NoError(t, err, "%s to %s", tc.from, tc.to)
+		}
+	})
+}
@@ -0,0 +1,92 @@
+package status
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// waitPollInterval is the delay before the first read-back while waiting for
+// a status change to persist; later read-backs back off from it. This can be
+// overridden for testing.
+var waitPollInterval = 500 * time.Millisecond
+
+// DefaultWaitMaxInterval is the longest delay between read-backs while
+// waiting for a status change to persist, used when
+// SetStatusOptions.WaitMaxInterval is not set.
+const DefaultWaitMaxInterval = 5 * time.Second
+
+// WatchStatus polls the latest revision of a release every interval until it
+// reports the expected status, returning an error if timeout elapses or ctx
+// is cancelled first. A release that cannot be read yet is polled again
+// rather than failing.
+func WatchStatus(ctx context.Context, cfg *action.Configuration, releaseName string, expected release.Status, interval, timeout time.Duration) error {
+	if err := checkStorage(cfg); err != nil {
+		return err
+	}
+	return pollStatus(ctx, cfg, releaseName, 0, expected, func(int) time.Duration { return interval }, timeout)
+}
+
+// waitForStatus re-reads a release until it reports the expected status or
+// the timeout elapses, backing off exponentially between reads up to
+// maxInterval.
+func waitForStatus(ctx context.Context, cfg *action.Configuration, releaseName string, revision int, expected release.Status, maxInterval, timeout time.Duration) error {
+	if maxInterval <= 0 {
+		maxInterval = DefaultWaitMaxInterval
+	}
+	delay := func(attempt int) time.Duration {
+		return backoffDelay(waitPollInterval, maxInterval, attempt)
+	}
+	return pollStatus(ctx, cfg, releaseName, revision, expected, delay, timeout)
+}
+
+// backoffDelay returns the delay before read-back attempt (counting from 0):
+// initial doubled once per attempt and capped at maxInterval, with up to half
+// of it replaced by random jitter so that concurrent waiters spread out. The
+// delay for an attempt is never shorter than the delay for the one before,
+// until the cap is reached.
+func backoffDelay(initial, maxInterval time.Duration, attempt int) time.Duration {
+	d := min(initial, maxInterval)
+	for range attempt {
+		if d >= maxInterval {
+			break
+		}
+		d = min(2*d, maxInterval)
+	}
+	if half := d / 2; half > 0 {
+		return half + rand.N(d-half+1)
+	}
+	return d
+}
+
+// pollStatus reads a release until it reports the expected status, the
+// timeout elapses, or ctx is cancelled, sleeping for delay(attempt) between
+// reads.
+func pollStatus(ctx context.Context, cfg *action.Configuration, releaseName string, revision int, expected release.Status, delay func(attempt int) time.Duration, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for attempt := 0; ; attempt++ {
+		rel, err := getRelease(cfg, releaseName, revision)
+		if err == nil && rel.Info.Status == expected {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			if err != nil {
+				return fmt.Errorf("timed out waiting for release %s to report status %s: %w", releaseName, expected, err)
+			}
+			return fmt.Errorf("timed out waiting for release %s to report status %s (last seen %s)", releaseName, expected, rel.Info.Status)
+		}
+
+		// Read once more at the deadline rather than sleeping past it
+		wait := min(delay(attempt), time.Until(deadline)+time.Millisecond)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("stopped waiting for release %s to report status %s: %w", releaseName, expected, ctx.Err())
+		case <-time.After(wait):
+		}
+	}
+}
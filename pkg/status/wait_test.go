@@ -0,0 +1,249 @@
+package status
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+// staleReadDriver wraps a memory driver and keeps returning the pre-update
+// status for a number of reads after each Update.
+type staleReadDriver struct {
+	*driver.Memory
+	staleReads int
+	remaining  int
+	reads      int
+	stale      release.Status
+}
+
+func (d *staleReadDriver) Update(key string, rls *release.Release) error {
+	d.remaining = d.staleReads
+	return d.Memory.Update(key, rls)
+}
+
+func (d *staleReadDriver) Get(key string) (*release.Release, error) {
+	rel, err := d.Memory.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	d.reads++
+	if d.remaining > 0 {
+		d.remaining--
+		stale := *rel
+		info := *rel.Info
+		info.Status = d.stale
+		stale.Info = &info
+		return &stale, nil
+	}
+	return rel, nil
+}
+
+func newStaleReadConfig(t *testing.T, staleReads int) (*action.Configuration, *staleReadDriver) {
+	t.Helper()
+
+	mem := driver.NewMemory()
+	rel := &release.Release{
+		Name:      "test-release",
+		Namespace: "default",
+		Version:   1,
+		Info: &release.Info{
+			Status: release.StatusPendingUpgrade,
+		},
+		Chart: &chart.Chart{
+			Metadata: &chart.Metadata{
+				Name:    "test-chart",
+				Version: "1.0.0",
+			},
+		},
+	}
+	require.NoError(t, mem.Create("sh.helm.release.v1.test-release.v1", rel))
+
+	d := &staleReadDriver{Memory: mem, staleReads: staleReads, stale: release.StatusPendingUpgrade}
+	return &action.Configuration{Releases: storage.Init(d)}, d
+}
+
+func TestSetStatus_Wait(t *testing.T) {
+	origInterval := waitPollInterval
+	waitPollInterval = time.Millisecond
+	defer func() { waitPollInterval = origInterval }()
+
+	t.Run("succeeds once the status is read back", func(t *testing.T) {
+		cfg, d := newStaleReadConfig(t, 2)
+
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusDeployed, SetStatusOptions{Revision: 1, WaitTimeout: time.Second})
+		require.NoError(t, err)
+		// One read for the lookup, one conflict check, two stale read-backs,
+		// one fresh read-back
+		assert.Equal(t, 5, d.reads)
+	})
+
+	t.Run("fails when the status never converges", func(t *testing.T) {
+		cfg, _ := newStaleReadConfig(t, 1<<30)
+
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusDeployed, SetStatusOptions{Revision: 1, WaitTimeout: 20 * time.Millisecond})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "timed out waiting for release test-release to report status deployed")
+		assert.Contains(t, err.Error(), "last seen pending-upgrade")
+	})
+
+	t.Run("does nothing once the context is cancelled", func(t *testing.T) {
+		cfg, d := newStaleReadConfig(t, 2)
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+
+		_, err := SetStatus(ctx, cfg, "test-release", release.StatusDeployed, SetStatusOptions{Revision: 1, WaitTimeout: time.Second})
+		require.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 0, d.reads)
+	})
+
+	t.Run("does not read back without a timeout", func(t *testing.T) {
+		cfg, d := newStaleReadConfig(t, 2)
+
+		_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusDeployed, SetStatusOptions{Revision: 1})
+		require.NoError(t, err)
+		// One read for the lookup, one conflict check
+		assert.Equal(t, 2, d.reads)
+	})
+}
+
+func TestWaitForStatus_ReadError(t *testing.T) {
+	origInterval := waitPollInterval
+	waitPollInterval = time.Millisecond
+	defer func() { waitPollInterval = origInterval }()
+
+	cfg := &action.Configuration{Releases: storage.Init(driver.NewMemory())}
+
+	err := waitForStatus(t.Context(), cfg, "missing", 0, release.StatusDeployed, 0, 5*time.Millisecond)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "timed out waiting for release missing")
+	assert.Contains(t, err.Error(), "not found")
+}
+
+func TestBackoffDelay(t *testing.T) {
+	initial, maxInterval := 10*time.Millisecond, time.Second
+
+	t.Run("intervals grow across attempts up to the cap", func(t *testing.T) {
+		var prev time.Duration
+		for attempt := range 12 {
+			ceiling := min(initial<<attempt, maxInterval)
+			d := backoffDelay(initial, maxInterval, attempt)
+			assert.GreaterOrEqual(t, d, ceiling/2, "attempt %d", attempt)
+			assert.LessOrEqual(t, d, ceiling, "attempt %d", attempt)
+			if ceiling < maxInterval {
+				assert.GreaterOrEqual(t, d, prev, "attempt %d", attempt)
+			}
+			prev = d
+		}
+	})
+
+	t.Run("does not overflow after many attempts", func(t *testing.T) {
+		d := backoffDelay(initial, maxInterval, 1000)
+		assert.GreaterOrEqual(t, d, maxInterval/2)
+		assert.LessOrEqual(t, d, maxInterval)
+	})
+
+	t.Run("caps the first interval", func(t *testing.T) {
+		assert.LessOrEqual(t, backoffDelay(time.Minute, maxInterval, 0), maxInterval)
+	})
+}
+
+func TestSetStatus_WaitMaxInterval(t *testing.T) {
+	origInterval := waitPollInterval
+	waitPollInterval = time.Hour
+	defer func() { waitPollInterval = origInterval }()
+
+	cfg, d := newStaleReadConfig(t, 2)
+
+	start := time.Now()
+	_, err := SetStatus(t.Context(), cfg, "test-release", release.StatusDeployed, SetStatusOptions{
+		Revision:        1,
+		WaitTimeout:     time.Minute,
+		WaitMaxInterval: time.Millisecond,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 5, d.reads)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+// changingDriver wraps a memory driver and moves every release to a new
+// status once it has been queried a number of times.
+type changingDriver struct {
+	*driver.Memory
+	queriesBeforeChange int
+	queries             int
+	next                release.Status
+}
+
+func (d *changingDriver) Query(labels map[string]string) ([]*release.Release, error) {
+	d.queries++
+	rels, err := d.Memory.Query(labels)
+	if err != nil || d.queries <= d.queriesBeforeChange {
+		return rels, err
+	}
+	for _, rel := range rels {
+		rel.Info.Status = d.next
+	}
+	return rels, nil
+}
+
+func TestWatchStatus(t *testing.T) {
+	newChangingConfig := func(t *testing.T, queriesBeforeChange int) (*action.Configuration, *changingDriver) {
+		t.Helper()
+
+		mem := driver.NewMemory()
+		rel := &release.Release{
+			Name:      "test-release",
+			Namespace: "default",
+			Version:   1,
+			Info: &release.Info{
+				Status: release.StatusPendingUpgrade,
+			},
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:    "test-chart",
+					Version: "1.0.0",
+				},
+			},
+		}
+		require.NoError(t, mem.Create("sh.helm.release.v1.test-release.v1", rel))
+
+		d := &changingDriver{Memory: mem, queriesBeforeChange: queriesBeforeChange, next: release.StatusDeployed}
+		return &action.Configuration{Releases: storage.Init(d)}, d
+	}
+
+	t.Run("returns once the status matches", func(t *testing.T) {
+		cfg, d := newChangingConfig(t, 2)
+
+		err := WatchStatus(t.Context(), cfg, "test-release", release.StatusDeployed, time.Millisecond, time.Second)
+		require.NoError(t, err)
+		assert.Equal(t, 3, d.queries)
+	})
+
+	t.Run("times out when the status never matches", func(t *testing.T) {
+		cfg, _ := newChangingConfig(t, 1<<30)
+
+		err := WatchStatus(t.Context(), cfg, "test-release", release.StatusDeployed, time.Millisecond, 10*time.Millisecond)
+		require.Error(t, err)
+		assert.Equal(t, "timed out waiting for release test-release to report status deployed (last seen pending-upgrade)", err.Error())
+	})
+	t.Run("stops promptly when the context is cancelled", func(t *testing.T) {
+		cfg, d := newChangingConfig(t, 1<<30)
+		ctx, cancel := context.WithCancel(t.Context())
+		time.AfterFunc(10*time.Millisecond, cancel)
+
+		start := time.Now()
+		err := WatchStatus(ctx, cfg, "test-release", release.StatusDeployed, time.Hour, time.Hour)
+		require.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, "stopped waiting for release test-release to report status deployed: context canceled", err.Error())
+		assert.Less(t, time.Since(start), time.Second)
+		assert.Equal(t, 1, d.queries)
+	})
+}